@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/joho/godotenv"
+)
+
+// healthcheckTimeout は healthcheck サブコマンドがエージェントの応答を待つ時間
+const healthcheckTimeout = 5 * time.Second
+
+// cliUsage はサブコマンドの使い方
+const cliUsage = `Usage:
+  mc-agent                          Run the agent
+  mc-agent status [server]          Show server status
+  mc-agent start|stop|restart <server> [--lock <id>] [--force] [--timeout <seconds>]
+                                    Start, stop or restart a server and wait for completion
+  mc-agent whitelist list <server>
+  mc-agent whitelist add <server> <player> [duration]
+  mc-agent whitelist remove <server> <player>
+                                    Manage a server's whitelist
+  mc-agent maintenance start <server> [--ttl <duration>] [--reason <text>]
+  mc-agent maintenance renew <lock-id> [--ttl <duration>]
+  mc-agent maintenance end <lock-id>
+  mc-agent maintenance list
+                                    Hold a maintenance lock (pauses auto-shutdown and blocks other commands)
+  mc-agent register-commands        Register Discord slash commands in the guild
+  mc-agent unregister-commands      Delete all Discord slash commands from the guild
+  mc-agent config validate [path]   Validate settings.json and print all errors
+  mc-agent config show [path]       Print effective settings and where each value comes from
+  mc-agent healthcheck [--live] [path]
+                                    Query the running agent's /readyz (or /healthz with --live); exits 1 if unhealthy
+
+Options for status, start, stop, restart, whitelist and maintenance:
+  --socket <path>   Control socket of the running agent (default: control.socket_path)
+  --direct          Operate on Docker and the whitelist files directly instead of through the agent
+                    (used automatically when the agent is not running; not available for maintenance)
+
+Options for start, stop and restart:
+  --lock <id>       Maintenance lock ID, required while the server is under maintenance
+  --force           Stop or restart even if players are online
+  --timeout <sec>   Seconds to wait for the container to stop (default 30)
+
+Options for maintenance:
+  --ttl <duration>  Lock duration such as 30m or 2h (default 1h, max 24h)
+  --reason <text>   Shown in status and the audit log
+`
+
+// runCLI はサブコマンドを実行する
+// サブコマンドでなければ handled=false を返し、通常どおりエージェントを起動する
+func runCLI(args []string) (code int, handled bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+
+	// SETTINGS_PATH 等を .env に書いている場合に備えて読み込む（無くてもよい）
+	_ = godotenv.Load()
+
+	switch args[0] {
+	case "config":
+		return runConfigCommand(args[1:]), true
+	case "healthcheck":
+		return runHealthcheck(args[1:]), true
+	case "status", "start", "stop", "restart", "whitelist", "maintenance":
+		return runServerCommand(args[0], args[1:]), true
+	case "register-commands":
+		return runDiscordCommands(true), true
+	case "unregister-commands":
+		return runDiscordCommands(false), true
+	case "help", "-h", "--help":
+		fmt.Print(cliUsage)
+		return 0, true
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n%s", args[0], cliUsage)
+		return 2, true
+	}
+}
+
+// runConfigCommand は config サブコマンドを実行する
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+
+	path := ""
+	if len(args) == 2 {
+		path = args[1]
+	}
+	path = utilities.ResolveSettingsPath(path)
+
+	switch args[0] {
+	case "validate":
+		return validateSettingsFile(path)
+	case "show":
+		return showSettings(path)
+	default:
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+}
+
+// validateSettingsFile は設定（環境変数の上書き込み）を検証し、エラーを JSON のキーパス付きで出力する
+func validateSettingsFile(path string) int {
+	settings, _, err := utilities.LoadEffectiveSettings(path)
+	if err != nil {
+		var validationErrs utilities.ValidationErrors
+		if !errors.As(err, &validationErrs) {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			return 1
+		}
+
+		printValidationErrors(path, validationErrs)
+		return 1
+	}
+
+	// サーバーのディレクトリはエージェントの起動時には警告のみ（ここではエラーとして報告する）
+	if pathErrs := settings.CheckServerPaths(); len(pathErrs) > 0 {
+		printValidationErrors(path, pathErrs)
+		return 1
+	}
+
+	fmt.Printf("%s: OK (version %d, %d server(s))\n", path, settings.Version, len(settings.RegisteredContainers))
+	return 0
+}
+
+// printValidationErrors は検証エラーをキーパス付きで出力する
+func printValidationErrors(path string, errs utilities.ValidationErrors) {
+	fmt.Fprintf(os.Stderr, "%s: %d error(s)\n", path, len(errs))
+	for _, e := range errs {
+		fmt.Fprintf(os.Stderr, "  %s\n", e.Error())
+	}
+}
+
+// showSettings は実際に使われる設定値と出どころを出力する
+func showSettings(path string) int {
+	settings, sources, err := utilities.LoadEffectiveSettings(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+
+	for _, line := range utilities.DescribeSettings(settings, sources) {
+		fmt.Println(line)
+	}
+	return 0
+}
+
+// runHealthcheck は稼働中のエージェントの /readyz（--live の場合は /healthz）を確認する（コンテナの HEALTHCHECK 用）
+// 待ち受けアドレスはエージェントと同じ設定から求める
+func runHealthcheck(args []string) int {
+	endpoint := "/readyz"
+	path := ""
+	for _, arg := range args {
+		switch {
+		case arg == "--live":
+			endpoint = "/healthz"
+		case strings.HasPrefix(arg, "-") || path != "":
+			fmt.Fprint(os.Stderr, cliUsage)
+			return 2
+		default:
+			path = arg
+		}
+	}
+	path = utilities.ResolveSettingsPath(path)
+
+	settings, _, err := utilities.LoadEffectiveSettings(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+	if !settings.Monitoring.Health {
+		// 無効にしている場合は判定できないため、コンテナを unhealthy にしない
+		fmt.Println("Health endpoints are disabled (monitoring.health is false)")
+		return 0
+	}
+
+	host, port, err := net.SplitHostPort(settings.Monitoring.Listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monitoring.listen: %v\n", err)
+		return 1
+	}
+	// すべてのアドレスで待ち受けている場合はループバックに接続する
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+
+	client := &http.Client{Timeout: healthcheckTimeout}
+	resp, err := client.Get("http://" + net.JoinHostPort(host, port) + endpoint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Health check failed: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	fmt.Print(string(body))
+	if resp.StatusCode != http.StatusOK {
+		return 1
+	}
+	return 0
+}
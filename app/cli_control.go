@@ -0,0 +1,548 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/api"
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/control"
+	"github.com/Koranoa3/mc-server-agent/internal/discord"
+	"github.com/Koranoa3/mc-server-agent/internal/docker"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+)
+
+// cliOptions はサーバーを操作するサブコマンドのオプション
+type cliOptions struct {
+	direct bool   // エージェントを介さずに Docker・ファイルを直接操作する
+	socket string // 制御用ソケットのパス（空の場合は設定の control.socket_path）
+
+	// start / stop / restart
+	lock    string // 保守ロックの ID
+	force   bool   // プレイヤーがいても停止・再起動する
+	timeout int    // 停止・再起動時にコンテナの終了を待つ秒数
+
+	// maintenance
+	ttl    string // 保守ロックの期限
+	reason string // 保守ロックの理由
+}
+
+// parseCLIOptions はオプションを取り除いた引数を返す（オプションは引数のどこに書いてもよい）
+func parseCLIOptions(args []string) (cliOptions, []string, error) {
+	var (
+		opts    cliOptions
+		timeout string
+	)
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--direct":
+			opts.direct = true
+			continue
+		case "--force":
+			opts.force = true
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || len(arg) == 1 {
+			rest = append(rest, arg)
+			continue
+		}
+
+		// 値を取るオプション（--name value / --name=value）
+		name, value, hasValue := strings.Cut(arg, "=")
+		var target *string
+		switch name {
+		case "--socket":
+			target = &opts.socket
+		case "--lock":
+			target = &opts.lock
+		case "--ttl":
+			target = &opts.ttl
+		case "--reason":
+			target = &opts.reason
+		case "--timeout":
+			target = &timeout
+		default:
+			return opts, nil, fmt.Errorf("unknown option: %s", arg)
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return opts, nil, fmt.Errorf("%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		*target = value
+	}
+
+	if timeout != "" {
+		seconds, err := strconv.Atoi(timeout)
+		if err != nil || seconds <= 0 {
+			return opts, nil, fmt.Errorf("--timeout must be a positive number of seconds, got %q", timeout)
+		}
+		opts.timeout = seconds
+	}
+	return opts, rest, nil
+}
+
+// cliUser は監査ログに実行者として記録する OS のユーザー名を返す
+func cliUser() string {
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return current.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "local"
+}
+
+// runServerCommand はサーバーを操作するサブコマンド（status / start / stop / restart / whitelist / maintenance）を実行する
+func runServerCommand(name string, args []string) int {
+	opts, args, err := parseCLIOptions(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, cliUsage)
+		return 2
+	}
+	utilities.InitCLILogger()
+
+	// Ctrl+C で待機を中断する（送信済みのコマンドはエージェント側で実行が続く）
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// オプションは対応するサブコマンドでのみ受け付ける
+	powerOptions := opts.lock != "" || opts.force || opts.timeout != 0
+	maintenanceOptions := opts.ttl != "" || opts.reason != ""
+	switch name {
+	case "status":
+		if len(args) > 1 || powerOptions || maintenanceOptions {
+			fmt.Fprint(os.Stderr, cliUsage)
+			return 2
+		}
+	case audit.ActionStart, audit.ActionStop, audit.ActionRestart:
+		if len(args) != 1 || maintenanceOptions {
+			fmt.Fprintf(os.Stderr, "Usage: mc-agent %s <server> [--lock <id>] [--force] [--timeout <seconds>]\n", name)
+			return 2
+		}
+	case "whitelist":
+		if !validWhitelistArgs(args) || powerOptions || maintenanceOptions {
+			fmt.Fprint(os.Stderr, whitelistUsage)
+			return 2
+		}
+	case "maintenance":
+		if !validMaintenanceArgs(args, opts) || powerOptions {
+			fmt.Fprint(os.Stderr, maintenanceUsage)
+			return 2
+		}
+		// 保守ロックは稼働中のエージェントが保持するため、直接の操作には切り替えない
+		if opts.direct {
+			fmt.Fprintln(os.Stderr, "maintenance locks are held by the running agent and cannot be used with --direct")
+			return 2
+		}
+	}
+
+	client, closeClient, err := openClient(ctx, opts, name == "maintenance")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	defer closeClient()
+
+	switch name {
+	case "status":
+		if len(args) == 1 {
+			err = printServer(ctx, client, args[0])
+		} else {
+			err = printServers(ctx, client)
+		}
+	case "whitelist":
+		err = runWhitelistCommand(ctx, client, args)
+	case "maintenance":
+		err = runMaintenanceCommand(ctx, client, args, opts)
+	default:
+		err = runPowerCommand(ctx, client, name, args[0], control.CommandOptions{Timeout: opts.timeout, Lock: opts.lock, Force: opts.force})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// openClient は稼働中のエージェントの制御用ソケットに接続する
+// --direct の場合と、ソケットが無い・接続を拒否された（エージェントが起動していない）場合は Docker・ファイルを直接操作する
+// agentOnly の場合はエージェントが起動していなければエラーにする
+func openClient(ctx context.Context, opts cliOptions, agentOnly bool) (*control.Client, func(), error) {
+	var settings *utilities.Settings
+	loadSettings := func() error {
+		var err error
+		if settings, _, err = utilities.LoadEffectiveSettings(utilities.ResolveSettingsPath("")); err != nil {
+			return fmt.Errorf("failed to load settings: %w", err)
+		}
+		return nil
+	}
+
+	if !opts.direct {
+		socketPath := opts.socket
+		if socketPath == "" {
+			if err := loadSettings(); err != nil {
+				return nil, nil, err
+			}
+			socketPath = settings.ControlSocketPath()
+		}
+
+		conn, err := net.DialTimeout("unix", socketPath, 5*time.Second)
+		if err == nil {
+			conn.Close()
+			return control.NewClient(socketPath, cliUser()), func() {}, nil
+		}
+		// 権限が無い場合などは、稼働中のエージェントと競合しないよう直接の操作に切り替えない
+		if opts.socket != "" || agentOnly || !(errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ECONNREFUSED)) {
+			return nil, nil, fmt.Errorf("failed to connect to the agent at %s: %w", socketPath, err)
+		}
+		fmt.Fprintf(os.Stderr, "Agent is not running (no control socket at %s), operating on Docker directly\n", socketPath)
+	}
+
+	if settings == nil {
+		if err := loadSettings(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return openDirectClient(ctx, settings)
+}
+
+// openDirectClient はエージェントと同じハンドラーを同じプロセスで動かし、Docker・ファイルを直接操作するクライアントを返す
+// 起動・停止はエージェントと同じく policy の判定を通り、監査ログに記録される（イベント・チャンネルへの投稿は行わない）
+func openDirectClient(ctx context.Context, settings *utilities.Settings) (*control.Client, func(), error) {
+	appState := state.NewAppState(settings)
+	dockerManager, err := docker.NewManager(appState)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := dockerManager.UpdateAllContainers(ctx); err != nil {
+		dockerManager.Close()
+		return nil, nil, err
+	}
+
+	auditLog := audit.NewLogger(settings.AuditLogPath(), nil)
+	profiles := utilities.NewProfileResolver(utilities.DataPath("profiles.json"), func() utilities.MojangConfig {
+		return appState.GetSettings().Mojang
+	})
+
+	// main のメインループの代わりにコマンドを実行する
+	commandChan := make(chan routine.Command, 1)
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case cmd := <-commandChan:
+				if cmd.Type == "reload" {
+					replyCommand(cmd, routine.CommandResult{Err: errors.New("reloading settings requires the running agent")})
+					continue
+				}
+				err := executeCommand(ctx, appState, dockerManager, auditLog, cmd)
+				// 完了後の状態を返すため取得し直す
+				if updateErr := dockerManager.UpdateAllContainers(ctx); updateErr != nil {
+					fmt.Fprintf(os.Stderr, "Failed to refresh container status: %v\n", updateErr)
+				}
+				replyCommand(cmd, routine.CommandResult{Err: err})
+			}
+		}
+	}()
+
+	handler := api.NewServer(appState, auditLog, profiles, commandChan).LocalHandler(audit.SourceCLI)
+	closeClient := func() {
+		cancel()
+		dockerManager.Close()
+	}
+	return control.NewLocalClient(handler, cliUser()), closeClient, nil
+}
+
+// printServers は全サーバーの状態を表で出力する
+func printServers(ctx context.Context, client *control.Client) error {
+	servers, err := client.Servers(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVER\tNAME\tSTATUS\tPLAYERS\tAUTO-SHUTDOWN")
+	for _, server := range servers {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", server.ID, server.DisplayName, formatStatus(server), server.Players, formatAutoShutdown(server))
+	}
+	return w.Flush()
+}
+
+// printServer はサーバーの状態をオンラインのプレイヤー名と合わせて出力する
+func printServer(ctx context.Context, client *control.Client, id string) error {
+	server, err := client.Server(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Server:\t%s (%s)\n", server.ID, server.DisplayName)
+	fmt.Fprintf(w, "Container:\t%s\n", server.ContainerName)
+	fmt.Fprintf(w, "Status:\t%s\n", formatStatus(server))
+	players := fmt.Sprint(server.Players)
+	if len(server.OnlinePlayers) > 0 {
+		players += " (" + strings.Join(server.OnlinePlayers, ", ") + ")"
+	}
+	fmt.Fprintf(w, "Players:\t%s\n", players)
+	fmt.Fprintf(w, "Auto-shutdown:\t%s\n", formatAutoShutdown(server))
+	if lock := server.Maintenance; lock != nil {
+		maintenance := fmt.Sprintf("by %s until %s", lock.Owner, lock.ExpiresAt.Local().Format(time.DateTime))
+		if lock.Reason != "" {
+			maintenance += " (" + lock.Reason + ")"
+		}
+		fmt.Fprintf(w, "Maintenance:\t%s\n", maintenance)
+	}
+	if server.LastChecked != nil {
+		fmt.Fprintf(w, "Last checked:\t%s\n", server.LastChecked.Local().Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+// formatStatus は状態をヘルスチェックの結果と合わせて返す
+func formatStatus(server control.ServerStatus) string {
+	if server.Health != "" {
+		return fmt.Sprintf("%s (%s)", server.Status, server.Health)
+	}
+	return server.Status
+}
+
+// formatAutoShutdown は自動停止の設定と予定時刻を返す
+func formatAutoShutdown(server control.ServerStatus) string {
+	switch {
+	case !server.AutoShutdown:
+		return "off"
+	case server.Maintenance != nil:
+		return "paused (maintenance)"
+	case server.AutoShutdownAt != nil:
+		return "at " + server.AutoShutdownAt.Local().Format(time.TimeOnly)
+	default:
+		return "on"
+	}
+}
+
+// runPowerCommand は start / stop / restart を実行して結果を出力する
+func runPowerCommand(ctx context.Context, client *control.Client, action, id string, opts control.CommandOptions) error {
+	fmt.Printf("Sending %s to %s...\n", action, id)
+	result, err := client.Command(ctx, id, action, opts)
+	if err != nil {
+		return err
+	}
+	if result.Status == "pending" {
+		fmt.Printf("%s is still in progress on %s\n", action, id)
+		return nil
+	}
+	fmt.Printf("%s completed on %s (status: %s)\n", action, id, result.Status)
+	return nil
+}
+
+// whitelistUsage は whitelist サブコマンドの使い方
+const whitelistUsage = `Usage:
+  mc-agent whitelist list <server>
+  mc-agent whitelist add <server> <player> [duration]
+  mc-agent whitelist remove <server> <player>
+`
+
+// validWhitelistArgs は whitelist サブコマンドの引数の数を確認する
+func validWhitelistArgs(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "list":
+		return len(args) == 2
+	case "add":
+		return len(args) == 3 || len(args) == 4
+	case "remove":
+		return len(args) == 3
+	default:
+		return false
+	}
+}
+
+// runWhitelistCommand は whitelist list / add / remove を実行して結果を出力する
+func runWhitelistCommand(ctx context.Context, client *control.Client, args []string) error {
+	id := args[1]
+	switch args[0] {
+	case "list":
+		entries, err := client.Whitelist(ctx, id)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println("The whitelist is empty.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tUUID\tEXPIRES")
+		for _, entry := range entries {
+			expires := "-"
+			if entry.ExpiresAt != nil {
+				expires = entry.ExpiresAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", entry.Name, entry.UUID, expires)
+		}
+		return w.Flush()
+
+	case "add":
+		duration := ""
+		if len(args) == 4 {
+			duration = args[3]
+		}
+		change, err := client.WhitelistAdd(ctx, id, args[2], duration)
+		if err != nil {
+			return err
+		}
+		if !change.Changed {
+			fmt.Printf("%s is already on the whitelist (%s)\n", change.Entry.Name, strings.Join(change.Servers, ", "))
+			return nil
+		}
+		fmt.Printf("Added %s to the whitelist (%s)\n", change.Entry.Name, strings.Join(change.Servers, ", "))
+		return nil
+
+	default:
+		change, err := client.WhitelistRemove(ctx, id, args[2])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s from the whitelist (%s)\n", change.Entry.Name, strings.Join(change.Servers, ", "))
+		return nil
+	}
+}
+
+// maintenanceUsage は maintenance サブコマンドの使い方
+const maintenanceUsage = `Usage:
+  mc-agent maintenance start <server> [--ttl <duration>] [--reason <text>]
+  mc-agent maintenance renew <lock-id> [--ttl <duration>]
+  mc-agent maintenance end <lock-id>
+  mc-agent maintenance list
+`
+
+// validMaintenanceArgs は maintenance サブコマンドの引数の数とオプションを確認する
+func validMaintenanceArgs(args []string, opts cliOptions) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "start":
+		return len(args) == 2
+	case "renew":
+		return len(args) == 2 && opts.reason == ""
+	case "end":
+		return len(args) == 2 && opts.ttl == "" && opts.reason == ""
+	case "list":
+		return len(args) == 1 && opts.ttl == "" && opts.reason == ""
+	default:
+		return false
+	}
+}
+
+// runMaintenanceCommand は maintenance start / renew / end / list を実行して結果を出力する
+// start はスクリプトから受け取れるよう、ロックの ID だけを標準出力に出す
+func runMaintenanceCommand(ctx context.Context, client *control.Client, args []string, opts cliOptions) error {
+	switch args[0] {
+	case "start":
+		lock, err := client.AcquireLock(ctx, args[1], opts.reason, opts.ttl)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Locked %s until %s\n", lock.Server, lock.ExpiresAt.Local().Format(time.DateTime))
+		fmt.Println(lock.ID)
+		return nil
+
+	case "renew":
+		lock, err := client.RenewLock(ctx, args[1], opts.ttl)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Extended the lock on %s until %s\n", lock.Server, lock.ExpiresAt.Local().Format(time.DateTime))
+		return nil
+
+	case "end":
+		lock, err := client.ReleaseLock(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Released the lock on %s\n", lock.Server)
+		return nil
+
+	default:
+		locks, err := client.Locks(ctx)
+		if err != nil {
+			return err
+		}
+		if len(locks) == 0 {
+			fmt.Println("No servers are under maintenance.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SERVER\tLOCK\tOWNER\tEXPIRES\tREASON")
+		for _, lock := range locks {
+			reason := lock.Reason
+			if reason == "" {
+				reason = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", lock.Server, lock.ID, lock.Owner, lock.ExpiresAt.Local().Format(time.DateTime), reason)
+		}
+		return w.Flush()
+	}
+}
+
+// runDiscordCommands は Discord のスラッシュコマンドを登録（register=false の場合は削除）する
+// エージェントを起動せずに REST API だけを使う（Gateway には接続しない）
+func runDiscordCommands(register bool) int {
+	utilities.InitCLILogger()
+
+	path := utilities.ResolveSettingsPath("")
+	settings, _, err := utilities.LoadEffectiveSettings(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+	config := settings.Discord
+	if config.Token == "" || config.GuildID == "" || config.AppID == "" {
+		fmt.Fprintln(os.Stderr, "Discord credentials are not configured (DISCORD_BOT_TOKEN, DISCORD_GUILD_ID, DISCORD_APP_ID)")
+		return 1
+	}
+
+	bot, err := discord.NewBot(config.Token, config.GuildID, config.AppID, state.NewAppState(settings), nil, nil, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	if register {
+		if err := bot.SyncCommands(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		fmt.Printf("Registered Discord commands in guild %s\n", config.GuildID)
+		return 0
+	}
+
+	count, err := bot.ClearCommands()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	fmt.Printf("Deleted %d Discord command(s) from guild %s\n", count, config.GuildID)
+	return 0
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// subscriberBuffer は購読者ごとのバッファ（あふれた分は破棄し、遅いクライアントでイベントの配信を止めない）
+	subscriberBuffer = 64
+
+	// keepAliveInterval はプロキシに接続を切られないように SSE のコメント行 / WebSocket の ping を送る間隔
+	keepAliveInterval = 30 * time.Second
+
+	// wsWriteTimeout は WebSocket への1回の書き込みの期限
+	wsWriteTimeout = 10 * time.Second
+)
+
+// snapshotEvent は接続直後に送る全サーバーの現在の状態の種類
+const snapshotEvent = "snapshot"
+
+// snapshotMessage は WebSocket で接続直後に送るメッセージ
+type snapshotMessage struct {
+	Type    string       `json:"type"`
+	Servers []serverView `json:"servers"`
+}
+
+// upgrader は WebSocket へのアップグレード
+// 認証は Cookie ではなくトークンで行うため、Origin は確認しない（他サイトから勝手に使われることはない）
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// eventFilter はクエリで指定された購読条件（空の場合はすべて）
+type eventFilter struct {
+	types   map[state.EventType]bool
+	servers map[string]bool
+}
+
+// parseEventFilter は type=status_changed,player_joined&server=main のようなクエリを解釈する
+func parseEventFilter(r *http.Request) (eventFilter, error) {
+	filter := eventFilter{types: map[state.EventType]bool{}, servers: map[string]bool{}}
+	query := r.URL.Query()
+
+	known := make(map[state.EventType]bool, len(state.EventTypes))
+	for _, t := range state.EventTypes {
+		known[t] = true
+	}
+	for _, value := range splitQuery(query["type"]) {
+		t := state.EventType(value)
+		if !known[t] {
+			return filter, fmt.Errorf("unknown event type %q", value)
+		}
+		filter.types[t] = true
+	}
+	for _, value := range splitQuery(query["server"]) {
+		filter.servers[value] = true
+	}
+	return filter, nil
+}
+
+// splitQuery は繰り返し・カンマ区切りの両方で指定された値を展開する
+func splitQuery(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+// match はイベントが条件に一致するか判定（サーバーを指定した場合、サーバーに紐付かないアラートも送る）
+func (f eventFilter) match(e state.Event) bool {
+	if len(f.types) > 0 && !f.types[e.Type] {
+		return false
+	}
+	if len(f.servers) > 0 && e.Server != "" && !f.servers[e.Server] {
+		return false
+	}
+	return true
+}
+
+// lastEventID は再接続時の Last-Event-ID（ヘッダーまたは last_event_id クエリ）を返す
+func lastEventID(r *http.Request) uint64 {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	id, _ := strconv.ParseUint(value, 10, 64)
+	return id
+}
+
+// snapshot は購読条件に合うサーバーの現在の状態を返す
+func (s *Server) snapshot(filter eventFilter) []serverView {
+	keys := make([]string, 0)
+	for key := range s.appState.GetSettings().RegisteredContainers {
+		if len(filter.servers) == 0 || filter.servers[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	views := make([]serverView, 0, len(keys))
+	for _, key := range keys {
+		if view, ok := s.serverView(key); ok {
+			views = append(views, view)
+		}
+	}
+	return views
+}
+
+// handleEvents はイベントを Server-Sent Events で送り続ける
+// 接続直後に "snapshot"（全サーバーの状態）を送り、以降はイベントの種類をイベント名、Event を data として送る
+// Last-Event-ID を付けて再接続した場合は snapshot の代わりに取りこぼしたイベントを送る
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	sub, backlog := s.appState.Events().Subscribe(lastEventID(r), subscriberBuffer)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	log.Info().Str("token", actor.UserName).Msg("Event stream connected")
+	defer log.Info().Str("token", actor.UserName).Msg("Event stream disconnected")
+
+	if lastEventID(r) == 0 {
+		if err := writeSSE(w, "", snapshotEvent, s.snapshot(filter)); err != nil {
+			return
+		}
+	}
+	for _, event := range backlog {
+		if filter.match(event) {
+			if err := writeSSE(w, strconv.FormatUint(event.ID, 10), string(event.Type), event); err != nil {
+				return
+			}
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case event := <-sub.C:
+			if !filter.match(event) {
+				continue
+			}
+			if err := writeSSE(w, strconv.FormatUint(event.ID, 10), string(event.Type), event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSSE は SSE のイベントを1つ書き込む（id が空の場合は付けない）
+func writeSSE(w http.ResponseWriter, id, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// handleEventsWebSocket はイベントを WebSocket のテキストメッセージ（JSON）で送り続ける
+// 最初に {"type": "snapshot", "servers": [...]} を送り、以降は Event をそのまま送る（クライアントからのメッセージは無視する）
+func (s *Server) handleEventsWebSocket(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade がエラーレスポンスを返している
+		log.Debug().Err(err).Msg("Failed to upgrade to WebSocket")
+		return
+	}
+	defer conn.Close()
+
+	sub, backlog := s.appState.Events().Subscribe(lastEventID(r), subscriberBuffer)
+	defer sub.Close()
+
+	log.Info().Str("token", actor.UserName).Msg("Event WebSocket connected")
+	defer log.Info().Str("token", actor.UserName).Msg("Event WebSocket disconnected")
+
+	// 切断・close フレーム・pong を受け取るために読み続ける
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(1024)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(v any) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteJSON(v)
+	}
+
+	if lastEventID(r) == 0 {
+		if err := send(snapshotMessage{Type: snapshotEvent, Servers: s.snapshot(filter)}); err != nil {
+			return
+		}
+	}
+	for _, event := range backlog {
+		if filter.match(event) {
+			if err := send(event); err != nil {
+				return
+			}
+		}
+	}
+
+	ping := time.NewTicker(keepAliveInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-s.done:
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "agent shutting down"), time.Now().Add(wsWriteTimeout))
+			return
+		case event := <-sub.C:
+			if !filter.match(event) {
+				continue
+			}
+			if err := send(event); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
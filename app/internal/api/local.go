@@ -0,0 +1,280 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/policy"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxCommandTimeout は POST /commands で指定できる停止のタイムアウトの上限（秒）
+	maxCommandTimeout = 600
+
+	// reloadTimeout は設定の再読み込みの結果を待つ時間
+	reloadTimeout = 30 * time.Second
+
+	// defaultLockTTL は保守ロックの期限を指定しなかった場合の長さ
+	defaultLockTTL = time.Hour
+
+	// maxLockTTL は保守ロックの期限の上限（長い作業は延長して使う）
+	maxLockTTL = 24 * time.Hour
+)
+
+// commandRequest は POST /commands のリクエスト（routine.Command に対応する）
+type commandRequest struct {
+	Type    string `json:"type"`              // start / stop / restart / reload
+	Server  string `json:"server,omitempty"`  // registered_containers のキー（reload 以外は必須）
+	Timeout int    `json:"timeout,omitempty"` // 停止・再起動時にコンテナの終了を待つ秒数（既定 30）
+	Lock    string `json:"lock,omitempty"`    // 保守ロックの ID（ロック中のサーバーを操作する場合）
+	Force   bool   `json:"force,omitempty"`   // プレイヤーがいても停止・再起動する
+}
+
+// reloadResponse は設定の再読み込みの結果のレスポンス
+type reloadResponse struct {
+	Action  string   `json:"action"`
+	Changes []string `json:"changes"` // 変更された設定（変更が無ければ空）
+}
+
+// lockRequest は POST /servers/{id}/lock のリクエスト
+type lockRequest struct {
+	Owner  string `json:"owner,omitempty"`  // 省略時は接続元のユーザー名
+	Reason string `json:"reason,omitempty"` // 例: "backup"
+	TTL    string `json:"ttl,omitempty"`    // 期限（例: "30m", "2h"、既定 1 時間、最大 24 時間）
+}
+
+// renewRequest は POST /locks/{id}/renew のリクエスト
+type renewRequest struct {
+	TTL string `json:"ttl,omitempty"` // 現在からの期限（省略時は 1 時間）
+}
+
+// stateResponse は GET /state のレスポンス
+type stateResponse struct {
+	Servers []serverView            `json:"servers"`
+	Locks   []state.MaintenanceLock `json:"locks"`
+}
+
+// localRoutes は制御用ソケットにのみ提供するエンドポイントを登録する
+// ロックの ID でロック中のサーバーを操作できるため、HTTP API のトークンでは使えないようにしている
+func (s *Server) localRoutes(mux *http.ServeMux, trusted func(handlerFunc) http.Handler) {
+	mux.Handle("POST /commands", trusted(s.handleLocalCommand))
+	mux.Handle("GET /state", trusted(s.handleState))
+
+	mux.Handle("GET /locks", trusted(s.handleListLocks))
+	mux.Handle("POST /servers/{id}/lock", trusted(s.handleAcquireLock))
+	mux.Handle("POST /locks/{id}/renew", trusted(s.handleRenewLock))
+	mux.Handle("DELETE /locks/{id}", trusted(s.handleReleaseLock))
+}
+
+// decodeRequest はリクエストボディを読み込む（ボディが空の場合はそのまま）
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// parseLockTTL は保守ロックの期限を解釈する（空の場合は既定値）
+func parseLockTTL(value string) (time.Duration, error) {
+	ttl, err := utilities.ParseListDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if ttl == 0 {
+		return defaultLockTTL, nil
+	}
+	if ttl > maxLockTTL {
+		return 0, fmt.Errorf("ttl must be at most %s", maxLockTTL)
+	}
+	return ttl, nil
+}
+
+// handleLocalCommand は routine.Command を発行する
+// start / stop / restart は HTTP API と同じチェックを通し（lock・force で保守ロックとプレイヤー在籍のチェックを外せる）、完了を待って結果を返す
+func (s *Server) handleLocalCommand(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	var req commandRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	switch req.Type {
+	case audit.ActionStart, audit.ActionStop, audit.ActionRestart:
+	case "reload":
+		s.runReload(w, r, actor)
+		return
+	default:
+		writeError(w, http.StatusBadRequest, "type must be one of start, stop, restart, reload")
+		return
+	}
+
+	if req.Server == "" {
+		writeError(w, http.StatusBadRequest, "server is required")
+		return
+	}
+	if req.Timeout < 0 || req.Timeout > maxCommandTimeout {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("timeout must be between 0 and %d", maxCommandTimeout))
+		return
+	}
+	if req.Timeout == 0 {
+		req.Timeout = defaultStopTimeout
+	}
+
+	s.runCommand(w, r, actor, req.Type, req.Server, req.Timeout, policy.Options{LockID: req.Lock, Force: req.Force})
+}
+
+// runReload は設定の再読み込みを依頼し、変更された設定を返す（監査ログは main で記録される）
+func (s *Server) runReload(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	reply := make(chan routine.CommandResult, 1)
+	cmd := routine.Command{
+		Type:     "reload",
+		Source:   actor.Source,
+		UserName: actor.UserName,
+		Reply:    reply,
+	}
+	if !s.sendCommand(w, cmd) {
+		return
+	}
+
+	timer := time.NewTimer(reloadTimeout)
+	defer timer.Stop()
+	select {
+	case result := <-reply:
+		if result.Err != nil {
+			writeError(w, http.StatusUnprocessableEntity, result.Err.Error())
+			return
+		}
+		changes := []string{}
+		if result.Message != "" {
+			changes = strings.Split(result.Message, "\n")
+		}
+		writeJSON(w, http.StatusOK, reloadResponse{Action: "reload", Changes: changes})
+	case <-timer.C:
+		writeError(w, http.StatusGatewayTimeout, "Timed out waiting for the settings to reload")
+	case <-r.Context().Done():
+	}
+}
+
+// handleState は全サーバーの状態と保守ロックを返す
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	keys := make([]string, 0)
+	for key := range s.appState.GetSettings().RegisteredContainers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	response := stateResponse{Servers: make([]serverView, 0, len(keys)), Locks: s.appState.Locks()}
+	for _, key := range keys {
+		if view, ok := s.serverView(key); ok {
+			response.Servers = append(response.Servers, view)
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleListLocks は有効な保守ロックを返す
+func (s *Server) handleListLocks(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	writeJSON(w, http.StatusOK, s.appState.Locks())
+}
+
+// handleAcquireLock はサーバーの保守ロックを取得する（既にロックされている場合は 409）
+func (s *Server) handleAcquireLock(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	key := r.PathValue("id")
+	if _, ok := s.appState.GetSettings().RegisteredContainers[key]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Server '%s' not found", key))
+		return
+	}
+
+	var req lockRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	ttl, err := parseLockTTL(req.TTL)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	owner := strings.TrimSpace(req.Owner)
+	if owner == "" {
+		owner = actor.UserName
+	}
+
+	lock, err := s.appState.AcquireLock(key, owner, strings.TrimSpace(req.Reason), ttl)
+	entry := actor
+	entry.Action = audit.ActionMaintenanceLock
+	entry.Server = key
+	if err != nil {
+		entry.Outcome = audit.OutcomeRejected
+		entry.Detail = err.Error()
+		s.auditLog.Record(entry)
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	entry.Outcome = audit.OutcomeSuccess
+	entry.Detail = lockDetail(lock)
+	s.auditLog.Record(entry)
+
+	log.Info().Str("container", key).Str("owner", lock.Owner).Time("expires_at", lock.ExpiresAt).Msg("Maintenance lock acquired")
+	writeJSON(w, http.StatusCreated, lock)
+}
+
+// handleRenewLock は保守ロックの期限を延長する
+func (s *Server) handleRenewLock(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	var req renewRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	ttl, err := parseLockTTL(req.TTL)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	lock, err := s.appState.RenewLock(r.PathValue("id"), ttl)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Maintenance lock not found (released or expired)")
+		return
+	}
+	writeJSON(w, http.StatusOK, lock)
+}
+
+// handleReleaseLock は保守ロックを解除する
+func (s *Server) handleReleaseLock(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	lock, err := s.appState.ReleaseLock(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Maintenance lock not found (released or expired)")
+		return
+	}
+
+	entry := actor
+	entry.Action = audit.ActionMaintenanceUnlock
+	entry.Server = lock.Server
+	entry.Outcome = audit.OutcomeSuccess
+	entry.Detail = lockDetail(lock)
+	s.auditLog.Record(entry)
+
+	log.Info().Str("container", lock.Server).Str("owner", lock.Owner).Msg("Maintenance lock released")
+	writeJSON(w, http.StatusOK, lock)
+}
+
+// lockDetail は監査ログに記録する保守ロックの内容
+func lockDetail(lock state.MaintenanceLock) string {
+	detail := fmt.Sprintf("owner %s, until %s", lock.Owner, lock.ExpiresAt.Truncate(time.Second).Format(time.RFC3339))
+	if lock.Reason != "" {
+		detail += ", reason: " + lock.Reason
+	}
+	return detail
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/rs/zerolog/log"
+)
+
+// shutdownTimeout は停止時に処理中のリクエストを待つ時間
+const shutdownTimeout = 5 * time.Second
+
+// UserHeader は制御用ソケットのクライアントが OS のユーザー名を送るヘッダー（監査ログの実行者に使う）
+const UserHeader = "X-MC-Agent-User"
+
+// Server は Discord のコマンドと同じ操作を提供する HTTP API
+type Server struct {
+	appState    *state.AppState
+	auditLog    *audit.Logger
+	profiles    *utilities.ProfileResolver
+	commandChan chan<- routine.Command
+
+	done       chan struct{} // Stop で閉じ、イベントのストリームを終了させる
+	stopOnce   sync.Once
+	httpServer *http.Server
+}
+
+// NewServer は新しい HTTP API サーバーを作成
+func NewServer(appState *state.AppState, auditLog *audit.Logger, profiles *utilities.ProfileResolver, commandChan chan<- routine.Command) *Server {
+	return &Server{
+		appState:    appState,
+		auditLog:    auditLog,
+		profiles:    profiles,
+		commandChan: commandChan,
+		done:        make(chan struct{}),
+	}
+}
+
+// Start は api.listen で待ち受けを開始する
+// 待ち受けアドレスは起動時に決まるため、設定の再読み込みでは変わらない（トークンは毎回設定から参照する）
+func (s *Server) Start(ctx context.Context) error {
+	listen := s.appState.GetSettings().API.Listen
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", listen, err)
+	}
+
+	s.httpServer = &http.Server{
+		Handler:           s.routes(s.authorize),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("HTTP API server stopped")
+		}
+	}()
+
+	log.Info().Str("listen", listener.Addr().String()).Msg("HTTP API started")
+	return nil
+}
+
+// Stop はイベントのストリームを終了してからサーバーを停止する
+func (s *Server) Stop() error {
+	s.stopOnce.Do(func() { close(s.done) })
+	if s.httpServer == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+// routes はエンドポイントを登録したハンドラーを返す
+// auth はリクエストの認証を行う（TCP は Bearer トークン、制御用ソケットは接続元を信頼する）
+func (s *Server) routes(auth authorizer) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// require は scope が付与されていればハンドラーを呼ぶ
+	require := func(scope string, next handlerFunc) http.Handler {
+		return auth(scope, false, next)
+	}
+	// requireStream は require と同じだが、Authorization ヘッダーの代わりに access_token クエリも受け付ける
+	requireStream := func(scope string, next handlerFunc) http.Handler {
+		return auth(scope, true, next)
+	}
+
+	mux.Handle("GET /servers", require(utilities.APIScopeRead, s.handleListServers))
+	mux.Handle("GET /servers/{id}", require(utilities.APIScopeRead, s.handleGetServer))
+	mux.Handle("POST /servers/{id}/start", require(utilities.APIScopeControl, s.handleCommand(audit.ActionStart)))
+	mux.Handle("POST /servers/{id}/stop", require(utilities.APIScopeControl, s.handleCommand(audit.ActionStop)))
+	mux.Handle("POST /servers/{id}/restart", require(utilities.APIScopeControl, s.handleCommand(audit.ActionRestart)))
+
+	mux.Handle("GET /servers/{id}/whitelist", require(utilities.APIScopeRead, s.handleWhitelistList))
+	mux.Handle("POST /servers/{id}/whitelist", require(utilities.APIScopeWhitelist, s.handleWhitelistAdd))
+	mux.Handle("DELETE /servers/{id}/whitelist/{name}", require(utilities.APIScopeWhitelist, s.handleWhitelistRemove))
+
+	// ブラウザの EventSource / WebSocket はヘッダーを付けられないため、access_token クエリも受け付ける
+	mux.Handle("GET /events", requireStream(utilities.APIScopeRead, s.handleEvents))
+	mux.Handle("GET /events/ws", requireStream(utilities.APIScopeRead, s.handleEventsWebSocket))
+
+	return mux
+}
+
+// LocalHandler は認証なしで同じエンドポイントを提供するハンドラーを返す（制御用ソケット用）
+// 接続できるユーザーはソケットのファイルの権限で制限する。api.enabled に関係なく使える
+// コマンドの発行・保守ロック・状態の取得のエンドポイント（local.go）はこのハンドラーにのみ登録する
+// 監査ログの実行者は UserHeader のユーザー名（無ければ "local"）、発生元は source
+func (s *Server) LocalHandler(source audit.Source) http.Handler {
+	trusted := func(next handlerFunc) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := strings.TrimSpace(r.Header.Get(UserHeader))
+			if name == "" {
+				name = "local"
+			}
+			next(w, r, audit.Entry{UserName: name, Source: source})
+		})
+	}
+
+	mux := s.routes(func(scope string, allowQuery bool, next handlerFunc) http.Handler {
+		return trusted(next)
+	})
+	s.localRoutes(mux, trusted)
+	return mux
+}
+
+// handlerFunc は認証済みのリクエストを処理する（actor には監査ログ用の実行者と発生元が入る）
+type handlerFunc func(w http.ResponseWriter, r *http.Request, actor audit.Entry)
+
+// authorizer は scope を確認してからハンドラーを呼ぶ http.Handler を返す
+type authorizer func(scope string, allowQuery bool, next handlerFunc) http.Handler
+
+// authorize はトークンとスコープを確認してからハンドラーを呼ぶ
+func (s *Server) authorize(scope string, allowQuery bool, next handlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		settings := s.appState.GetSettings()
+		if !settings.API.Enabled {
+			writeError(w, http.StatusServiceUnavailable, "API is disabled")
+			return
+		}
+
+		name, token, ok := s.authenticate(r, allowQuery)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mc-agent"`)
+			writeError(w, http.StatusUnauthorized, "Invalid or missing API token")
+			return
+		}
+		if !token.HasScope(scope) {
+			log.Warn().Str("token", name).Str("scope", scope).Str("path", r.URL.Path).Msg("API token lacks scope")
+			writeError(w, http.StatusForbidden, fmt.Sprintf("This token does not have the %q scope", scope))
+			return
+		}
+
+		next(w, r, audit.Entry{UserName: name, Source: audit.SourceAPI})
+	})
+}
+
+// authenticate は Authorization ヘッダー（allowQuery の場合は access_token クエリ）のトークンに一致する設定を返す（比較は定数時間）
+func (s *Server) authenticate(r *http.Request, allowQuery bool) (string, utilities.APIToken, bool) {
+	scheme, presented, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		presented = ""
+	}
+	if presented == "" && allowQuery {
+		presented = r.URL.Query().Get("access_token")
+	}
+	if presented == "" {
+		return "", utilities.APIToken{}, false
+	}
+
+	var (
+		matchedName  string
+		matchedToken utilities.APIToken
+		matched      bool
+	)
+	// 一致しても残りのトークンと比較を続ける（どのトークンに一致したかを応答時間から推測されないように）
+	for name, token := range s.appState.GetSettings().API.Tokens {
+		if token.Token == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(token.Token)) == 1 {
+			matchedName, matchedToken, matched = name, token, true
+		}
+	}
+	return matchedName, matchedToken, matched
+}
+
+// errorResponse はエラー時のレスポンス
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeJSON は値を JSON で返す
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debug().Err(err).Msg("Failed to write API response")
+	}
+}
+
+// writeError はエラーメッセージを JSON で返す
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/Koranoa3/mc-server-agent/internal/policy"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// defaultStopTimeout は停止・再起動時にコンテナの終了を待つ秒数
+	defaultStopTimeout = 30
+
+	// commandGrace はコマンドの完了を待つ時間のうち、停止のタイムアウトに上乗せする余裕
+	commandGrace = 30 * time.Second
+)
+
+// serverView はサーバーの状態のレスポンス
+type serverView struct {
+	ID             string           `json:"id"` // registered_containers のキー
+	DisplayName    string           `json:"display_name"`
+	ContainerName  string           `json:"container_name"`
+	Status         string           `json:"status"`
+	Health         string           `json:"health,omitempty"`
+	Players        int              `json:"players"`
+	OnlinePlayers  []string         `json:"online_players,omitempty"` // GET /servers/{id} のみ（RCON で取得）
+	AutoShutdown   bool             `json:"auto_shutdown"`
+	AutoShutdownAt *time.Time       `json:"auto_shutdown_at,omitempty"` // プレイヤーがいない稼働中のサーバーが自動停止される予定時刻（保守ロック中は無し）
+	Maintenance    *state.LockEvent `json:"maintenance,omitempty"`      // 保守ロック中の場合（ロックの ID は含めない）
+	LastChecked    *time.Time       `json:"last_checked,omitempty"`
+}
+
+// commandResponse はコマンドの実行結果のレスポンス
+type commandResponse struct {
+	Action string `json:"action"`
+	Server string `json:"server"`
+	Status string `json:"status"` // 完了時点のサーバーの状態
+}
+
+// serverView は登録済みのサーバーの状態を返す（state に無い場合は unknown）
+func (s *Server) serverView(key string) (serverView, bool) {
+	settings := s.appState.GetSettings()
+	config, ok := settings.RegisteredContainers[key]
+	if !ok {
+		return serverView{}, false
+	}
+
+	view := serverView{
+		ID:            key,
+		DisplayName:   config.DisplayName,
+		ContainerName: config.ContainerName,
+		Status:        container.StatusUnknown.String(),
+		AutoShutdown:  config.AutoShutdown,
+	}
+	lock, locked := s.appState.Lock(key)
+	if locked {
+		view.Maintenance = &state.LockEvent{Owner: lock.Owner, Reason: lock.Reason, ExpiresAt: lock.ExpiresAt}
+	}
+
+	cont := s.container(key)
+	if cont == nil {
+		return view, true
+	}
+
+	view.Status = cont.Status.String()
+	view.Health = cont.Health
+	view.Players = cont.Players
+	if !cont.LastChecked.IsZero() {
+		lastChecked := cont.LastChecked
+		view.LastChecked = &lastChecked
+	}
+	if config.AutoShutdown && !locked && cont.Status == container.StatusRunning && cont.Players == 0 && !cont.StopTimer.IsZero() {
+		shutdownAt := cont.StopTimer.Add(time.Duration(settings.RegularTask.AutoShutdownDelay) * time.Second)
+		view.AutoShutdownAt = &shutdownAt
+	}
+	return view, true
+}
+
+// container は state のコンテナを返す（無ければ nil）
+func (s *Server) container(key string) *container.Container {
+	stateObj, ok := s.appState.GetContainer(key)
+	if !ok {
+		return nil
+	}
+	cont, _ := stateObj.(*container.Container)
+	return cont
+}
+
+// handleListServers は登録済みのすべてのサーバーの状態を返す
+func (s *Server) handleListServers(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	keys := make([]string, 0)
+	for key := range s.appState.GetSettings().RegisteredContainers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	views := make([]serverView, 0, len(keys))
+	for _, key := range keys {
+		if view, ok := s.serverView(key); ok {
+			views = append(views, view)
+		}
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// handleGetServer はサーバーの状態をオンラインのプレイヤー名と合わせて返す
+func (s *Server) handleGetServer(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	key := r.PathValue("id")
+	view, ok := s.serverView(key)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Server '"+key+"' not found")
+		return
+	}
+
+	if cont := s.container(key); cont != nil && cont.Status == container.StatusRunning {
+		players, err := cont.FetchAllPlayers(r.Context())
+		if err != nil {
+			log.Warn().Err(err).Str("container", key).Msg("Failed to fetch realtime players")
+		}
+		for _, player := range players {
+			view.OnlinePlayers = append(view.OnlinePlayers, player.Name)
+		}
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+// handleCommand は start / stop / restart を Discord と同じチェックを通してから実行し、完了を待って結果を返す
+func (s *Server) handleCommand(action string) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+		s.runCommand(w, r, actor, action, r.PathValue("id"), defaultStopTimeout, policy.Options{})
+	}
+}
+
+// runCommand はチェックを通してからコマンドを送り、完了を待って結果を返す
+// timeout は停止・再起動時にコンテナの終了を待つ秒数（完了を待つ時間もこれに合わせて延ばす）
+func (s *Server) runCommand(w http.ResponseWriter, r *http.Request, actor audit.Entry, action, key string, timeout int, opts policy.Options) {
+	// 登録・保守ロック・現在状態（起動済み・停止済み・プレイヤー在籍など）・許可設定のチェック
+	if rejection := policy.CheckCommandWithOptions(r.Context(), s.appState, action, key, opts); rejection != nil {
+		entry := actor
+		entry.Action = action
+		entry.Server = key
+		entry.Outcome = audit.OutcomeRejected
+		entry.Detail = rejection.Message
+		s.auditLog.Record(entry)
+		s.appState.Events().Publish(state.Event{
+			Type:    state.EventCommandRejected,
+			Server:  key,
+			Command: &state.CommandEvent{Action: action, Source: string(actor.Source), UserName: actor.UserName, Error: rejection.Message},
+		})
+
+		writeError(w, rejectionStatus(rejection.Reason), rejection.Message)
+		return
+	}
+
+	reply := make(chan routine.CommandResult, 1)
+	cmd := routine.Command{
+		Type:        action,
+		ContainerID: key,
+		Timeout:     timeout,
+		Source:      actor.Source,
+		UserName:    actor.UserName,
+		Reply:       reply,
+	}
+	if !s.sendCommand(w, cmd) {
+		return
+	}
+
+	// 結果は main の監査ログに記録される
+	timer := time.NewTimer(time.Duration(timeout)*time.Second + commandGrace)
+	defer timer.Stop()
+	select {
+	case result := <-reply:
+		if result.Err != nil {
+			writeError(w, http.StatusBadGateway, result.Err.Error())
+			return
+		}
+		status := container.StatusUnknown.String()
+		if cont := s.container(key); cont != nil {
+			status = cont.Status.String()
+		}
+		writeJSON(w, http.StatusOK, commandResponse{Action: action, Server: key, Status: status})
+	case <-timer.C:
+		// コマンド自体は実行が続く
+		writeJSON(w, http.StatusAccepted, commandResponse{Action: action, Server: key, Status: "pending"})
+	case <-r.Context().Done():
+	}
+}
+
+// sendCommand はコマンドをキューに入れる（いっぱいの場合はエラーを返して false）
+func (s *Server) sendCommand(w http.ResponseWriter, cmd routine.Command) bool {
+	select {
+	case s.commandChan <- cmd:
+		log.Info().
+			Str("action", cmd.Type).
+			Str("container", cmd.ContainerID).
+			Str("user", cmd.UserName).
+			Msg("Command sent to channel")
+		return true
+	default:
+		log.Error().Msg("Command channel is full")
+		writeError(w, http.StatusServiceUnavailable, "Command queue is full. Please try again later.")
+		return false
+	}
+}
+
+// rejectionStatus は拒否の種類に対応する HTTP のステータスコードを返す
+func rejectionStatus(reason policy.Reason) int {
+	switch reason {
+	case policy.ReasonNotFound:
+		return http.StatusNotFound
+	case policy.ReasonConflict:
+		return http.StatusConflict
+	case policy.ReasonForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/rs/zerolog/log"
+)
+
+// maxRequestBody はリクエストボディの上限
+const maxRequestBody = 64 << 10
+
+// whitelistAddRequest は POST /servers/{id}/whitelist のリクエスト
+type whitelistAddRequest struct {
+	Name     string `json:"name"`
+	Duration string `json:"duration,omitempty"` // 期限付きの場合（例: "2h", "3d"、/whitelist add の duration と同じ形式）
+}
+
+// whitelistChangeResponse はホワイトリストの変更結果のレスポンス
+type whitelistChangeResponse struct {
+	Entry   utilities.WhitelistEntry `json:"entry"`
+	Changed bool                     `json:"changed"` // false の場合は既に登録済み（期限は更新される）
+	Servers []string                 `json:"servers"` // 同じホワイトリストファイルを使うサーバー
+}
+
+// whitelistTarget はサーバーのホワイトリストファイルと、同じファイルを使うサーバーを返す
+func (s *Server) whitelistTarget(w http.ResponseWriter, key string) (string, []string, bool) {
+	settings := s.appState.GetSettings()
+	if _, ok := settings.RegisteredContainers[key]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Server '%s' not found", key))
+		return "", nil, false
+	}
+
+	path := settings.WhitelistFile(key)
+	if path == "" {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Whitelist path is not configured for %s", key))
+		return "", nil, false
+	}
+
+	servers := make([]string, 0)
+	for other := range settings.RegisteredContainers {
+		if settings.WhitelistFile(other) == path {
+			servers = append(servers, other)
+		}
+	}
+	sort.Strings(servers)
+	return path, servers, true
+}
+
+// handleWhitelistList はサーバーのホワイトリストを返す
+func (s *Server) handleWhitelistList(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	path, _, ok := s.whitelistTarget(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	entries, err := utilities.LoadWhitelist(path)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to load whitelist")
+		writeError(w, http.StatusInternalServerError, "Failed to load whitelist")
+		return
+	}
+	if entries == nil {
+		entries = []utilities.WhitelistEntry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// handleWhitelistAdd はプレイヤー名を Mojang API（オフラインモードのサーバーは名前から）で解決してホワイトリストに追加する
+// Discord の管理者による /whitelist add と同じく承認やアカウントの紐付けは行わない
+func (s *Server) handleWhitelistAdd(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	key := r.PathValue("id")
+	path, servers, ok := s.whitelistTarget(w, key)
+	if !ok {
+		return
+	}
+
+	var req whitelistAddRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	duration, err := utilities.ParseListDuration(req.Duration)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var expiresAt *time.Time
+	if duration > 0 {
+		until := time.Now().Add(duration).Truncate(time.Second)
+		expiresAt = &until
+		actor.Detail = "until " + until.Format(time.RFC3339)
+	}
+
+	profile := utilities.OfflineProfile(req.Name)
+	if !s.appState.GetSettings().OfflineMode(key) {
+		if profile, err = s.profiles.Lookup(req.Name); err != nil {
+			if errors.Is(err, utilities.ErrPlayerNotFound) {
+				writeError(w, http.StatusNotFound, fmt.Sprintf("Player '%s' not found", req.Name))
+				return
+			}
+			log.Error().Err(err).Str("player", req.Name).Msg("Failed to resolve player")
+			writeError(w, http.StatusBadGateway, "Failed to resolve player")
+			return
+		}
+	}
+
+	changed, err := utilities.AddToWhitelist(path, profile.ID, profile.Name, "", expiresAt)
+	s.recordWhitelistChange(actor, audit.ActionWhitelistAdd, servers, profile.Name, changed, err)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to update whitelist")
+		writeError(w, http.StatusInternalServerError, "Failed to update whitelist")
+		return
+	}
+	s.refreshWhitelist(r, servers)
+
+	status := http.StatusOK
+	if changed {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, whitelistChangeResponse{
+		Entry:   utilities.WhitelistEntry{UUID: utilities.FormatUUID(profile.ID), Name: profile.Name, ExpiresAt: expiresAt},
+		Changed: changed,
+		Servers: servers,
+	})
+}
+
+// handleWhitelistRemove はホワイトリストからプレイヤーを削除する（名前はファイルのエントリから探す）
+func (s *Server) handleWhitelistRemove(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	path, servers, ok := s.whitelistTarget(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	name := r.PathValue("name")
+
+	entries, err := utilities.LoadWhitelist(path)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to load whitelist")
+		writeError(w, http.StatusInternalServerError, "Failed to load whitelist")
+		return
+	}
+
+	var entry *utilities.WhitelistEntry
+	for idx := range entries {
+		if strings.EqualFold(entries[idx].Name, name) {
+			entry = &entries[idx]
+			break
+		}
+	}
+	if entry == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("%s is not on the whitelist", name))
+		return
+	}
+
+	changed, err := utilities.RemoveFromWhitelist(path, entry.UUID)
+	s.recordWhitelistChange(actor, audit.ActionWhitelistRemove, servers, entry.Name, changed, err)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to update whitelist")
+		writeError(w, http.StatusInternalServerError, "Failed to update whitelist")
+		return
+	}
+	s.refreshWhitelist(r, servers)
+
+	writeJSON(w, http.StatusOK, whitelistChangeResponse{Entry: *entry, Changed: changed, Servers: servers})
+}
+
+// recordWhitelistChange はホワイトリストの変更をサーバーごとに監査ログに記録（変更が無ければ記録しない）
+func (s *Server) recordWhitelistChange(actor audit.Entry, action string, servers []string, player string, changed bool, err error) {
+	if err == nil && !changed {
+		return
+	}
+
+	detail := player
+	if actor.Detail != "" {
+		detail = fmt.Sprintf("%s (%s)", player, actor.Detail)
+	}
+	for _, server := range servers {
+		entry := actor
+		entry.Action = action
+		entry.Server = server
+		entry.Outcome = audit.OutcomeSuccess
+		entry.Detail = detail
+		if err != nil {
+			entry.Outcome = audit.OutcomeFailure
+			entry.Error = err.Error()
+		}
+		s.auditLog.Record(entry)
+	}
+}
+
+// refreshWhitelist は稼働中のサーバーにホワイトリストを再読み込みさせる
+func (s *Server) refreshWhitelist(r *http.Request, servers []string) {
+	for _, key := range servers {
+		cont := s.container(key)
+		if cont == nil || cont.Status != container.StatusRunning {
+			continue
+		}
+		if err := cont.RefreshWhitelist(r.Context()); err != nil {
+			log.Error().Err(err).Str("container_id", key).Msg("Failed to refresh whitelist")
+		}
+	}
+}
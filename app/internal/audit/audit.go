@@ -0,0 +1,247 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Source は操作の発生元
+type Source string
+
+const (
+	SourceSlashCommand Source = "slash_command" // スラッシュコマンド
+	SourceButton       Source = "button"        // メッセージのボタン
+	SourceSchedule     Source = "schedule"      // 定期タスク
+	SourceAutoShutdown Source = "auto_shutdown" // 自動停止
+	SourceSettingsFile Source = "settings_file" // 設定ファイルの変更検知
+	SourceSignal       Source = "signal"        // シグナル（SIGHUP）
+	SourceMemberEvent  Source = "member_event"  // メンバーの脱退・ロール変更
+	SourceAPI          Source = "api"           // HTTP API（UserName はトークンの名前）
+	SourceDashboard    Source = "dashboard"     // Web ダッシュボード（Discord の OAuth2 でログインしたユーザー）
+	SourceSlack        Source = "slack"         // Slack のスラッシュコマンド
+	SourceMatrix       Source = "matrix"        // Matrix のルームのメッセージ
+	SourceTelegram     Source = "telegram"      // Telegram のチャットのコマンド
+	SourceCLI          Source = "cli"           // mc-agent のサブコマンド（UserName は OS のユーザー名）
+)
+
+// Outcome は操作の結果
+type Outcome string
+
+const (
+	OutcomeSuccess  Outcome = "success"  // 成功
+	OutcomeFailure  Outcome = "failure"  // 実行したが失敗
+	OutcomeRejected Outcome = "rejected" // 事前チェックで拒否
+)
+
+// 記録対象のアクション
+const (
+	ActionStart           = "start"
+	ActionStop            = "stop"
+	ActionRestart         = "restart"
+	ActionWhitelistAdd    = "whitelist_add"
+	ActionWhitelistRemove = "whitelist_remove"
+	ActionWhitelistApply  = "whitelist_apply"
+	ActionWhitelistDeny   = "whitelist_deny"
+	ActionWhitelistRename = "whitelist_rename"
+	ActionWhitelistImport = "whitelist_import"
+	ActionWhitelistSync   = "whitelist_sync"
+	ActionSettingsChange  = "settings_change"
+	ActionOpAdd           = "op_add"
+	ActionOpRemove        = "op_remove"
+	ActionBanAdd          = "ban_add"
+	ActionBanRemove       = "ban_remove"
+	ActionBanIPAdd        = "ban_ip_add"
+	ActionBanIPRemove     = "ban_ip_remove"
+
+	ActionMaintenanceLock   = "maintenance_lock"   // 保守ロックの取得（制御用ソケット）
+	ActionMaintenanceUnlock = "maintenance_unlock" // 保守ロックの解除
+)
+
+// Entry は監査ログの1エントリ
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Server   string    `json:"server,omitempty"`
+	UserID   string    `json:"user_id,omitempty"`
+	UserName string    `json:"user_name,omitempty"`
+	Source   Source    `json:"source"`
+	Outcome  Outcome   `json:"outcome"`
+	Detail   string    `json:"detail,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Filter は監査ログ検索の条件（空のフィールドは無視）
+type Filter struct {
+	Server string
+	UserID string
+	Action string
+	Source Source
+}
+
+// Match はエントリが条件に一致するか判定
+func (f Filter) Match(e Entry) bool {
+	if f.Server != "" && e.Server != f.Server {
+		return false
+	}
+	if f.UserID != "" && e.UserID != f.UserID {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	if f.Source != "" && e.Source != f.Source {
+		return false
+	}
+	return true
+}
+
+// Logger は監査ログを JSON Lines 形式でファイルに書き込む
+type Logger struct {
+	mu     sync.Mutex
+	path   string
+	notify chan<- Entry
+}
+
+// NewLogger は新しい Logger を作成
+// notify が nil でなければ記録したエントリを送信する（チャンネル投稿用）
+func NewLogger(path string, notify chan<- Entry) *Logger {
+	return &Logger{
+		path:   path,
+		notify: notify,
+	}
+}
+
+// SetPath は書き込み先を変更する
+func (l *Logger) SetPath(path string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.path = path
+}
+
+// Record はエントリを記録する
+// 書き込みに失敗しても呼び出し元の処理は止めない（ログ出力のみ）
+func (l *Logger) Record(e Entry) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	if err := l.append(e); err != nil {
+		log.Error().Err(err).Str("action", e.Action).Msg("Failed to write audit entry")
+	}
+
+	log.Info().
+		Str("action", e.Action).
+		Str("server", e.Server).
+		Str("user", e.UserName).
+		Str("source", string(e.Source)).
+		Str("outcome", string(e.Outcome)).
+		Msg("Audit")
+
+	if l.notify != nil {
+		select {
+		case l.notify <- e:
+		default:
+			log.Warn().Msg("Audit notify channel is full, dropping entry")
+		}
+	}
+}
+
+// append はエントリを1行追記する
+func (l *Logger) append(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer file.Close()
+
+	// 排他ロック
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock audit file: %w", err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit file: %w", err)
+	}
+
+	return nil
+}
+
+// Recent は条件に一致する最新のエントリを新しい順に最大 limit 件返す
+func (l *Logger) Recent(filter Filter, limit int) ([]Entry, error) {
+	l.mu.Lock()
+	path := l.path
+	l.mu.Unlock()
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Entry{}, nil
+		}
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer file.Close()
+
+	// 読み取り共有ロック
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_SH); err != nil {
+		return nil, fmt.Errorf("failed to lock audit file: %w", err)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	// 直近 limit 件だけをリングバッファで保持
+	ring := make([]Entry, 0, limit)
+	next := 0
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// 壊れた行は読み飛ばす
+			continue
+		}
+		if !filter.Match(e) {
+			continue
+		}
+		if len(ring) < limit {
+			ring = append(ring, e)
+		} else if limit > 0 {
+			ring[next] = e
+			next = (next + 1) % limit
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit file: %w", err)
+	}
+
+	// 新しい順に並べ替え
+	result := make([]Entry, 0, len(ring))
+	for idx := 0; idx < len(ring); idx++ {
+		result = append(result, ring[(next+len(ring)-1-idx)%len(ring)])
+	}
+
+	return result, nil
+}
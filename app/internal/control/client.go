@@ -0,0 +1,244 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/api"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+)
+
+// ErrAgentNotRunning はソケットが無い・接続を拒否された（エージェントが起動していない）場合のエラー
+var ErrAgentNotRunning = errors.New("agent is not running")
+
+// ServerStatus はサーバーの状態（GET /servers のレスポンス）
+type ServerStatus struct {
+	ID             string           `json:"id"`
+	DisplayName    string           `json:"display_name"`
+	ContainerName  string           `json:"container_name"`
+	Status         string           `json:"status"`
+	Health         string           `json:"health,omitempty"`
+	Players        int              `json:"players"`
+	OnlinePlayers  []string         `json:"online_players,omitempty"`
+	AutoShutdown   bool             `json:"auto_shutdown"`
+	AutoShutdownAt *time.Time       `json:"auto_shutdown_at,omitempty"`
+	Maintenance    *state.LockEvent `json:"maintenance,omitempty"` // 保守ロック中の場合
+	LastChecked    *time.Time       `json:"last_checked,omitempty"`
+}
+
+// CommandOptions は起動・停止・再起動の追加の指定
+type CommandOptions struct {
+	Timeout int    // 停止・再起動時にコンテナの終了を待つ秒数（0 の場合は 30 秒）
+	Lock    string // 保守ロックの ID（ロック中のサーバーを操作する場合）
+	Force   bool   // プレイヤーがいても停止・再起動する
+}
+
+// CommandResult は起動・停止・再起動の結果
+type CommandResult struct {
+	Action string `json:"action"`
+	Server string `json:"server"`
+	Status string `json:"status"` // 完了時点のサーバーの状態（完了を待てなかった場合は "pending"）
+}
+
+// WhitelistChange はホワイトリストの追加・削除の結果
+type WhitelistChange struct {
+	Entry   utilities.WhitelistEntry `json:"entry"`
+	Changed bool                     `json:"changed"`
+	Servers []string                 `json:"servers"`
+}
+
+// Error はエージェントが返したエラー
+type Error struct {
+	Status  int
+	Message string
+}
+
+// Error はエラーメッセージを返す
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Client は制御用ソケット（または同じプロセスのハンドラー）に HTTP でリクエストを送る
+type Client struct {
+	http *http.Client
+	user string // 監査ログの実行者として送るユーザー名
+}
+
+// NewClient は制御用ソケットに接続するクライアントを作成
+func NewClient(socketPath, user string) *Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &Client{http: &http.Client{Transport: transport}, user: user}
+}
+
+// NewLocalClient は同じプロセスのハンドラーを直接呼び出すクライアントを作成（エージェントを介さずに操作する場合）
+func NewLocalClient(handler http.Handler, user string) *Client {
+	return &Client{http: &http.Client{Transport: handlerTransport{handler}}, user: user}
+}
+
+// handlerTransport はリクエストをハンドラーに渡してレスポンスを返す
+type handlerTransport struct {
+	handler http.Handler
+}
+
+// RoundTrip はハンドラーを呼び出す
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	t.handler.ServeHTTP(recorder, req)
+	return recorder.Result(), nil
+}
+
+// Servers は登録済みのすべてのサーバーの状態を返す
+func (c *Client) Servers(ctx context.Context) ([]ServerStatus, error) {
+	var servers []ServerStatus
+	err := c.do(ctx, http.MethodGet, "/servers", nil, &servers)
+	return servers, err
+}
+
+// Server はサーバーの状態をオンラインのプレイヤー名と合わせて返す
+func (c *Client) Server(ctx context.Context, id string) (ServerStatus, error) {
+	var server ServerStatus
+	err := c.do(ctx, http.MethodGet, "/servers/"+url.PathEscape(id), nil, &server)
+	return server, err
+}
+
+// Command は start / stop / restart を実行し、完了を待って結果を返す
+func (c *Client) Command(ctx context.Context, id, action string, opts CommandOptions) (CommandResult, error) {
+	var result CommandResult
+	body := map[string]any{"type": action, "server": id}
+	if opts.Timeout > 0 {
+		body["timeout"] = opts.Timeout
+	}
+	if opts.Lock != "" {
+		body["lock"] = opts.Lock
+	}
+	if opts.Force {
+		body["force"] = true
+	}
+	err := c.do(ctx, http.MethodPost, "/commands", body, &result)
+	return result, err
+}
+
+// Locks は有効な保守ロックを返す
+func (c *Client) Locks(ctx context.Context) ([]state.MaintenanceLock, error) {
+	var locks []state.MaintenanceLock
+	err := c.do(ctx, http.MethodGet, "/locks", nil, &locks)
+	return locks, err
+}
+
+// AcquireLock はサーバーの保守ロックを取得する（ttl が空の場合は 1 時間）
+func (c *Client) AcquireLock(ctx context.Context, id, reason, ttl string) (state.MaintenanceLock, error) {
+	var lock state.MaintenanceLock
+	body := map[string]string{}
+	if reason != "" {
+		body["reason"] = reason
+	}
+	if ttl != "" {
+		body["ttl"] = ttl
+	}
+	err := c.do(ctx, http.MethodPost, "/servers/"+url.PathEscape(id)+"/lock", body, &lock)
+	return lock, err
+}
+
+// RenewLock は保守ロックの期限を現在から ttl 後に延長する（ttl が空の場合は 1 時間）
+func (c *Client) RenewLock(ctx context.Context, lockID, ttl string) (state.MaintenanceLock, error) {
+	var lock state.MaintenanceLock
+	body := map[string]string{}
+	if ttl != "" {
+		body["ttl"] = ttl
+	}
+	err := c.do(ctx, http.MethodPost, "/locks/"+url.PathEscape(lockID)+"/renew", body, &lock)
+	return lock, err
+}
+
+// ReleaseLock は保守ロックを解除する
+func (c *Client) ReleaseLock(ctx context.Context, lockID string) (state.MaintenanceLock, error) {
+	var lock state.MaintenanceLock
+	err := c.do(ctx, http.MethodDelete, "/locks/"+url.PathEscape(lockID), nil, &lock)
+	return lock, err
+}
+
+// Whitelist はサーバーのホワイトリストを返す
+func (c *Client) Whitelist(ctx context.Context, id string) ([]utilities.WhitelistEntry, error) {
+	var entries []utilities.WhitelistEntry
+	err := c.do(ctx, http.MethodGet, "/servers/"+url.PathEscape(id)+"/whitelist", nil, &entries)
+	return entries, err
+}
+
+// WhitelistAdd はプレイヤーをホワイトリストに追加する（duration が空でなければ期限付き）
+func (c *Client) WhitelistAdd(ctx context.Context, id, name, duration string) (WhitelistChange, error) {
+	var change WhitelistChange
+	body := map[string]string{"name": name}
+	if duration != "" {
+		body["duration"] = duration
+	}
+	err := c.do(ctx, http.MethodPost, "/servers/"+url.PathEscape(id)+"/whitelist", body, &change)
+	return change, err
+}
+
+// WhitelistRemove はプレイヤーをホワイトリストから削除する
+func (c *Client) WhitelistRemove(ctx context.Context, id, name string) (WhitelistChange, error) {
+	var change WhitelistChange
+	err := c.do(ctx, http.MethodDelete, "/servers/"+url.PathEscape(id)+"/whitelist/"+url.PathEscape(name), nil, &change)
+	return change, err
+}
+
+// do はリクエストを送り、レスポンスを out に読み込む（2xx 以外は *Error を返す）
+func (c *Client) do(ctx context.Context, method, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = strings.NewReader(string(data))
+	}
+	// ホスト名は使われない（ソケットのパスで接続する）
+	req, err := http.NewRequestWithContext(ctx, method, "http://mc-agent"+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set(api.UserHeader, c.user)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ECONNREFUSED) {
+			return ErrAgentNotRunning
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&apiErr)
+		if apiErr.Error == "" {
+			apiErr.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+		}
+		return &Error{Status: resp.StatusCode, Message: apiErr.Error}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// shutdownTimeout は停止時に処理中のリクエストを待つ時間
+const shutdownTimeout = 5 * time.Second
+
+// Server は稼働中のエージェントを操作する UNIX ドメインソケットのサーバー
+// HTTP API と同じエンドポイントを HTTP over UNIX ソケットで提供し、認証はソケットのファイルの権限で行う
+type Server struct {
+	path    string
+	mode    os.FileMode // ソケットのファイルの権限（control.socket_mode）
+	group   string      // ソケットのファイルのグループ名または GID（空の場合は変更しない）
+	handler http.Handler
+
+	httpServer *http.Server
+}
+
+// NewServer は新しい制御用ソケットのサーバーを作成
+func NewServer(path string, mode os.FileMode, group string, handler http.Handler) *Server {
+	return &Server{
+		path:    path,
+		mode:    mode,
+		group:   group,
+		handler: handler,
+	}
+}
+
+// Start はソケットを作成して待ち受けを開始する
+// 前回の異常終了で残ったソケットは削除するが、別のエージェントが待ち受けている場合はエラーにする
+func (s *Server) Start(ctx context.Context) error {
+	if _, err := os.Stat(s.path); err == nil {
+		if conn, err := net.DialTimeout("unix", s.path, time.Second); err == nil {
+			conn.Close()
+			return fmt.Errorf("another agent is already listening on %s", s.path)
+		}
+		if err := os.Remove(s.path); err != nil {
+			return fmt.Errorf("failed to remove stale socket %s: %w", s.path, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", s.path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.path, err)
+	}
+	if err := s.setPermissions(); err != nil {
+		listener.Close()
+		return err
+	}
+
+	s.httpServer = &http.Server{
+		Handler:           s.handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("Control socket server stopped")
+		}
+	}()
+
+	log.Info().Str("path", s.path).Str("mode", s.mode.String()).Str("group", s.group).Msg("Control socket started")
+	return nil
+}
+
+// setPermissions はソケットのファイルのグループと権限を設定する（接続できるユーザーの制限になる）
+func (s *Server) setPermissions() error {
+	if s.group != "" {
+		gid, err := lookupGroup(s.group)
+		if err != nil {
+			return err
+		}
+		if err := os.Chown(s.path, -1, gid); err != nil {
+			return fmt.Errorf("failed to change group of %s: %w", s.path, err)
+		}
+	}
+	if err := os.Chmod(s.path, s.mode); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// lookupGroup はグループ名（数値の場合は GID）から GID を返す
+func lookupGroup(name string) (int, error) {
+	group, err := user.LookupGroup(name)
+	if err != nil {
+		var unknown user.UnknownGroupError
+		if !errors.As(err, &unknown) {
+			return 0, fmt.Errorf("failed to look up group %s: %w", name, err)
+		}
+		if group, err = user.LookupGroupId(name); err != nil {
+			return 0, fmt.Errorf("group %s not found", name)
+		}
+	}
+	return strconv.Atoi(group.Gid)
+}
+
+// Stop はサーバーを停止する（ソケットのファイルは閉じるときに削除される）
+func (s *Server) Stop() error {
+	if s.httpServer == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
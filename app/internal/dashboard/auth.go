@@ -0,0 +1,397 @@
+package dashboard
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Discord の OAuth2 のエンドポイント
+	discordAuthorizeURL = "https://discord.com/oauth2/authorize"
+	discordTokenURL     = "https://discord.com/api/v10/oauth2/token"
+	discordUserURL      = "https://discord.com/api/v10/users/@me"
+
+	// sessionCookie はログイン中のセッション ID の Cookie
+	sessionCookie = "mc_agent_session"
+
+	// stateCookie は OAuth2 の state（ログイン開始時にブラウザと結び付ける）の Cookie
+	stateCookie = "mc_agent_oauth_state"
+
+	// sessionTTL はログインの有効期間（エージェントを再起動した場合もログアウトされる）
+	sessionTTL = 24 * time.Hour
+
+	// stateTTL はログイン開始から Discord で承認するまでの期限
+	stateTTL = 10 * time.Minute
+
+	// membershipRecheck はギルドのメンバー・管理者権限を確認し直す間隔（ロールの変更を反映するため）
+	membershipRecheck = 5 * time.Minute
+
+	// oauthTimeout は Discord の OAuth2 API の呼び出しの時間の上限
+	oauthTimeout = 10 * time.Second
+
+	// csrfHeader は変更を伴うリクエストに付ける CSRF トークンのヘッダー
+	csrfHeader = "X-CSRF-Token"
+)
+
+// role はエンドポイントに必要な権限
+type role int
+
+const (
+	roleViewer role = iota // 閲覧（dashboard.public の場合はログイン不要）
+	roleMember             // ギルドのメンバー（起動・停止）
+	roleAdmin              // 管理者権限（ホワイトリストの閲覧）
+)
+
+// session はログイン中のユーザー
+type session struct {
+	UserID    string
+	UserName  string
+	Avatar    string
+	CSRFToken string
+	Admin     bool
+	CheckedAt time.Time // 最後にメンバー・管理者権限を確認した時刻
+	ExpiresAt time.Time
+}
+
+// sessionStore はメモリ上のセッション（エージェントの再起動で破棄される）
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// newSessionStore は新しい sessionStore を作成
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: make(map[string]*session)}
+}
+
+// create はセッションを作成し、ID を返す（期限切れのセッションはこのときに削除する）
+func (st *sessionStore) create(sess *session) string {
+	id := randomToken()
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := time.Now()
+	for key, existing := range st.sessions {
+		if now.After(existing.ExpiresAt) {
+			delete(st.sessions, key)
+		}
+	}
+	st.sessions[id] = sess
+	return id
+}
+
+// get は有効なセッションのコピーを返す
+func (st *sessionStore) get(id string) (session, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	sess, ok := st.sessions[id]
+	if !ok || time.Now().After(sess.ExpiresAt) {
+		return session{}, false
+	}
+	return *sess, true
+}
+
+// update はメンバー・管理者権限の確認結果を反映する
+func (st *sessionStore) update(id string, admin bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if sess, ok := st.sessions[id]; ok {
+		sess.Admin = admin
+		sess.CheckedAt = time.Now()
+	}
+}
+
+// delete はセッションを削除する
+func (st *sessionStore) delete(id string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.sessions, id)
+}
+
+// randomToken は推測できないランダムな文字列を返す
+func randomToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to read random bytes: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// redirectURI は OAuth2 のリダイレクト先（Discord の Developer Portal に登録する）
+func (s *Server) redirectURI() string {
+	return strings.TrimRight(s.appState.GetSettings().Dashboard.PublicURL, "/") + "/auth/callback"
+}
+
+// secureCookies は public_url が https の場合に Cookie を Secure にする
+func (s *Server) secureCookies() bool {
+	return strings.HasPrefix(s.appState.GetSettings().Dashboard.PublicURL, "https://")
+}
+
+// setCookie は HttpOnly の Cookie を設定する（maxAge が負の場合は削除）
+func (s *Server) setCookie(w http.ResponseWriter, name, value string, maxAge time.Duration) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   s.secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(maxAge.Seconds()),
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+// handleLogin は Discord の認可画面にリダイレクトする
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	state := randomToken()
+	s.setCookie(w, stateCookie, state, stateTTL)
+
+	query := url.Values{
+		"client_id":     {s.appState.GetSettings().Discord.AppID},
+		"redirect_uri":  {s.redirectURI()},
+		"response_type": {"code"},
+		"scope":         {"identify"},
+		"state":         {state},
+	}
+	http.Redirect(w, r, discordAuthorizeURL+"?"+query.Encode(), http.StatusFound)
+}
+
+// discordUser は /users/@me のレスポンス
+type discordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Avatar   string `json:"avatar"`
+}
+
+// handleCallback は認可コードをアクセストークンに交換し、ギルドのメンバーであればログインさせる
+func (s *Server) handleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	cookie, err := r.Cookie(stateCookie)
+	s.setCookie(w, stateCookie, "", -1)
+	if err != nil || query.Get("state") == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		http.Error(w, "Login expired or invalid. Please try again.", http.StatusBadRequest)
+		return
+	}
+	if query.Get("error") != "" {
+		// ユーザーが承認しなかった
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), oauthTimeout)
+	defer cancel()
+
+	user, err := s.fetchDiscordUser(ctx, query.Get("code"))
+	if err != nil {
+		log.Error().Err(err).Msg("Dashboard login failed")
+		http.Error(w, "Failed to log in with Discord.", http.StatusBadGateway)
+		return
+	}
+
+	member, admin, err := s.membership(user.ID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to check guild membership")
+		http.Error(w, "Failed to check your Discord server membership.", http.StatusBadGateway)
+		return
+	}
+	if !member {
+		log.Warn().Str("user_id", user.ID).Str("user", user.Username).Msg("Dashboard login rejected (not a guild member)")
+		http.Error(w, "You are not a member of the Discord server.", http.StatusForbidden)
+		return
+	}
+
+	now := time.Now()
+	id := s.sessions.create(&session{
+		UserID:    user.ID,
+		UserName:  user.Username,
+		Avatar:    user.Avatar,
+		CSRFToken: randomToken(),
+		Admin:     admin,
+		CheckedAt: now,
+		ExpiresAt: now.Add(sessionTTL),
+	})
+	s.setCookie(w, sessionCookie, id, sessionTTL)
+
+	log.Info().Str("user_id", user.ID).Str("user", user.Username).Bool("admin", admin).Msg("Dashboard login")
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// fetchDiscordUser は認可コードをアクセストークンに交換してユーザーを取得する
+func (s *Server) fetchDiscordUser(ctx context.Context, code string) (discordUser, error) {
+	settings := s.appState.GetSettings()
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {s.redirectURI()},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discordTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return discordUser{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(settings.Discord.AppID, settings.Dashboard.ClientSecret)
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+	}
+	if err := doJSON(req, &token); err != nil {
+		return discordUser{}, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, discordUserURL, nil)
+	if err != nil {
+		return discordUser{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	var user discordUser
+	if err := doJSON(req, &user); err != nil {
+		return discordUser{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.ID == "" {
+		return discordUser{}, fmt.Errorf("failed to get user: empty user ID")
+	}
+	return user, nil
+}
+
+// doJSON はリクエストを送り、200 番台であれば JSON をデコードする
+func doJSON(req *http.Request, v any) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
+
+// handleLogout はセッションを削除する
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if id, sess, ok := s.currentSession(r); ok {
+		if !validCSRF(r, sess) {
+			writeError(w, http.StatusForbidden, "Invalid CSRF token")
+			return
+		}
+		s.sessions.delete(id)
+	}
+	s.setCookie(w, sessionCookie, "", -1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// currentSession はリクエストの Cookie に対応するセッションを返す
+// 確認から時間が経っている場合はギルドのメンバー・管理者権限を確認し直し、脱退していればログアウトさせる
+func (s *Server) currentSession(r *http.Request) (string, session, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", session{}, false
+	}
+	sess, ok := s.sessions.get(cookie.Value)
+	if !ok {
+		return "", session{}, false
+	}
+
+	if time.Since(sess.CheckedAt) >= membershipRecheck {
+		member, admin, err := s.membership(sess.UserID)
+		switch {
+		case err != nil:
+			// Discord に問い合わせられない間は前回の結果を使う
+			log.Warn().Err(err).Str("user_id", sess.UserID).Msg("Failed to recheck guild membership")
+		case !member:
+			log.Info().Str("user_id", sess.UserID).Msg("Dashboard session ended (no longer a guild member)")
+			s.sessions.delete(cookie.Value)
+			return "", session{}, false
+		default:
+			s.sessions.update(cookie.Value, admin)
+			sess.Admin = admin
+		}
+	}
+	return cookie.Value, sess, true
+}
+
+// validCSRF は CSRF トークンのヘッダーがセッションのものと一致するか判定する
+func validCSRF(r *http.Request, sess session) bool {
+	token := r.Header.Get(csrfHeader)
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(sess.CSRFToken)) == 1
+}
+
+// handlerFunc は権限を確認したリクエストを処理する（actor には監査ログ用の実行者と発生元が入る、未ログインの閲覧では UserID が空）
+type handlerFunc func(w http.ResponseWriter, r *http.Request, actor audit.Entry)
+
+// require はログインと権限を確認してからハンドラーを呼ぶ（GET 以外は CSRF トークンも確認する）
+func (s *Server) require(required role, next handlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.appState.GetSettings().Dashboard.Enabled {
+			writeError(w, http.StatusServiceUnavailable, "Dashboard is disabled")
+			return
+		}
+
+		_, sess, ok := s.currentSession(r)
+		if !ok {
+			if required == roleViewer && s.appState.GetSettings().Dashboard.Public {
+				next(w, r, audit.Entry{Source: audit.SourceDashboard})
+				return
+			}
+			writeError(w, http.StatusUnauthorized, "Please log in with Discord")
+			return
+		}
+		if required == roleAdmin && !sess.Admin {
+			writeError(w, http.StatusForbidden, "Administrator permission is required")
+			return
+		}
+		if r.Method != http.MethodGet && !validCSRF(r, sess) {
+			writeError(w, http.StatusForbidden, "Invalid CSRF token")
+			return
+		}
+
+		next(w, r, audit.Entry{UserID: sess.UserID, UserName: sess.UserName, Source: audit.SourceDashboard})
+	})
+}
+
+// meResponse は /data/me のレスポンス（画面の表示の切り替えに使う）
+type meResponse struct {
+	User      *meUser `json:"user"` // 未ログインの場合は null
+	CSRFToken string  `json:"csrf_token,omitempty"`
+	Public    bool    `json:"public"`
+}
+
+// meUser はログイン中のユーザー
+type meUser struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Avatar string `json:"avatar,omitempty"` // https://cdn.discordapp.com/avatars/{id}/{avatar}.png
+	Admin  bool   `json:"admin"`
+}
+
+// handleMe はログイン中のユーザーと CSRF トークンを返す
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	response := meResponse{Public: s.appState.GetSettings().Dashboard.Public}
+	if _, sess, ok := s.currentSession(r); ok {
+		response.User = &meUser{ID: sess.UserID, Name: sess.UserName, Avatar: sess.Avatar, Admin: sess.Admin}
+		response.CSRFToken = sess.CSRFToken
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,327 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/Koranoa3/mc-server-agent/internal/policy"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// commandTimeout はコマンドの完了を待つ時間（停止のタイムアウト 30 秒 + 余裕）
+	commandTimeout = 60 * time.Second
+
+	// resourceCacheTTL はリソース使用量を Docker から取得し直すまでの時間（複数の画面で開いても問い合わせを増やさない）
+	resourceCacheTTL = 5 * time.Second
+
+	// statsTimeout はリソース使用量の取得1回の時間の上限
+	statsTimeout = 3 * time.Second
+
+	// defaultEventLimit / maxEventLimit は /data/events で返すイベントの数
+	defaultEventLimit = 50
+	maxEventLimit     = 200
+)
+
+// serverCard はサーバーのカードに表示する内容
+type serverCard struct {
+	ID             string     `json:"id"` // registered_containers のキー
+	DisplayName    string     `json:"display_name"`
+	Icon           string     `json:"icon,omitempty"`
+	Status         string     `json:"status"`
+	Health         string     `json:"health,omitempty"`
+	Players        int        `json:"players"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`       // 稼働中のみ（稼働時間の表示に使う）
+	AutoShutdownAt *time.Time `json:"auto_shutdown_at,omitempty"` // プレイヤーがいない稼働中のサーバーが自動停止される予定時刻
+	CPUPercent     *float64   `json:"cpu_percent,omitempty"`      // 1コアを 100 とした使用率（前回の取得との差から計算）
+	MemoryBytes    uint64     `json:"memory_bytes,omitempty"`
+	MemoryLimit    uint64     `json:"memory_limit,omitempty"`
+	CanStart       bool       `json:"can_start"` // allowed_actions（実行時にも確認する）
+	CanStop        bool       `json:"can_stop"`
+	LastChecked    *time.Time `json:"last_checked,omitempty"`
+}
+
+// commandResponse はコマンドの実行結果のレスポンス
+type commandResponse struct {
+	Action string `json:"action"`
+	Server string `json:"server"`
+	Status string `json:"status"` // 完了時点のサーバーの状態（完了を待ちきれなかった場合は pending）
+}
+
+// resourceSample は取得したリソース使用量と、前回との差から計算した CPU 使用率
+type resourceSample struct {
+	usage      container.ResourceUsage
+	at         time.Time
+	cpuPercent *float64
+}
+
+// resourceCache はサーバーごとの直近のリソース使用量
+type resourceCache struct {
+	mu      sync.Mutex
+	samples map[string]resourceSample
+}
+
+// newResourceCache は新しい resourceCache を作成
+func newResourceCache() *resourceCache {
+	return &resourceCache{samples: make(map[string]resourceSample)}
+}
+
+// get は直近のリソース使用量を返す（古ければ Docker から取得し直す）
+func (c *resourceCache) get(ctx context.Context, key string, cont *container.Container) (resourceSample, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev, ok := c.samples[key]
+	if ok && time.Since(prev.at) < resourceCacheTTL {
+		return prev, true
+	}
+
+	statsCtx, cancel := context.WithTimeout(ctx, statsTimeout)
+	defer cancel()
+	usage, err := cont.Stats(statsCtx)
+	if err != nil {
+		log.Debug().Err(err).Str("container", key).Msg("Failed to get container stats for dashboard")
+		return resourceSample{}, false
+	}
+
+	sample := resourceSample{usage: usage, at: time.Now()}
+	// 再起動で累積 CPU 時間が戻った場合は計算しない
+	if ok && usage.CPUTime >= prev.usage.CPUTime {
+		percent := float64(usage.CPUTime-prev.usage.CPUTime) / float64(sample.at.Sub(prev.at)) * 100
+		sample.cpuPercent = &percent
+	}
+	c.samples[key] = sample
+	return sample, true
+}
+
+// forget は稼働していないサーバーの記録を削除する
+func (c *resourceCache) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.samples, key)
+}
+
+// container は state のコンテナを返す（無ければ nil）
+func (s *Server) container(key string) *container.Container {
+	stateObj, ok := s.appState.GetContainer(key)
+	if !ok {
+		return nil
+	}
+	cont, _ := stateObj.(*container.Container)
+	return cont
+}
+
+// serverCard は登録済みのサーバーのカードの内容を返す（state に無い場合は unknown）
+func (s *Server) serverCard(ctx context.Context, key string, config utilities.ContainerConfig, settings *utilities.Settings) serverCard {
+	card := serverCard{
+		ID:          key,
+		DisplayName: config.DisplayName,
+		Icon:        config.Icon,
+		Status:      container.StatusUnknown.String(),
+		CanStart:    policy.IsActionAllowed(settings.AllowedActions, audit.ActionStart),
+		CanStop:     policy.IsActionAllowed(settings.AllowedActions, audit.ActionStop),
+	}
+
+	cont := s.container(key)
+	if cont == nil {
+		return card
+	}
+
+	card.Status = cont.Status.String()
+	card.Health = cont.Health
+	card.Players = cont.Players
+	if !cont.LastChecked.IsZero() {
+		lastChecked := cont.LastChecked
+		card.LastChecked = &lastChecked
+	}
+	if config.AutoShutdown && cont.Status == container.StatusRunning && cont.Players == 0 && !cont.StopTimer.IsZero() {
+		shutdownAt := cont.StopTimer.Add(time.Duration(settings.RegularTask.AutoShutdownDelay) * time.Second)
+		card.AutoShutdownAt = &shutdownAt
+	}
+
+	if cont.Status != container.StatusRunning && cont.Status != container.StatusStarting {
+		s.resources.forget(key)
+		return card
+	}
+	if !cont.StartedAt.IsZero() {
+		startedAt := cont.StartedAt
+		card.StartedAt = &startedAt
+	}
+	if sample, ok := s.resources.get(ctx, key, cont); ok {
+		card.CPUPercent = sample.cpuPercent
+		card.MemoryBytes = sample.usage.MemoryBytes
+		card.MemoryLimit = sample.usage.MemoryLimit
+	}
+	return card
+}
+
+// handleServers は登録済みのすべてのサーバーのカードを返す
+func (s *Server) handleServers(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	settings := s.appState.GetSettings()
+	keys := make([]string, 0, len(settings.RegisteredContainers))
+	for key := range settings.RegisteredContainers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	cards := make([]serverCard, 0, len(keys))
+	for _, key := range keys {
+		cards = append(cards, s.serverCard(r.Context(), key, settings.RegisteredContainers[key], settings))
+	}
+	writeJSON(w, http.StatusOK, cards)
+}
+
+// handleEvents は最近のイベントを古い順に返す（?limit= で件数、?after= で指定した ID より後のみ）
+// 未ログインの閲覧（dashboard.public）では操作したユーザー・保守ロックの取得者を伏せる
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	limit := defaultEventLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxEventLimit)
+	}
+	after, _ := strconv.ParseUint(r.URL.Query().Get("after"), 10, 64)
+
+	events := make([]state.Event, 0, limit)
+	for _, event := range s.appState.Events().Recent(limit) {
+		if event.ID <= after {
+			continue
+		}
+		if actor.UserID == "" && event.Command != nil {
+			command := *event.Command
+			command.UserID = ""
+			command.UserName = ""
+			event.Command = &command
+		}
+		if actor.UserID == "" && event.Lock != nil {
+			lock := *event.Lock
+			lock.Owner = ""
+			event.Lock = &lock
+		}
+		events = append(events, event)
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// handleWhitelist はサーバーのホワイトリストを返す（Discord の /whitelist list と同じく管理者のみ）
+func (s *Server) handleWhitelist(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+	key := r.PathValue("id")
+	settings := s.appState.GetSettings()
+	if _, ok := settings.RegisteredContainers[key]; !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Server '%s' not found", key))
+		return
+	}
+	path := settings.WhitelistFile(key)
+	if path == "" {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Whitelist path is not configured for %s", key))
+		return
+	}
+
+	entries, err := utilities.LoadWhitelist(path)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("Failed to load whitelist")
+		writeError(w, http.StatusInternalServerError, "Failed to load whitelist")
+		return
+	}
+	if entries == nil {
+		entries = []utilities.WhitelistEntry{}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// handleCommand は start / stop を Discord のボタンと同じチェックを通してから実行し、完了を待って結果を返す
+func (s *Server) handleCommand(action string) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, actor audit.Entry) {
+		key := r.PathValue("id")
+
+		// 登録・現在状態（起動済み・停止済み・プレイヤー在籍など）・許可設定のチェック
+		if rejection := policy.CheckCommand(r.Context(), s.appState, action, key); rejection != nil {
+			entry := actor
+			entry.Action = action
+			entry.Server = key
+			entry.Outcome = audit.OutcomeRejected
+			entry.Detail = rejection.Message
+			s.auditLog.Record(entry)
+			s.appState.Events().Publish(state.Event{
+				Type:    state.EventCommandRejected,
+				Server:  key,
+				Command: &state.CommandEvent{Action: action, Source: string(actor.Source), UserID: actor.UserID, UserName: actor.UserName, Error: rejection.Message},
+			})
+
+			writeError(w, rejectionStatus(rejection.Reason), rejection.Message)
+			return
+		}
+
+		reply := make(chan routine.CommandResult, 1)
+		cmd := routine.Command{
+			Type:        action,
+			ContainerID: key,
+			Timeout:     30,
+			Source:      actor.Source,
+			UserID:      actor.UserID,
+			UserName:    actor.UserName,
+			Reply:       reply,
+		}
+
+		select {
+		case s.commandChan <- cmd:
+			log.Info().
+				Str("action", action).
+				Str("container", key).
+				Str("user", actor.UserName).
+				Msg("Command sent to channel")
+		default:
+			log.Error().Msg("Command channel is full")
+			writeError(w, http.StatusServiceUnavailable, "Command queue is full. Please try again later.")
+			return
+		}
+
+		// 結果は main の監査ログに記録される
+		timer := time.NewTimer(commandTimeout)
+		defer timer.Stop()
+		select {
+		case result := <-reply:
+			if result.Err != nil {
+				writeError(w, http.StatusBadGateway, result.Err.Error())
+				return
+			}
+			status := container.StatusUnknown.String()
+			if cont := s.container(key); cont != nil {
+				status = cont.Status.String()
+			}
+			writeJSON(w, http.StatusOK, commandResponse{Action: action, Server: key, Status: status})
+		case <-timer.C:
+			// コマンド自体は実行が続く
+			writeJSON(w, http.StatusAccepted, commandResponse{Action: action, Server: key, Status: "pending"})
+		case <-r.Context().Done():
+		}
+	}
+}
+
+// rejectionStatus は拒否の種類に対応する HTTP のステータスコードを返す
+func rejectionStatus(reason policy.Reason) int {
+	switch reason {
+	case policy.ReasonNotFound:
+		return http.StatusNotFound
+	case policy.ReasonConflict:
+		return http.StatusConflict
+	case policy.ReasonForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
@@ -0,0 +1,161 @@
+package dashboard
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/rs/zerolog/log"
+)
+
+// shutdownTimeout は停止時に処理中のリクエストを待つ時間
+const shutdownTimeout = 5 * time.Second
+
+// static はダッシュボードの画面（HTML / JS / CSS）
+//
+//go:embed static
+var static embed.FS
+
+// MembershipFunc は Discord のユーザー ID から、ギルドのメンバーか・管理者権限を持つかを返す
+// Discord Bot と同じ権限で判定するために使う（discord パッケージを参照しないよう関数で受け取る）
+type MembershipFunc func(userID string) (member, admin bool, err error)
+
+// Server は Web ダッシュボード（dashboard.enabled の場合のみ起動）
+// サーバーの状態・最近のイベント・ホワイトリストを表示し、ログインしたメンバーは起動・停止できる
+type Server struct {
+	appState    *state.AppState
+	auditLog    *audit.Logger
+	membership  MembershipFunc
+	commandChan chan<- routine.Command
+
+	sessions  *sessionStore
+	resources *resourceCache
+
+	httpServer *http.Server
+	stopOnce   sync.Once
+}
+
+// NewServer は新しいダッシュボードを作成
+func NewServer(appState *state.AppState, auditLog *audit.Logger, membership MembershipFunc, commandChan chan<- routine.Command) *Server {
+	return &Server{
+		appState:    appState,
+		auditLog:    auditLog,
+		membership:  membership,
+		commandChan: commandChan,
+		sessions:    newSessionStore(),
+		resources:   newResourceCache(),
+	}
+}
+
+// Start は dashboard.listen で待ち受けを開始する
+// 待ち受けアドレスは起動時に決まるため、設定の再読み込みでは変わらない（public_url 等は毎回設定から参照する）
+func (s *Server) Start(ctx context.Context) error {
+	listen := s.appState.GetSettings().Dashboard.Listen
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", listen, err)
+	}
+
+	handler, err := s.routes()
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	s.httpServer = &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("Dashboard server stopped")
+		}
+	}()
+
+	log.Info().Str("listen", listener.Addr().String()).Msg("Dashboard started")
+	return nil
+}
+
+// Stop はサーバーを停止する
+func (s *Server) Stop() error {
+	var err error
+	s.stopOnce.Do(func() {
+		if s.httpServer == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = s.httpServer.Shutdown(ctx)
+	})
+	return err
+}
+
+// routes はエンドポイントを登録したハンドラーを返す
+func (s *Server) routes() (http.Handler, error) {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dashboard assets: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServerFS(files))
+
+	// Discord の OAuth2 によるログイン
+	mux.HandleFunc("GET /login", s.handleLogin)
+	mux.HandleFunc("GET /auth/callback", s.handleCallback)
+	mux.HandleFunc("POST /logout", s.handleLogout)
+
+	// 画面から読み込むデータ
+	mux.HandleFunc("GET /data/me", s.handleMe)
+	mux.Handle("GET /data/servers", s.require(roleViewer, s.handleServers))
+	mux.Handle("GET /data/events", s.require(roleViewer, s.handleEvents))
+	mux.Handle("GET /data/servers/{id}/whitelist", s.require(roleAdmin, s.handleWhitelist))
+	mux.Handle("POST /data/servers/{id}/start", s.require(roleMember, s.handleCommand(audit.ActionStart)))
+	mux.Handle("POST /data/servers/{id}/stop", s.require(roleMember, s.handleCommand(audit.ActionStop)))
+
+	return securityHeaders(mux), nil
+}
+
+// securityHeaders は画面を他サイトに埋め込ませない等のヘッダーを付ける
+func securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Referrer-Policy", "same-origin")
+		header.Set("Content-Security-Policy", "default-src 'self'; img-src 'self' https://cdn.discordapp.com; frame-ancestors 'none'")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// errorResponse はエラー時のレスポンス
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeJSON は値を JSON で返す
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debug().Err(err).Msg("Failed to write dashboard response")
+	}
+}
+
+// writeError はエラーメッセージを JSON で返す
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
@@ -0,0 +1,317 @@
+// mc-agent ダッシュボード
+// サーバーの状態とイベントを定期的に読み込み、ログインしたメンバーには起動・停止ボタンを表示する
+"use strict";
+
+const REFRESH_INTERVAL = 5000;
+
+const STATUS_LABELS = {
+  running: "稼働中",
+  starting: "起動中",
+  stopped: "停止中",
+  not_found: "コンテナなし",
+  unknown: "不明",
+};
+
+const EVENT_LABELS = {
+  status_changed: "状態の変化",
+  player_joined: "参加",
+  player_left: "退出",
+  command_started: "操作の開始",
+  command_completed: "操作の完了",
+  command_failed: "操作の失敗",
+  command_rejected: "操作の拒否",
+  alert: "アラート",
+  maintenance_locked: "保守の開始",
+  maintenance_unlocked: "保守の終了",
+};
+
+let me = { user: null, public: false };
+let servers = [];
+let busy = new Set();
+let lastEventID = 0;
+
+// fetchJSON はデータを読み込む（失敗した場合はエラーメッセージで例外を投げる）
+async function fetchJSON(path, options = {}) {
+  const response = await fetch(path, { credentials: "same-origin", ...options });
+  if (response.status === 204) {
+    return null;
+  }
+  const body = await response.json().catch(() => ({}));
+  if (!response.ok) {
+    const error = new Error(body.error || `HTTP ${response.status}`);
+    error.status = response.status;
+    throw error;
+  }
+  return body;
+}
+
+// post は CSRF トークンを付けて POST する
+function post(path) {
+  return fetchJSON(path, { method: "POST", headers: { "X-CSRF-Token": me.csrf_token || "" } });
+}
+
+function showNotice(message) {
+  const notice = document.getElementById("notice");
+  notice.textContent = message;
+  notice.hidden = !message;
+}
+
+function formatDuration(ms) {
+  const minutes = Math.max(0, Math.floor(ms / 60000));
+  const days = Math.floor(minutes / 1440);
+  const hours = Math.floor((minutes % 1440) / 60);
+  if (days > 0) {
+    return `${days}日 ${hours}時間`;
+  }
+  if (hours > 0) {
+    return `${hours}時間 ${minutes % 60}分`;
+  }
+  return `${minutes}分`;
+}
+
+function formatBytes(bytes) {
+  const units = ["B", "KiB", "MiB", "GiB", "TiB"];
+  let value = bytes;
+  let unit = 0;
+  while (value >= 1024 && unit < units.length - 1) {
+    value /= 1024;
+    unit++;
+  }
+  return `${value.toFixed(unit === 0 ? 0 : 1)} ${units[unit]}`;
+}
+
+function formatTime(value) {
+  return new Date(value).toLocaleString();
+}
+
+// renderIcon はサーバーのアイコン（Unicode の絵文字またはカスタム絵文字）を表示する
+function renderIcon(element, icon) {
+  element.replaceChildren();
+  const custom = /^<a?:\w+:(\d+)>$/.exec(icon || "");
+  if (custom) {
+    const img = document.createElement("img");
+    img.src = `https://cdn.discordapp.com/emojis/${custom[1]}.png`;
+    img.alt = "";
+    element.append(img);
+  } else {
+    element.textContent = icon || "";
+  }
+}
+
+function renderAccount() {
+  document.getElementById("login").hidden = !!me.user;
+  document.getElementById("user").hidden = !me.user;
+  if (me.user) {
+    document.getElementById("user-name").textContent = me.user.name;
+    const avatar = document.getElementById("avatar");
+    if (me.user.avatar) {
+      avatar.src = `https://cdn.discordapp.com/avatars/${me.user.id}/${me.user.avatar}.png?size=64`;
+      avatar.hidden = false;
+    }
+  }
+  document.getElementById("whitelist-section").hidden = !(me.user && me.user.admin);
+}
+
+function renderServers() {
+  const container = document.getElementById("servers");
+  const template = document.getElementById("card-template");
+  const now = Date.now();
+
+  container.replaceChildren(...servers.map((server) => {
+    const card = template.content.firstElementChild.cloneNode(true);
+    card.dataset.status = server.status;
+    renderIcon(card.querySelector(".icon"), server.icon);
+    card.querySelector(".name").textContent = server.display_name || server.id;
+
+    let status = STATUS_LABELS[server.status] || server.status;
+    if (server.health) {
+      status += ` (${server.health})`;
+    }
+    card.querySelector(".status").textContent = status;
+    card.querySelector(".players").textContent = `${server.players} 人`;
+    card.querySelector(".uptime").textContent = server.started_at ? formatDuration(now - new Date(server.started_at)) : "-";
+    card.querySelector(".cpu").textContent = server.cpu_percent != null ? `${server.cpu_percent.toFixed(1)}%` : "-";
+    card.querySelector(".memory").textContent = server.memory_bytes
+      ? `${formatBytes(server.memory_bytes)} / ${formatBytes(server.memory_limit)}`
+      : "-";
+
+    if (server.auto_shutdown_at) {
+      const autoShutdown = card.querySelector(".auto-shutdown");
+      autoShutdown.textContent = `プレイヤーがいないため、あと ${formatDuration(new Date(server.auto_shutdown_at) - now)} で自動停止します`;
+      autoShutdown.hidden = false;
+    }
+
+    if (me.user) {
+      const actions = card.querySelector(".actions");
+      const start = actions.querySelector(".start");
+      const stop = actions.querySelector(".stop");
+      const pending = busy.has(server.id);
+      start.hidden = !server.can_start;
+      stop.hidden = !server.can_stop;
+      start.disabled = pending || server.status !== "stopped";
+      stop.disabled = pending || server.status !== "running";
+      start.addEventListener("click", () => runCommand(server, "start"));
+      stop.addEventListener("click", () => runCommand(server, "stop"));
+      actions.hidden = !(server.can_start || server.can_stop);
+    }
+    return card;
+  }));
+
+  const select = document.getElementById("whitelist-server");
+  if (select.options.length !== servers.length) {
+    select.replaceChildren(...servers.map((server) => new Option(server.display_name || server.id, server.id)));
+    loadWhitelist();
+  }
+}
+
+function describeEvent(event) {
+  switch (event.type) {
+    case "status_changed":
+      return `${STATUS_LABELS[event.status.from] || event.status.from} → ${STATUS_LABELS[event.status.to] || event.status.to}`;
+    case "player_joined":
+    case "player_left":
+      return `${event.player.name}（${event.player.online} 人）`;
+    case "command_started":
+    case "command_completed":
+    case "command_failed":
+    case "command_rejected": {
+      let text = event.command.action;
+      if (event.command.user_name) {
+        text += ` by ${event.command.user_name}`;
+      }
+      if (event.command.error) {
+        text += `: ${event.command.error}`;
+      }
+      return text;
+    }
+    case "alert":
+      return event.alert.message;
+    case "maintenance_locked":
+    case "maintenance_unlocked": {
+      let text = event.lock.owner ? `by ${event.lock.owner}` : "";
+      if (event.lock.reason) {
+        text += `（${event.lock.reason}）`;
+      }
+      if (event.lock.expired) {
+        text += " 期限切れ";
+      }
+      return text;
+    }
+    default:
+      return "";
+  }
+}
+
+function renderEvents(events) {
+  const list = document.getElementById("events");
+  const names = Object.fromEntries(servers.map((server) => [server.id, server.display_name || server.id]));
+  for (const event of events) {
+    const item = document.createElement("li");
+    item.dataset.type = event.type;
+    const time = document.createElement("time");
+    time.dateTime = event.time;
+    time.textContent = formatTime(event.time);
+    const label = document.createElement("strong");
+    label.textContent = [names[event.server] || event.server, EVENT_LABELS[event.type] || event.type].filter(Boolean).join(" ");
+    item.append(time, label, document.createTextNode(` ${describeEvent(event)}`));
+    list.prepend(item);
+  }
+  while (list.children.length > 100) {
+    list.lastElementChild.remove();
+  }
+}
+
+async function loadServers() {
+  servers = await fetchJSON("/data/servers");
+  renderServers();
+}
+
+async function loadEvents() {
+  const events = await fetchJSON(`/data/events?after=${lastEventID}`);
+  if (events.length > 0) {
+    lastEventID = events[events.length - 1].id;
+    renderEvents(events);
+  }
+}
+
+async function loadWhitelist() {
+  if (!(me.user && me.user.admin)) {
+    return;
+  }
+  const server = document.getElementById("whitelist-server").value;
+  const body = document.getElementById("whitelist");
+  if (!server) {
+    body.replaceChildren();
+    return;
+  }
+  try {
+    const entries = await fetchJSON(`/data/servers/${encodeURIComponent(server)}/whitelist`);
+    body.replaceChildren(...entries.map((entry) => {
+      const row = document.createElement("tr");
+      for (const value of [entry.name, entry.uuid, entry.expires_at ? formatTime(entry.expires_at) : "無期限"]) {
+        const cell = document.createElement("td");
+        cell.textContent = value;
+        row.append(cell);
+      }
+      return row;
+    }));
+  } catch (error) {
+    const row = document.createElement("tr");
+    const cell = document.createElement("td");
+    cell.colSpan = 3;
+    cell.textContent = error.message;
+    row.append(cell);
+    body.replaceChildren(row);
+  }
+}
+
+async function runCommand(server, action) {
+  const label = action === "start" ? "起動" : "停止";
+  if (!confirm(`${server.display_name || server.id} を${label}しますか？`)) {
+    return;
+  }
+  busy.add(server.id);
+  renderServers();
+  try {
+    const result = await post(`/data/servers/${encodeURIComponent(server.id)}/${action}`);
+    showNotice(result.status === "pending" ? `${label}を受け付けました（完了待ち）` : `${label}しました`);
+  } catch (error) {
+    showNotice(error.message);
+  } finally {
+    busy.delete(server.id);
+    await refresh();
+  }
+}
+
+async function refresh() {
+  try {
+    await loadServers();
+    await loadEvents();
+  } catch (error) {
+    if (error.status === 401) {
+      showNotice("Discord でログインすると、サーバーの状態を確認できます。");
+      return;
+    }
+    showNotice(`読み込みに失敗しました: ${error.message}`);
+  }
+}
+
+async function init() {
+  me = await fetchJSON("/data/me");
+  renderAccount();
+
+  document.getElementById("logout").addEventListener("click", async () => {
+    await post("/logout").catch(() => {});
+    location.reload();
+  });
+  document.getElementById("whitelist-server").addEventListener("change", loadWhitelist);
+
+  if (!me.user && !me.public) {
+    showNotice("Discord でログインすると、サーバーの状態を確認できます。");
+    return;
+  }
+  await refresh();
+  setInterval(refresh, REFRESH_INTERVAL);
+}
+
+init().catch((error) => showNotice(`読み込みに失敗しました: ${error.message}`));
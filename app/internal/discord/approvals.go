@@ -0,0 +1,558 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// ホワイトリスト申請のモーダル・ボタンの CustomID
+const (
+	whitelistApplyModalPrefix = "whitelist_apply:" // + server オプションの値
+	whitelistApproveAction    = "wl_approve"       // "wl_approve:<申請ID>"
+	whitelistDenyAction       = "wl_deny"          // "wl_deny:<申請ID>"
+)
+
+// requestExpiryInterval は申請の期限切れを確認する間隔
+const requestExpiryInterval = time.Minute
+
+// WhitelistRequest はモデレーターの承認待ちのホワイトリスト申請
+type WhitelistRequest struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	UserName   string    `json:"user_name"`
+	PlayerName string    `json:"player_name"`
+	UUID       string    `json:"uuid"`
+	Server     string    `json:"server,omitempty"` // 空の場合は全サーバー
+	Note       string    `json:"note,omitempty"`
+	ChannelID  string    `json:"channel_id"`
+	MessageID  string    `json:"message_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"` // ゼロ値の場合は期限なし
+}
+
+// requestStore は承認待ちの申請を永続化する
+type requestStore struct {
+	mu       sync.Mutex
+	path     string
+	requests []WhitelistRequest
+}
+
+// newRequestStore は新しい requestStore を作成
+func newRequestStore(path string) *requestStore {
+	return &requestStore{path: path}
+}
+
+// load はファイルから申請一覧を読み込む
+func (r *requestStore) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var requests []WhitelistRequest
+	if _, err := utilities.LoadJSONFile(r.path, &requests); err != nil {
+		return err
+	}
+	r.requests = requests
+	return nil
+}
+
+// saveLocked は申請一覧をファイルに書き込む（呼び出し側でロック済み）
+func (r *requestStore) saveLocked() error {
+	if r.requests == nil {
+		r.requests = []WhitelistRequest{}
+	}
+	return utilities.SaveJSONFile(r.path, r.requests)
+}
+
+// add は申請を追加して保存
+func (r *requestStore) add(request WhitelistRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, request)
+	return r.saveLocked()
+}
+
+// get は指定IDの申請を返す
+func (r *requestStore) get(id string) (WhitelistRequest, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, request := range r.requests {
+		if request.ID == id {
+			return request, true
+		}
+	}
+	return WhitelistRequest{}, false
+}
+
+// find は同じプレイヤー・同じ対象の承認待ちの申請を返す
+func (r *requestStore) find(uuid, server string) (WhitelistRequest, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, request := range r.requests {
+		if request.UUID == uuid && request.Server == server {
+			return request, true
+		}
+	}
+	return WhitelistRequest{}, false
+}
+
+// remove は指定IDの申請を削除して保存
+// 同時に承認・却下された場合に備え、削除できた呼び出し側だけが処理を続ける
+func (r *requestStore) remove(id string) (WhitelistRequest, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for idx, request := range r.requests {
+		if request.ID == id {
+			r.requests = append(r.requests[:idx], r.requests[idx+1:]...)
+			return request, true, r.saveLocked()
+		}
+	}
+	return WhitelistRequest{}, false, nil
+}
+
+// setMessage は申請の投稿先メッセージを記録して保存
+func (r *requestStore) setMessage(id, channelID, messageID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for idx := range r.requests {
+		if r.requests[idx].ID == id {
+			r.requests[idx].ChannelID = channelID
+			r.requests[idx].MessageID = messageID
+			return r.saveLocked()
+		}
+	}
+	return nil
+}
+
+// removeExpired は期限切れの申請を削除して返す
+func (r *requestStore) removeExpired(now time.Time) ([]WhitelistRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var expired []WhitelistRequest
+	kept := make([]WhitelistRequest, 0, len(r.requests))
+	for _, request := range r.requests {
+		if !request.ExpiresAt.IsZero() && now.After(request.ExpiresAt) {
+			expired = append(expired, request)
+			continue
+		}
+		kept = append(kept, request)
+	}
+
+	if len(expired) == 0 {
+		return nil, nil
+	}
+	r.requests = kept
+	return expired, r.saveLocked()
+}
+
+// whitelistApprovalEnabled は承認制が有効か判定
+func (b *Bot) whitelistApprovalEnabled() bool {
+	config := b.settings().WhitelistApproval
+	return config.Enabled && config.ChannelID != ""
+}
+
+// handleWhitelistApply は申請用のモーダルを表示（/whitelist apply、承認制での /whitelist add）
+func (b *Bot) handleWhitelistApply(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	if !b.whitelistApprovalEnabled() {
+		b.respondError(s, i, "ホワイトリストの申請は無効です。/whitelist add を使用してください")
+		return
+	}
+
+	options := optionMap(subcommand.Options)
+	server := optionString(options, "server")
+	if _, err := b.resolveWhitelistTargets(server); err != nil {
+		b.respondError(s, i, err.Error())
+		return
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: whitelistApplyModalPrefix + server,
+			Title:    "Whitelist application",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:  "playername",
+							Label:     "Minecraft player name",
+							Style:     discordgo.TextInputShort,
+							Value:     optionString(options, "playername"),
+							Required:  true,
+							MinLength: 3,
+							MaxLength: 16,
+						},
+					},
+				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:  "note",
+							Label:     "Note for moderators",
+							Style:     discordgo.TextInputParagraph,
+							Required:  false,
+							MaxLength: 500,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to show whitelist application modal")
+	}
+}
+
+// handleWhitelistApplySubmit は申請モーダルの送信を処理し、モデレーター用チャンネルに投稿する
+func (b *Bot) handleWhitelistApplySubmit(s *discordgo.Session, i *discordgo.InteractionCreate, server string, data discordgo.ModalSubmitInteractionData) {
+	if !b.whitelistApprovalEnabled() {
+		b.respondError(s, i, "ホワイトリストの申請は無効です。/whitelist add を使用してください")
+		return
+	}
+
+	// "all" と省略は同じ申請として扱う
+	if strings.EqualFold(server, whitelistAllServers) {
+		server = ""
+	}
+
+	inputs := modalValues(data.Components)
+	playerName := strings.TrimSpace(inputs["playername"])
+	note := strings.TrimSpace(inputs["note"])
+
+	targets, err := b.resolveWhitelistTargets(server)
+	if err != nil {
+		b.respondError(s, i, err.Error())
+		return
+	}
+
+	if !b.deferEphemeral(s, i) {
+		return
+	}
+
+	profile, ok := b.fetchProfile(s, i, playerName, targets)
+	if !ok {
+		return
+	}
+
+	allow_icon := b.settings().Icons["allow"]
+	deny_icon := b.settings().Icons["deny"]
+	uuid := utilities.FormatUUID(profile.ID)
+
+	if err := b.checkLink(i.Member.User.ID, uuid, false); err != nil {
+		b.sendFollowup(s, i, fmt.Sprintf("%s %v", deny_icon, err))
+		return
+	}
+	if _, pending := b.requests.find(uuid, server); pending {
+		b.sendFollowup(s, i, fmt.Sprintf("%s **%s** は既に申請中です", deny_icon, profile.Name))
+		return
+	}
+	if whitelisted, err := isWhitelisted(targets, profile); err == nil && whitelisted {
+		b.sendFollowup(s, i, fmt.Sprintf("%s **%s** は既にホワイトリストに含まれています", allow_icon, profile.Name))
+		return
+	}
+
+	now := time.Now()
+	request := WhitelistRequest{
+		ID:         newID(),
+		UserID:     i.Member.User.ID,
+		UserName:   i.Member.User.Username,
+		PlayerName: profile.Name,
+		UUID:       uuid,
+		Server:     server,
+		Note:       note,
+		CreatedAt:  now,
+	}
+	if expireAfter := b.settings().WhitelistApproval.ExpireAfter; expireAfter > 0 {
+		request.ExpiresAt = now.Add(time.Duration(expireAfter) * time.Second)
+	}
+
+	// 先に保存してからボタン付きで投稿（投稿直後に押されても申請が見つかるように）
+	if err := b.requests.add(request); err != nil {
+		log.Error().Err(err).Msg("Failed to save whitelist request")
+		b.sendFollowup(s, i, fmt.Sprintf("%s エラーが発生しました: %v", deny_icon, err))
+		return
+	}
+
+	channelID := b.settings().WhitelistApproval.ChannelID
+	msg, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{b.requestEmbed(request, "")},
+		Components: requestButtons(request.ID),
+	})
+	if err != nil {
+		log.Error().Err(err).Str("channel_id", channelID).Msg("Failed to post whitelist request")
+		b.requests.remove(request.ID)
+		b.sendFollowup(s, i, fmt.Sprintf("%s 申請を送信できませんでした: %v", deny_icon, err))
+		return
+	}
+	if err := b.requests.setMessage(request.ID, msg.ChannelID, msg.ID); err != nil {
+		log.Error().Err(err).Msg("Failed to save whitelist request message")
+	}
+
+	b.auditLog.Record(audit.Entry{
+		Action:   audit.ActionWhitelistApply,
+		Server:   server,
+		UserID:   i.Member.User.ID,
+		UserName: i.Member.User.Username,
+		Source:   audit.SourceSlashCommand,
+		Outcome:  audit.OutcomeSuccess,
+		Detail:   profile.Name,
+	})
+
+	log.Info().
+		Str("request_id", request.ID).
+		Str("player", profile.Name).
+		Str("user", i.Member.User.Username).
+		Msg("Whitelist request submitted")
+
+	b.sendFollowup(s, i, fmt.Sprintf("%s **%s** のホワイトリスト申請を送信しました。承認されると DM でお知らせします", allow_icon, profile.Name))
+}
+
+// isWhitelisted はすべての対象のホワイトリストにプレイヤーが含まれているか判定
+func isWhitelisted(targets []playerListTarget, profile *utilities.MojangProfile) (bool, error) {
+	for _, target := range targets {
+		uuid := utilities.FormatUUID(profileFor(profile, target).ID)
+		entries, err := utilities.LoadWhitelist(target.path)
+		if err != nil {
+			return false, err
+		}
+		found := false
+		for _, entry := range entries {
+			if strings.EqualFold(entry.UUID, uuid) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// requestButtons は承認・却下ボタンを返す
+func requestButtons(id string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Approve",
+					Style:    discordgo.SuccessButton,
+					CustomID: whitelistApproveAction + ":" + id,
+				},
+				discordgo.Button{
+					Label:    "Deny",
+					Style:    discordgo.DangerButton,
+					CustomID: whitelistDenyAction + ":" + id,
+				},
+			},
+		},
+	}
+}
+
+// requestEmbed は申請の Embed を作る（status が空の場合は承認待ち）
+func (b *Bot) requestEmbed(request WhitelistRequest, status string) *discordgo.MessageEmbed {
+	servers := "すべてのサーバー"
+	if request.Server != "" {
+		servers = b.serverDisplayName(b.resolveServerKey(request.Server))
+	}
+
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "申請者", Value: fmt.Sprintf("<@%s>", request.UserID), Inline: true},
+		{Name: "プレイヤー", Value: fmt.Sprintf("%s\n`%s`", request.PlayerName, request.UUID), Inline: true},
+		{Name: "サーバー", Value: servers, Inline: true},
+	}
+	if request.Note != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "メモ", Value: request.Note})
+	}
+
+	color := 0xf0b232 // Yellow
+	if status == "" {
+		if !request.ExpiresAt.IsZero() {
+			fields = append(fields, &discordgo.MessageEmbedField{Name: "期限", Value: fmt.Sprintf("<t:%d:R>", request.ExpiresAt.Unix())})
+		}
+	} else {
+		color = 0x80848e // Gray
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "結果", Value: status})
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:     "ホワイトリスト申請",
+		Fields:    fields,
+		Color:     color,
+		Timestamp: request.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// handleRequestDecision は承認・却下ボタンを処理
+func (b *Bot) handleRequestDecision(s *discordgo.Session, i *discordgo.InteractionCreate, action, id string) {
+	// 管理者権限チェック
+	if !b.isAdmin(i.Member) {
+		b.respondError(s, i, "この操作には管理者権限が必要です")
+		return
+	}
+
+	request, ok := b.requests.get(id)
+	if !ok {
+		b.respondError(s, i, "この申請は既に処理されたか、期限切れです")
+		return
+	}
+
+	var targets []playerListTarget
+	if action == whitelistApproveAction {
+		var err error
+		targets, err = b.resolveWhitelistTargets(request.Server)
+		if err != nil {
+			b.respondError(s, i, err.Error())
+			return
+		}
+	}
+
+	// 他のモデレーターと同時に押された場合は先に削除できた方だけが処理する
+	request, ok, err := b.requests.remove(id)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to save whitelist requests")
+	}
+	if !ok {
+		b.respondError(s, i, "この申請は既に処理されたか、期限切れです")
+		return
+	}
+
+	// ファイル書き込みと RCON に時間がかかる場合があるため先に応答
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send deferred response")
+	}
+
+	moderator := fmt.Sprintf("<@%s>", i.Member.User.ID)
+	var status, dm string
+	if action == whitelistApproveAction {
+		profile := &utilities.MojangProfile{ID: request.UUID, Name: request.PlayerName}
+		results := b.writeWhitelist(interactionActor(i), targets, audit.ActionWhitelistAdd, profile, request.UserID, nil)
+		b.linkPlayer(request.UserID, i.Member.User.ID, profile)
+		status = fmt.Sprintf("承認 (%s)\n%s", moderator, b.formatPlayerListResults(audit.ActionWhitelistAdd, request.PlayerName, results))
+		dm = fmt.Sprintf("**%s** のホワイトリスト申請が承認されました", request.PlayerName)
+		for _, r := range results {
+			if r.err != nil {
+				dm = fmt.Sprintf("**%s** のホワイトリスト申請は承認されましたが、一部のサーバーで追加に失敗しました。管理者にお問い合わせください", request.PlayerName)
+				break
+			}
+		}
+	} else {
+		b.auditLog.Record(audit.Entry{
+			Action:   audit.ActionWhitelistDeny,
+			Server:   request.Server,
+			UserID:   i.Member.User.ID,
+			UserName: i.Member.User.Username,
+			Source:   audit.SourceButton,
+			Outcome:  audit.OutcomeSuccess,
+			Detail:   fmt.Sprintf("%s (requested by %s)", request.PlayerName, request.UserName),
+		})
+		status = fmt.Sprintf("却下 (%s)", moderator)
+		dm = fmt.Sprintf("**%s** のホワイトリスト申請は却下されました", request.PlayerName)
+	}
+
+	embeds := []*discordgo.MessageEmbed{b.requestEmbed(request, status)}
+	components := []discordgo.MessageComponent{}
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds:     &embeds,
+		Components: &components,
+	}); err != nil {
+		log.Error().Err(err).Str("request_id", request.ID).Msg("Failed to update whitelist request message")
+	}
+
+	log.Info().
+		Str("request_id", request.ID).
+		Str("player", request.PlayerName).
+		Str("decision", action).
+		Str("moderator", i.Member.User.Username).
+		Msg("Whitelist request decided")
+
+	b.notifyRequester(request, dm)
+}
+
+// notifyRequester は申請者に DM で結果を知らせる
+func (b *Bot) notifyRequester(request WhitelistRequest, content string) {
+	b.sendDirectMessage(request.UserID, content)
+}
+
+// sendDirectMessage はユーザーに DM を送る（DM を受け付けていない場合は諦める）
+func (b *Bot) sendDirectMessage(userID, content string) {
+	channel, err := b.session.UserChannelCreate(userID)
+	if err == nil {
+		_, err = b.session.ChannelMessageSend(channel.ID, content)
+	}
+	if err != nil {
+		log.Debug().Err(err).Str("user_id", userID).Msg("Failed to send direct message")
+	}
+}
+
+// runRequestExpiry は期限切れの申請を定期的に片付ける
+func (b *Bot) runRequestExpiry(ctx context.Context) {
+	ticker := time.NewTicker(requestExpiryInterval)
+	defer ticker.Stop()
+
+	// 停止中に期限が切れたものを先に片付ける
+	b.expireRequests()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.expireRequests()
+		}
+	}
+}
+
+// expireRequests は期限切れの申請を削除し、投稿と申請者に知らせる
+func (b *Bot) expireRequests() {
+	expired, err := b.requests.removeExpired(time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to save whitelist requests")
+	}
+
+	for _, request := range expired {
+		b.auditLog.Record(audit.Entry{
+			Action:  audit.ActionWhitelistDeny,
+			Server:  request.Server,
+			Source:  audit.SourceSchedule,
+			Outcome: audit.OutcomeSuccess,
+			Detail:  fmt.Sprintf("%s (requested by %s, expired)", request.PlayerName, request.UserName),
+		})
+
+		if request.MessageID != "" {
+			embeds := []*discordgo.MessageEmbed{b.requestEmbed(request, "期限切れ")}
+			components := []discordgo.MessageComponent{}
+			_, err := b.session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+				Channel:    request.ChannelID,
+				ID:         request.MessageID,
+				Embeds:     &embeds,
+				Components: &components,
+			})
+			if err != nil {
+				log.Debug().Err(err).Str("request_id", request.ID).Msg("Failed to update expired whitelist request message")
+			}
+		}
+
+		log.Info().
+			Str("request_id", request.ID).
+			Str("player", request.PlayerName).
+			Msg("Whitelist request expired")
+
+		b.notifyRequester(request, fmt.Sprintf("**%s** のホワイトリスト申請は期限切れになりました。必要であれば再度申請してください", request.PlayerName))
+	}
+}
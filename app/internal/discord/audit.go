@@ -0,0 +1,367 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// auditCommandDefinition は /mc-audit コマンドの定義を返す
+func (b *Bot) auditCommandDefinition() *discordgo.ApplicationCommand {
+	minLimit := 1.0
+
+	return &discordgo.ApplicationCommand{
+		Name:        "mc-audit",
+		Description: "Show recent operator actions (Admin only)",
+		NameLocalizations: &map[discordgo.Locale]string{
+			discordgo.Japanese: "mc-監査ログ",
+		},
+		DescriptionLocalizations: &map[discordgo.Locale]string{
+			discordgo.Japanese: "最近の操作履歴を表示（管理者のみ）",
+		},
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "server",
+				Description: "Filter by server",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "サーバー",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "サーバーで絞り込み",
+				},
+				Autocomplete: true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "Filter by user",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "ユーザー",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "ユーザーで絞り込み",
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "action",
+				Description: "Filter by action",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "操作",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "操作の種類で絞り込み",
+				},
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "start", Value: audit.ActionStart},
+					{Name: "stop", Value: audit.ActionStop},
+					{Name: "restart", Value: audit.ActionRestart},
+					{Name: "whitelist add", Value: audit.ActionWhitelistAdd},
+					{Name: "whitelist remove", Value: audit.ActionWhitelistRemove},
+					{Name: "whitelist apply", Value: audit.ActionWhitelistApply},
+					{Name: "whitelist deny", Value: audit.ActionWhitelistDeny},
+					{Name: "whitelist rename", Value: audit.ActionWhitelistRename},
+					{Name: "whitelist import", Value: audit.ActionWhitelistImport},
+					{Name: "whitelist sync", Value: audit.ActionWhitelistSync},
+					{Name: "settings change", Value: audit.ActionSettingsChange},
+					{Name: "op add", Value: audit.ActionOpAdd},
+					{Name: "op remove", Value: audit.ActionOpRemove},
+					{Name: "ban", Value: audit.ActionBanAdd},
+					{Name: "pardon", Value: audit.ActionBanRemove},
+					{Name: "ban ip", Value: audit.ActionBanIPAdd},
+					{Name: "pardon ip", Value: audit.ActionBanIPRemove},
+					{Name: "maintenance lock", Value: audit.ActionMaintenanceLock},
+					{Name: "maintenance unlock", Value: audit.ActionMaintenanceUnlock},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "source",
+				Description: "Filter by source",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "発生元",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "発生元で絞り込み",
+				},
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "slash command", Value: string(audit.SourceSlashCommand)},
+					{Name: "button", Value: string(audit.SourceButton)},
+					{Name: "schedule", Value: string(audit.SourceSchedule)},
+					{Name: "auto shutdown", Value: string(audit.SourceAutoShutdown)},
+					{Name: "settings file", Value: string(audit.SourceSettingsFile)},
+					{Name: "signal", Value: string(audit.SourceSignal)},
+					{Name: "member event", Value: string(audit.SourceMemberEvent)},
+					{Name: "http api", Value: string(audit.SourceAPI)},
+					{Name: "dashboard", Value: string(audit.SourceDashboard)},
+					{Name: "slack", Value: string(audit.SourceSlack)},
+					{Name: "matrix", Value: string(audit.SourceMatrix)},
+					{Name: "telegram", Value: string(audit.SourceTelegram)},
+					{Name: "cli", Value: string(audit.SourceCLI)},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "limit",
+				Description: "Number of entries to show (default 10)",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "件数",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "表示する件数（デフォルト 10）",
+				},
+				MinValue: &minLimit,
+				MaxValue: 25,
+			},
+		},
+	}
+}
+
+// handleAuditCommand は /mc-audit コマンドを処理
+func (b *Bot) handleAuditCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// 管理者権限チェック
+	if !b.isAdmin(i.Member) {
+		b.respondError(s, i, "この操作には管理者権限が必要です")
+		return
+	}
+
+	filter := audit.Filter{}
+	limit := 10
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "server":
+			filter.Server = b.resolveServerKey(opt.StringValue())
+		case "user":
+			filter.UserID = opt.UserValue(nil).ID
+		case "action":
+			filter.Action = opt.StringValue()
+		case "source":
+			filter.Source = audit.Source(opt.StringValue())
+		case "limit":
+			limit = int(opt.IntValue())
+		}
+	}
+
+	entries, err := b.auditLog.Recent(filter, limit)
+	if err != nil {
+		b.respondError(s, i, fmt.Sprintf("エラーが発生しました: %v", err))
+		return
+	}
+
+	var builder strings.Builder
+	if len(entries) == 0 {
+		builder.WriteString("No matching entries.")
+	}
+	for _, e := range entries {
+		builder.WriteString(b.formatAuditLine(e))
+		builder.WriteString("\n")
+	}
+
+	// レスポンスを送信 (ephemeral, message_deleteafter は適用しない)
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Title:       "📜 Audit Log",
+					Description: builder.String(),
+					Color:       0x5865f2,
+					Footer: &discordgo.MessageEmbedFooter{
+						Text: fmt.Sprintf("%d entries", len(entries)),
+					},
+				},
+			},
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to respond to audit command")
+	}
+}
+
+// formatAuditLine は監査ログエントリを1行に整形
+func (b *Bot) formatAuditLine(e audit.Entry) string {
+	user := "system"
+	switch {
+	case utilities.IsSnowflake(e.UserID):
+		user = fmt.Sprintf("<@%s>", e.UserID)
+	case e.UserName != "":
+		// Slack・Matrix・Telegram のユーザーはメンションできない
+		user = e.UserName
+	case e.UserID != "":
+		user = e.UserID
+	}
+
+	line := fmt.Sprintf("%s <t:%d:f> `%s`", b.outcomeIcon(e.Outcome), e.Time.Unix(), e.Action)
+	if e.Server != "" {
+		line += " " + b.serverDisplayName(e.Server)
+	}
+	if e.Detail != "" {
+		line += fmt.Sprintf(" (%s)", e.Detail)
+	}
+	line += fmt.Sprintf(" — %s via %s", user, e.Source)
+	if e.Error != "" {
+		line += fmt.Sprintf("\n　↳ %s", e.Error)
+	}
+	return line
+}
+
+// PostAuditEntry は監査ログエントリを監査チャンネルへ投稿
+func (b *Bot) PostAuditEntry(e audit.Entry) {
+	channelID := b.settings().Audit.ChannelID
+	if channelID == "" || b.session == nil {
+		return
+	}
+
+	color := 0x79d683 // Green
+	switch e.Outcome {
+	case audit.OutcomeFailure:
+		color = 0xed4245 // Red
+	case audit.OutcomeRejected:
+		color = 0xfee75c // Yellow
+	}
+
+	_, err := b.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Description: b.formatAuditLine(e),
+				Color:       color,
+				Timestamp:   e.Time.Format(time.RFC3339),
+			},
+		},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		log.Error().Err(err).Str("channel_id", channelID).Msg("Failed to post audit entry")
+	}
+}
+
+// PostAlert はエージェント自体の異常とその回復を監査チャンネルへ投稿
+func (b *Bot) PostAlert(alert state.Alert) {
+	channelID := b.settings().Audit.ChannelID
+	if channelID == "" || b.session == nil {
+		return
+	}
+
+	title := "🚨 Alert"
+	color := 0xed4245 // Red
+	if alert.Level == "info" {
+		title = "✅ Recovered"
+		color = 0x79d683 // Green
+	}
+
+	_, err := b.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Title:       title,
+				Description: alert.Message,
+				Color:       color,
+				Timestamp:   time.Now().Format(time.RFC3339),
+			},
+		},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		log.Error().Err(err).Str("channel_id", channelID).Msg("Failed to post alert")
+	}
+}
+
+// outcomeIcon は結果に対応する絵文字を返す
+func (b *Bot) outcomeIcon(outcome audit.Outcome) string {
+	switch outcome {
+	case audit.OutcomeSuccess:
+		if icon, ok := b.settings().Icons["allow"]; ok {
+			return icon
+		}
+		return "✅"
+	case audit.OutcomeFailure:
+		return "⚠️"
+	default:
+		if icon, ok := b.settings().Icons["deny"]; ok {
+			return icon
+		}
+		return "⛔"
+	}
+}
+
+// serverDisplayName はコンテナキーから表示名を返す（未登録ならキーそのまま）
+func (b *Bot) serverDisplayName(key string) string {
+	if config, ok := b.settings().RegisteredContainers[key]; ok {
+		return config.DisplayName
+	}
+	return key
+}
+
+// interactionSource はインタラクションの種類から監査ログの発生元を判定
+func interactionSource(i *discordgo.InteractionCreate) audit.Source {
+	if i.Type == discordgo.InteractionMessageComponent {
+		return audit.SourceButton
+	}
+	return audit.SourceSlashCommand
+}
+
+// rejectCommand は事前チェックで拒否したコマンドを記録してエラーを返す
+func (b *Bot) rejectCommand(s *discordgo.Session, i *discordgo.InteractionCreate, action, containerID, message string) {
+	b.auditLog.Record(audit.Entry{
+		Action:   action,
+		Server:   containerID,
+		UserID:   i.Member.User.ID,
+		UserName: i.Member.User.Username,
+		Source:   interactionSource(i),
+		Outcome:  audit.OutcomeRejected,
+		Detail:   message,
+	})
+	b.appState.Events().Publish(state.Event{
+		Type:   state.EventCommandRejected,
+		Server: containerID,
+		Command: &state.CommandEvent{
+			Action:   action,
+			Source:   string(interactionSource(i)),
+			UserID:   i.Member.User.ID,
+			UserName: i.Member.User.Username,
+			Error:    message,
+		},
+	})
+	b.respondError(s, i, message)
+}
+
+// interactionActor はインタラクションの実行者と発生元を監査ログエントリの形で返す
+func interactionActor(i *discordgo.InteractionCreate) audit.Entry {
+	return audit.Entry{
+		UserID:   i.Member.User.ID,
+		UserName: i.Member.User.Username,
+		Source:   interactionSource(i),
+	}
+}
+
+// recordPlayerListChange はプレイヤーリスト（ホワイトリスト / OP / BAN）の変更を監査ログに記録
+// actor には実行者と発生元を設定しておく（actor.Detail は補足として detail の後に付ける）
+// changed が false の場合（既に存在/存在しない）は記録しない
+func (b *Bot) recordPlayerListChange(actor audit.Entry, action, server, detail string, changed bool, err error) {
+	if err == nil && !changed {
+		return
+	}
+
+	entry := actor
+	entry.Action = action
+	entry.Server = server
+	entry.Outcome = audit.OutcomeSuccess
+	entry.Detail = detail
+	if actor.Detail != "" {
+		entry.Detail = fmt.Sprintf("%s (%s)", detail, actor.Detail)
+	}
+	if err != nil {
+		entry.Outcome = audit.OutcomeFailure
+		entry.Error = err.Error()
+	}
+	b.auditLog.Record(entry)
+}
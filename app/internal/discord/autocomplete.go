@@ -0,0 +1,246 @@
+package discord
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// maxAutocompleteChoices は Discord が受け付ける候補数の上限
+const maxAutocompleteChoices = 25
+
+// serverStatusFilter はコマンドごとに候補に含めるサーバーの状態を返す
+// nil の場合は状態で絞り込まない
+func serverStatusFilter(commandName string) func(container.WorkingStatus) bool {
+	switch commandName {
+	case "mc-start":
+		return func(s container.WorkingStatus) bool {
+			return s == container.StatusStopped
+		}
+	case "mc-stop":
+		return func(s container.WorkingStatus) bool {
+			return s == container.StatusRunning || s == container.StatusStarting
+		}
+	default:
+		return nil
+	}
+}
+
+// handleAutocomplete はオプション入力中の候補を返す
+func (b *Bot) handleAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+
+	focused := findFocusedOption(data.Options)
+	if focused == nil {
+		return
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	switch focused.Name {
+	case "server":
+		choices = b.serverChoices(focused.StringValue(), serverStatusFilter(data.Name))
+		if data.Name == "whitelist" || data.Name == "mc-op" || data.Name == "mc-ban" {
+			choices = withAllServersChoice(focused.StringValue(), choices)
+		}
+	case "from":
+		choices = b.serverChoices(focused.StringValue(), nil)
+	case "servers":
+		choices = b.serverListChoices(focused.StringValue())
+	case "key":
+		choices = b.settingKeyChoices(focused.StringValue())
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		log.Debug().Err(err).Str("command", data.Name).Msg("Failed to respond to autocomplete")
+	}
+}
+
+// findFocusedOption は入力中のオプションを探す（サブコマンドの中も探索）
+func findFocusedOption(options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	for _, opt := range options {
+		if opt.Focused {
+			return opt
+		}
+		if found := findFocusedOption(opt.Options); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// serverCandidate は候補となるサーバー
+type serverCandidate struct {
+	key         string
+	displayName string
+	score       int
+}
+
+// serverChoices は現在の設定と状態から入力に一致するサーバーの候補を返す
+func (b *Bot) serverChoices(query string, statusFilter func(container.WorkingStatus) bool) []*discordgo.ApplicationCommandOptionChoice {
+	candidates := b.matchServers(query, statusFilter)
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(candidates))
+	for _, c := range candidates {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  c.displayName,
+			Value: c.key,
+		})
+	}
+	return choices
+}
+
+// withAllServersChoice は全サーバーを表す候補を先頭に加える
+func withAllServersChoice(query string, choices []*discordgo.ApplicationCommandOptionChoice) []*discordgo.ApplicationCommandOptionChoice {
+	if fuzzyScore(query, whitelistAllServers) == 0 {
+		return choices
+	}
+
+	all := &discordgo.ApplicationCommandOptionChoice{
+		Name:  "All servers",
+		Value: whitelistAllServers,
+	}
+	choices = append([]*discordgo.ApplicationCommandOptionChoice{all}, choices...)
+	if len(choices) > maxAutocompleteChoices {
+		choices = choices[:maxAutocompleteChoices]
+	}
+	return choices
+}
+
+// serverListChoices はカンマ区切りのサーバー指定の最後の要素を補完する
+func (b *Bot) serverListChoices(input string) []*discordgo.ApplicationCommandOptionChoice {
+	prefix := ""
+	query := input
+	if idx := strings.LastIndex(input, ","); idx >= 0 {
+		prefix = input[:idx+1]
+		query = strings.TrimSpace(input[idx+1:])
+	}
+
+	// 既に指定済みのサーバーは除外
+	selected := map[string]bool{}
+	for _, key := range strings.Split(prefix, ",") {
+		selected[strings.TrimSpace(key)] = true
+	}
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0)
+	for _, c := range b.matchServers(query, nil) {
+		if selected[c.key] {
+			continue
+		}
+		value := prefix + c.key
+		name := c.displayName
+		if prefix != "" {
+			name = strings.TrimSuffix(prefix, ",") + ", " + c.displayName
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  name,
+			Value: value,
+		})
+	}
+	return choices
+}
+
+// matchServers は登録済みサーバーを入力との一致度順に返す（最大 maxAutocompleteChoices 件）
+func (b *Bot) matchServers(query string, statusFilter func(container.WorkingStatus) bool) []serverCandidate {
+	settings := b.appState.GetSettings()
+
+	candidates := make([]serverCandidate, 0, len(settings.RegisteredContainers))
+	for key, config := range settings.RegisteredContainers {
+		status := container.StatusUnknown
+		if stateObj, ok := b.appState.GetContainer(key); ok {
+			if cont, ok := stateObj.(*container.Container); ok {
+				status = cont.Status
+			}
+		}
+
+		if statusFilter != nil && !statusFilter(status) {
+			continue
+		}
+
+		score := max(fuzzyScore(query, key), fuzzyScore(query, config.DisplayName))
+		if score == 0 {
+			continue
+		}
+
+		candidates = append(candidates, serverCandidate{
+			key:         key,
+			displayName: config.DisplayName,
+			score:       score,
+		})
+	}
+
+	sort.Slice(candidates, func(a, c int) bool {
+		if candidates[a].score != candidates[c].score {
+			return candidates[a].score > candidates[c].score
+		}
+		return candidates[a].key < candidates[c].key
+	})
+
+	if len(candidates) > maxAutocompleteChoices {
+		candidates = candidates[:maxAutocompleteChoices]
+	}
+	return candidates
+}
+
+// fuzzyScore は入力と対象文字列の一致度を返す（0 は不一致）
+// 完全一致 > 前方一致 > 部分一致 > 文字の順序一致（例: "srv" → "survival"）
+func fuzzyScore(query, target string) int {
+	q := strings.ToLower(strings.TrimSpace(query))
+	t := strings.ToLower(target)
+
+	switch {
+	case q == "":
+		return 1
+	case q == t:
+		return 100
+	case strings.HasPrefix(t, q):
+		return 80
+	case strings.Contains(t, q):
+		return 60
+	}
+
+	// 文字が順番通りに含まれているか（間が空いているほど低スコア）
+	targetRunes := []rune(t)
+	pos := 0
+	gaps := 0
+	for _, r := range q {
+		found := false
+		for pos < len(targetRunes) {
+			if targetRunes[pos] == r {
+				found = true
+				pos++
+				break
+			}
+			pos++
+			gaps++
+		}
+		if !found {
+			return 0
+		}
+	}
+
+	return max(40-gaps, 2)
+}
+
+// resolveServerKey は入力値をコンテナキーに解決する
+// 候補を選ばずに表示名を直接入力された場合も受け付ける
+func (b *Bot) resolveServerKey(value string) string {
+	settings := b.appState.GetSettings()
+	if _, ok := settings.RegisteredContainers[value]; ok {
+		return value
+	}
+	for key, config := range settings.RegisteredContainers {
+		if strings.EqualFold(config.DisplayName, value) || strings.EqualFold(key, value) {
+			return key
+		}
+	}
+	return value
+}
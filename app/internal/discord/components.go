@@ -3,10 +3,12 @@ package discord
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"sort"
 	"time"
 
 	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -32,22 +34,33 @@ func parseEmoji(emojiStr string) *discordgo.ComponentEmoji {
 	}
 }
 
+// filterServers はソート済みのコンテナIDのうち filter に含まれるものを返す
+// filter が空の場合はすべて返す
+func filterServers(containers map[string]state.Container, filter []string) []string {
+	ids := make([]string, 0, len(containers))
+	for id := range containers {
+		if len(filter) > 0 && !slices.Contains(filter, id) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 // buildStatusEmbed はコンテナステータスの Embed を構築
-func (b *Bot) buildStatusEmbed() *discordgo.MessageEmbed {
+// filter を指定した場合はそのサーバーのみ表示
+func (b *Bot) buildStatusEmbed(filter []string) *discordgo.MessageEmbed {
 	containers := b.appState.GetAllContainers()
 
 	fields := make([]*discordgo.MessageEmbedField, 0, len(containers))
 
 	// コンテナをIDでソート
-	ids := make([]string, 0, len(containers))
-	for id := range containers {
-		ids = append(ids, id)
-	}
-	sort.Strings(ids)
+	ids := filterServers(containers, filter)
 
 	for _, id := range ids {
 		containerInterface := containers[id]
-		config, ok := b.settings.RegisteredContainers[id]
+		config, ok := b.settings().RegisteredContainers[id]
 		if !ok {
 			continue
 		}
@@ -64,7 +77,7 @@ func (b *Bot) buildStatusEmbed() *discordgo.MessageEmbed {
 
 		// アイコン取得
 		icon := config.Icon
-		if iconURL, ok := b.settings.Icons[icon]; ok {
+		if iconURL, ok := b.settings().Icons[icon]; ok {
 			icon = iconURL
 		}
 
@@ -111,16 +124,18 @@ func (b *Bot) buildStatusEmbed() *discordgo.MessageEmbed {
 }
 
 // buildActionButtons はアクションボタンを構築
-func (b *Bot) buildActionButtons() []discordgo.MessageComponent {
-	if !b.settings.AllowedActions.PlaceButtons {
+// filter を指定した場合はそのサーバーのみ表示
+func (b *Bot) buildActionButtons(filter []string) []discordgo.MessageComponent {
+	if !b.settings().AllowedActions.PlaceButtons {
 		return nil
 	}
 
 	containers := b.appState.GetAllContainers()
 	rows := make([]discordgo.MessageComponent, 0)
 
-	for id, containerInterface := range containers {
-		config, ok := b.settings.RegisteredContainers[id]
+	for _, id := range filterServers(containers, filter) {
+		containerInterface := containers[id]
+		config, ok := b.settings().RegisteredContainers[id]
 		if !ok {
 			continue
 		}
@@ -139,18 +154,18 @@ func (b *Bot) buildActionButtons() []discordgo.MessageComponent {
 
 		// Start ボタン用の絵文字取得
 		startEmoji := "▶️"
-		if icon, ok := b.settings.Icons["poweron_mono"]; ok {
+		if icon, ok := b.settings().Icons["poweron_mono"]; ok {
 			startEmoji = icon
 		}
 
 		// Stop ボタン用の絵文字取得
 		stopEmoji := "⏹️"
-		if icon, ok := b.settings.Icons["poweroff_mono"]; ok {
+		if icon, ok := b.settings().Icons["poweroff_mono"]; ok {
 			stopEmoji = icon
 		}
 
 		// Start ボタン
-		if b.settings.AllowedActions.PowerOn && cont.Status != container.StatusRunning {
+		if b.settings().AllowedActions.PowerOn && cont.Status != container.StatusRunning {
 			buttons = append(buttons, discordgo.Button{
 				Label:    "Start",
 				Style:    discordgo.SuccessButton,
@@ -160,7 +175,7 @@ func (b *Bot) buildActionButtons() []discordgo.MessageComponent {
 		}
 
 		// Stop ボタン
-		if b.settings.AllowedActions.PowerOff && cont.Status == container.StatusRunning {
+		if b.settings().AllowedActions.PowerOff && cont.Status == container.StatusRunning {
 			buttons = append(buttons, discordgo.Button{
 				Label:    "Stop",
 				Style:    discordgo.DangerButton,
@@ -192,7 +207,7 @@ func (b *Bot) buildActionButtons() []discordgo.MessageComponent {
 	// if len(rows) > 0 {
 	// 	// Refresh アイコン取得
 	// 	refreshEmoji := "🔄"
-	// 	if icon, ok := b.settings.Icons["reload_mono"]; ok {
+	// 	if icon, ok := b.settings().Icons["reload_mono"]; ok {
 	// 		refreshEmoji = icon
 	// 	}
 
@@ -215,22 +230,22 @@ func (b *Bot) buildActionButtons() []discordgo.MessageComponent {
 func (b *Bot) getStatusIcon(status container.WorkingStatus) string {
 	switch status {
 	case container.StatusRunning:
-		if icon, ok := b.settings.Icons["poweron"]; ok {
+		if icon, ok := b.settings().Icons["poweron"]; ok {
 			return icon
 		}
 		return "🟢"
 	case container.StatusStarting:
-		if icon, ok := b.settings.Icons["reload"]; ok {
+		if icon, ok := b.settings().Icons["reload"]; ok {
 			return icon
 		}
 		return "🟡"
 	case container.StatusStopped:
-		if icon, ok := b.settings.Icons["poweroff"]; ok {
+		if icon, ok := b.settings().Icons["poweroff"]; ok {
 			return icon
 		}
 		return "🔴"
 	case container.StatusNotFound:
-		if icon, ok := b.settings.Icons["deny"]; ok {
+		if icon, ok := b.settings().Icons["deny"]; ok {
 			return icon
 		}
 		return "❓"
@@ -0,0 +1,515 @@
+package discord
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// configEditModalPrefix はサーバー設定編集モーダルの CustomID の接頭辞
+const configEditModalPrefix = "config_edit:"
+
+// configCommandDefinition は /mc-config コマンドの定義を返す
+func (b *Bot) configCommandDefinition() *discordgo.ApplicationCommand {
+	keyOption := func(required bool) *discordgo.ApplicationCommandOption {
+		return &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "key",
+			Description: "Setting key path (e.g. regular_task.auto_shutdown_delay)",
+			NameLocalizations: map[discordgo.Locale]string{
+				discordgo.Japanese: "キー",
+			},
+			DescriptionLocalizations: map[discordgo.Locale]string{
+				discordgo.Japanese: "設定のキー（例: regular_task.auto_shutdown_delay）",
+			},
+			Required:     required,
+			Autocomplete: true,
+		}
+	}
+	serverOption := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "server",
+		Description: "Registered server",
+		NameLocalizations: map[discordgo.Locale]string{
+			discordgo.Japanese: "サーバー",
+		},
+		DescriptionLocalizations: map[discordgo.Locale]string{
+			discordgo.Japanese: "登録済みのサーバー",
+		},
+		Required:     true,
+		Autocomplete: true,
+	}
+
+	return &discordgo.ApplicationCommand{
+		Name:        "mc-config",
+		Description: "Manage settings.json (Admin only)",
+		NameLocalizations: &map[discordgo.Locale]string{
+			discordgo.Japanese: "mc-設定",
+		},
+		DescriptionLocalizations: &map[discordgo.Locale]string{
+			discordgo.Japanese: "settings.json を管理（管理者のみ）",
+		},
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "get",
+				Description: "Show a setting value",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "表示",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "設定値を表示",
+				},
+				Options: []*discordgo.ApplicationCommandOption{keyOption(false)},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "set",
+				Description: "Change a setting value",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "変更",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "設定値を変更",
+				},
+				Options: []*discordgo.ApplicationCommandOption{
+					keyOption(true),
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "value",
+						Description: "New value (JSON or plain text)",
+						NameLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "値",
+						},
+						DescriptionLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "新しい値（JSON またはテキスト）",
+						},
+						Required: true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "register",
+				Description: "Register a new server",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "登録",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "サーバーを新しく登録",
+				},
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "id",
+						Description: "Key used in registered_containers",
+						NameLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "id",
+						},
+						DescriptionLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "registered_containers のキー",
+						},
+						Required: true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "container_name",
+						Description: "Docker container name",
+						NameLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "コンテナ名",
+						},
+						DescriptionLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "Docker のコンテナ名",
+						},
+						Required: true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "display_name",
+						Description: "Name shown in Discord",
+						NameLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "表示名",
+						},
+						DescriptionLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "Discord に表示する名前",
+						},
+						Required: true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "path",
+						Description: "Server data directory",
+						NameLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "パス",
+						},
+						DescriptionLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "サーバーデータのディレクトリ",
+						},
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "icon",
+						Description: "Emoji shown next to the server",
+						NameLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "アイコン",
+						},
+						DescriptionLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "サーバー名の横に表示する絵文字",
+						},
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+						Name:        "auto_shutdown",
+						Description: "Stop automatically when nobody is online",
+						NameLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "自動停止",
+						},
+						DescriptionLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "誰もいないときに自動で停止する",
+						},
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "unregister",
+				Description: "Unregister a server",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "登録解除",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "サーバーの登録を解除",
+				},
+				Options: []*discordgo.ApplicationCommandOption{serverOption},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "edit",
+				Description: "Edit a server's settings in a form",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "編集",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "サーバーの設定をフォームで編集",
+				},
+				Options: []*discordgo.ApplicationCommandOption{serverOption},
+			},
+		},
+	}
+}
+
+// handleConfigCommand は /mc-config コマンドを処理
+func (b *Bot) handleConfigCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// 管理者権限チェック
+	if !b.isAdmin(i.Member) {
+		b.respondError(s, i, "この操作には管理者権限が必要です")
+		return
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		b.respondError(s, i, "Subcommand is required")
+		return
+	}
+
+	subCommand := options[0]
+	values := optionMap(subCommand.Options)
+
+	switch subCommand.Name {
+	case "get":
+		b.handleConfigGet(s, i, optionString(values, "key"))
+
+	case "set":
+		key := strings.TrimSpace(values["key"].StringValue())
+		raw := values["value"].StringValue()
+		// 環境変数で上書きされているキーはファイルを変えても反映されない
+		if env, overridden := utilities.SettingEnvOverride(key); overridden {
+			b.respondError(s, i, fmt.Sprintf("%s is overridden by environment variable %s", key, env))
+			return
+		}
+		b.applyConfigChange(s, i, "", fmt.Sprintf("set %s", key), func(settings *utilities.Settings) (*utilities.Settings, error) {
+			return utilities.SetSettingValue(settings, key, raw)
+		})
+
+	case "register":
+		id := strings.TrimSpace(values["id"].StringValue())
+		config := utilities.ContainerConfig{
+			ContainerName: strings.TrimSpace(values["container_name"].StringValue()),
+			DisplayName:   strings.TrimSpace(values["display_name"].StringValue()),
+		}
+		if opt, ok := values["path"]; ok {
+			config.Path = strings.TrimSpace(opt.StringValue())
+		}
+		if opt, ok := values["icon"]; ok {
+			config.Icon = strings.TrimSpace(opt.StringValue())
+		}
+		if opt, ok := values["auto_shutdown"]; ok {
+			config.AutoShutdown = opt.BoolValue()
+		}
+		b.applyConfigChange(s, i, id, "register", func(settings *utilities.Settings) (*utilities.Settings, error) {
+			if id == "" {
+				return nil, fmt.Errorf("id is required")
+			}
+			if _, exists := settings.RegisteredContainers[id]; exists {
+				return nil, fmt.Errorf("server '%s' is already registered", id)
+			}
+			if settings.RegisteredContainers == nil {
+				settings.RegisteredContainers = make(map[string]utilities.ContainerConfig)
+			}
+			settings.RegisteredContainers[id] = config
+			return settings, nil
+		})
+
+	case "unregister":
+		id := b.resolveServerKey(values["server"].StringValue())
+		b.applyConfigChange(s, i, id, "unregister", func(settings *utilities.Settings) (*utilities.Settings, error) {
+			if _, exists := settings.RegisteredContainers[id]; !exists {
+				return nil, fmt.Errorf("server '%s' is not registered", id)
+			}
+			delete(settings.RegisteredContainers, id)
+			return settings, nil
+		})
+
+	case "edit":
+		b.showConfigEditModal(s, i, b.resolveServerKey(values["server"].StringValue()))
+
+	default:
+		b.respondError(s, i, "Unknown subcommand")
+	}
+}
+
+// handleConfigGet は設定値を表示する
+func (b *Bot) handleConfigGet(s *discordgo.Session, i *discordgo.InteractionCreate, key string) {
+	value, err := utilities.GetSettingValue(b.settings(), key)
+	if err != nil {
+		b.respondError(s, i, err.Error())
+		return
+	}
+
+	title := key
+	if title == "" {
+		title = "settings.json"
+	}
+
+	// 表示名などの日本語を途中で切らないよう文字数で切り詰める
+	const maxLength = 1800
+	if truncated := truncateRunes(value, maxLength); truncated != value {
+		value = truncated + "\n..."
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("**%s**\n```json\n%s\n```", title, value),
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to respond to config get")
+	}
+}
+
+// showConfigEditModal はサーバー設定の編集モーダルを表示する
+func (b *Bot) showConfigEditModal(s *discordgo.Session, i *discordgo.InteractionCreate, id string) {
+	config, ok := b.settings().RegisteredContainers[id]
+	if !ok {
+		b.respondError(s, i, fmt.Sprintf("Server '%s' is not registered", id))
+		return
+	}
+
+	textInput := func(customID, label, value string, required bool) discordgo.MessageComponent {
+		return discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.TextInput{
+					CustomID: customID,
+					Label:    label,
+					Style:    discordgo.TextInputShort,
+					Value:    value,
+					Required: required,
+				},
+			},
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: configEditModalPrefix + id,
+			Title:    truncateRunes("Edit "+config.DisplayName, 45),
+			Components: []discordgo.MessageComponent{
+				textInput("display_name", "Display name", config.DisplayName, true),
+				textInput("container_name", "Container name", config.ContainerName, true),
+				textInput("path", "Path", config.Path, false),
+				textInput("icon", "Icon", config.Icon, false),
+				textInput("auto_shutdown", "Auto shutdown (true / false)", strconv.FormatBool(config.AutoShutdown), true),
+			},
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Str("server", id).Msg("Failed to show config edit modal")
+	}
+}
+
+// handleModalSubmit はモーダルの送信を処理
+func (b *Bot) handleModalSubmit(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ModalSubmitData()
+
+	log.Info().
+		Str("custom_id", data.CustomID).
+		Str("user", i.Member.User.Username).
+		Msg("Received modal submit")
+
+	switch {
+	case strings.HasPrefix(data.CustomID, configEditModalPrefix):
+		b.handleConfigEditSubmit(s, i, strings.TrimPrefix(data.CustomID, configEditModalPrefix), data)
+	case strings.HasPrefix(data.CustomID, whitelistApplyModalPrefix):
+		b.handleWhitelistApplySubmit(s, i, strings.TrimPrefix(data.CustomID, whitelistApplyModalPrefix), data)
+	default:
+		b.respondError(s, i, "Unknown form")
+	}
+}
+
+// handleConfigEditSubmit はサーバー設定編集モーダルの送信を処理
+func (b *Bot) handleConfigEditSubmit(s *discordgo.Session, i *discordgo.InteractionCreate, id string, data discordgo.ModalSubmitInteractionData) {
+	// モーダル表示後に権限が外れている可能性があるため再チェック
+	if !b.isAdmin(i.Member) {
+		b.respondError(s, i, "この操作には管理者権限が必要です")
+		return
+	}
+
+	inputs := modalValues(data.Components)
+
+	autoShutdown, err := strconv.ParseBool(strings.TrimSpace(inputs["auto_shutdown"]))
+	if err != nil {
+		b.respondError(s, i, "auto_shutdown must be true or false")
+		return
+	}
+
+	b.applyConfigChange(s, i, id, "edit", func(settings *utilities.Settings) (*utilities.Settings, error) {
+		config, exists := settings.RegisteredContainers[id]
+		if !exists {
+			return nil, fmt.Errorf("server '%s' is not registered", id)
+		}
+		// モーダルに無い項目（whitelist_path 等）はそのまま残す
+		config.DisplayName = strings.TrimSpace(inputs["display_name"])
+		config.ContainerName = strings.TrimSpace(inputs["container_name"])
+		config.Path = strings.TrimSpace(inputs["path"])
+		config.Icon = strings.TrimSpace(inputs["icon"])
+		config.AutoShutdown = autoShutdown
+		settings.RegisteredContainers[id] = config
+		return settings, nil
+	})
+}
+
+// modalValues はモーダルの入力値を CustomID ごとに取り出す
+func modalValues(components []discordgo.MessageComponent) map[string]string {
+	values := make(map[string]string)
+	for _, component := range components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, c := range row.Components {
+			if input, ok := c.(*discordgo.TextInput); ok {
+				values[input.CustomID] = input.Value
+			}
+		}
+	}
+	return values
+}
+
+// applyConfigChange は設定ファイルを変更・保存し、再読み込みで即時反映する
+// 変更前の内容は settings.json.bak に残る
+func (b *Bot) applyConfigChange(s *discordgo.Session, i *discordgo.InteractionCreate, server, detail string, modify func(*utilities.Settings) (*utilities.Settings, error)) {
+	// Deferred response
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send deferred response")
+		return
+	}
+
+	deny_icon := b.settings().Icons["deny"]
+
+	if _, _, err := utilities.UpdateSettingsFile("", modify); err != nil {
+		b.auditLog.Record(audit.Entry{
+			Action:   audit.ActionSettingsChange,
+			Server:   server,
+			UserID:   i.Member.User.ID,
+			UserName: i.Member.User.Username,
+			Source:   interactionSource(i),
+			Outcome:  audit.OutcomeFailure,
+			Detail:   detail,
+			Error:    err.Error(),
+		})
+		s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+			Content: fmt.Sprintf("%s 設定を変更できませんでした: %v", deny_icon, err),
+		})
+		return
+	}
+
+	// 保存した内容を再読み込みで反映（差分の監査記録も再読み込み側で行う）
+	result, err := b.requestReload(i)
+	if err != nil {
+		s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+			Content: fmt.Sprintf("%s 設定は保存しましたが反映できませんでした: %v", deny_icon, err),
+		})
+		return
+	}
+
+	allow_icon := b.settings().Icons["allow"]
+	s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content: fmt.Sprintf("%s 設定を変更しました\n%s", allow_icon, formatSettingsDiff(result.Message)),
+	})
+}
+
+// settingKeyChoices は入力に一致する設定キーの候補を返す
+func (b *Bot) settingKeyChoices(query string) []*discordgo.ApplicationCommandOptionChoice {
+	type keyCandidate struct {
+		key   string
+		score int
+	}
+
+	candidates := make([]keyCandidate, 0)
+	for _, key := range utilities.SettingKeyPaths(b.settings()) {
+		if score := fuzzyScore(query, key); score > 0 {
+			candidates = append(candidates, keyCandidate{key: key, score: score})
+		}
+	}
+
+	// スコア順（同点は SettingKeyPaths のソート順を維持）
+	sort.SliceStable(candidates, func(a, c int) bool {
+		return candidates[a].score > candidates[c].score
+	})
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, maxAutocompleteChoices)
+	for _, c := range candidates {
+		if len(choices) >= maxAutocompleteChoices {
+			break
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  truncateRunes(c.key, 100),
+			Value: c.key,
+		})
+	}
+	return choices
+}
+
+// truncateRunes は文字列を最大 n 文字に切り詰める
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
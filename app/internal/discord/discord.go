@@ -2,10 +2,15 @@ package discord
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
 	"sync"
 
-	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/monitoring"
 	"github.com/Koranoa3/mc-server-agent/internal/routine"
 	"github.com/Koranoa3/mc-server-agent/internal/state"
 	"github.com/Koranoa3/mc-server-agent/internal/utilities"
@@ -13,35 +18,85 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// Bot は Discord Bot の管理構造体
+// Bot は Discord Bot の管理構造体（notifier.Notifier の実装の1つ）
 type Bot struct {
 	session     *discordgo.Session
-	settings    *utilities.Settings
 	appState    *state.AppState
+	auditLog    *audit.Logger
 	commandChan chan<- routine.Command
 	guildID     string
 	appID       string
 
+	// 常駐ステータスパネル
+	panels *panelStore
+
+	// 承認待ちのホワイトリスト申請
+	requests *requestStore
+
+	// Discord アカウントと Minecraft アカウントの紐付け
+	links *linkStore
+
+	// Mojang API のプロフィール解決（キャッシュ付き）
+	profiles *utilities.ProfileResolver
+
+	// 確認待ちのホワイトリストのインポート
+	imports *importStore
+
+	// プレゼンス・パネルの更新ワーカー
+	updater *updateWorker
+
 	// コマンド登録情報
 	commands           []*discordgo.ApplicationCommand
 	registeredCommands []*discordgo.ApplicationCommand
+	syncedCommands     []*discordgo.ApplicationCommand // 最後に Discord に登録した定義（変更の検知用）
 	mu                 sync.RWMutex
 }
 
 // NewBot は新しい Discord Bot インスタンスを作成
-func NewBot(token, guildID, appID string, settings *utilities.Settings, appState *state.AppState, commandChan chan<- routine.Command) (*Bot, error) {
+// profiles は HTTP API と共有する（キャッシュファイルを1つのインスタンスで扱うため）
+func NewBot(token, guildID, appID string, appState *state.AppState, auditLog *audit.Logger, profiles *utilities.ProfileResolver, commandChan chan<- routine.Command) (*Bot, error) {
 	session, err := discordgo.New("Bot " + token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Discord session: %w", err)
 	}
+	// REST API のエラーとレート制限をメトリクスに数える
+	session.Client.Transport = monitoring.DiscordTransport(session.Client.Transport)
 
 	bot := &Bot{
 		session:     session,
-		settings:    settings,
 		appState:    appState,
+		auditLog:    auditLog,
+		profiles:    profiles,
 		commandChan: commandChan,
 		guildID:     guildID,
 		appID:       appID,
+		panels:      newPanelStore(utilities.DataPath("panels.json")),
+		requests:    newRequestStore(utilities.DataPath("whitelist_requests.json")),
+		links:       newLinkStore(utilities.DataPath("links.json")),
+		imports:     newImportStore(),
+	}
+
+	bot.updater = newUpdateWorker(bot, updateWindow)
+
+	// 保存済みパネルの読み込み
+	if err := bot.panels.load(); err != nil {
+		log.Error().Err(err).Msg("Failed to load status panels")
+	}
+
+	// 承認待ちの申請の読み込み
+	if err := bot.requests.load(); err != nil {
+		log.Error().Err(err).Msg("Failed to load whitelist requests")
+	}
+
+	// 紐付けの読み込み
+	if err := bot.links.load(); err != nil {
+		log.Error().Err(err).Msg("Failed to load account links")
+	}
+
+	// メンバーの脱退・ロール変更の監視には特権インテント（Server Members Intent）が必要
+	// インテントは接続時に決まるため、設定の再読み込みでは切り替わらない
+	if appState.GetSettings().AccountLinks.WatchesMembers() {
+		session.Identify.Intents = discordgo.IntentsAllWithoutPrivileged | discordgo.IntentsGuildMembers
 	}
 
 	// コマンド定義
@@ -53,6 +108,11 @@ func NewBot(token, guildID, appID string, settings *utilities.Settings, appState
 	return bot, nil
 }
 
+// settings は現在の設定を返す（再読み込みに追従するため毎回 AppState から取得）
+func (b *Bot) settings() *utilities.Settings {
+	return b.appState.GetSettings()
+}
+
 // defineCommands はスラッシュコマンドを定義
 func (b *Bot) defineCommands() {
 	b.commands = []*discordgo.ApplicationCommand{
@@ -96,8 +156,8 @@ func (b *Bot) defineCommands() {
 					DescriptionLocalizations: map[discordgo.Locale]string{
 						discordgo.Japanese: "起動するサーバー",
 					},
-					Required: true,
-					Choices:  b.buildServerChoices(),
+					Required:     true,
+					Autocomplete: true,
 				},
 			},
 		},
@@ -121,8 +181,8 @@ func (b *Bot) defineCommands() {
 					DescriptionLocalizations: map[discordgo.Locale]string{
 						discordgo.Japanese: "停止するサーバー",
 					},
-					Required: true,
-					Choices:  b.buildServerChoices(),
+					Required:     true,
+					Autocomplete: true,
 				},
 			},
 		},
@@ -159,6 +219,40 @@ func (b *Bot) defineCommands() {
 							},
 							Required: true,
 						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "server",
+							Description: "Target server (default: all)",
+							NameLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "サーバー",
+							},
+							DescriptionLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "対象のサーバー（省略時は全サーバー）",
+							},
+							Autocomplete: true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionUser,
+							Name:        "owner",
+							Description: "Discord user to link the player to (Admin only, default: you)",
+							NameLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "所有者",
+							},
+							DescriptionLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "プレイヤーを紐付けるユーザー（管理者のみ、省略時は自分）",
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "duration",
+							Description: "Remove automatically after this period (e.g. 12h, 2d, 1w)",
+							NameLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "期間",
+							},
+							DescriptionLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "この期間が過ぎたら自動的に削除（例: 12h, 2d, 1w）",
+							},
+						},
 					},
 				},
 				{
@@ -184,6 +278,43 @@ func (b *Bot) defineCommands() {
 							},
 							Required: true,
 						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "server",
+							Description: "Target server (default: all)",
+							NameLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "サーバー",
+							},
+							DescriptionLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "対象のサーバー（省略時は全サーバー）",
+							},
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "apply",
+					Description: "Request to be added to the whitelist",
+					NameLocalizations: map[discordgo.Locale]string{
+						discordgo.Japanese: "申請",
+					},
+					DescriptionLocalizations: map[discordgo.Locale]string{
+						discordgo.Japanese: "ホワイトリストへの追加を申請",
+					},
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "server",
+							Description: "Target server (default: all)",
+							NameLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "サーバー",
+							},
+							DescriptionLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "対象のサーバー（省略時は全サーバー）",
+							},
+							Autocomplete: true,
+						},
 					},
 				},
 				{
@@ -196,36 +327,152 @@ func (b *Bot) defineCommands() {
 					DescriptionLocalizations: map[discordgo.Locale]string{
 						discordgo.Japanese: "ホワイトリストを表示（管理者のみ）",
 					},
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "server",
+							Description: "Target server (default: all)",
+							NameLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "サーバー",
+							},
+							DescriptionLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "対象のサーバー（省略時は全サーバー）",
+							},
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "refresh",
+					Description: "Update player names that have changed (Admin only)",
+					NameLocalizations: map[discordgo.Locale]string{
+						discordgo.Japanese: "名前更新",
+					},
+					DescriptionLocalizations: map[discordgo.Locale]string{
+						discordgo.Japanese: "変更されたプレイヤー名を反映（管理者のみ）",
+					},
+					Options: []*discordgo.ApplicationCommandOption{
+						targetServerOption(),
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "sync",
+					Description: "Reconcile the whitelist file with running servers (Admin only)",
+					NameLocalizations: map[discordgo.Locale]string{
+						discordgo.Japanese: "同期",
+					},
+					DescriptionLocalizations: map[discordgo.Locale]string{
+						discordgo.Japanese: "ホワイトリストファイルと稼働中のサーバーのずれを解消（管理者のみ）",
+					},
+					Options: []*discordgo.ApplicationCommandOption{
+						targetServerOption(),
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "mode",
+							Description: "How to handle players only on the server (default: merge)",
+							NameLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "モード",
+							},
+							DescriptionLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "サーバーにだけいるプレイヤーの扱い（デフォルト: merge）",
+							},
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "merge (keep players only on the server)", Value: whitelistSyncMerge},
+								{Name: "file (the file wins)", Value: "file"},
+							},
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "import",
+					Description: "Import players from a file or another server, with a preview (Admin only)",
+					NameLocalizations: map[discordgo.Locale]string{
+						discordgo.Japanese: "インポート",
+					},
+					DescriptionLocalizations: map[discordgo.Locale]string{
+						discordgo.Japanese: "ファイルや他のサーバーからプレイヤーを一括追加（差分を確認してから適用、管理者のみ）",
+					},
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionAttachment,
+							Name:        "file",
+							Description: "CSV (name[,uuid]) or JSON (whitelist.json format or an array of names)",
+							NameLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "ファイル",
+							},
+							DescriptionLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "CSV（名前[,UUID]）または JSON（whitelist.json 形式か名前の配列）",
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "from",
+							Description: "Server whose whitelist to import",
+							NameLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "コピー元",
+							},
+							DescriptionLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "ホワイトリストをコピーするサーバー",
+							},
+							Autocomplete: true,
+						},
+						targetServerOption(),
+						{
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Name:        "replace",
+							Description: "Also remove players not in the import (default: false)",
+							NameLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "置き換え",
+							},
+							DescriptionLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "インポートに含まれないプレイヤーを削除する（デフォルト: false）",
+							},
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "export",
+					Description: "Download the whitelist as a file (Admin only)",
+					NameLocalizations: map[discordgo.Locale]string{
+						discordgo.Japanese: "エクスポート",
+					},
+					DescriptionLocalizations: map[discordgo.Locale]string{
+						discordgo.Japanese: "ホワイトリストをファイルで取得（管理者のみ）",
+					},
+					Options: []*discordgo.ApplicationCommandOption{
+						targetServerOption(),
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "format",
+							Description: "File format (default: json)",
+							NameLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "形式",
+							},
+							DescriptionLocalizations: map[discordgo.Locale]string{
+								discordgo.Japanese: "ファイル形式（デフォルト: json）",
+							},
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "json", Value: "json"},
+								{Name: "csv", Value: "csv"},
+							},
+						},
+					},
 				},
 			},
 		},
+		b.auditCommandDefinition(),
+		b.panelCommandDefinition(),
+		b.reloadCommandDefinition(),
+		b.configCommandDefinition(),
+		b.opCommandDefinition(),
+		b.banCommandDefinition(),
 	}
 }
 
-// buildServerChoices は設定から選択肢を構築（存在しないコンテナは除外）
-func (b *Bot) buildServerChoices() []*discordgo.ApplicationCommandOptionChoice {
-	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(b.settings.RegisteredContainers))
-
-	for id, config := range b.settings.RegisteredContainers {
-		// コンテナの存在確認
-		if stateObj, ok := b.appState.GetContainer(id); ok {
-			if cont, ok := stateObj.(*container.Container); ok {
-				// StatusNotFound のコンテナは選択肢に含めない
-				if cont.Status == container.StatusNotFound {
-					continue
-				}
-			}
-		}
-
-		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
-			Name:  config.DisplayName,
-			Value: id,
-		})
-	}
-
-	return choices
-}
-
 // registerHandlers はイベントハンドラーを登録
 func (b *Bot) registerHandlers() {
 	// Ready イベント
@@ -235,8 +482,28 @@ func (b *Bot) registerHandlers() {
 			Str("discriminator", s.State.User.Discriminator).
 			Msg("Discord bot is ready")
 
-		// 初期プレゼンスを設定
-		b.UpdatePresence()
+		// 再接続時はプレゼンスがリセットされるため必ず送り直す
+		b.updater.forget(presenceRenderKey)
+		b.RequestUpdate()
+
+		// 停止中に脱退・ロール変更したメンバーを確認
+		go b.reconcileLinks()
+	})
+
+	// メンバーの脱退・ロール変更（紐付けたプレイヤーをホワイトリストから外す）
+	b.session.AddHandler(func(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+		b.handleMemberRemove(m)
+	})
+	b.session.AddHandler(func(s *discordgo.Session, m *discordgo.GuildMemberUpdate) {
+		b.handleMemberUpdate(m)
+	})
+
+	// REST API のレート制限（discordgo が自動で待機・再試行したもの）
+	b.session.AddHandler(func(s *discordgo.Session, r *discordgo.RateLimit) {
+		log.Warn().
+			Str("url", r.URL).
+			Dur("retry_after", r.RetryAfter).
+			Msg("Discord API rate limited")
 	})
 
 	// Interaction Create イベント
@@ -252,9 +519,18 @@ func (b *Bot) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCr
 		b.handleCommand(s, i)
 	case discordgo.InteractionMessageComponent:
 		b.handleComponent(s, i)
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		b.handleAutocomplete(s, i)
+	case discordgo.InteractionModalSubmit:
+		b.handleModalSubmit(s, i)
 	}
 }
 
+// Name は Notifier の名前を返す
+func (b *Bot) Name() string {
+	return "discord"
+}
+
 // Start は Discord Bot を起動
 func (b *Bot) Start(ctx context.Context) error {
 	// セッションを開く
@@ -264,6 +540,13 @@ func (b *Bot) Start(ctx context.Context) error {
 
 	log.Info().Msg("Discord session opened")
 
+	// 更新ワーカーを起動
+	go b.updater.run(ctx)
+
+	// 申請の期限切れ処理を起動
+	go b.runRequestExpiry(ctx)
+	go b.runWhitelistExpiry(ctx)
+
 	// コマンドを登録
 	if err := b.RegisterCommands(); err != nil {
 		b.session.Close()
@@ -308,10 +591,48 @@ func (b *Bot) RegisterCommands() error {
 		b.registeredCommands = append(b.registeredCommands, registered)
 		log.Info().Str("name", cmd.Name).Str("id", registered.ID).Msg("Command registered")
 	}
+	b.syncedCommands = b.commands
 
 	return nil
 }
 
+// SyncCommands はコマンド定義を作り直して Discord 側と同期する（設定の再読み込み時）
+// 一括上書きのため、定義から無くなったコマンドは Discord 側からも削除される
+func (b *Bot) SyncCommands() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.defineCommands()
+	return b.overwriteCommands()
+}
+
+// SyncCommandsIfChanged はコマンド定義（サーバーの選択肢など）が前回の登録から変わった場合のみ Discord 側と同期する
+// 設定の再読み込みのたびに呼ぶため、変更が無ければ Discord API を呼ばない
+func (b *Bot) SyncCommandsIfChanged() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.defineCommands()
+	if b.syncedCommands != nil && reflect.DeepEqual(b.commands, b.syncedCommands) {
+		log.Debug().Msg("Discord command definitions unchanged, skipping sync")
+		return nil
+	}
+	return b.overwriteCommands()
+}
+
+// overwriteCommands は現在の定義で Discord 側のコマンドを一括上書きする（b.mu を取得して呼ぶ）
+func (b *Bot) overwriteCommands() error {
+	registered, err := b.session.ApplicationCommandBulkOverwrite(b.appID, b.guildID, b.commands)
+	if err != nil {
+		return fmt.Errorf("failed to sync commands: %w", err)
+	}
+	b.registeredCommands = registered
+	b.syncedCommands = b.commands
+
+	log.Info().Int("count", len(registered)).Msg("Discord commands synced")
+	return nil
+}
+
 // UnregisterCommands は登録したスラッシュコマンドを削除
 func (b *Bot) UnregisterCommands() error {
 	b.mu.Lock()
@@ -335,74 +656,65 @@ func (b *Bot) UnregisterCommands() error {
 	return nil
 }
 
-// Session は Discord セッションを返す
-func (b *Bot) Session() *discordgo.Session {
-	return b.session
-}
-
-// UpdatePinnedMessages は📌リアクションがついた Bot のメッセージをすべて更新
-func (b *Bot) UpdatePinnedMessages() {
-	log.Info().Msg("Updating pinned messages")
+// ClearCommands はギルドに登録されたこのアプリケーションのスラッシュコマンドをすべて削除する
+// （mc-agent unregister-commands 用。エージェントの異常終了で残ったコマンドも削除する）
+func (b *Bot) ClearCommands() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	// すべての登録済みチャンネルを取得
-	// guildID からギルドの全チャンネルを取得
-	channels, err := b.session.GuildChannels(b.guildID)
+	existing, err := b.session.ApplicationCommands(b.appID, b.guildID)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to get guild channels")
-		return
+		return 0, fmt.Errorf("failed to list commands: %w", err)
 	}
+	if _, err := b.session.ApplicationCommandBulkOverwrite(b.appID, b.guildID, []*discordgo.ApplicationCommand{}); err != nil {
+		return 0, fmt.Errorf("failed to delete commands: %w", err)
+	}
+	b.registeredCommands = nil
 
-	updatedCount := 0
+	log.Info().Int("count", len(existing)).Msg("Discord commands cleared")
+	return len(existing), nil
+}
 
-	// 各チャンネルでピン留めされたメッセージを確認
-	for _, channel := range channels {
-		// テキストチャンネルのみ対象
-		if channel.Type != discordgo.ChannelTypeGuildText {
-			continue
+// Membership はユーザーがギルドのメンバーか、管理者権限を持つかを返す（ダッシュボードのログイン用）
+// スラッシュコマンドの isAdmin と同じく Administrator 権限（またはギルドのオーナー）で判定する（ロールの権限から計算する）
+func (b *Bot) Membership(userID string) (member, admin bool, err error) {
+	guildMember, err := b.session.GuildMember(b.guildID, userID)
+	if err != nil {
+		var restErr *discordgo.RESTError
+		if errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound {
+			return false, false, nil
+		}
+		return false, false, fmt.Errorf("failed to get guild member: %w", err)
+	}
+
+	guild, err := b.session.State.Guild(b.guildID)
+	if err != nil {
+		if guild, err = b.session.Guild(b.guildID); err != nil {
+			return false, false, fmt.Errorf("failed to get guild: %w", err)
 		}
+	}
 
-		// 各チャンネルのピン留めメッセージを取得
-		messages, err := b.session.ChannelMessagesPinned(channel.ID)
-		if err != nil {
-			log.Error().Err(err).Str("channel_id", channel.ID).Msg("Failed to get channel messages")
+	admin = guild.OwnerID == userID
+	for _, role := range guild.Roles {
+		// @everyone ロールの ID はギルドの ID と同じ
+		if role.ID != guild.ID && !slices.Contains(guildMember.Roles, role.ID) {
 			continue
 		}
-
-		// 各メッセージを確認
-		for _, msg := range messages {
-			// Bot 自身のメッセージかつ、Embeds または Components があるかチェック
-			if msg.Author.ID != b.session.State.User.ID || (len(msg.Embeds) == 0 && len(msg.Components) == 0) {
-				continue
-			}
-
-			// メッセージを更新
-			embed := b.buildStatusEmbed()
-			components := b.buildActionButtons()
-
-			_, err := b.session.ChannelMessageEditComplex(&discordgo.MessageEdit{
-				Channel:    msg.ChannelID,
-				ID:         msg.ID,
-				Embeds:     &[]*discordgo.MessageEmbed{embed},
-				Components: &components,
-			})
-
-			if err != nil {
-				log.Error().
-					Err(err).
-					Str("channel_id", msg.ChannelID).
-					Str("message_id", msg.ID).
-					Msg("Failed to update pinned message")
-			} else {
-				log.Debug().
-					Str("channel_id", msg.ChannelID).
-					Str("message_id", msg.ID).
-					Msg("Updated pinned message")
-				updatedCount++
-			}
+		if role.Permissions&discordgo.PermissionAdministrator != 0 {
+			admin = true
 		}
 	}
+	return true, admin, nil
+}
+
+// Connected は Gateway に接続済み（READY 後、切断されていない）か返す
+func (b *Bot) Connected() bool {
+	b.session.RLock()
+	defer b.session.RUnlock()
+	return b.session.DataReady
+}
 
-	log.Info().
-		Int("updated_messages", updatedCount).
-		Msg("Pinned messages update completed")
+// Session は Discord セッションを返す
+func (b *Bot) Session() *discordgo.Session {
+	return b.session
 }
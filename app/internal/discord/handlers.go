@@ -3,11 +3,13 @@ package discord
 import (
 	"context"
 	"fmt"
-	"os"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
 	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/Koranoa3/mc-server-agent/internal/policy"
 	"github.com/Koranoa3/mc-server-agent/internal/routine"
 	"github.com/Koranoa3/mc-server-agent/internal/utilities"
 	"github.com/bwmarrin/discordgo"
@@ -34,6 +36,18 @@ func (b *Bot) handleCommand(s *discordgo.Session, i *discordgo.InteractionCreate
 		b.handleStopCommand(s, i)
 	case "whitelist":
 		b.handleWhitelistCommand(s, i)
+	case "mc-audit":
+		b.handleAuditCommand(s, i)
+	case "mc-panel":
+		b.handlePanelCommand(s, i)
+	case "mc-reload":
+		b.handleReloadCommand(s, i)
+	case "mc-config":
+		b.handleConfigCommand(s, i)
+	case "mc-op":
+		b.handleOpCommand(s, i)
+	case "mc-ban":
+		b.handleBanCommand(s, i)
 	default:
 		b.respondError(s, i, "Unknown command")
 	}
@@ -66,6 +80,10 @@ func (b *Bot) handleComponent(s *discordgo.Session, i *discordgo.InteractionCrea
 		b.executeCommand(s, i, "stop", containerID)
 	case "refresh":
 		b.handleRefreshButton(s, i)
+	case whitelistApproveAction, whitelistDenyAction:
+		b.handleRequestDecision(s, i, action, containerID)
+	case whitelistImportApplyAction, whitelistImportCancelAction:
+		b.handleImportDecision(s, i, action, containerID)
 	default:
 		b.respondError(s, i, "Unknown action")
 	}
@@ -73,7 +91,7 @@ func (b *Bot) handleComponent(s *discordgo.Session, i *discordgo.InteractionCrea
 
 // handleStatusCommand は /mc-status コマンドを処理
 func (b *Bot) handleStatusCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	embed := b.buildStatusEmbed()
+	embed := b.buildStatusEmbed(nil)
 
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -90,8 +108,8 @@ func (b *Bot) handleStatusCommand(s *discordgo.Session, i *discordgo.Interaction
 
 // handleListCommand は /mc-list コマンドを処理
 func (b *Bot) handleListCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	embed := b.buildStatusEmbed()
-	components := b.buildActionButtons()
+	embed := b.buildStatusEmbed(nil)
+	components := b.buildActionButtons(nil)
 
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -114,7 +132,7 @@ func (b *Bot) handleStartCommand(s *discordgo.Session, i *discordgo.InteractionC
 		return
 	}
 
-	containerID := options[0].StringValue()
+	containerID := b.resolveServerKey(options[0].StringValue())
 	b.executeCommand(s, i, "start", containerID)
 }
 
@@ -126,14 +144,14 @@ func (b *Bot) handleStopCommand(s *discordgo.Session, i *discordgo.InteractionCr
 		return
 	}
 
-	containerID := options[0].StringValue()
+	containerID := b.resolveServerKey(options[0].StringValue())
 	b.executeCommand(s, i, "stop", containerID)
 }
 
 // handleRefreshButton は Refresh ボタンを処理
 func (b *Bot) handleRefreshButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	embed := b.buildStatusEmbed()
-	components := b.buildActionButtons()
+	embed := b.buildStatusEmbed(nil)
+	components := b.buildActionButtons(nil)
 
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseUpdateMessage,
@@ -150,64 +168,12 @@ func (b *Bot) handleRefreshButton(s *discordgo.Session, i *discordgo.Interaction
 
 // executeCommand はコマンドを実行し結果を返す
 func (b *Bot) executeCommand(s *discordgo.Session, i *discordgo.InteractionCreate, action, containerID string) {
-	// 設定確認
-	config, ok := b.settings.RegisteredContainers[containerID]
-	if !ok {
-		b.respondError(s, i, fmt.Sprintf("Container '%s' not found", containerID))
-		return
-	}
-
-	// コンテナの現在状態をチェックして即時エラーメッセージを返す（起動済み・停止済み・プレイヤー在籍など）
-	if stateObj, ok := b.appState.GetContainer(containerID); ok {
-		if cont, ok := stateObj.(*container.Container); ok {
-			switch action {
-			case "start":
-				if cont.Status == container.StatusRunning {
-					b.respondError(s, i, fmt.Sprintf("%s is already running.", config.DisplayName))
-					return
-				}
-				if cont.Status == container.StatusStarting {
-					b.respondError(s, i, fmt.Sprintf("%s is currently starting. Please wait and try again.", config.DisplayName))
-					return
-				}
-				if cont.Status == container.StatusNotFound || cont.ID == "" {
-					b.respondError(s, i, fmt.Sprintf("%s is currently unavailable (container not found).", config.DisplayName))
-					return
-				}
-			case "stop":
-				if cont.Status == container.StatusStopped || cont.Status == container.StatusNotFound {
-					b.respondError(s, i, fmt.Sprintf("%s is already stopped.", config.DisplayName))
-					return
-				}
-
-				// リアルタイムでプレイヤー数を取得（rcon-cli経由）
-				ctx := context.Background()
-				players, err := cont.FetchAllPlayers(ctx)
-				if err != nil {
-					// rcon-cli失敗時はキャッシュ値にフォールバック
-					log.Warn().Err(err).Str("container", containerID).Msg("Failed to fetch realtime players, using cached value")
-					if cont.Players > 0 {
-						b.respondError(s, i, fmt.Sprintf("%s cannot be stopped because there are players online (%d players).", config.DisplayName, cont.Players))
-						return
-					}
-				} else if len(players) > 0 {
-					// リアルタイム取得成功、プレイヤーがいる場合
-					b.respondError(s, i, fmt.Sprintf("%s cannot be stopped because there are players online (%d players).", config.DisplayName, len(players)))
-					return
-				}
-			}
-		}
-	} else {
-		// state に存在しない場合は警告として返す
-		b.respondError(s, i, fmt.Sprintf("Unable to retrieve status for %s. Please try again later.", config.DisplayName))
-		return
-	}
-
-	// アクション確認
-	if !b.isActionAllowed(action) {
-		b.respondError(s, i, fmt.Sprintf("Sorry, the action `%s` is not allowed.", action))
+	// 登録・現在状態（起動済み・停止済み・プレイヤー在籍など）・許可設定をチェックして即時エラーメッセージを返す
+	if rejection := policy.CheckCommand(context.Background(), b.appState, action, containerID); rejection != nil {
+		b.rejectCommand(s, i, action, containerID, rejection.Message)
 		return
 	}
+	config := b.settings().RegisteredContainers[containerID]
 
 	// Deferred response (処理に時間がかかるため)
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -227,6 +193,9 @@ func (b *Bot) executeCommand(s *discordgo.Session, i *discordgo.InteractionCreat
 		Type:        action,
 		ContainerID: containerID,
 		Timeout:     30,
+		Source:      interactionSource(i),
+		UserID:      i.Member.User.ID,
+		UserName:    i.Member.User.Username,
 	}
 
 	select {
@@ -237,7 +206,7 @@ func (b *Bot) executeCommand(s *discordgo.Session, i *discordgo.InteractionCreat
 			Msg("Command sent to channel")
 
 		// Followup メッセージで結果を通知（自動削除をスケジュール）
-		allow_icon := b.settings.Icons["allow"]
+		allow_icon := b.settings().Icons["allow"]
 		content := fmt.Sprintf("%s `%s` command sent to **%s**", allow_icon, action, config.DisplayName)
 		msg, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
 			Content: content,
@@ -246,9 +215,9 @@ func (b *Bot) executeCommand(s *discordgo.Session, i *discordgo.InteractionCreat
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to send followup message")
 		} else {
-			if b.settings != nil && b.settings.MessageDeleteAfter > 0 {
+			if b.settings() != nil && b.settings().MessageDeleteAfter > 0 {
 				go func(msg *discordgo.Message) {
-					time.Sleep(time.Duration(b.settings.MessageDeleteAfter) * time.Second)
+					time.Sleep(time.Duration(b.settings().MessageDeleteAfter) * time.Second)
 					if err := s.FollowupMessageDelete(i.Interaction, msg.ID); err != nil {
 						log.Debug().Err(err).Msg("Failed to delete followup message")
 					}
@@ -259,15 +228,15 @@ func (b *Bot) executeCommand(s *discordgo.Session, i *discordgo.InteractionCreat
 	default:
 		log.Error().Msg("Command channel is full")
 		// エラーフォローアップ（自動削除）
-		deny_icon := b.settings.Icons["deny"]
+		deny_icon := b.settings().Icons["deny"]
 		msg, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
 			Content: fmt.Sprintf("%s Command queue is full. Please try again later.", deny_icon),
 		})
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to send error followup")
-		} else if b.settings != nil && b.settings.MessageDeleteAfter > 0 {
+		} else if b.settings() != nil && b.settings().MessageDeleteAfter > 0 {
 			go func(msg *discordgo.Message) {
-				time.Sleep(time.Duration(b.settings.MessageDeleteAfter) * time.Second)
+				time.Sleep(time.Duration(b.settings().MessageDeleteAfter) * time.Second)
 				if err := s.FollowupMessageDelete(i.Interaction, msg.ID); err != nil {
 					log.Debug().Err(err).Msg("Failed to delete error followup")
 				}
@@ -276,21 +245,9 @@ func (b *Bot) executeCommand(s *discordgo.Session, i *discordgo.InteractionCreat
 	}
 }
 
-// isActionAllowed はアクションが許可されているか確認
-func (b *Bot) isActionAllowed(action string) bool {
-	switch action {
-	case "start":
-		return b.settings.AllowedActions.PowerOn
-	case "stop":
-		return b.settings.AllowedActions.PowerOff
-	default:
-		return false
-	}
-}
-
 // respondError はエラーレスポンスを返す
 func (b *Bot) respondError(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
-	deny_icon := b.settings.Icons["deny"]
+	deny_icon := b.settings().Icons["deny"]
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
@@ -305,9 +262,9 @@ func (b *Bot) respondError(s *discordgo.Session, i *discordgo.InteractionCreate,
 	}
 
 	// 自動削除スケジュール
-	if b.settings != nil && b.settings.MessageDeleteAfter > 0 {
+	if b.settings() != nil && b.settings().MessageDeleteAfter > 0 {
 		go func() {
-			time.Sleep(time.Duration(b.settings.MessageDeleteAfter) * time.Second)
+			time.Sleep(time.Duration(b.settings().MessageDeleteAfter) * time.Second)
 			if derr := s.InteractionResponseDelete(i.Interaction); derr != nil {
 				log.Debug().Err(derr).Msg("Failed to delete interaction response (error)")
 			}
@@ -315,6 +272,33 @@ func (b *Bot) respondError(s *discordgo.Session, i *discordgo.InteractionCreate,
 	}
 }
 
+// respondSuccess は成功レスポンスを返す
+func (b *Bot) respondSuccess(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
+	allow_icon := b.settings().Icons["allow"]
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("%s %s", allow_icon, message),
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	if err != nil {
+		log.Error().Err(err).Str("message", message).Msg("Failed to send success response")
+		return
+	}
+
+	// 自動削除スケジュール
+	if b.settings() != nil && b.settings().MessageDeleteAfter > 0 {
+		go func() {
+			time.Sleep(time.Duration(b.settings().MessageDeleteAfter) * time.Second)
+			if derr := s.InteractionResponseDelete(i.Interaction); derr != nil {
+				log.Debug().Err(derr).Msg("Failed to delete interaction response (success)")
+			}
+		}()
+	}
+}
+
 // handleWhitelistCommand は /whitelist コマンドを処理
 func (b *Bot) handleWhitelistCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	options := i.ApplicationCommandData().Options
@@ -330,92 +314,114 @@ func (b *Bot) handleWhitelistCommand(s *discordgo.Session, i *discordgo.Interact
 		b.handleWhitelistAdd(s, i, subcommand)
 	case "remove":
 		b.handleWhitelistRemove(s, i, subcommand)
+	case "apply":
+		b.handleWhitelistApply(s, i, subcommand)
 	case "list":
-		b.handleWhitelistList(s, i)
+		b.handleWhitelistList(s, i, subcommand)
+	case "refresh":
+		b.handleWhitelistRefresh(s, i, subcommand)
+	case "import":
+		b.handleWhitelistImport(s, i, subcommand)
+	case "export":
+		b.handleWhitelistExport(s, i, subcommand)
+	case "sync":
+		b.handleWhitelistSync(s, i, subcommand)
 	default:
 		b.respondError(s, i, "Unknown subcommand")
 	}
 }
 
-// handleWhitelistAdd はプレイヤーをホワイトリストに追加
-func (b *Bot) handleWhitelistAdd(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
-	if len(subcommand.Options) == 0 {
-		b.respondError(s, i, "Player name is required")
-		return
-	}
+// whitelistAllServers は全サーバーを対象にする server オプションの値
+const whitelistAllServers = "all"
 
-	playerName := subcommand.Options[0].StringValue()
-	whitelistPath := os.Getenv("WHITELIST_PATH")
-	if whitelistPath == "" {
-		b.respondError(s, i, "WHITELIST_PATH environment variable is not set")
-		return
+// playerListTarget は同じプレイヤーリストファイルを使うサーバーのまとまり
+type playerListTarget struct {
+	path    string
+	servers []string // コンテナキー
+	offline bool     // online-mode=false のサーバー（オフライン UUID を使う）
+}
+
+// profileFor は対象のサーバーで使う UUID のプロフィールを返す（オフラインモードならオフライン UUID）
+func profileFor(profile *utilities.MojangProfile, target playerListTarget) *utilities.MojangProfile {
+	if target.offline {
+		return utilities.OfflineProfile(profile.Name)
 	}
+	return profile
+}
 
-	// Deferred response
-	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Flags: discordgo.MessageFlagsEphemeral,
-		},
-	})
+// resolveWhitelistTargets は server オプションの値から対象のホワイトリストファイルを決める
+func (b *Bot) resolveWhitelistTargets(value string) ([]playerListTarget, error) {
+	settings := b.settings()
+	return b.resolvePlayerListTargets(value, "whitelist", settings.WhitelistFile)
+}
 
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to send deferred response")
-		return
-	}
+// resolvePlayerListTargets は server オプションの値から対象のファイルを決める
+// 空または "all" の場合は登録済みの全サーバー（ファイルを共有しているサーバーはまとめる）
+func (b *Bot) resolvePlayerListTargets(value, kind string, pathOf func(key string) string) ([]playerListTarget, error) {
+	settings := b.settings()
 
-	// Mojang API でプレイヤー情報を取得
-	profile, err := utilities.FetchMojangProfile(playerName)
-	if err != nil {
-		deny_icon := b.settings.Icons["deny"]
-		content := fmt.Sprintf("%s プレイヤー名が不明です: %s", deny_icon, playerName)
-		if err.Error() != "player not found" {
-			content = fmt.Sprintf("%s エラーが発生しました: %v", deny_icon, err)
+	var keys []string
+	if value == "" || strings.EqualFold(value, whitelistAllServers) {
+		for key := range settings.RegisteredContainers {
+			keys = append(keys, key)
 		}
-
-		s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
-			Content: content,
-		})
-		return
+		sort.Strings(keys)
+	} else {
+		key := b.resolveServerKey(value)
+		if _, ok := settings.RegisteredContainers[key]; !ok {
+			return nil, fmt.Errorf("Server '%s' not found", value)
+		}
+		keys = []string{key}
 	}
 
-	// ホワイトリストに追加
-	userID := i.Member.User.ID
-	isNew, err := utilities.AddToWhitelist(whitelistPath, profile.ID, profile.Name, userID)
-	if err != nil {
-		deny_icon := b.settings.Icons["deny"]
-		s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
-			Content: fmt.Sprintf("%s エラーが発生しました: %v", deny_icon, err),
-		})
-		return
+	targets := make([]playerListTarget, 0, len(keys))
+	index := make(map[string]int)
+	missing := make([]string, 0)
+	for _, key := range keys {
+		path := pathOf(key)
+		if path == "" {
+			missing = append(missing, key)
+			continue
+		}
+		if idx, ok := index[path]; ok {
+			targets[idx].servers = append(targets[idx].servers, key)
+			continue
+		}
+		index[path] = len(targets)
+		targets = append(targets, playerListTarget{path: path, servers: []string{key}, offline: settings.OfflineMode(key)})
 	}
 
-	// 成功メッセージ
-	allow_icon := b.settings.Icons["allow"]
-	var content string
-	if isNew {
-		content = fmt.Sprintf("%s **%s** を追加しました", allow_icon, profile.Name)
-	} else {
-		content = fmt.Sprintf("%s **%s** は既にホワイトリストに含まれています", allow_icon, profile.Name)
+	if len(targets) == 0 {
+		hint := "path"
+		if kind == "whitelist" {
+			hint = "path or whitelist_path"
+		}
+		return nil, fmt.Errorf("%s is not configured for %s (set %s)", kind, strings.Join(missing, ", "), hint)
+	}
+	if len(missing) > 0 {
+		log.Warn().Str("list", kind).Strs("servers", missing).Msg("Player list is not configured, skipping")
 	}
+	return targets, nil
+}
 
-	msg, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
-		Content: content,
-	})
+// serverNames はコンテナキーの表示名をカンマ区切りで返す
+func (b *Bot) serverNames(keys []string) string {
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, b.serverDisplayName(key))
+	}
+	return strings.Join(names, ", ")
+}
 
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to send followup message")
-	} else if b.settings != nil && b.settings.MessageDeleteAfter > 0 {
-		go func(msg *discordgo.Message) {
-			time.Sleep(time.Duration(b.settings.MessageDeleteAfter) * time.Second)
-			if err := s.FollowupMessageDelete(i.Interaction, msg.ID); err != nil {
-				log.Debug().Err(err).Msg("Failed to delete followup message")
-			}
-		}(msg)
+// handleWhitelistAdd はプレイヤーをホワイトリストに追加
+func (b *Bot) handleWhitelistAdd(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	// 承認制の場合、管理者以外は申請フォームを表示
+	if b.whitelistApprovalEnabled() && !b.isAdmin(i.Member) {
+		b.handleWhitelistApply(s, i, subcommand)
+		return
 	}
 
-	// 稼働中のコンテナにホワイトリスト更新を通知
-	b.refreshAllContainersWhitelist()
+	b.modifyWhitelist(s, i, subcommand, audit.ActionWhitelistAdd)
 }
 
 // handleWhitelistRemove はプレイヤーをホワイトリストから削除
@@ -426,152 +432,287 @@ func (b *Bot) handleWhitelistRemove(s *discordgo.Session, i *discordgo.Interacti
 		return
 	}
 
-	if len(subcommand.Options) == 0 {
+	b.modifyWhitelist(s, i, subcommand, audit.ActionWhitelistRemove)
+}
+
+// modifyWhitelist は対象サーバーのホワイトリストにプレイヤーを追加または削除する
+func (b *Bot) modifyWhitelist(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption, action string) {
+	options := optionMap(subcommand.Options)
+	playerOpt, ok := options["playername"]
+	if !ok {
 		b.respondError(s, i, "Player name is required")
 		return
 	}
+	playerName := playerOpt.StringValue()
 
-	playerName := subcommand.Options[0].StringValue()
-	whitelistPath := os.Getenv("WHITELIST_PATH")
-	if whitelistPath == "" {
-		b.respondError(s, i, "WHITELIST_PATH environment variable is not set")
+	// duration を指定した場合は期限付きで追加
+	duration, err := utilities.ParseListDuration(optionString(options, "duration"))
+	if err != nil {
+		b.respondError(s, i, err.Error())
 		return
 	}
 
-	// Deferred response
-	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Flags: discordgo.MessageFlagsEphemeral,
-		},
-	})
-
+	targets, err := b.resolveWhitelistTargets(optionString(options, "server"))
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to send deferred response")
+		b.respondError(s, i, err.Error())
+		return
+	}
+
+	if !b.deferEphemeral(s, i) {
 		return
 	}
 
 	// Mojang API でプレイヤー情報を取得
-	profile, err := utilities.FetchMojangProfile(playerName)
-	if err != nil {
-		deny_icon := b.settings.Icons["deny"]
-		content := fmt.Sprintf("%s プレイヤー名が不明です: %s", deny_icon, playerName)
-		if err.Error() != "player not found" {
-			content = fmt.Sprintf("%s エラーが発生しました: %v", deny_icon, err)
-		}
+	profile, ok := b.fetchProfile(s, i, playerName, targets)
+	if !ok {
+		return
+	}
 
-		s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
-			Content: content,
-		})
+	if action == audit.ActionWhitelistRemove {
+		results := b.writeWhitelist(interactionActor(i), targets, action, profile, i.Member.User.ID, nil)
+		b.unlinkIfUnlisted(profile)
+		b.sendFollowup(s, i, b.formatPlayerListResults(action, profile.Name, results))
 		return
 	}
 
-	// ホワイトリストから削除
-	removed, err := utilities.RemoveFromWhitelist(whitelistPath, profile.ID)
-	if err != nil {
-		deny_icon := b.settings.Icons["deny"]
-		s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
-			Content: fmt.Sprintf("%s エラーが発生しました: %v", deny_icon, err),
-		})
+	// 追加したプレイヤーは実行者（管理者が owner を指定した場合はそのユーザー）に紐付ける
+	admin := b.isAdmin(i.Member)
+	ownerID := i.Member.User.ID
+	if opt, ok := options["owner"]; ok && admin {
+		ownerID = opt.UserValue(nil).ID
+	}
+	if err := b.checkLink(ownerID, profile.ID, admin && ownerID == i.Member.User.ID); err != nil {
+		b.sendFollowup(s, i, fmt.Sprintf("%s %v", b.settings().Icons["deny"], err))
 		return
 	}
 
-	// 成功メッセージ
-	allow_icon := b.settings.Icons["allow"]
-	var content string
-	if removed {
-		content = fmt.Sprintf("%s **%s** を削除しました", allow_icon, profile.Name)
-	} else {
-		content = fmt.Sprintf("%s **%s** はホワイトリストに含まれていません", allow_icon, profile.Name)
+	actor := interactionActor(i)
+	var expiresAt *time.Time
+	if duration > 0 {
+		expires := time.Now().Add(duration).Truncate(time.Second)
+		expiresAt = &expires
+		actor.Detail = "until " + expires.Format(time.RFC3339)
 	}
 
-	msg, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
-		Content: content,
-	})
+	results := b.writeWhitelist(actor, targets, action, profile, i.Member.User.ID, expiresAt)
+	for _, r := range results {
+		if r.err == nil {
+			b.linkPlayer(ownerID, i.Member.User.ID, profile)
+			break
+		}
+	}
 
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to send followup message")
-	} else if b.settings != nil && b.settings.MessageDeleteAfter > 0 {
-		go func(msg *discordgo.Message) {
-			time.Sleep(time.Duration(b.settings.MessageDeleteAfter) * time.Second)
-			if err := s.FollowupMessageDelete(i.Interaction, msg.ID); err != nil {
-				log.Debug().Err(err).Msg("Failed to delete followup message")
-			}
-		}(msg)
+	content := b.formatPlayerListResults(action, profile.Name, results)
+	if expiresAt != nil {
+		content += fmt.Sprintf("\n⏳ <t:%d:f>（<t:%d:R>）に自動的に削除されます", expiresAt.Unix(), expiresAt.Unix())
 	}
+	b.sendFollowup(s, i, content)
+}
+
+// writeWhitelist はホワイトリストファイルごとにプレイヤーを追加・削除し、actor の操作として監査ログに記録する
+// 変更したサーバーのうち稼働中のものにはホワイトリストの再読み込みを通知する
+// expiresAt は追加する場合の期限（nil の場合は無期限）
+func (b *Bot) writeWhitelist(actor audit.Entry, targets []playerListTarget, action string, profile *utilities.MojangProfile, addedUserID string, expiresAt *time.Time) []playerListResult {
+	results := make([]playerListResult, 0, len(targets))
+	changedServers := make([]string, 0)
+	for _, target := range targets {
+		var changed bool
+		var err error
+		player := profileFor(profile, target)
+		if action == audit.ActionWhitelistAdd {
+			changed, err = utilities.AddToWhitelist(target.path, player.ID, player.Name, addedUserID, expiresAt)
+		} else {
+			changed, err = utilities.RemoveFromWhitelist(target.path, player.ID)
+		}
 
-	// 稼働中のコンテナにホワイトリスト更新を通知
-	b.refreshAllContainersWhitelist()
+		for _, server := range target.servers {
+			b.recordPlayerListChange(actor, action, server, profile.Name, changed, err)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("path", target.path).Msg("Failed to update whitelist")
+		} else if changed {
+			changedServers = append(changedServers, target.servers...)
+		}
+		results = append(results, playerListResult{target: target, changed: changed, err: err})
+	}
+
+	// 変更したサーバーのうち稼働中のものにホワイトリスト更新を通知
+	b.refreshContainersWhitelist(changedServers)
+	return results
 }
 
 // handleWhitelistList はホワイトリストを表示
-func (b *Bot) handleWhitelistList(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func (b *Bot) handleWhitelistList(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
 	// 管理者権限チェック
 	if !b.isAdmin(i.Member) {
 		b.respondError(s, i, "この操作には管理者権限が必要です")
 		return
 	}
 
-	whitelistPath := os.Getenv("WHITELIST_PATH")
-	if whitelistPath == "" {
-		b.respondError(s, i, "WHITELIST_PATH environment variable is not set")
+	targets, err := b.resolveWhitelistTargets(optionString(optionMap(subcommand.Options), "server"))
+	if err != nil {
+		b.respondError(s, i, err.Error())
 		return
 	}
 
-	// ホワイトリストを読み込み
-	entries, err := utilities.LoadWhitelist(whitelistPath)
-	if err != nil {
-		b.respondError(s, i, fmt.Sprintf("エラーが発生しました: %v", err))
+	// 稼働中のサーバーへの問い合わせ（whitelist list）に時間がかかるため先に応答
+	if !b.deferEphemeral(s, i) {
 		return
 	}
+	ctx := context.Background()
 
 	// リストを整形
 	var builder strings.Builder
 	builder.WriteString("Check UUID at https://api.minecraftservices.com/minecraft/profile/lookup/YOUR-UUID \n")
-	builder.WriteString("```\n")
-	builder.WriteString(fmt.Sprintf("Whitelist Total: %d players\n\n", len(entries)))
 
 	userCache := map[string]string{}
-
-	for idx, entry := range entries {
-		addedBy := "Unknown"
-		if entry.AddedUserID != "" {
-			if cached, ok := userCache[entry.AddedUserID]; ok {
-				addedBy = cached
-			} else {
-				// Discord APIからユーザー情報を取得
-				user, err := s.User(entry.AddedUserID)
-				if err != nil {
-					// 取得失敗時はIDのみ表示
-					addedBy = fmt.Sprintf("<@%s>", entry.AddedUserID)
-				} else {
-					globalName := user.GlobalName
-					if globalName == "" {
-						globalName = user.Username
-					}
-					addedBy = fmt.Sprintf("%s - %s", globalName, user.Username)
-				}
-				// キャッシュに保存
-				userCache[entry.AddedUserID] = addedBy
+	userLabel := func(userID string) string {
+		if cached, ok := userCache[userID]; ok {
+			return cached
+		}
+		// Discord APIからユーザー情報を取得
+		label := fmt.Sprintf("<@%s>", userID) // 取得失敗時はIDのみ表示
+		if user, err := s.User(userID); err == nil {
+			globalName := user.GlobalName
+			if globalName == "" {
+				globalName = user.Username
 			}
+			label = fmt.Sprintf("%s - %s", globalName, user.Username)
 		}
-		builder.WriteString(fmt.Sprintf("%2d. %-16s (Added by: %s)\n    -  %s\n", idx+1, entry.Name, addedBy, entry.UUID))
+		// キャッシュに保存
+		userCache[userID] = label
+		return label
 	}
 
-	builder.WriteString("```")
+	for _, target := range targets {
+		// ホワイトリストを読み込み
+		entries, err := utilities.LoadWhitelist(target.path)
+		if err != nil {
+			b.sendListFollowup(s, i, fmt.Sprintf("%s エラーが発生しました (%s): %v", b.settings().Icons["deny"], b.serverNames(target.servers), err))
+			return
+		}
+
+		builder.WriteString(fmt.Sprintf("**%s**\n", b.serverNames(target.servers)))
+		builder.WriteString("```\n")
+		builder.WriteString(fmt.Sprintf("Whitelist Total: %d players\n\n", len(entries)))
+
+		for idx, entry := range entries {
+			addedBy := "Unknown"
+			if entry.AddedUserID != "" {
+				addedBy = userLabel(entry.AddedUserID)
+			}
+			linked := ""
+			if link, ok := b.links.owner(entry.UUID); ok {
+				linked = ", Linked: " + userLabel(link.UserID)
+			}
+			expires := ""
+			if entry.ExpiresAt != nil {
+				expires = ", Expires: " + entry.ExpiresAt.Local().Format("2006-01-02 15:04")
+			}
+			builder.WriteString(fmt.Sprintf("%2d. %-16s (Added by: %s%s%s)\n    -  %s\n", idx+1, entry.Name, addedBy, linked, expires, entry.UUID))
+		}
+
+		// 稼働中のサーバーが読み込んでいる内容とのずれ
+		builder.WriteString(b.formatWhitelistDrift(ctx, target, entries))
+		builder.WriteString("```\n")
+	}
 
 	// レスポンスを送信 (ephemeral, message_deleteafter は適用しない)
-	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: builder.String(),
-			Flags:   discordgo.MessageFlagsEphemeral,
-		},
-	})
+	b.sendListFollowup(s, i, builder.String())
+}
+
+// playerRename はプレイヤー名の変更
+type playerRename struct {
+	uuid    string
+	oldName string
+	newName string
+}
+
+// handleWhitelistRefresh はホワイトリストのプレイヤー名を UUID から引き直し、名前の変更を反映する
+// オフラインモードのサーバーは UUID が名前から作られるため対象外
+func (b *Bot) handleWhitelistRefresh(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	// 管理者権限チェック
+	if !b.isAdmin(i.Member) {
+		b.respondError(s, i, "この操作には管理者権限が必要です")
+		return
+	}
+
+	targets, err := b.resolveWhitelistTargets(optionString(optionMap(subcommand.Options), "server"))
+	if err != nil {
+		b.respondError(s, i, err.Error())
+		return
+	}
+
+	if !b.deferEphemeral(s, i) {
+		return
+	}
+
+	allow_icon := b.settings().Icons["allow"]
+	deny_icon := b.settings().Icons["deny"]
+	actor := interactionActor(i)
+
+	var builder strings.Builder
+	changedServers := make([]string, 0)
+	for _, target := range targets {
+		servers := b.serverNames(target.servers)
+		if target.offline {
+			builder.WriteString(fmt.Sprintf("%s %s: オフラインモードのためスキップしました\n", allow_icon, servers))
+			continue
+		}
+
+		renames, err := b.findRenames(target.path)
+		if err != nil {
+			builder.WriteString(fmt.Sprintf("%s %s: エラー (%v)\n", deny_icon, servers, err))
+			continue
+		}
 
+		applied := 0
+		for _, rename := range renames {
+			_, err := utilities.RenameInWhitelist(target.path, rename.uuid, rename.newName)
+			detail := fmt.Sprintf("%s → %s", rename.oldName, rename.newName)
+			for _, server := range target.servers {
+				b.recordPlayerListChange(actor, audit.ActionWhitelistRename, server, detail, true, err)
+			}
+			if err != nil {
+				log.Error().Err(err).Str("path", target.path).Msg("Failed to update whitelist")
+				builder.WriteString(fmt.Sprintf("%s %s: %s (エラー: %v)\n", deny_icon, servers, detail, err))
+				continue
+			}
+			b.renameLink(rename.uuid, rename.newName)
+			builder.WriteString(fmt.Sprintf("%s %s: %s\n", allow_icon, servers, detail))
+			applied++
+		}
+
+		if applied > 0 {
+			changedServers = append(changedServers, target.servers...)
+		} else if len(renames) == 0 {
+			builder.WriteString(fmt.Sprintf("%s %s: 名前の変更はありません\n", allow_icon, servers))
+		}
+	}
+
+	b.refreshContainersWhitelist(changedServers)
+	b.sendFollowup(s, i, fitMessage(strings.TrimSuffix(builder.String(), "\n")))
+}
+
+// findRenames はホワイトリストのうち現在の名前と異なるエントリを返す
+func (b *Bot) findRenames(path string) ([]playerRename, error) {
+	entries, err := utilities.LoadWhitelist(path)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to respond to whitelist list command")
+		return nil, err
 	}
+
+	var renames []playerRename
+	for _, entry := range entries {
+		profile, err := b.profiles.LookupUUID(entry.UUID)
+		if err != nil {
+			log.Warn().Err(err).Str("uuid", entry.UUID).Str("player", entry.Name).Msg("Failed to look up player name")
+			continue
+		}
+		if profile.Name != entry.Name {
+			renames = append(renames, playerRename{uuid: entry.UUID, oldName: entry.Name, newName: profile.Name})
+		}
+	}
+	return renames, nil
 }
 
 // isAdmin は管理者権限をチェック
@@ -581,12 +722,15 @@ func (b *Bot) isAdmin(member *discordgo.Member) bool {
 	return (permissions & discordgo.PermissionAdministrator) != 0
 }
 
-// refreshAllContainersWhitelist はすべての稼働中コンテナのホワイトリストを更新
-func (b *Bot) refreshAllContainersWhitelist() {
-	containers := b.appState.GetAllContainers()
+// refreshContainersWhitelist は指定したサーバーのうち稼働中のもののホワイトリストを再読み込みさせる
+func (b *Bot) refreshContainersWhitelist(keys []string) {
 	ctx := context.Background()
 
-	for id, containerInterface := range containers {
+	for _, id := range keys {
+		containerInterface, ok := b.appState.GetContainer(id)
+		if !ok {
+			continue
+		}
 		cont, ok := containerInterface.(*container.Container)
 		if !ok {
 			continue
@@ -609,3 +753,20 @@ func (b *Bot) refreshAllContainersWhitelist() {
 		}
 	}
 }
+
+// optionMap はオプションを名前で引けるようにする
+func optionMap(options []*discordgo.ApplicationCommandInteractionDataOption) map[string]*discordgo.ApplicationCommandInteractionDataOption {
+	result := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(options))
+	for _, opt := range options {
+		result[opt.Name] = opt
+	}
+	return result
+}
+
+// optionString は文字列オプションの値を返す（指定されていなければ空）
+func optionString(options map[string]*discordgo.ApplicationCommandInteractionDataOption, name string) string {
+	if opt, ok := options[name]; ok {
+		return strings.TrimSpace(opt.StringValue())
+	}
+	return ""
+}
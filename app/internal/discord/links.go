@@ -0,0 +1,306 @@
+package discord
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// AccountLink は Discord ユーザーと Minecraft プレイヤーの紐付け
+type AccountLink struct {
+	UserID     string    `json:"user_id"`
+	UUID       string    `json:"uuid"`
+	PlayerName string    `json:"player_name"`
+	LinkedBy   string    `json:"linked_by"` // 紐付けを作成したユーザー（管理者が代理で追加した場合は本人と異なる）
+	LinkedAt   time.Time `json:"linked_at"`
+}
+
+// linkStore は紐付けの一覧を永続化する（1プレイヤーにつき所有者は1人）
+type linkStore struct {
+	mu    sync.Mutex
+	path  string
+	links []AccountLink
+}
+
+// newLinkStore は新しい linkStore を作成
+func newLinkStore(path string) *linkStore {
+	return &linkStore{path: path}
+}
+
+// load はファイルから紐付けの一覧を読み込む
+func (l *linkStore) load() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var links []AccountLink
+	if _, err := utilities.LoadJSONFile(l.path, &links); err != nil {
+		return err
+	}
+	l.links = links
+	return nil
+}
+
+// saveLocked は紐付けの一覧をファイルに書き込む（呼び出し側でロック済み）
+func (l *linkStore) saveLocked() error {
+	if l.links == nil {
+		l.links = []AccountLink{}
+	}
+	return utilities.SaveJSONFile(l.path, l.links)
+}
+
+// owner はプレイヤーの紐付けを返す
+func (l *linkStore) owner(uuid string) (AccountLink, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, link := range l.links {
+		if strings.EqualFold(link.UUID, uuid) {
+			return link, true
+		}
+	}
+	return AccountLink{}, false
+}
+
+// forUser はユーザーに紐付けられたプレイヤーを返す
+func (l *linkStore) forUser(userID string) []AccountLink {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var links []AccountLink
+	for _, link := range l.links {
+		if link.UserID == userID {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// users は紐付けを持つユーザーIDの一覧を返す
+func (l *linkStore) users() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var users []string
+	for _, link := range l.links {
+		if !slices.Contains(users, link.UserID) {
+			users = append(users, link.UserID)
+		}
+	}
+	return users
+}
+
+// link は紐付けを追加して保存（既に紐付けがあれば置き換え）
+func (l *linkStore) link(link AccountLink) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for idx := range l.links {
+		if strings.EqualFold(l.links[idx].UUID, link.UUID) {
+			l.links[idx] = link
+			return l.saveLocked()
+		}
+	}
+	l.links = append(l.links, link)
+	return l.saveLocked()
+}
+
+// unlink はプレイヤーの紐付けを削除して保存
+func (l *linkStore) unlink(uuid string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for idx, link := range l.links {
+		if strings.EqualFold(link.UUID, uuid) {
+			l.links = append(l.links[:idx], l.links[idx+1:]...)
+			return true, l.saveLocked()
+		}
+	}
+	return false, nil
+}
+
+// rename はプレイヤー名を更新して保存（紐付けがなければ何もしない）
+func (l *linkStore) rename(uuid, name string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for idx := range l.links {
+		if strings.EqualFold(l.links[idx].UUID, uuid) {
+			if l.links[idx].PlayerName == name {
+				return nil
+			}
+			l.links[idx].PlayerName = name
+			return l.saveLocked()
+		}
+	}
+	return nil
+}
+
+// unlinkUser はユーザーの紐付けをすべて削除して返す
+func (l *linkStore) unlinkUser(userID string) ([]AccountLink, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var removed []AccountLink
+	kept := make([]AccountLink, 0, len(l.links))
+	for _, link := range l.links {
+		if link.UserID == userID {
+			removed = append(removed, link)
+			continue
+		}
+		kept = append(kept, link)
+	}
+
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	l.links = kept
+	return removed, l.saveLocked()
+}
+
+// checkLink はユーザーにプレイヤーを紐付けられるか確認する
+// 他のユーザーに紐付いているプレイヤーは追加できず、管理者以外は max_per_user を超えられない
+func (b *Bot) checkLink(userID, uuid string, admin bool) error {
+	uuid = utilities.FormatUUID(uuid)
+
+	if link, ok := b.links.owner(uuid); ok {
+		if link.UserID == userID {
+			return nil
+		}
+		if !admin {
+			return fmt.Errorf("**%s** は他のユーザーに紐付けられています", link.PlayerName)
+		}
+	}
+
+	limit := b.settings().AccountLinks.MaxPerUser
+	if admin || limit == 0 {
+		return nil
+	}
+	if count := len(b.links.forUser(userID)); count >= limit {
+		return fmt.Errorf("紐付けられるプレイヤーは %d 人までです（現在 %d 人）", limit, count)
+	}
+	return nil
+}
+
+// linkPlayer はプレイヤーをユーザーに紐付ける
+func (b *Bot) linkPlayer(userID, linkedBy string, profile *utilities.MojangProfile) {
+	err := b.links.link(AccountLink{
+		UserID:     userID,
+		UUID:       utilities.FormatUUID(profile.ID),
+		PlayerName: profile.Name,
+		LinkedBy:   linkedBy,
+		LinkedAt:   time.Now(),
+	})
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Str("player", profile.Name).Msg("Failed to save account link")
+	}
+}
+
+// renameLink は紐付けのプレイヤー名を更新する（名前の変更を反映）
+func (b *Bot) renameLink(uuid, name string) {
+	if err := b.links.rename(uuid, name); err != nil {
+		log.Error().Err(err).Str("player", name).Msg("Failed to save account links")
+	}
+}
+
+// unlinkIfUnlisted はどのホワイトリストにも残っていないプレイヤーの紐付けを削除する
+func (b *Bot) unlinkIfUnlisted(profile *utilities.MojangProfile) {
+	targets, err := b.resolveWhitelistTargets("")
+	if err != nil {
+		return
+	}
+	for _, target := range targets {
+		uuid := utilities.FormatUUID(profileFor(profile, target).ID)
+		entries, err := utilities.LoadWhitelist(target.path)
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			if strings.EqualFold(entry.UUID, uuid) {
+				return
+			}
+		}
+	}
+
+	if _, err := b.links.unlink(utilities.FormatUUID(profile.ID)); err != nil {
+		log.Error().Err(err).Str("player", profile.Name).Msg("Failed to save account links")
+	}
+}
+
+// handleMemberRemove はサーバーを抜けたメンバーのプレイヤーをホワイトリストから外す
+func (b *Bot) handleMemberRemove(m *discordgo.GuildMemberRemove) {
+	if m.GuildID != b.guildID || !b.settings().AccountLinks.RemoveOnLeave {
+		return
+	}
+	b.revokeLinks(m.User.ID, m.User.Username, "left the guild")
+}
+
+// handleMemberUpdate は必要なロールを失ったメンバーのプレイヤーをホワイトリストから外す
+func (b *Bot) handleMemberUpdate(m *discordgo.GuildMemberUpdate) {
+	roleID := b.settings().AccountLinks.RequiredRoleID
+	if m.GuildID != b.guildID || roleID == "" || slices.Contains(m.Roles, roleID) {
+		return
+	}
+	b.revokeLinks(m.User.ID, m.User.Username, "lost the required role")
+}
+
+// reconcileLinks は Bot の停止中に脱退・ロール変更したメンバーを確認する（起動時）
+func (b *Bot) reconcileLinks() {
+	config := b.settings().AccountLinks
+	if !config.WatchesMembers() {
+		return
+	}
+
+	for _, userID := range b.links.users() {
+		member, err := b.session.GuildMember(b.guildID, userID)
+		switch {
+		case restErrorCode(err) == discordgo.ErrCodeUnknownMember:
+			if config.RemoveOnLeave {
+				b.revokeLinks(userID, "", "left the guild")
+			}
+		case err != nil:
+			log.Warn().Err(err).Str("user_id", userID).Msg("Failed to fetch guild member")
+		case config.RequiredRoleID != "" && !slices.Contains(member.Roles, config.RequiredRoleID):
+			b.revokeLinks(userID, member.User.Username, "lost the required role")
+		}
+	}
+}
+
+// revokeLinks はユーザーに紐付いたプレイヤーを全サーバーのホワイトリストから外し、紐付けを削除する
+func (b *Bot) revokeLinks(userID, userName, reason string) {
+	links, err := b.links.unlinkUser(userID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to save account links")
+	}
+	if len(links) == 0 {
+		return
+	}
+
+	targets, err := b.resolveWhitelistTargets("")
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to resolve whitelists")
+		return
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Str("user", userName).
+		Int("players", len(links)).
+		Str("reason", reason).
+		Msg("Removing linked players from whitelist")
+
+	actor := audit.Entry{
+		Source: audit.SourceMemberEvent,
+		Detail: fmt.Sprintf("linked to <@%s>, %s", userID, reason),
+	}
+	for _, link := range links {
+		profile := &utilities.MojangProfile{ID: link.UUID, Name: link.PlayerName}
+		b.writeWhitelist(actor, targets, audit.ActionWhitelistRemove, profile, "", nil)
+	}
+}
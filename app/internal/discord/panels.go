@@ -0,0 +1,356 @@
+package discord
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// Panel は常駐ステータスパネル（状態変化に合わせて編集されるメッセージ）
+type Panel struct {
+	ID        string    `json:"id"`
+	ChannelID string    `json:"channel_id"`
+	MessageID string    `json:"message_id"`
+	Servers   []string  `json:"servers,omitempty"` // 空の場合は全サーバー
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// panelStore はパネルの一覧を永続化する
+type panelStore struct {
+	mu     sync.Mutex
+	path   string
+	panels []Panel
+}
+
+// newPanelStore は新しい panelStore を作成
+func newPanelStore(path string) *panelStore {
+	return &panelStore{path: path}
+}
+
+// load はファイルからパネル一覧を読み込む
+func (p *panelStore) load() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var panels []Panel
+	if _, err := utilities.LoadJSONFile(p.path, &panels); err != nil {
+		return err
+	}
+	p.panels = panels
+	return nil
+}
+
+// saveLocked はパネル一覧をファイルに書き込む（呼び出し側でロック済み）
+func (p *panelStore) saveLocked() error {
+	if p.panels == nil {
+		p.panels = []Panel{}
+	}
+	return utilities.SaveJSONFile(p.path, p.panels)
+}
+
+// list はパネル一覧のコピーを返す
+func (p *panelStore) list() []Panel {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Panel(nil), p.panels...)
+}
+
+// add はパネルを追加して保存
+func (p *panelStore) add(panel Panel) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.panels = append(p.panels, panel)
+	return p.saveLocked()
+}
+
+// remove は指定IDのパネルを削除して保存
+func (p *panelStore) remove(id string) (Panel, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for idx, panel := range p.panels {
+		if panel.ID == id {
+			p.panels = append(p.panels[:idx], p.panels[idx+1:]...)
+			return panel, true, p.saveLocked()
+		}
+	}
+	return Panel{}, false, nil
+}
+
+// setMessage はパネルのメッセージIDを差し替えて保存（再作成時）
+func (p *panelStore) setMessage(id, messageID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for idx := range p.panels {
+		if p.panels[idx].ID == id {
+			p.panels[idx].MessageID = messageID
+			return p.saveLocked()
+		}
+	}
+	return nil
+}
+
+// newID はパネルや申請の短いIDを生成
+func newID() string {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// restErrorCode は Discord API エラーのエラーコードを返す（取得できなければ 0）
+func restErrorCode(err error) int {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Message != nil {
+		return restErr.Message.Code
+	}
+	return 0
+}
+
+// panelCommandDefinition は /mc-panel コマンドの定義を返す
+func (b *Bot) panelCommandDefinition() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "mc-panel",
+		Description: "Manage live status panels (Admin only)",
+		NameLocalizations: &map[discordgo.Locale]string{
+			discordgo.Japanese: "mc-パネル",
+		},
+		DescriptionLocalizations: &map[discordgo.Locale]string{
+			discordgo.Japanese: "常駐ステータスパネルを管理（管理者のみ）",
+		},
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "create",
+				Description: "Post a live status panel in this channel",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "作成",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "このチャンネルに常駐ステータスパネルを投稿",
+				},
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "servers",
+						Description: "Comma separated server keys to show (default: all)",
+						NameLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "サーバー",
+						},
+						DescriptionLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "表示するサーバーをカンマ区切りで指定（省略時はすべて）",
+						},
+						Autocomplete: true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "remove",
+				Description: "Remove a live status panel",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "削除",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "常駐ステータスパネルを削除",
+				},
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "panel",
+						Description: "Panel ID (see /mc-panel list)",
+						NameLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "パネル",
+						},
+						DescriptionLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "パネルID（/mc-panel list で確認）",
+						},
+						Required: true,
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "Show all live status panels",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "リスト",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "常駐ステータスパネルの一覧を表示",
+				},
+			},
+		},
+	}
+}
+
+// handlePanelCommand は /mc-panel コマンドを処理
+func (b *Bot) handlePanelCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// 管理者権限チェック
+	if !b.isAdmin(i.Member) {
+		b.respondError(s, i, "この操作には管理者権限が必要です")
+		return
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		b.respondError(s, i, "Subcommand is required")
+		return
+	}
+
+	subcommand := options[0]
+
+	switch subcommand.Name {
+	case "create":
+		b.handlePanelCreate(s, i, subcommand)
+	case "remove":
+		b.handlePanelRemove(s, i, subcommand)
+	case "list":
+		b.handlePanelList(s, i)
+	default:
+		b.respondError(s, i, "Unknown subcommand")
+	}
+}
+
+// handlePanelCreate はパネルを投稿して登録
+func (b *Bot) handlePanelCreate(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	var servers []string
+	for _, opt := range subcommand.Options {
+		if opt.Name != "servers" {
+			continue
+		}
+		for _, key := range strings.FieldsFunc(opt.StringValue(), func(r rune) bool { return r == ',' || r == ' ' }) {
+			if _, ok := b.settings().RegisteredContainers[key]; !ok {
+				b.respondError(s, i, fmt.Sprintf("Container '%s' not found", key))
+				return
+			}
+			servers = append(servers, key)
+		}
+	}
+
+	embed := b.buildStatusEmbed(servers)
+	components := b.buildActionButtons(servers)
+
+	msg, err := s.ChannelMessageSendComplex(i.ChannelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: components,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("channel_id", i.ChannelID).Msg("Failed to post status panel")
+		b.respondError(s, i, fmt.Sprintf("パネルを投稿できませんでした: %v", err))
+		return
+	}
+
+	panel := Panel{
+		ID:        newID(),
+		ChannelID: msg.ChannelID,
+		MessageID: msg.ID,
+		Servers:   servers,
+		CreatedBy: i.Member.User.ID,
+		CreatedAt: time.Now(),
+	}
+	if err := b.panels.add(panel); err != nil {
+		log.Error().Err(err).Msg("Failed to save status panel")
+		b.respondError(s, i, fmt.Sprintf("エラーが発生しました: %v", err))
+		return
+	}
+	b.updater.remember(panelRenderKey(panel.ID), renderHash(embed, components))
+
+	log.Info().
+		Str("panel", panel.ID).
+		Str("channel_id", panel.ChannelID).
+		Strs("servers", panel.Servers).
+		Msg("Status panel created")
+
+	b.respondSuccess(s, i, fmt.Sprintf("パネル `%s` を作成しました", panel.ID))
+}
+
+// handlePanelRemove はパネルの登録を解除してメッセージを削除
+func (b *Bot) handlePanelRemove(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	if len(subcommand.Options) == 0 {
+		b.respondError(s, i, "Panel ID is required")
+		return
+	}
+
+	panelID := subcommand.Options[0].StringValue()
+	panel, found, err := b.panels.remove(panelID)
+	if err != nil {
+		b.respondError(s, i, fmt.Sprintf("エラーが発生しました: %v", err))
+		return
+	}
+	if !found {
+		b.respondError(s, i, fmt.Sprintf("パネル `%s` は存在しません", panelID))
+		return
+	}
+
+	// メッセージが既に削除されていても問題ない
+	if err := s.ChannelMessageDelete(panel.ChannelID, panel.MessageID); err != nil {
+		log.Debug().Err(err).Str("panel", panel.ID).Msg("Failed to delete panel message")
+	}
+
+	log.Info().Str("panel", panel.ID).Msg("Status panel removed")
+	b.respondSuccess(s, i, fmt.Sprintf("パネル `%s` を削除しました", panel.ID))
+}
+
+// handlePanelList はパネル一覧を表示
+func (b *Bot) handlePanelList(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	panels := b.panels.list()
+
+	var builder strings.Builder
+	if len(panels) == 0 {
+		builder.WriteString("No panels.")
+	}
+	for _, panel := range panels {
+		servers := "all"
+		if len(panel.Servers) > 0 {
+			servers = strings.Join(panel.Servers, ", ")
+		}
+		builder.WriteString(fmt.Sprintf("`%s` https://discord.com/channels/%s/%s/%s (%s)\n",
+			panel.ID, b.guildID, panel.ChannelID, panel.MessageID, servers))
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: builder.String(),
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to respond to panel list command")
+	}
+}
+
+// recreatePanel は削除されたパネルのメッセージを投稿し直す
+func (b *Bot) recreatePanel(panel Panel, embed *discordgo.MessageEmbed, components []discordgo.MessageComponent) {
+	msg, err := b.session.ChannelMessageSendComplex(panel.ChannelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: components,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("panel", panel.ID).Msg("Failed to recreate status panel")
+		return
+	}
+
+	if err := b.panels.setMessage(panel.ID, msg.ID); err != nil {
+		log.Error().Err(err).Str("panel", panel.ID).Msg("Failed to save recreated panel")
+		return
+	}
+	b.updater.remember(panelRenderKey(panel.ID), renderHash(embed, components))
+
+	log.Info().
+		Str("panel", panel.ID).
+		Str("message_id", msg.ID).
+		Msg("Status panel recreated")
+}
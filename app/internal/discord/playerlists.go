@@ -0,0 +1,787 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// rconNothingChanged は RCON で変更がなかった場合の応答の接頭辞
+const rconNothingChanged = "Nothing changed"
+
+// maxMessageLength は Discord のメッセージ長の上限（余裕を持たせた値）
+const maxMessageLength = 1990
+
+// playerListResult は1つのプレイヤーリストファイルへの操作結果
+type playerListResult struct {
+	target  playerListTarget
+	changed bool
+	live    bool // RCON で稼働中のサーバーに反映した
+	err     error
+}
+
+// playerListChange はプレイヤーリストへの1つの変更
+type playerListChange struct {
+	action      string                                      // 監査ログのアクション
+	subject     string                                      // 対象（プレイヤー名または IP アドレス）
+	rcon        []string                                    // 稼働中のサーバーで実行するコマンド
+	offlineOnly string                                      // 停止中のみ反映できるオプション（稼働中に指定されたら警告）
+	apply       func(target playerListTarget) (bool, error) // 停止中のサーバーのファイルを書き換える
+}
+
+// playerOption は /mc-op, /mc-ban の playername オプション
+func playerOption(description, descriptionJa string) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "playername",
+		Description: description,
+		NameLocalizations: map[discordgo.Locale]string{
+			discordgo.Japanese: "プレイヤー名",
+		},
+		DescriptionLocalizations: map[discordgo.Locale]string{
+			discordgo.Japanese: descriptionJa,
+		},
+		Required: true,
+	}
+}
+
+// targetServerOption は対象サーバーのオプション（省略時は全サーバー）
+func targetServerOption() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "server",
+		Description: "Target server (default: all)",
+		NameLocalizations: map[discordgo.Locale]string{
+			discordgo.Japanese: "サーバー",
+		},
+		DescriptionLocalizations: map[discordgo.Locale]string{
+			discordgo.Japanese: "対象のサーバー（省略時は全サーバー）",
+		},
+		Autocomplete: true,
+	}
+}
+
+// opCommandDefinition は /mc-op コマンドの定義を返す
+func (b *Bot) opCommandDefinition() *discordgo.ApplicationCommand {
+	minLevel, maxLevel := 1.0, 4.0
+
+	return &discordgo.ApplicationCommand{
+		Name:        "mc-op",
+		Description: "Manage server operators (Admin only)",
+		DescriptionLocalizations: &map[discordgo.Locale]string{
+			discordgo.Japanese: "サーバーの OP を管理（管理者のみ）",
+		},
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "add",
+				Description: "Make a player a server operator",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "追加",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "プレイヤーを OP にする",
+				},
+				Options: []*discordgo.ApplicationCommandOption{
+					playerOption("Player name to op", "OP にするプレイヤー名"),
+					targetServerOption(),
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "level",
+						Description: "Permission level (1-4, default: 4)",
+						NameLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "レベル",
+						},
+						DescriptionLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "権限レベル（1〜4、省略時は 4）",
+						},
+						MinValue: &minLevel,
+						MaxValue: maxLevel,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+						Name:        "bypass_player_limit",
+						Description: "Allow joining when the server is full",
+						NameLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "人数制限を無視",
+						},
+						DescriptionLocalizations: map[discordgo.Locale]string{
+							discordgo.Japanese: "満員でも参加できるようにする",
+						},
+					},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "remove",
+				Description: "Remove operator status from a player",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "削除",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "プレイヤーの OP を解除",
+				},
+				Options: []*discordgo.ApplicationCommandOption{
+					playerOption("Player name to deop", "OP を解除するプレイヤー名"),
+					targetServerOption(),
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "Show server operators",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "リスト",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "OP の一覧を表示",
+				},
+				Options: []*discordgo.ApplicationCommandOption{
+					targetServerOption(),
+				},
+			},
+		},
+	}
+}
+
+// banCommandDefinition は /mc-ban コマンドの定義を返す
+func (b *Bot) banCommandDefinition() *discordgo.ApplicationCommand {
+	reasonOption := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "reason",
+		Description: "Reason shown to the player",
+		NameLocalizations: map[discordgo.Locale]string{
+			discordgo.Japanese: "理由",
+		},
+		DescriptionLocalizations: map[discordgo.Locale]string{
+			discordgo.Japanese: "プレイヤーに表示される理由",
+		},
+	}
+	durationOption := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "duration",
+		Description: "Ban duration such as 12h, 7d or 2w (default: forever)",
+		NameLocalizations: map[discordgo.Locale]string{
+			discordgo.Japanese: "期間",
+		},
+		DescriptionLocalizations: map[discordgo.Locale]string{
+			discordgo.Japanese: "BAN の期間（例: 12h, 7d, 2w。省略時は無期限）",
+		},
+	}
+	addressOption := func(description, descriptionJa string) *discordgo.ApplicationCommandOption {
+		return &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "address",
+			Description: description,
+			NameLocalizations: map[discordgo.Locale]string{
+				discordgo.Japanese: "アドレス",
+			},
+			DescriptionLocalizations: map[discordgo.Locale]string{
+				discordgo.Japanese: descriptionJa,
+			},
+			Required: true,
+		}
+	}
+
+	return &discordgo.ApplicationCommand{
+		Name:        "mc-ban",
+		Description: "Manage banned players and IP addresses (Admin only)",
+		DescriptionLocalizations: &map[discordgo.Locale]string{
+			discordgo.Japanese: "BAN したプレイヤーと IP アドレスを管理（管理者のみ）",
+		},
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "add",
+				Description: "Ban a player",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "追加",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "プレイヤーを BAN",
+				},
+				Options: []*discordgo.ApplicationCommandOption{
+					playerOption("Player name to ban", "BAN するプレイヤー名"),
+					targetServerOption(),
+					reasonOption,
+					durationOption,
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "remove",
+				Description: "Pardon a player",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "解除",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "プレイヤーの BAN を解除",
+				},
+				Options: []*discordgo.ApplicationCommandOption{
+					playerOption("Player name to pardon", "BAN を解除するプレイヤー名"),
+					targetServerOption(),
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "ip",
+				Description: "Ban an IP address",
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "IP アドレスを BAN",
+				},
+				Options: []*discordgo.ApplicationCommandOption{
+					addressOption("IP address to ban", "BAN する IP アドレス"),
+					targetServerOption(),
+					reasonOption,
+					durationOption,
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "pardon-ip",
+				Description: "Pardon an IP address",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "ip解除",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "IP アドレスの BAN を解除",
+				},
+				Options: []*discordgo.ApplicationCommandOption{
+					addressOption("IP address to pardon", "BAN を解除する IP アドレス"),
+					targetServerOption(),
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        "list",
+				Description: "Show banned players and IP addresses",
+				NameLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "リスト",
+				},
+				DescriptionLocalizations: map[discordgo.Locale]string{
+					discordgo.Japanese: "BAN の一覧を表示",
+				},
+				Options: []*discordgo.ApplicationCommandOption{
+					targetServerOption(),
+				},
+			},
+		},
+	}
+}
+
+// handleOpCommand は /mc-op コマンドを処理
+func (b *Bot) handleOpCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// 管理者権限チェック
+	if !b.isAdmin(i.Member) {
+		b.respondError(s, i, "この操作には管理者権限が必要です")
+		return
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		b.respondError(s, i, "Subcommand is required")
+		return
+	}
+
+	subcommand := options[0]
+	opts := optionMap(subcommand.Options)
+	server := optionString(opts, "server")
+
+	switch subcommand.Name {
+	case "add":
+		level := 4
+		if opt, ok := opts["level"]; ok {
+			level = int(opt.IntValue())
+		}
+		bypass := false
+		if opt, ok := opts["bypass_player_limit"]; ok {
+			bypass = opt.BoolValue()
+		}
+		offlineOnly := ""
+		if _, ok := opts["level"]; ok {
+			offlineOnly = "level"
+		} else if _, ok := opts["bypass_player_limit"]; ok {
+			offlineOnly = "bypass_player_limit"
+		}
+
+		b.modifyPlayerList(s, i, server, utilities.OpsFileName, optionString(opts, "playername"), func(profile *utilities.MojangProfile) playerListChange {
+			return playerListChange{
+				action:      audit.ActionOpAdd,
+				subject:     profile.Name,
+				rcon:        []string{"op", profile.Name},
+				offlineOnly: offlineOnly,
+				apply: func(target playerListTarget) (bool, error) {
+					return utilities.AddOp(target.path, profileFor(profile, target).ID, profile.Name, level, bypass)
+				},
+			}
+		})
+	case "remove":
+		b.modifyPlayerList(s, i, server, utilities.OpsFileName, optionString(opts, "playername"), func(profile *utilities.MojangProfile) playerListChange {
+			return playerListChange{
+				action:  audit.ActionOpRemove,
+				subject: profile.Name,
+				rcon:    []string{"deop", profile.Name},
+				apply: func(target playerListTarget) (bool, error) {
+					return utilities.RemoveOp(target.path, profileFor(profile, target).ID)
+				},
+			}
+		})
+	case "list":
+		b.handleOpList(s, i, server)
+	default:
+		b.respondError(s, i, "Unknown subcommand")
+	}
+}
+
+// handleBanCommand は /mc-ban コマンドを処理
+func (b *Bot) handleBanCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// 管理者権限チェック
+	if !b.isAdmin(i.Member) {
+		b.respondError(s, i, "この操作には管理者権限が必要です")
+		return
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		b.respondError(s, i, "Subcommand is required")
+		return
+	}
+
+	subcommand := options[0]
+	opts := optionMap(subcommand.Options)
+	server := optionString(opts, "server")
+	reason := optionString(opts, "reason")
+	source := i.Member.User.Username
+
+	duration, err := utilities.ParseListDuration(optionString(opts, "duration"))
+	if err != nil {
+		b.respondError(s, i, err.Error())
+		return
+	}
+	offlineOnly := ""
+	if duration > 0 {
+		offlineOnly = "duration"
+	}
+
+	switch subcommand.Name {
+	case "add":
+		b.modifyPlayerList(s, i, server, utilities.BannedPlayersFileName, optionString(opts, "playername"), func(profile *utilities.MojangProfile) playerListChange {
+			return playerListChange{
+				action:      audit.ActionBanAdd,
+				subject:     profile.Name,
+				rcon:        withReason([]string{"ban", profile.Name}, reason),
+				offlineOnly: offlineOnly,
+				apply: func(target playerListTarget) (bool, error) {
+					return utilities.AddBan(target.path, profileFor(profile, target).ID, profile.Name, source, banReason(reason), duration)
+				},
+			}
+		})
+	case "remove":
+		b.modifyPlayerList(s, i, server, utilities.BannedPlayersFileName, optionString(opts, "playername"), func(profile *utilities.MojangProfile) playerListChange {
+			return playerListChange{
+				action:  audit.ActionBanRemove,
+				subject: profile.Name,
+				rcon:    []string{"pardon", profile.Name},
+				apply: func(target playerListTarget) (bool, error) {
+					return utilities.RemoveBan(target.path, profileFor(profile, target).ID)
+				},
+			}
+		})
+	case "ip":
+		address := optionString(opts, "address")
+		if net.ParseIP(address) == nil {
+			b.respondError(s, i, fmt.Sprintf("Invalid IP address: %s", address))
+			return
+		}
+		b.modifyIPList(s, i, server, playerListChange{
+			action:      audit.ActionBanIPAdd,
+			subject:     address,
+			rcon:        withReason([]string{"ban-ip", address}, reason),
+			offlineOnly: offlineOnly,
+			apply: func(target playerListTarget) (bool, error) {
+				return utilities.AddIPBan(target.path, address, source, banReason(reason), duration)
+			},
+		})
+	case "pardon-ip":
+		address := optionString(opts, "address")
+		if net.ParseIP(address) == nil {
+			b.respondError(s, i, fmt.Sprintf("Invalid IP address: %s", address))
+			return
+		}
+		b.modifyIPList(s, i, server, playerListChange{
+			action:  audit.ActionBanIPRemove,
+			subject: address,
+			rcon:    []string{"pardon-ip", address},
+			apply: func(target playerListTarget) (bool, error) {
+				return utilities.RemoveIPBan(target.path, address)
+			},
+		})
+	case "list":
+		b.handleBanList(s, i, server)
+	default:
+		b.respondError(s, i, "Unknown subcommand")
+	}
+}
+
+// withReason は理由が指定されていればコマンドの末尾に付ける
+func withReason(args []string, reason string) []string {
+	if reason == "" {
+		return args
+	}
+	return append(args, reason)
+}
+
+// banReason はファイルに記録する理由を返す（省略時はサーバーと同じ既定値）
+func banReason(reason string) string {
+	if reason == "" {
+		return "Banned by an operator."
+	}
+	return reason
+}
+
+// modifyPlayerList はプレイヤー名を Mojang API で解決してから各サーバーのプレイヤーリストを変更する
+func (b *Bot) modifyPlayerList(s *discordgo.Session, i *discordgo.InteractionCreate, server, fileName, playerName string, build func(profile *utilities.MojangProfile) playerListChange) {
+	if playerName == "" {
+		b.respondError(s, i, "Player name is required")
+		return
+	}
+
+	targets, err := b.resolveServerFileTargets(server, fileName)
+	if err != nil {
+		b.respondError(s, i, err.Error())
+		return
+	}
+
+	if !b.deferEphemeral(s, i) {
+		return
+	}
+
+	profile, ok := b.fetchProfile(s, i, playerName, targets)
+	if !ok {
+		return
+	}
+
+	b.applyPlayerListChange(s, i, targets, build(profile))
+}
+
+// modifyIPList は各サーバーの banned-ips.json を変更する
+func (b *Bot) modifyIPList(s *discordgo.Session, i *discordgo.InteractionCreate, server string, change playerListChange) {
+	targets, err := b.resolveServerFileTargets(server, utilities.BannedIPsFileName)
+	if err != nil {
+		b.respondError(s, i, err.Error())
+		return
+	}
+
+	if !b.deferEphemeral(s, i) {
+		return
+	}
+
+	b.applyPlayerListChange(s, i, targets, change)
+}
+
+// resolveServerFileTargets は server オプションの値からサーバーディレクトリ内の対象ファイルを決める
+func (b *Bot) resolveServerFileTargets(value, fileName string) ([]playerListTarget, error) {
+	settings := b.settings()
+	return b.resolvePlayerListTargets(value, fileName, func(key string) string {
+		return settings.ServerFile(key, fileName)
+	})
+}
+
+// applyPlayerListChange は変更を各サーバーに適用する
+// 稼働中のサーバーには RCON で、停止中のサーバーにはファイルを直接書き換えて反映する
+func (b *Bot) applyPlayerListChange(s *discordgo.Session, i *discordgo.InteractionCreate, targets []playerListTarget, change playerListChange) {
+	ctx := context.Background()
+
+	results := make([]playerListResult, 0, len(targets))
+	for _, target := range targets {
+		result := playerListResult{target: target}
+
+		if cont := b.runningContainer(target.servers); cont != nil {
+			output, err := cont.RunRCON(ctx, change.rcon...)
+			result.live = true
+			result.err = err
+			result.changed = err == nil && !strings.HasPrefix(output, rconNothingChanged)
+			log.Debug().Str("container_id", cont.ID).Str("output", output).Msg("RCON command executed")
+		} else {
+			result.changed, result.err = change.apply(target)
+		}
+
+		for _, server := range target.servers {
+			b.recordPlayerListChange(interactionActor(i), change.action, server, change.subject, result.changed, result.err)
+		}
+		if result.err != nil {
+			log.Error().Err(result.err).Str("path", target.path).Str("action", change.action).Msg("Failed to update player list")
+		}
+		results = append(results, result)
+	}
+
+	content := b.formatPlayerListResults(change.action, change.subject, results)
+	if change.offlineOnly != "" {
+		for _, r := range results {
+			if r.live {
+				content += fmt.Sprintf("\n⚠️ `%s` はサーバー停止中のみ反映されます（稼働中のサーバーには既定値で適用しました）", change.offlineOnly)
+				break
+			}
+		}
+	}
+	b.sendFollowup(s, i, content)
+}
+
+// runningContainer はサーバーのうち稼働中のコンテナを返す（なければ nil）
+func (b *Bot) runningContainer(keys []string) *container.Container {
+	for _, key := range keys {
+		containerInterface, ok := b.appState.GetContainer(key)
+		if !ok {
+			continue
+		}
+		cont, ok := containerInterface.(*container.Container)
+		if ok && cont.Status == container.StatusRunning {
+			return cont
+		}
+	}
+	return nil
+}
+
+// playerListTexts は操作ごとの結果メッセージ（変更あり / 変更なし / 一覧の見出し）
+var playerListTexts = map[string][3]string{
+	audit.ActionWhitelistAdd:    {"を追加しました", "は既にホワイトリストに含まれています", "のホワイトリスト"},
+	audit.ActionWhitelistRemove: {"を削除しました", "はホワイトリストに含まれていません", "のホワイトリスト"},
+	audit.ActionOpAdd:           {"を OP にしました", "は既に OP です", "の OP"},
+	audit.ActionOpRemove:        {"の OP を解除しました", "は OP ではありません", "の OP"},
+	audit.ActionBanAdd:          {"を BAN しました", "は既に BAN されています", "の BAN"},
+	audit.ActionBanRemove:       {"の BAN を解除しました", "は BAN されていません", "の BAN"},
+	audit.ActionBanIPAdd:        {"を BAN しました", "は既に BAN されています", "の BAN"},
+	audit.ActionBanIPRemove:     {"の BAN を解除しました", "は BAN されていません", "の BAN"},
+}
+
+// formatPlayerListResults は追加・削除の結果メッセージを作る
+func (b *Bot) formatPlayerListResults(action, subject string, results []playerListResult) string {
+	allow_icon := b.settings().Icons["allow"]
+	deny_icon := b.settings().Icons["deny"]
+	texts := playerListTexts[action]
+	changedText, unchangedText, title := texts[0], texts[1], texts[2]
+
+	// 対象が1つの場合は1行で返す
+	if len(results) == 1 {
+		r := results[0]
+		servers := b.serverNames(r.target.servers)
+		switch {
+		case r.err != nil:
+			return fmt.Sprintf("%s エラーが発生しました (%s): %v", deny_icon, servers, r.err)
+		case r.changed:
+			return fmt.Sprintf("%s **%s** %s (%s)", allow_icon, subject, changedText, servers)
+		default:
+			return fmt.Sprintf("%s **%s** %s (%s)", allow_icon, subject, unchangedText, servers)
+		}
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("**%s** %s:\n", subject, title))
+	for _, r := range results {
+		servers := b.serverNames(r.target.servers)
+		switch {
+		case r.err != nil:
+			builder.WriteString(fmt.Sprintf("%s %s: エラー (%v)\n", deny_icon, servers, r.err))
+		case r.changed:
+			builder.WriteString(fmt.Sprintf("%s %s: %s\n", allow_icon, servers, trimParticle(changedText)))
+		default:
+			builder.WriteString(fmt.Sprintf("%s %s: %s\n", allow_icon, servers, trimParticle(unchangedText)))
+		}
+	}
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+// trimParticle は一覧表示用に先頭の助詞を取り除く
+func trimParticle(text string) string {
+	for _, particle := range []string{"を", "は", "の"} {
+		if trimmed, ok := strings.CutPrefix(text, particle); ok {
+			return strings.TrimSpace(trimmed)
+		}
+	}
+	return text
+}
+
+// deferEphemeral は時間のかかる処理のために deferred response を返す
+func (b *Bot) deferEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate) bool {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send deferred response")
+		return false
+	}
+	return true
+}
+
+// fetchProfile は Mojang API でプレイヤー情報を取得する（失敗時はフォローアップでエラーを返す）
+// 対象がすべてオフラインモードのサーバーの場合は API を使わずオフライン UUID を返す
+func (b *Bot) fetchProfile(s *discordgo.Session, i *discordgo.InteractionCreate, playerName string, targets []playerListTarget) (*utilities.MojangProfile, bool) {
+	if allOffline(targets) {
+		return utilities.OfflineProfile(playerName), true
+	}
+
+	profile, err := b.profiles.Lookup(playerName)
+	if err != nil {
+		deny_icon := b.settings().Icons["deny"]
+		content := fmt.Sprintf("%s プレイヤー名が不明です: %s", deny_icon, playerName)
+		if !errors.Is(err, utilities.ErrPlayerNotFound) {
+			content = fmt.Sprintf("%s エラーが発生しました: %v", deny_icon, err)
+		}
+
+		s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+			Content: content,
+		})
+		return nil, false
+	}
+	return profile, true
+}
+
+// allOffline は対象がすべてオフラインモードのサーバーか判定
+func allOffline(targets []playerListTarget) bool {
+	for _, target := range targets {
+		if !target.offline {
+			return false
+		}
+	}
+	return len(targets) > 0
+}
+
+// sendFollowup はフォローアップメッセージを送信し、message_deleteafter 秒後に削除する
+func (b *Bot) sendFollowup(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	msg, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content: content,
+	})
+
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send followup message")
+	} else if b.settings() != nil && b.settings().MessageDeleteAfter > 0 {
+		go func(msg *discordgo.Message) {
+			time.Sleep(time.Duration(b.settings().MessageDeleteAfter) * time.Second)
+			if err := s.FollowupMessageDelete(i.Interaction, msg.ID); err != nil {
+				log.Debug().Err(err).Msg("Failed to delete followup message")
+			}
+		}(msg)
+	}
+}
+
+// handleOpList は OP の一覧を表示
+func (b *Bot) handleOpList(s *discordgo.Session, i *discordgo.InteractionCreate, server string) {
+	targets, err := b.resolveServerFileTargets(server, utilities.OpsFileName)
+	if err != nil {
+		b.respondError(s, i, err.Error())
+		return
+	}
+
+	var builder strings.Builder
+	for _, target := range targets {
+		entries, err := utilities.LoadPlayerList[utilities.OpEntry](target.path)
+		if err != nil {
+			b.respondError(s, i, fmt.Sprintf("エラーが発生しました (%s): %v", b.serverNames(target.servers), err))
+			return
+		}
+
+		builder.WriteString(fmt.Sprintf("**%s**\n```\n", b.serverNames(target.servers)))
+		builder.WriteString(fmt.Sprintf("Operators Total: %d players\n\n", len(entries)))
+		for idx, entry := range entries {
+			bypass := ""
+			if entry.BypassesPlayerLimit {
+				bypass = ", bypasses player limit"
+			}
+			builder.WriteString(fmt.Sprintf("%2d. %-16s (level %d%s)\n", idx+1, entry.Name, entry.Level, bypass))
+		}
+		builder.WriteString("```\n")
+	}
+
+	b.respondList(s, i, builder.String())
+}
+
+// handleBanList は BAN したプレイヤーと IP アドレスの一覧を表示
+func (b *Bot) handleBanList(s *discordgo.Session, i *discordgo.InteractionCreate, server string) {
+	targets, err := b.resolveServerFileTargets(server, utilities.BannedPlayersFileName)
+	if err != nil {
+		b.respondError(s, i, err.Error())
+		return
+	}
+
+	settings := b.settings()
+	var builder strings.Builder
+	for _, target := range targets {
+		players, err := utilities.LoadPlayerList[utilities.BanEntry](target.path)
+		if err != nil {
+			b.respondError(s, i, fmt.Sprintf("エラーが発生しました (%s): %v", b.serverNames(target.servers), err))
+			return
+		}
+		ips, err := utilities.LoadPlayerList[utilities.IPBanEntry](settings.ServerFile(target.servers[0], utilities.BannedIPsFileName))
+		if err != nil {
+			b.respondError(s, i, fmt.Sprintf("エラーが発生しました (%s): %v", b.serverNames(target.servers), err))
+			return
+		}
+
+		builder.WriteString(fmt.Sprintf("**%s**\n```\n", b.serverNames(target.servers)))
+		builder.WriteString(fmt.Sprintf("Banned Players: %d, Banned IPs: %d\n\n", len(players), len(ips)))
+		for idx, entry := range players {
+			builder.WriteString(fmt.Sprintf("%2d. %-16s (expires: %s, by: %s)\n    -  %s\n", idx+1, entry.Name, entry.Expires, entry.Source, entry.Reason))
+		}
+		for idx, entry := range ips {
+			builder.WriteString(fmt.Sprintf("%2d. %-16s (expires: %s, by: %s)\n    -  %s\n", len(players)+idx+1, entry.IP, entry.Expires, entry.Source, entry.Reason))
+		}
+		builder.WriteString("```\n")
+	}
+
+	b.respondList(s, i, builder.String())
+}
+
+// respondList は一覧を ephemeral で返す（message_deleteafter は適用しない）
+func (b *Bot) respondList(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fitMessage(content),
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	if err != nil {
+		log.Error().Err(err).Str("command", i.ApplicationCommandData().Name).Msg("Failed to respond to list command")
+	}
+}
+
+// sendListFollowup は一覧をフォローアップで送信（deferEphemeral の後に使う、message_deleteafter は適用しない）
+func (b *Bot) sendListFollowup(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	_, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content: fitMessage(content),
+	})
+	if err != nil {
+		log.Error().Err(err).Str("command", i.ApplicationCommandData().Name).Msg("Failed to send list followup")
+	}
+}
+
+// fitMessage はコードブロックを含むメッセージを Discord のメッセージ長の上限に収める
+func fitMessage(content string) string {
+	if len(content) <= maxMessageLength {
+		return content
+	}
+	cut := maxMessageLength - 4
+	if idx := strings.LastIndex(content[:cut], "\n"); idx >= 0 {
+		cut = idx
+	} else {
+		// 改行が無い場合は文字の途中で切らないよう、文字の先頭まで戻す
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+	}
+	return content[:cut] + "\n```"
+}
@@ -5,15 +5,10 @@ import (
 
 	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
 	"github.com/bwmarrin/discordgo"
-	"github.com/rs/zerolog/log"
 )
 
-// UpdatePresence はBotのステータスメッセージを更新
-func (b *Bot) UpdatePresence() {
-	if b.session == nil {
-		return
-	}
-
+// buildPresence はBotのステータスメッセージを構築
+func (b *Bot) buildPresence() discordgo.UpdateStatusData {
 	containers := b.appState.GetAllContainers()
 
 	// オンラインプレイヤー数をカウント
@@ -53,7 +48,7 @@ func (b *Bot) UpdatePresence() {
 		message = "/mc-list | 待機中"
 	}
 
-	err := b.session.UpdateStatusComplex(discordgo.UpdateStatusData{
+	return discordgo.UpdateStatusData{
 		Status: string(status),
 		Activities: []*discordgo.Activity{
 			{
@@ -61,14 +56,5 @@ func (b *Bot) UpdatePresence() {
 				Type: activityType,
 			},
 		},
-	})
-
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to update presence")
-	} else {
-		log.Debug().
-			Str("status", string(status)).
-			Str("message", message).
-			Msg("Presence updated")
 	}
 }
@@ -0,0 +1,112 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// reloadTimeout は設定の再読み込み結果を待つ最大時間
+const reloadTimeout = 30 * time.Second
+
+// reloadCommandDefinition は /mc-reload コマンドの定義を返す
+func (b *Bot) reloadCommandDefinition() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "mc-reload",
+		Description: "Reload settings.json without restarting (Admin only)",
+		NameLocalizations: &map[discordgo.Locale]string{
+			discordgo.Japanese: "mc-設定再読み込み",
+		},
+		DescriptionLocalizations: &map[discordgo.Locale]string{
+			discordgo.Japanese: "再起動せずに settings.json を再読み込み（管理者のみ）",
+		},
+	}
+}
+
+// handleReloadCommand は /mc-reload コマンドを処理
+func (b *Bot) handleReloadCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// 管理者権限チェック
+	if !b.isAdmin(i.Member) {
+		b.respondError(s, i, "この操作には管理者権限が必要です")
+		return
+	}
+
+	// Deferred response
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send deferred response")
+		return
+	}
+
+	result, err := b.requestReload(i)
+	if err != nil {
+		deny_icon := b.settings().Icons["deny"]
+		s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+			Content: fmt.Sprintf("%s 設定を再読み込みできませんでした: %v", deny_icon, err),
+		})
+		return
+	}
+
+	allow_icon := b.settings().Icons["allow"]
+	s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content: fmt.Sprintf("%s 設定を再読み込みしました\n%s", allow_icon, formatSettingsDiff(result.Message)),
+	})
+}
+
+// requestReload は main.go に設定の再読み込みを依頼して結果を待つ
+func (b *Bot) requestReload(i *discordgo.InteractionCreate) (routine.CommandResult, error) {
+	reply := make(chan routine.CommandResult, 1)
+	cmd := routine.Command{
+		Type:     "reload",
+		Source:   audit.SourceSlashCommand,
+		UserID:   i.Member.User.ID,
+		UserName: i.Member.User.Username,
+		Reply:    reply,
+	}
+
+	select {
+	case b.commandChan <- cmd:
+	default:
+		return routine.CommandResult{}, fmt.Errorf("command queue is full")
+	}
+
+	select {
+	case result := <-reply:
+		return result, result.Err
+	case <-time.After(reloadTimeout):
+		return routine.CommandResult{}, fmt.Errorf("timed out waiting for reload")
+	}
+}
+
+// formatSettingsDiff は差分をコードブロックに整形（長すぎる場合は省略）
+func formatSettingsDiff(diff string) string {
+	if diff == "" {
+		return "変更はありません"
+	}
+
+	const maxLength = 1800
+	if len(diff) > maxLength {
+		cut := maxLength
+		if idx := strings.LastIndex(diff[:cut], "\n"); idx >= 0 {
+			cut = idx
+		} else {
+			// 改行が無い場合は文字の途中で切らないよう、文字の先頭まで戻す
+			for cut > 0 && !utf8.RuneStart(diff[cut]) {
+				cut--
+			}
+		}
+		diff = diff[:cut] + "\n..."
+	}
+	return "```diff\n" + diff + "\n```"
+}
@@ -0,0 +1,270 @@
+package discord
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// updateWindow は更新依頼をまとめる時間（この間の依頼は1回の反映にまとめる）
+const updateWindow = 2 * time.Second
+
+// presenceRenderKey はプレゼンスの描画内容を記録するキー
+const presenceRenderKey = "presence"
+
+// panelRenderKey はパネルの描画内容を記録するキー
+func panelRenderKey(panelID string) string {
+	return "panel:" + panelID
+}
+
+// updateWorker はプレゼンスとパネルへの状態反映を main ループから切り離して行う
+// - 短時間の更新依頼はまとめて1回にする
+// - 描画内容が前回と同じなら API を呼ばない
+// - レート制限中のバケットは retry-after まで待ってから再試行する
+type updateWorker struct {
+	bot      *Bot
+	window   time.Duration
+	requests chan struct{}
+
+	mu       sync.Mutex
+	rendered map[string]string    // キー → 最後に反映した内容のハッシュ
+	retryAt  map[string]time.Time // バケット → 再試行可能になる時刻
+}
+
+// newUpdateWorker は新しい updateWorker を作成
+func newUpdateWorker(bot *Bot, window time.Duration) *updateWorker {
+	return &updateWorker{
+		bot:      bot,
+		window:   window,
+		requests: make(chan struct{}, 1),
+		rendered: make(map[string]string),
+		retryAt:  make(map[string]time.Time),
+	}
+}
+
+// RequestUpdate はプレゼンスとパネルの更新を依頼する（ブロックしない）
+func (b *Bot) RequestUpdate() {
+	b.updater.request()
+}
+
+// request は更新依頼を積む（既に依頼済みなら何もしない）
+func (w *updateWorker) request() {
+	select {
+	case w.requests <- struct{}{}:
+	default:
+	}
+}
+
+// remember は反映済みの描画内容を記録する
+func (w *updateWorker) remember(key, hash string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rendered[key] = hash
+}
+
+// forget は記録を消して次回の反映を強制する
+func (w *updateWorker) forget(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.rendered, key)
+}
+
+// unchanged は描画内容が前回の反映と同じか判定
+func (w *updateWorker) unchanged(key, hash string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rendered[key] == hash
+}
+
+// run は更新ワーカーのループ
+func (w *updateWorker) run(ctx context.Context) {
+	var timer *time.Timer
+	var fire <-chan time.Time
+
+	log.Info().Dur("window", w.window).Msg("Discord update worker started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			log.Info().Msg("Discord update worker shutting down")
+			return
+
+		case <-w.requests:
+			// 最初の依頼から window 経過後にまとめて反映
+			if fire == nil {
+				timer = time.NewTimer(w.window)
+				fire = timer.C
+			}
+
+		case <-fire:
+			fire = nil
+			if retry := w.flush(); retry > 0 {
+				// レート制限で反映できなかったものは待ってから再試行
+				log.Debug().Dur("retry_in", retry).Msg("Discord updates deferred by rate limit")
+				timer = time.NewTimer(retry)
+				fire = timer.C
+			}
+		}
+	}
+}
+
+// flush はプレゼンスとパネルを反映する
+// レート制限で反映できなかったものがあれば、次に試行すべきまでの時間を返す
+func (w *updateWorker) flush() time.Duration {
+	if w.bot.session == nil {
+		return 0
+	}
+
+	w.flushPresence()
+	return w.flushPanels()
+}
+
+// flushPresence はプレゼンスを反映する
+func (w *updateWorker) flushPresence() {
+	data := w.bot.buildPresence()
+
+	hash := hashJSON(data)
+	if w.unchanged(presenceRenderKey, hash) {
+		return
+	}
+
+	if err := w.bot.session.UpdateStatusComplex(data); err != nil {
+		log.Error().Err(err).Msg("Failed to update presence")
+		return
+	}
+
+	w.remember(presenceRenderKey, hash)
+	log.Debug().
+		Str("status", data.Status).
+		Str("message", data.Activities[0].Name).
+		Msg("Presence updated")
+}
+
+// flushPanels はすべてのパネルを反映する
+func (w *updateWorker) flushPanels() time.Duration {
+	var retry time.Duration
+	deferTo := func(wait time.Duration) {
+		if retry == 0 || wait < retry {
+			retry = wait
+		}
+	}
+
+	for _, panel := range w.bot.panels.list() {
+		embed := w.bot.buildStatusEmbed(panel.Servers)
+		components := w.bot.buildActionButtons(panel.Servers)
+
+		key := panelRenderKey(panel.ID)
+		hash := renderHash(embed, components)
+		if w.unchanged(key, hash) {
+			continue
+		}
+
+		// レート制限中のバケットは後回し（discordgo はメッセージの編集をチャンネル単位のバケットで管理する）
+		bucket := discordgo.EndpointChannelMessage(panel.ChannelID, "")
+		if wait := w.bucketWait(bucket); wait > 0 {
+			deferTo(wait)
+			continue
+		}
+
+		_, err := w.bot.session.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			Channel:    panel.ChannelID,
+			ID:         panel.MessageID,
+			Embeds:     &[]*discordgo.MessageEmbed{embed},
+			Components: &components,
+		}, discordgo.WithRetryOnRatelimit(false))
+		if err == nil {
+			w.remember(key, hash)
+			continue
+		}
+
+		var rateLimitErr *discordgo.RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			log.Warn().
+				Str("panel", panel.ID).
+				Dur("retry_after", rateLimitErr.RetryAfter).
+				Msg("Rate limited while updating status panel")
+			w.setRetryAt(bucket, rateLimitErr.RetryAfter)
+			deferTo(rateLimitErr.RetryAfter)
+			continue
+		}
+
+		switch restErrorCode(err) {
+		case discordgo.ErrCodeUnknownMessage:
+			// メッセージが削除されている場合は再作成
+			w.bot.recreatePanel(panel, embed, components)
+		case discordgo.ErrCodeUnknownChannel:
+			// チャンネルごと削除されている場合は登録を解除
+			log.Warn().Str("panel", panel.ID).Msg("Panel channel no longer exists, removing panel")
+			if _, _, err := w.bot.panels.remove(panel.ID); err != nil {
+				log.Error().Err(err).Str("panel", panel.ID).Msg("Failed to remove panel")
+			}
+			w.forget(key)
+		default:
+			log.Error().
+				Err(err).
+				Str("panel", panel.ID).
+				Str("channel_id", panel.ChannelID).
+				Str("message_id", panel.MessageID).
+				Msg("Failed to update status panel")
+		}
+	}
+
+	return retry
+}
+
+// bucketWait はバケットが利用可能になるまでの時間を返す
+// retry-after による待機と discordgo のバケット情報（残り回数・global 制限）の両方を考慮する
+func (w *updateWorker) bucketWait(bucket string) time.Duration {
+	w.mu.Lock()
+	until, ok := w.retryAt[bucket]
+	if ok && !time.Now().Before(until) {
+		delete(w.retryAt, bucket)
+		ok = false
+	}
+	w.mu.Unlock()
+
+	if ok {
+		return time.Until(until)
+	}
+
+	limiter := w.bot.session.Ratelimiter
+	return limiter.GetWaitTime(limiter.GetBucket(bucket), 1)
+}
+
+// setRetryAt は retry-after をバケットに記録する
+func (w *updateWorker) setRetryAt(bucket string, after time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.retryAt[bucket] = time.Now().Add(after)
+}
+
+// renderHash はパネルの描画内容のハッシュを計算（タイムスタンプは除外）
+func renderHash(embed *discordgo.MessageEmbed, components []discordgo.MessageComponent) string {
+	copied := *embed
+	copied.Timestamp = ""
+	return hashJSON(struct {
+		Embed      discordgo.MessageEmbed       `json:"embed"`
+		Components []discordgo.MessageComponent `json:"components"`
+	}{copied, components})
+}
+
+// hashJSON は値を JSON 化したもののハッシュを返す
+func hashJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		// ハッシュ化できない場合は毎回反映する
+		return fmt.Sprintf("unhashable-%d", time.Now().UnixNano())
+	}
+	hash := sha256.Sum256(data)
+	return fmt.Sprintf("%x", hash[:8])
+}
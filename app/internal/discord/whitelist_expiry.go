@@ -0,0 +1,106 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/rs/zerolog/log"
+)
+
+// whitelistExpiryInterval は期限付きのホワイトリストを確認する間隔
+const whitelistExpiryInterval = time.Minute
+
+// expiredPlayer は期限切れで削除したプレイヤー
+type expiredPlayer struct {
+	entry   utilities.WhitelistEntry
+	servers []string
+}
+
+// runWhitelistExpiry は期限切れのホワイトリストのエントリを定期的に削除する
+func (b *Bot) runWhitelistExpiry(ctx context.Context) {
+	ticker := time.NewTicker(whitelistExpiryInterval)
+	defer ticker.Stop()
+
+	// 停止中に期限が切れたものを先に片付ける
+	b.expireWhitelistEntries(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.expireWhitelistEntries(ctx)
+		}
+	}
+}
+
+// expireWhitelistEntries は期限切れのエントリを全サーバーのホワイトリストから削除する
+// 稼働中のサーバーはホワイトリストを再読み込みしてからプレイヤーをキックし、追加したユーザーに DM で知らせる
+func (b *Bot) expireWhitelistEntries(ctx context.Context) {
+	targets, err := b.resolveWhitelistTargets("")
+	if err != nil {
+		log.Debug().Err(err).Msg("Skipping whitelist expiry")
+		return
+	}
+
+	actor := audit.Entry{Source: audit.SourceSchedule, Detail: "expired"}
+	now := time.Now()
+
+	var expired []*expiredPlayer
+	byUUID := make(map[string]*expiredPlayer)
+	for _, target := range targets {
+		entries, err := utilities.RemoveExpiredFromWhitelist(target.path, now)
+		if err != nil {
+			log.Error().Err(err).Str("path", target.path).Msg("Failed to remove expired whitelist entries")
+			continue
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		for _, entry := range entries {
+			for _, server := range target.servers {
+				b.recordPlayerListChange(actor, audit.ActionWhitelistRemove, server, entry.Name, true, nil)
+			}
+
+			key := strings.ToLower(entry.UUID)
+			player, ok := byUUID[key]
+			if !ok {
+				player = &expiredPlayer{entry: entry}
+				byUUID[key] = player
+				expired = append(expired, player)
+			}
+			player.servers = append(player.servers, target.servers...)
+		}
+
+		// 再読み込みしてからキックする（キック直後に再接続されないように）
+		b.refreshContainersWhitelist(target.servers)
+		if cont := b.runningContainer(target.servers); cont != nil {
+			for _, entry := range entries {
+				output, err := cont.RunRCON(ctx, "kick", entry.Name, "Your temporary whitelist access has expired")
+				if err != nil {
+					log.Warn().Err(err).Str("container_id", cont.ID).Str("player", entry.Name).Msg("Failed to kick expired player")
+					continue
+				}
+				log.Debug().Str("container_id", cont.ID).Str("output", output).Msg("RCON command executed")
+			}
+		}
+	}
+
+	for _, player := range expired {
+		log.Info().
+			Str("player", player.entry.Name).
+			Strs("servers", player.servers).
+			Msg("Temporary whitelist entry expired")
+
+		b.unlinkIfUnlisted(&utilities.MojangProfile{ID: player.entry.UUID, Name: player.entry.Name})
+		if player.entry.AddedUserID != "" {
+			b.sendDirectMessage(player.entry.AddedUserID, fmt.Sprintf("**%s** の期限付きホワイトリスト登録が期限切れになり、削除しました (%s)",
+				player.entry.Name, b.serverNames(player.servers)))
+		}
+	}
+}
@@ -0,0 +1,668 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// ホワイトリストのインポート確認ボタンの CustomID
+const (
+	whitelistImportApplyAction  = "wl_import_apply"  // "wl_import_apply:<インポートID>"
+	whitelistImportCancelAction = "wl_import_cancel" // "wl_import_cancel:<インポートID>"
+)
+
+const (
+	// importTTL は確認待ちのインポートを保持する時間
+	importTTL = 15 * time.Minute
+	// maxImportFileSize は添付ファイルの上限サイズ
+	maxImportFileSize = 1 << 20
+)
+
+var (
+	// playerNamePattern は Minecraft のプレイヤー名の形式
+	playerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,16}$`)
+	// uuidPattern はハイフンの有無を問わない UUID の形式
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}$`)
+)
+
+// importRow はインポート元の1行（UUID は省略可）
+type importRow struct {
+	name string
+	uuid string
+}
+
+// uuidMismatch は同じ名前で UUID が異なるエントリ（適用するとインポート側で置き換える）
+type uuidMismatch struct {
+	current  utilities.WhitelistEntry
+	imported utilities.WhitelistEntry
+}
+
+// importPlan は1つのホワイトリストファイルへの変更内容
+type importPlan struct {
+	target     playerListTarget
+	additions  []utilities.WhitelistEntry
+	removals   []utilities.WhitelistEntry
+	mismatches []uuidMismatch
+}
+
+// empty は変更がないか判定
+func (p importPlan) empty() bool {
+	return len(p.additions) == 0 && len(p.removals) == 0 && len(p.mismatches) == 0
+}
+
+// pendingImport は確認待ちのインポート
+type pendingImport struct {
+	userID    string
+	plans     []importPlan
+	createdAt time.Time
+}
+
+// importStore は確認待ちのインポートを保持する（再起動で消えてよいためメモリのみ）
+type importStore struct {
+	mu      sync.Mutex
+	imports map[string]pendingImport
+}
+
+// newImportStore は新しい importStore を作成
+func newImportStore() *importStore {
+	return &importStore{imports: make(map[string]pendingImport)}
+}
+
+// add はインポートを追加し、期限切れのものを片付ける
+func (s *importStore) add(id string, pending pendingImport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, p := range s.imports {
+		if time.Since(p.createdAt) > importTTL {
+			delete(s.imports, key)
+		}
+	}
+	s.imports[id] = pending
+}
+
+// take はインポートを取り出す（同時に押された場合は先に取り出せた方だけが処理する）
+func (s *importStore) take(id string) (pendingImport, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.imports[id]
+	if !ok {
+		return pendingImport{}, false
+	}
+	delete(s.imports, id)
+	if time.Since(pending.createdAt) > importTTL {
+		return pendingImport{}, false
+	}
+	return pending, true
+}
+
+// handleWhitelistImport は添付ファイルまたは他のサーバーのホワイトリストを読み込み、差分を確認ボタン付きで表示
+func (b *Bot) handleWhitelistImport(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	// 管理者権限チェック
+	if !b.isAdmin(i.Member) {
+		b.respondError(s, i, "この操作には管理者権限が必要です")
+		return
+	}
+
+	options := optionMap(subcommand.Options)
+	from := optionString(options, "from")
+	replace := false
+	if opt, ok := options["replace"]; ok {
+		replace = opt.BoolValue()
+	}
+
+	var attachment *discordgo.MessageAttachment
+	if opt, ok := options["file"]; ok {
+		attachment = i.ApplicationCommandData().Resolved.Attachments[opt.Value.(string)]
+	}
+	if (attachment == nil) == (from == "") {
+		b.respondError(s, i, "Specify either file or from")
+		return
+	}
+	if attachment != nil && attachment.Size > maxImportFileSize {
+		b.respondError(s, i, fmt.Sprintf("File is too large (max %d KB)", maxImportFileSize/1024))
+		return
+	}
+
+	var source playerListTarget
+	if from != "" {
+		if strings.EqualFold(from, whitelistAllServers) {
+			b.respondError(s, i, "Specify a single server for from")
+			return
+		}
+		sources, err := b.resolveWhitelistTargets(from)
+		if err != nil {
+			b.respondError(s, i, err.Error())
+			return
+		}
+		source = sources[0]
+	}
+
+	targets, err := b.resolveWhitelistTargets(optionString(options, "server"))
+	if err != nil {
+		b.respondError(s, i, err.Error())
+		return
+	}
+
+	if !b.deferEphemeral(s, i) {
+		return
+	}
+
+	deny_icon := b.settings().Icons["deny"]
+
+	var rows []importRow
+	if attachment != nil {
+		rows, err = downloadImportRows(attachment)
+	} else {
+		rows, err = whitelistImportRows(source)
+	}
+	if err != nil {
+		b.sendFollowup(s, i, fmt.Sprintf("%s 読み込みに失敗しました: %v", deny_icon, err))
+		return
+	}
+	if len(rows) == 0 {
+		b.sendFollowup(s, i, fmt.Sprintf("%s インポートするプレイヤーがいません", deny_icon))
+		return
+	}
+
+	plans, unresolved, err := b.planImport(rows, targets, replace)
+	if err != nil {
+		b.sendFollowup(s, i, fmt.Sprintf("%s エラーが発生しました: %v", deny_icon, err))
+		return
+	}
+
+	content := b.formatImportPlans(plans, unresolved, replace)
+	hasChanges := false
+	for _, plan := range plans {
+		if !plan.empty() {
+			hasChanges = true
+			break
+		}
+	}
+	if !hasChanges {
+		b.sendFollowup(s, i, content)
+		return
+	}
+
+	id := newID()
+	b.imports.add(id, pendingImport{userID: i.Member.User.ID, plans: plans, createdAt: time.Now()})
+
+	_, err = s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content:    content,
+		Components: importButtons(id),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send import preview")
+	}
+}
+
+// downloadImportRows は添付ファイルをダウンロードして読み込む
+func downloadImportRows(attachment *discordgo.MessageAttachment) ([]importRow, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Get(attachment.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", attachment.Filename, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: status %d", attachment.Filename, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImportFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", attachment.Filename, err)
+	}
+	if len(data) > maxImportFileSize {
+		return nil, fmt.Errorf("%s is too large", attachment.Filename)
+	}
+	return parseImportRows(data)
+}
+
+// whitelistImportRows は他のサーバーのホワイトリストを読み込む
+// オフラインモードのサーバーの UUID は名前から作られたものなので、名前だけを使って解決し直す
+func whitelistImportRows(source playerListTarget) ([]importRow, error) {
+	entries, err := utilities.LoadWhitelist(source.path)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]importRow, 0, len(entries))
+	for _, entry := range entries {
+		row := importRow{name: entry.Name, uuid: entry.UUID}
+		if source.offline {
+			row.uuid = ""
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseImportRows は JSON（whitelist.json 形式または名前の配列）か CSV（名前と任意の UUID）を読み込む
+func parseImportRows(data []byte) ([]importRow, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // BOM
+	trimmed := bytes.TrimSpace(data)
+
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var entries []utilities.WhitelistEntry
+		if err := json.Unmarshal(trimmed, &entries); err == nil {
+			rows := make([]importRow, 0, len(entries))
+			for _, entry := range entries {
+				rows = append(rows, importRow{name: entry.Name, uuid: entry.UUID})
+			}
+			return rows, nil
+		}
+
+		var names []string
+		if err := json.Unmarshal(trimmed, &names); err != nil {
+			return nil, fmt.Errorf("unsupported JSON (expected whitelist.json format or an array of names)")
+		}
+		rows := make([]importRow, 0, len(names))
+		for _, name := range names {
+			rows = append(rows, importRow{name: name})
+		}
+		return rows, nil
+	}
+
+	reader := csv.NewReader(bytes.NewReader(trimmed))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV: %w", err)
+	}
+
+	rows := make([]importRow, 0, len(records))
+	for idx, record := range records {
+		var row importRow
+		for _, field := range record {
+			field = strings.TrimSpace(field)
+			switch {
+			case field == "":
+			case uuidPattern.MatchString(field):
+				row.uuid = field
+			case row.name == "":
+				row.name = field
+			}
+		}
+		// 見出し行は読み飛ばす
+		if idx == 0 && row.uuid == "" && strings.EqualFold(row.name, "name") {
+			continue
+		}
+		if row.name != "" || row.uuid != "" {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// planImport は対象ごとに現在のホワイトリストとの差分を作る
+// replace が true の場合はインポートに含まれないプレイヤーを削除する
+func (b *Bot) planImport(rows []importRow, targets []playerListTarget, replace bool) ([]importPlan, []string, error) {
+	profiles := make(map[string]*utilities.MojangProfile) // 名前 → オンラインのプロフィール（nil は解決失敗）
+	unresolvedSet := make(map[string]bool)
+
+	plans := make([]importPlan, 0, len(targets))
+	for _, target := range targets {
+		current, err := utilities.LoadWhitelist(target.path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", b.serverNames(target.servers), err)
+		}
+
+		byUUID := make(map[string]utilities.WhitelistEntry, len(current))
+		byName := make(map[string]utilities.WhitelistEntry, len(current))
+		for _, entry := range current {
+			byUUID[strings.ToLower(entry.UUID)] = entry
+			byName[strings.ToLower(entry.Name)] = entry
+		}
+
+		plan := importPlan{target: target}
+		imported := make(map[string]bool)
+		replaced := make(map[string]bool)
+		for _, row := range rows {
+			entry, ok := b.resolveImportRow(row, target, profiles)
+			if !ok {
+				label := row.name
+				if label == "" {
+					label = row.uuid
+				}
+				unresolvedSet[label] = true
+				continue
+			}
+
+			uuid := strings.ToLower(entry.UUID)
+			if imported[uuid] {
+				continue
+			}
+			imported[uuid] = true
+
+			if _, ok := byUUID[uuid]; ok {
+				continue
+			}
+			if existing, ok := byName[strings.ToLower(entry.Name)]; ok && !replaced[strings.ToLower(existing.UUID)] {
+				plan.mismatches = append(plan.mismatches, uuidMismatch{current: existing, imported: entry})
+				replaced[strings.ToLower(existing.UUID)] = true
+				continue
+			}
+			plan.additions = append(plan.additions, entry)
+		}
+
+		if replace {
+			for _, entry := range current {
+				uuid := strings.ToLower(entry.UUID)
+				if !imported[uuid] && !replaced[uuid] {
+					plan.removals = append(plan.removals, entry)
+				}
+			}
+		}
+		plans = append(plans, plan)
+	}
+
+	unresolved := make([]string, 0, len(unresolvedSet))
+	for name := range unresolvedSet {
+		unresolved = append(unresolved, name)
+	}
+	sort.Strings(unresolved)
+	return plans, unresolved, nil
+}
+
+// resolveImportRow はインポート元の1行を対象のサーバーのエントリにする
+// UUID がある行はそのまま使い、名前だけの行は Mojang API（オフラインモードはオフライン UUID）で解決する
+func (b *Bot) resolveImportRow(row importRow, target playerListTarget, profiles map[string]*utilities.MojangProfile) (utilities.WhitelistEntry, bool) {
+	if !playerNamePattern.MatchString(row.name) {
+		return utilities.WhitelistEntry{}, false
+	}
+
+	if row.uuid != "" && !target.offline {
+		uuid := strings.ToLower(strings.ReplaceAll(row.uuid, "-", ""))
+		return utilities.WhitelistEntry{UUID: utilities.FormatUUID(uuid), Name: row.name}, true
+	}
+
+	profile, cached := profiles[strings.ToLower(row.name)]
+	if !cached {
+		var err error
+		profile, err = b.profiles.Lookup(row.name)
+		if err != nil {
+			if !errors.Is(err, utilities.ErrPlayerNotFound) {
+				log.Warn().Err(err).Str("player", row.name).Msg("Failed to resolve player for import")
+			}
+			profile = nil
+		}
+		profiles[strings.ToLower(row.name)] = profile
+	}
+
+	if target.offline {
+		name := row.name
+		if profile != nil {
+			name = profile.Name // 大文字小文字を正しい名前に揃える
+		}
+		profile = utilities.OfflineProfile(name)
+	}
+	if profile == nil {
+		return utilities.WhitelistEntry{}, false
+	}
+	return utilities.WhitelistEntry{UUID: utilities.FormatUUID(profile.ID), Name: profile.Name}, true
+}
+
+// formatImportPlans は差分のプレビューを作る
+func (b *Bot) formatImportPlans(plans []importPlan, unresolved []string, replace bool) string {
+	var builder strings.Builder
+	mode := "追加のみ"
+	if replace {
+		mode = "置き換え"
+	}
+	builder.WriteString(fmt.Sprintf("**ホワイトリストのインポート**（%s）\n", mode))
+
+	for _, plan := range plans {
+		builder.WriteString(fmt.Sprintf("**%s** (+%d −%d ~%d)\n",
+			b.serverNames(plan.target.servers), len(plan.additions), len(plan.removals), len(plan.mismatches)))
+		if plan.empty() {
+			builder.WriteString("変更はありません\n")
+			continue
+		}
+
+		builder.WriteString("```diff\n")
+		for _, entry := range plan.additions {
+			builder.WriteString(fmt.Sprintf("+ %-16s %s\n", entry.Name, entry.UUID))
+		}
+		for _, entry := range plan.removals {
+			builder.WriteString(fmt.Sprintf("- %-16s %s\n", entry.Name, entry.UUID))
+		}
+		for _, m := range plan.mismatches {
+			builder.WriteString(fmt.Sprintf("! %-16s %s → %s\n", m.imported.Name, m.current.UUID, m.imported.UUID))
+		}
+		builder.WriteString("```\n")
+	}
+
+	if len(unresolved) > 0 {
+		builder.WriteString(fmt.Sprintf("⚠️ 解決できなかったプレイヤー（%d）: %s\n", len(unresolved), strings.Join(unresolved, ", ")))
+	}
+	return fitMessage(strings.TrimSuffix(builder.String(), "\n"))
+}
+
+// importButtons はインポートの確認ボタンを作る
+func importButtons(id string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Apply",
+					Style:    discordgo.SuccessButton,
+					CustomID: whitelistImportApplyAction + ":" + id,
+				},
+				discordgo.Button{
+					Label:    "Cancel",
+					Style:    discordgo.SecondaryButton,
+					CustomID: whitelistImportCancelAction + ":" + id,
+				},
+			},
+		},
+	}
+}
+
+// handleImportDecision はインポートの Apply / Cancel ボタンを処理
+func (b *Bot) handleImportDecision(s *discordgo.Session, i *discordgo.InteractionCreate, action, id string) {
+	// 管理者権限チェック
+	if !b.isAdmin(i.Member) {
+		b.respondError(s, i, "この操作には管理者権限が必要です")
+		return
+	}
+
+	pending, ok := b.imports.take(id)
+	if !ok {
+		b.respondError(s, i, "このインポートは既に処理されたか、期限切れです")
+		return
+	}
+
+	if action == whitelistImportCancelAction {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    "インポートをキャンセルしました",
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to respond to import cancel")
+		}
+		return
+	}
+
+	// ファイル書き込みに時間がかかる場合があるため先に応答
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send deferred response")
+	}
+
+	allow_icon := b.settings().Icons["allow"]
+	deny_icon := b.settings().Icons["deny"]
+	actor := interactionActor(i)
+
+	var builder strings.Builder
+	changedServers := make([]string, 0)
+	for _, plan := range pending.plans {
+		if plan.empty() {
+			continue
+		}
+
+		servers := b.serverNames(plan.target.servers)
+		err := applyImportPlan(plan, pending.userID)
+		detail := fmt.Sprintf("+%d -%d ~%d", len(plan.additions), len(plan.removals), len(plan.mismatches))
+		for _, server := range plan.target.servers {
+			b.recordPlayerListChange(actor, audit.ActionWhitelistImport, server, detail, true, err)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("path", plan.target.path).Msg("Failed to import whitelist")
+			builder.WriteString(fmt.Sprintf("%s %s: エラー (%v)\n", deny_icon, servers, err))
+			continue
+		}
+		changedServers = append(changedServers, plan.target.servers...)
+		builder.WriteString(fmt.Sprintf("%s %s: %s\n", allow_icon, servers, detail))
+	}
+
+	b.refreshContainersWhitelist(changedServers)
+
+	content := "**ホワイトリストをインポートしました**\n" + strings.TrimSuffix(builder.String(), "\n")
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content:    &content,
+		Components: &[]discordgo.MessageComponent{},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to update import message")
+	}
+}
+
+// applyImportPlan は差分を適用する
+// プレビュー後に他の操作で変わっていてもよいように、最新のファイルに対して UUID 単位で反映する
+func applyImportPlan(plan importPlan, addedUserID string) error {
+	drop := make(map[string]bool)
+	for _, entry := range plan.removals {
+		drop[strings.ToLower(entry.UUID)] = true
+	}
+	additions := append([]utilities.WhitelistEntry{}, plan.additions...)
+	for _, m := range plan.mismatches {
+		drop[strings.ToLower(m.current.UUID)] = true
+		additions = append(additions, m.imported)
+	}
+
+	return utilities.UpdateWhitelist(plan.target.path, func(entries []utilities.WhitelistEntry) ([]utilities.WhitelistEntry, bool, error) {
+		kept := make([]utilities.WhitelistEntry, 0, len(entries)+len(additions))
+		present := make(map[string]bool)
+		for _, entry := range entries {
+			uuid := strings.ToLower(entry.UUID)
+			if drop[uuid] {
+				continue
+			}
+			kept = append(kept, entry)
+			present[uuid] = true
+		}
+		for _, entry := range additions {
+			if present[strings.ToLower(entry.UUID)] {
+				continue
+			}
+			entry.AddedUserID = addedUserID
+			kept = append(kept, entry)
+		}
+		return kept, true, nil
+	})
+}
+
+// handleWhitelistExport はホワイトリストをファイルとして返す
+func (b *Bot) handleWhitelistExport(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	// 管理者権限チェック
+	if !b.isAdmin(i.Member) {
+		b.respondError(s, i, "この操作には管理者権限が必要です")
+		return
+	}
+
+	options := optionMap(subcommand.Options)
+	format := optionString(options, "format")
+	if format == "" {
+		format = "json"
+	}
+
+	targets, err := b.resolveWhitelistTargets(optionString(options, "server"))
+	if err != nil {
+		b.respondError(s, i, err.Error())
+		return
+	}
+
+	files := make([]*discordgo.File, 0, len(targets))
+	var builder strings.Builder
+	for _, target := range targets {
+		entries, err := utilities.LoadWhitelist(target.path)
+		if err != nil {
+			b.respondError(s, i, fmt.Sprintf("エラーが発生しました (%s): %v", b.serverNames(target.servers), err))
+			return
+		}
+
+		data, contentType, err := encodeWhitelist(entries, format)
+		if err != nil {
+			b.respondError(s, i, fmt.Sprintf("エラーが発生しました: %v", err))
+			return
+		}
+
+		files = append(files, &discordgo.File{
+			Name:        fmt.Sprintf("whitelist-%s.%s", strings.Join(target.servers, "+"), format),
+			ContentType: contentType,
+			Reader:      bytes.NewReader(data),
+		})
+		builder.WriteString(fmt.Sprintf("**%s**: %d players\n", b.serverNames(target.servers), len(entries)))
+	}
+
+	// レスポンスを送信 (ephemeral, message_deleteafter は適用しない)
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: strings.TrimSuffix(builder.String(), "\n"),
+			Files:   files,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to respond to whitelist export")
+	}
+}
+
+// encodeWhitelist はホワイトリストを JSON（whitelist.json と同じ形式）または CSV にする
+func encodeWhitelist(entries []utilities.WhitelistEntry, format string) ([]byte, string, error) {
+	if format == "csv" {
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		writer.Write([]string{"name", "uuid", "added_user_id", "expires_at"})
+		for _, entry := range entries {
+			expiresAt := ""
+			if entry.ExpiresAt != nil {
+				expiresAt = entry.ExpiresAt.Format(time.RFC3339)
+			}
+			writer.Write([]string{entry.Name, entry.UUID, entry.AddedUserID, expiresAt})
+		}
+		writer.Flush()
+		return buf.Bytes(), "text/csv", writer.Error()
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal whitelist: %w", err)
+	}
+	return data, "application/json", nil
+}
@@ -0,0 +1,269 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog/log"
+)
+
+// whitelistSyncMerge はサーバーにだけいるプレイヤーをファイルに取り込んでから再読み込みする sync のモード
+const whitelistSyncMerge = "merge"
+
+// whitelistDrift はホワイトリストファイルと稼働中のサーバーが読み込んでいる内容の差
+type whitelistDrift struct {
+	server     string   // コンテナキー
+	fileOnly   []string // ファイルにあるがサーバーが読み込んでいない（再読み込みされていない）
+	serverOnly []string // サーバーにあるがファイルにない（ゲーム内の変更が上書きされた等）
+}
+
+// empty は差がないか判定
+func (d whitelistDrift) empty() bool {
+	return len(d.fileOnly) == 0 && len(d.serverOnly) == 0
+}
+
+// whitelistSyncResult は1つのホワイトリストファイルの sync の結果
+type whitelistSyncResult struct {
+	loaded  []string // 再読み込みでサーバーに反映したプレイヤー
+	added   []string // サーバーにだけいたためファイルに追加したプレイヤー（merge）
+	dropped []string // ファイルにないためサーバーから外れたプレイヤー（file）
+}
+
+// empty はずれがなかったか判定
+func (r whitelistSyncResult) empty() bool {
+	return len(r.loaded) == 0 && len(r.added) == 0 && len(r.dropped) == 0
+}
+
+// runningContainers はサーバーのうち稼働中のコンテナをすべて返す
+func (b *Bot) runningContainers(keys []string) map[string]*container.Container {
+	running := make(map[string]*container.Container)
+	for _, key := range keys {
+		containerInterface, ok := b.appState.GetContainer(key)
+		if !ok {
+			continue
+		}
+		cont, ok := containerInterface.(*container.Container)
+		if ok && cont.Status == container.StatusRunning {
+			running[key] = cont
+		}
+	}
+	return running
+}
+
+// detectWhitelistDrift は稼働中のサーバーに whitelist list を問い合わせ、ファイルとの差を返す（停止中のサーバーは対象外）
+func (b *Bot) detectWhitelistDrift(ctx context.Context, target playerListTarget, entries []utilities.WhitelistEntry) ([]whitelistDrift, error) {
+	inFile := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		inFile[strings.ToLower(entry.Name)] = entry.Name
+	}
+
+	running := b.runningContainers(target.servers)
+
+	var drifts []whitelistDrift
+	var errs []error
+	for _, key := range target.servers {
+		cont, ok := running[key]
+		if !ok {
+			continue
+		}
+
+		names, err := cont.WhitelistNames(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", b.serverDisplayName(key), err))
+			continue
+		}
+
+		drift := whitelistDrift{server: key}
+		onServer := make(map[string]bool, len(names))
+		for _, name := range names {
+			onServer[strings.ToLower(name)] = true
+			if _, ok := inFile[strings.ToLower(name)]; !ok {
+				drift.serverOnly = append(drift.serverOnly, name)
+			}
+		}
+		for _, entry := range entries {
+			if !onServer[strings.ToLower(entry.Name)] {
+				drift.fileOnly = append(drift.fileOnly, entry.Name)
+			}
+		}
+		drifts = append(drifts, drift)
+	}
+	return drifts, errors.Join(errs...)
+}
+
+// formatWhitelistDrift は /whitelist list に表示するずれの行を作る（コードブロック内に置く）
+func (b *Bot) formatWhitelistDrift(ctx context.Context, target playerListTarget, entries []utilities.WhitelistEntry) string {
+	drifts, err := b.detectWhitelistDrift(ctx, target, entries)
+	if err != nil {
+		log.Warn().Err(err).Str("path", target.path).Msg("Failed to check whitelist drift")
+	}
+
+	var builder strings.Builder
+	for _, drift := range drifts {
+		if drift.empty() {
+			continue
+		}
+		prefix := ""
+		if len(target.servers) > 1 {
+			prefix = b.serverDisplayName(drift.server) + ": "
+		}
+		builder.WriteString("\n")
+		if len(drift.fileOnly) > 0 {
+			builder.WriteString(fmt.Sprintf("! %sNot loaded by the server: %s\n", prefix, strings.Join(drift.fileOnly, ", ")))
+		}
+		if len(drift.serverOnly) > 0 {
+			builder.WriteString(fmt.Sprintf("! %sOnly on the server: %s\n", prefix, strings.Join(drift.serverOnly, ", ")))
+		}
+	}
+	if builder.Len() > 0 {
+		builder.WriteString("  -> run /whitelist sync\n")
+	}
+	return builder.String()
+}
+
+// handleWhitelistSync は稼働中のサーバーとホワイトリストファイルのずれを解消する
+// merge（デフォルト）はサーバーにだけいるプレイヤーをファイルに追加してから、file はファイルの内容のまま再読み込みさせる
+func (b *Bot) handleWhitelistSync(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand *discordgo.ApplicationCommandInteractionDataOption) {
+	// 管理者権限チェック
+	if !b.isAdmin(i.Member) {
+		b.respondError(s, i, "この操作には管理者権限が必要です")
+		return
+	}
+
+	options := optionMap(subcommand.Options)
+	mode := optionString(options, "mode")
+	if mode == "" {
+		mode = whitelistSyncMerge
+	}
+
+	targets, err := b.resolveWhitelistTargets(optionString(options, "server"))
+	if err != nil {
+		b.respondError(s, i, err.Error())
+		return
+	}
+
+	if !b.deferEphemeral(s, i) {
+		return
+	}
+
+	ctx := context.Background()
+	allow_icon := b.settings().Icons["allow"]
+	deny_icon := b.settings().Icons["deny"]
+	actor := interactionActor(i)
+
+	var builder strings.Builder
+	for _, target := range targets {
+		servers := b.serverNames(target.servers)
+		running := b.runningContainers(target.servers)
+		if len(running) == 0 {
+			builder.WriteString(fmt.Sprintf("%s %s: 停止中です（起動時にファイルが読み込まれます）\n", allow_icon, servers))
+			continue
+		}
+
+		result, err := b.syncWhitelist(ctx, target, mode, running)
+		if err != nil {
+			log.Error().Err(err).Str("path", target.path).Msg("Failed to sync whitelist")
+		}
+		if err == nil && result.empty() {
+			builder.WriteString(fmt.Sprintf("%s %s: ずれはありません\n", allow_icon, servers))
+			continue
+		}
+
+		detail := fmt.Sprintf("mode %s", mode)
+		if len(result.loaded) > 0 {
+			detail += fmt.Sprintf(", loaded: %s", strings.Join(result.loaded, ", "))
+		}
+		if len(result.added) > 0 {
+			detail += fmt.Sprintf(", added from server: %s", strings.Join(result.added, ", "))
+		}
+		if len(result.dropped) > 0 {
+			detail += fmt.Sprintf(", dropped from server: %s", strings.Join(result.dropped, ", "))
+		}
+		for _, server := range target.servers {
+			b.recordPlayerListChange(actor, audit.ActionWhitelistSync, server, detail, true, err)
+		}
+
+		if err != nil {
+			builder.WriteString(fmt.Sprintf("%s %s: エラー (%v)\n", deny_icon, servers, err))
+			continue
+		}
+		if len(result.loaded) > 0 {
+			builder.WriteString(fmt.Sprintf("%s %s: 未反映だった %s をサーバーに読み込ませました\n", allow_icon, servers, strings.Join(result.loaded, ", ")))
+		}
+		if len(result.added) > 0 {
+			builder.WriteString(fmt.Sprintf("%s %s: サーバーにだけいた %s をファイルに追加しました\n", allow_icon, servers, strings.Join(result.added, ", ")))
+		}
+		if len(result.dropped) > 0 {
+			builder.WriteString(fmt.Sprintf("%s %s: ファイルにない %s をサーバーから外しました\n", allow_icon, servers, strings.Join(result.dropped, ", ")))
+		}
+	}
+
+	b.sendFollowup(s, i, strings.TrimSuffix(builder.String(), "\n"))
+}
+
+// syncWhitelist は1つのホワイトリストファイルについてずれを解消する
+func (b *Bot) syncWhitelist(ctx context.Context, target playerListTarget, mode string, running map[string]*container.Container) (whitelistSyncResult, error) {
+	var result whitelistSyncResult
+
+	entries, err := utilities.LoadWhitelist(target.path)
+	if err != nil {
+		return result, err
+	}
+
+	drifts, err := b.detectWhitelistDrift(ctx, target, entries)
+	if err != nil {
+		return result, err
+	}
+
+	var serverOnly []string
+	seen := make(map[string]bool)
+	loaded := make(map[string]bool)
+	for _, drift := range drifts {
+		for _, name := range drift.serverOnly {
+			if !seen[strings.ToLower(name)] {
+				seen[strings.ToLower(name)] = true
+				serverOnly = append(serverOnly, name)
+			}
+		}
+		for _, name := range drift.fileOnly {
+			if !loaded[strings.ToLower(name)] {
+				loaded[strings.ToLower(name)] = true
+				result.loaded = append(result.loaded, name)
+			}
+		}
+	}
+	if len(serverOnly) == 0 && len(result.loaded) == 0 {
+		return result, nil
+	}
+
+	if mode == whitelistSyncMerge {
+		for _, name := range serverOnly {
+			profile := utilities.OfflineProfile(name)
+			if !target.offline {
+				if profile, err = b.profiles.Lookup(name); err != nil {
+					return result, fmt.Errorf("failed to resolve %s: %w", name, err)
+				}
+			}
+			if _, err := utilities.AddToWhitelist(target.path, profile.ID, profile.Name, "", nil); err != nil {
+				return result, err
+			}
+			result.added = append(result.added, profile.Name)
+		}
+	} else {
+		result.dropped = serverOnly
+	}
+
+	// ファイルの内容をサーバーに読み込ませる
+	for key, cont := range running {
+		if err := cont.RefreshWhitelist(ctx); err != nil {
+			return result, fmt.Errorf("%s: %w", b.serverDisplayName(key), err)
+		}
+	}
+	return result, nil
+}
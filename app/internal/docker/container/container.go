@@ -29,6 +29,9 @@ type Container struct {
 	Players     int
 	LastChecked time.Time
 	StopTimer   time.Time
+	StartedAt   time.Time // 稼働中の場合、コンテナが起動した時刻
+	ExitCode    int       // 停止中の場合、最後の終了コード
+	OOMKilled   bool      // 停止中の場合、メモリ不足で強制終了されたか
 	StateHash   string
 
 	client *client.Client
@@ -65,6 +68,17 @@ func (c *Container) Update(ctx context.Context) error {
 
 	c.Image = inspect.Config.Image
 	c.LastChecked = time.Now()
+	c.StartedAt = time.Time{}
+	c.ExitCode = 0
+	c.OOMKilled = false
+	if inspect.State.Running {
+		if startedAt, err := time.Parse(time.RFC3339Nano, inspect.State.StartedAt); err == nil {
+			c.StartedAt = startedAt
+		}
+	} else {
+		c.ExitCode = inspect.State.ExitCode
+		c.OOMKilled = inspect.State.OOMKilled
+	}
 
 	// 稼働状態の判定
 	if inspect.State.Running {
@@ -238,28 +252,6 @@ func (c *Container) HasChanged(previousHash string) bool {
 
 // RefreshWhitelist は whitelist reload コマンドを実行
 func (c *Container) RefreshWhitelist(ctx context.Context) error {
-	execConfig := container.ExecOptions{
-		Cmd:          []string{"rcon-cli", "whitelist", "reload"},
-		AttachStdout: true,
-		AttachStderr: true,
-	}
-
-	execID, err := c.client.ContainerExecCreate(ctx, c.ID, execConfig)
-	if err != nil {
-		return fmt.Errorf("failed to create exec: %w", err)
-	}
-
-	resp, err := c.client.ContainerExecAttach(ctx, execID.ID, container.ExecStartOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to attach exec: %w", err)
-	}
-	defer resp.Close()
-
-	// 出力を読み取る
-	_, err = io.ReadAll(resp.Reader)
-	if err != nil {
-		return fmt.Errorf("failed to read exec output: %w", err)
-	}
-
-	return nil
+	_, err := c.RunRCON(ctx, "whitelist", "reload")
+	return err
 }
@@ -0,0 +1,95 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// RunRCON はコンテナ内の rcon-cli でサーバーコマンドを実行し、出力を返す
+func (c *Container) RunRCON(ctx context.Context, args ...string) (string, error) {
+	execConfig := container.ExecOptions{
+		Cmd:          append([]string{"rcon-cli"}, args...),
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+
+	execID, err := c.client.ContainerExecCreate(ctx, c.ID, execConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	resp, err := c.client.ContainerExecAttach(ctx, execID.ID, container.ExecStartOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to attach exec: %w", err)
+	}
+	defer resp.Close()
+
+	// stdout / stderr は多重化されているため分離して読む
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, resp.Reader); err != nil {
+		return "", fmt.Errorf("failed to read exec output: %w", err)
+	}
+
+	inspect, err := c.client.ContainerExecInspect(ctx, execID.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	if inspect.ExitCode != 0 {
+		return "", fmt.Errorf("rcon-cli exited with code %d: %s", inspect.ExitCode, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stripFormatCodes(stdout.String())), nil
+}
+
+// WhitelistNames は稼働中のサーバーが読み込んでいるホワイトリストのプレイヤー名を返す（whitelist list）
+func (c *Container) WhitelistNames(ctx context.Context) ([]string, error) {
+	output, err := c.RunRCON(ctx, "whitelist", "list")
+	if err != nil {
+		return nil, err
+	}
+	return parseWhitelistList(output)
+}
+
+// parseWhitelistList は whitelist list の出力を解釈する
+// 例: "There are 2 whitelisted player(s): Steve, Alex" / "There are no whitelisted players"
+func parseWhitelistList(output string) ([]string, error) {
+	if strings.Contains(output, "no whitelisted players") {
+		return []string{}, nil
+	}
+
+	_, list, ok := strings.Cut(output, ":")
+	if !ok {
+		return nil, fmt.Errorf("unexpected whitelist list output: %q", output)
+	}
+
+	names := make([]string, 0)
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// stripFormatCodes は Minecraft の書式コード（§ + 1文字）を取り除く
+func stripFormatCodes(s string) string {
+	var builder strings.Builder
+	skip := false
+	for _, r := range s {
+		if skip {
+			skip = false
+			continue
+		}
+		if r == '§' {
+			skip = true
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
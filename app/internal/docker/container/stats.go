@@ -0,0 +1,50 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ResourceUsage はコンテナのリソース使用量
+type ResourceUsage struct {
+	CPUTime     time.Duration // 起動からの累積 CPU 時間
+	MemoryBytes uint64        // ページキャッシュを除いたメモリ使用量（docker stats と同じ計算）
+	MemoryLimit uint64        // メモリの上限（制限なしの場合はホストのメモリ量）
+}
+
+// Stats はコンテナのリソース使用量を1回だけ取得する
+func (c *Container) Stats(ctx context.Context) (ResourceUsage, error) {
+	resp, err := c.client.ContainerStatsOneShot(ctx, c.ID)
+	if err != nil {
+		return ResourceUsage{}, fmt.Errorf("failed to get container stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var stats container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return ResourceUsage{}, fmt.Errorf("failed to decode container stats: %w", err)
+	}
+
+	return ResourceUsage{
+		CPUTime:     time.Duration(stats.CPUStats.CPUUsage.TotalUsage),
+		MemoryBytes: memoryUsage(stats.MemoryStats),
+		MemoryLimit: stats.MemoryStats.Limit,
+	}, nil
+}
+
+// memoryUsage はメモリ使用量からページキャッシュ（inactive_file）を除く
+// cgroup v1 は total_inactive_file、v2 は inactive_file に入っている
+func memoryUsage(mem container.MemoryStats) uint64 {
+	cache, ok := mem.Stats["total_inactive_file"]
+	if !ok {
+		cache = mem.Stats["inactive_file"]
+	}
+	if cache > mem.Usage {
+		return mem.Usage
+	}
+	return mem.Usage - cache
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/Koranoa3/mc-server-agent/internal/monitoring"
 	"github.com/Koranoa3/mc-server-agent/internal/state"
 	dockertypes "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
@@ -38,6 +39,15 @@ func (m *Manager) Close() error {
 	return nil
 }
 
+// Ping は Docker デーモンに接続できるか確認する
+func (m *Manager) Ping(ctx context.Context) error {
+	if _, err := m.client.Ping(ctx); err != nil {
+		monitoring.DockerAPIError("", "ping")
+		return fmt.Errorf("failed to ping docker: %w", err)
+	}
+	return nil
+}
+
 // UpdateAllContainers は設定に登録された全コンテナの情報を更新
 func (m *Manager) UpdateAllContainers(ctx context.Context) error {
 	settings := m.state.GetSettings()
@@ -46,6 +56,7 @@ func (m *Manager) UpdateAllContainers(ctx context.Context) error {
 		// コンテナ名で検索
 		containers, err := m.client.ContainerList(ctx, dockertypes.ListOptions{All: true})
 		if err != nil {
+			monitoring.DockerAPIError("", "list")
 			return fmt.Errorf("failed to list containers: %w", err)
 		}
 
@@ -73,6 +84,7 @@ func (m *Manager) UpdateAllContainers(ctx context.Context) error {
 					// ID が変わっている場合は最新の ID を反映
 					cont.SetID(c.ID)
 					if err := cont.Update(ctx); err != nil {
+						monitoring.DockerAPIError(key, "inspect")
 						return fmt.Errorf("failed to update container %s: %w", key, err)
 					}
 					m.state.UpdateContainer(key, cont)
@@ -116,6 +128,7 @@ func (m *Manager) StartContainer(ctx context.Context, key string) error {
 	}
 
 	if err := cont.Start(ctx); err != nil {
+		monitoring.DockerAPIError(key, "start")
 		return err
 	}
 
@@ -142,6 +155,7 @@ func (m *Manager) StopContainer(ctx context.Context, key string, timeout int) er
 	}
 
 	if err := cont.Stop(ctx, timeout); err != nil {
+		monitoring.DockerAPIError(key, "stop")
 		return err
 	}
 
@@ -168,6 +182,7 @@ func (m *Manager) RestartContainer(ctx context.Context, key string, timeout int)
 	}
 
 	if err := cont.Restart(ctx, timeout); err != nil {
+		monitoring.DockerAPIError(key, "restart")
 		return err
 	}
 
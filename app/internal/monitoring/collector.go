@@ -0,0 +1,117 @@
+package monitoring
+
+import (
+	"context"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// statsTimeout は1回の収集で Docker からリソース使用量を取得する時間の上限
+const statsTimeout = 5 * time.Second
+
+// serverStatuses は mc_agent_server_status の status ラベルに出す値
+var serverStatuses = []container.WorkingStatus{
+	container.StatusUnknown,
+	container.StatusRunning,
+	container.StatusStarting,
+	container.StatusStopped,
+	container.StatusNotFound,
+}
+
+var (
+	serverStatusDesc = prometheus.NewDesc(namespace+"_server_status",
+		"Current server status (1 for the current status, 0 otherwise).", []string{"server", "status"}, nil)
+	serverPlayersDesc = prometheus.NewDesc(namespace+"_server_players_online",
+		"Players online.", []string{"server"}, nil)
+	serverUptimeDesc = prometheus.NewDesc(namespace+"_server_uptime_seconds",
+		"Seconds since the container started (running servers only).", []string{"server"}, nil)
+	serverAutoShutdownDesc = prometheus.NewDesc(namespace+"_server_auto_shutdown_seconds",
+		"Seconds until the server is stopped automatically (only while the shutdown is scheduled).", []string{"server"}, nil)
+	serverCPUDesc = prometheus.NewDesc(namespace+"_server_cpu_seconds_total",
+		"Cumulative CPU time consumed by the container (running servers only).", []string{"server"}, nil)
+	serverMemoryDesc = prometheus.NewDesc(namespace+"_server_memory_bytes",
+		"Memory used by the container excluding page cache (running servers only).", []string{"server"}, nil)
+	serverMemoryLimitDesc = prometheus.NewDesc(namespace+"_server_memory_limit_bytes",
+		"Memory limit of the container (running servers only).", []string{"server"}, nil)
+)
+
+// serverCollector は AppState のサーバーの状態を収集時に読み取るコレクター
+// リソース使用量は稼働中のサーバーについて収集のたびに Docker から取得する
+type serverCollector struct {
+	appState *state.AppState
+}
+
+// RegisterServerCollector はサーバーごとのメトリクスを登録する
+func RegisterServerCollector(appState *state.AppState) {
+	registry.MustRegister(&serverCollector{appState: appState})
+}
+
+// Describe はメトリクスの定義を返す
+func (c *serverCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- serverStatusDesc
+	ch <- serverPlayersDesc
+	ch <- serverUptimeDesc
+	ch <- serverAutoShutdownDesc
+	ch <- serverCPUDesc
+	ch <- serverMemoryDesc
+	ch <- serverMemoryLimitDesc
+}
+
+// Collect は登録済みのサーバーごとにメトリクスを返す
+func (c *serverCollector) Collect(ch chan<- prometheus.Metric) {
+	settings := c.appState.GetSettings()
+	now := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+
+	for key, config := range settings.RegisteredContainers {
+		status := container.StatusUnknown
+		var cont *container.Container
+		if stateObj, ok := c.appState.GetContainer(key); ok {
+			if cont, ok = stateObj.(*container.Container); ok {
+				status = cont.Status
+			}
+		}
+
+		for _, s := range serverStatuses {
+			value := 0.0
+			if s == status {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(serverStatusDesc, prometheus.GaugeValue, value, key, s.String())
+		}
+		if cont == nil {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(serverPlayersDesc, prometheus.GaugeValue, float64(cont.Players), key)
+
+		if status != container.StatusRunning && status != container.StatusStarting {
+			continue
+		}
+		if !cont.StartedAt.IsZero() {
+			ch <- prometheus.MustNewConstMetric(serverUptimeDesc, prometheus.GaugeValue, now.Sub(cont.StartedAt).Seconds(), key)
+		}
+
+		// routine と同じ条件で自動停止の予定を計算
+		if config.AutoShutdown && status == container.StatusRunning && cont.Players == 0 && !cont.StopTimer.IsZero() {
+			shutdownAt := cont.StopTimer.Add(time.Duration(settings.RegularTask.AutoShutdownDelay) * time.Second)
+			ch <- prometheus.MustNewConstMetric(serverAutoShutdownDesc, prometheus.GaugeValue, max(shutdownAt.Sub(now).Seconds(), 0), key)
+		}
+
+		usage, err := cont.Stats(ctx)
+		if err != nil {
+			DockerAPIError(key, "stats")
+			log.Warn().Err(err).Str("container", key).Msg("Failed to collect container stats")
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(serverCPUDesc, prometheus.CounterValue, usage.CPUTime.Seconds(), key)
+		ch <- prometheus.MustNewConstMetric(serverMemoryDesc, prometheus.GaugeValue, float64(usage.MemoryBytes), key)
+		ch <- prometheus.MustNewConstMetric(serverMemoryLimitDesc, prometheus.GaugeValue, float64(usage.MemoryLimit), key)
+	}
+}
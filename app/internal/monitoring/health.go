@@ -0,0 +1,184 @@
+package monitoring
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+)
+
+const (
+	// pingTimeout は /healthz・/readyz で Docker に ping する時間の上限
+	pingTimeout = 3 * time.Second
+
+	// minStaleAfter は routine が止まったとみなすまでの最短の時間（間隔が短い場合でも一時的な遅れで失敗にしない）
+	minStaleAfter = 30 * time.Second
+)
+
+// 各チェックの結果
+const (
+	checkOK       = "ok"
+	checkFail     = "fail"
+	checkDisabled = "disabled"
+)
+
+// HealthChecks は /healthz・/readyz で確認する外部の依存（monitoring から docker・discord を参照しないよう関数で受け取る）
+type HealthChecks struct {
+	DockerPing       func(ctx context.Context) error
+	DiscordConnected func() bool // Discord 連携なしで起動した場合は nil
+}
+
+// agentHealth は routine と設定の読み込みの最新の結果
+var agentHealth = struct {
+	sync.Mutex
+	startedAt      time.Time
+	lastTick       time.Time
+	lastSuccess    time.Time
+	tickErr        error
+	settingsLoaded time.Time
+	settingsErr    error
+}{startedAt: time.Now()}
+
+// RecordRoutineTick は routine の1回の監視の結果を記録する
+func RecordRoutineTick(err error) {
+	agentHealth.Lock()
+	defer agentHealth.Unlock()
+	agentHealth.lastTick = time.Now()
+	agentHealth.tickErr = err
+	if err == nil {
+		agentHealth.lastSuccess = agentHealth.lastTick
+	}
+}
+
+// RecordSettingsLoad は設定の読み込み（起動時・再読み込み）の結果を記録する
+// 再読み込みに失敗した場合は以前の設定で動き続けるが、次の起動に失敗するため /readyz では失敗として返す
+func RecordSettingsLoad(err error) {
+	agentHealth.Lock()
+	defer agentHealth.Unlock()
+	agentHealth.settingsErr = err
+	if err == nil {
+		agentHealth.settingsLoaded = time.Now()
+	}
+}
+
+// checkResult は1つのチェックの結果
+type checkResult struct {
+	Status string     `json:"status"` // ok / fail / disabled
+	Error  string     `json:"error,omitempty"`
+	Time   *time.Time `json:"time,omitempty"` // routine は最後に成功した時刻、settings は最後に読み込めた時刻
+}
+
+// healthReport は /healthz・/readyz のレスポンス
+type healthReport struct {
+	Status string                 `json:"status"` // ok / fail
+	Checks map[string]checkResult `json:"checks"`
+}
+
+// healthHandler は /healthz と /readyz を提供する
+type healthHandler struct {
+	appState *state.AppState
+	checks   HealthChecks
+}
+
+// report はすべてのチェックを実行し、結果と routine が動き続けているか（liveness）を返す
+func (h *healthHandler) report(ctx context.Context) (healthReport, bool) {
+	report := healthReport{Status: checkOK, Checks: map[string]checkResult{}}
+
+	pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	if err := h.checks.DockerPing(pingCtx); err != nil {
+		report.Checks["docker"] = checkResult{Status: checkFail, Error: err.Error()}
+	} else {
+		report.Checks["docker"] = checkResult{Status: checkOK}
+	}
+
+	switch {
+	case h.checks.DiscordConnected == nil:
+		report.Checks["discord"] = checkResult{Status: checkDisabled}
+	case h.checks.DiscordConnected():
+		report.Checks["discord"] = checkResult{Status: checkOK}
+	default:
+		report.Checks["discord"] = checkResult{Status: checkFail, Error: "gateway is not connected"}
+	}
+
+	agentHealth.Lock()
+	startedAt, lastTick, lastSuccess, tickErr := agentHealth.startedAt, agentHealth.lastTick, agentHealth.lastSuccess, agentHealth.tickErr
+	settingsLoaded, settingsErr := agentHealth.settingsLoaded, agentHealth.settingsErr
+	agentHealth.Unlock()
+
+	// 定期監視の間隔の3回分（最短 minStaleAfter）更新がなければ止まっているとみなす
+	staleAfter := max(3*time.Duration(h.appState.GetSettings().RegularTask.Interval)*time.Second, minStaleAfter)
+	now := time.Now()
+
+	// liveness: 監視のループが回っているか（Docker の失敗は問わない、初回の監視までは起動時刻から数える）
+	lastAttempt := lastTick
+	if lastAttempt.IsZero() {
+		lastAttempt = startedAt
+	}
+	alive := now.Sub(lastAttempt) <= staleAfter
+
+	routine := checkResult{Status: checkOK}
+	if !lastSuccess.IsZero() {
+		routine.Time = &lastSuccess
+	}
+	switch {
+	case !alive:
+		routine.Status = checkFail
+		routine.Error = "routine has not run for " + now.Sub(lastAttempt).Truncate(time.Second).String()
+	case lastSuccess.IsZero():
+		routine.Status = checkFail
+		routine.Error = "no successful routine tick yet"
+		if tickErr != nil {
+			routine.Error = tickErr.Error()
+		}
+	case now.Sub(lastSuccess) > staleAfter:
+		routine.Status = checkFail
+		routine.Error = "last successful routine tick was " + now.Sub(lastSuccess).Truncate(time.Second).String() + " ago"
+		if tickErr != nil {
+			routine.Error += ": " + tickErr.Error()
+		}
+	}
+	report.Checks["routine"] = routine
+
+	settings := checkResult{Status: checkOK}
+	if !settingsLoaded.IsZero() {
+		settings.Time = &settingsLoaded
+	}
+	if settingsErr != nil {
+		settings.Status = checkFail
+		settings.Error = settingsErr.Error()
+	}
+	report.Checks["settings"] = settings
+
+	for _, check := range report.Checks {
+		if check.Status == checkFail {
+			report.Status = checkFail
+		}
+	}
+	return report, alive
+}
+
+// handleHealthz はエージェントが動き続けているか返す（routine が止まっている場合のみ 503）
+// チェックの結果はすべて返すが、Docker・Discord の障害ではエージェントを再起動しても直らないため失敗にしない
+func (h *healthHandler) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	report, alive := h.report(r.Context())
+	report.Status = checkOK
+	code := http.StatusOK
+	if !alive {
+		report.Status = checkFail
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, report)
+}
+
+// handleReadyz はすべてのチェックに通っているか返す（1つでも失敗していれば 503）
+func (h *healthHandler) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	report, _ := h.report(r.Context())
+	code := http.StatusOK
+	if report.Status != checkOK {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, report)
+}
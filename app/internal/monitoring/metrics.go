@@ -0,0 +1,136 @@
+package monitoring
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// namespace はメトリクス名の接頭辞
+const namespace = "mc_agent"
+
+// registry はエージェントのメトリクスを登録するレジストリ（/metrics で公開）
+var registry = prometheus.NewRegistry()
+
+var (
+	// commandsTotal は start / stop / restart の件数（outcome は audit の Outcome と同じ）
+	commandsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_total",
+		Help:      "Server commands by registered_containers key, action and outcome.",
+	}, []string{"server", "action", "outcome"})
+
+	// dockerAPIErrors は Docker API の呼び出しの失敗数
+	dockerAPIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "docker_api_errors_total",
+		Help:      "Failed Docker API calls by registered_containers key (empty for calls not tied to a server) and operation.",
+	}, []string{"server", "operation"})
+
+	// discordAPIErrors は Discord REST API のエラーレスポンス・通信エラーの数
+	discordAPIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "discord_api_errors_total",
+		Help:      "Failed Discord REST API requests by HTTP status code (\"error\" for transport errors, rate limits excluded).",
+	}, []string{"status"})
+
+	// discordRateLimits は Discord REST API のレート制限（429）の数
+	discordRateLimits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "discord_rate_limits_total",
+		Help:      "Discord REST API responses with status 429.",
+	})
+
+	// routineTickDuration は routine の1回の監視にかかった時間
+	routineTickDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "routine_tick_duration_seconds",
+		Help:      "Time spent on one routine tick (container update, change detection and auto shutdown).",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		commandsTotal,
+		dockerAPIErrors,
+		discordAPIErrors,
+		discordRateLimits,
+		routineTickDuration,
+	)
+}
+
+// DockerAPIError は Docker API の呼び出しの失敗を数える
+func DockerAPIError(server, operation string) {
+	dockerAPIErrors.WithLabelValues(server, operation).Inc()
+}
+
+// ObserveRoutineTick は routine の1回の監視にかかった時間を記録する
+func ObserveRoutineTick(duration time.Duration) {
+	routineTickDuration.Observe(duration.Seconds())
+}
+
+// CountCommands はイベントバスのコマンドの結果を commands_total に数える（ctx の終了まで続ける）
+func CountCommands(ctx context.Context, appState *state.AppState) {
+	sub, _ := appState.Events().Subscribe(0, 64)
+	defer sub.Close()
+
+	outcomes := map[state.EventType]audit.Outcome{
+		state.EventCommandCompleted: audit.OutcomeSuccess,
+		state.EventCommandFailed:    audit.OutcomeFailure,
+		state.EventCommandRejected:  audit.OutcomeRejected,
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-sub.C:
+			outcome, ok := outcomes[event.Type]
+			if !ok || event.Command == nil {
+				continue
+			}
+			// 登録されていないサーバーへの操作（API で任意の ID を指定できる）はラベルを増やさないようまとめる
+			server := event.Server
+			if _, ok := appState.GetSettings().RegisteredContainers[server]; !ok {
+				server = ""
+			}
+			commandsTotal.WithLabelValues(server, event.Command.Action, string(outcome)).Inc()
+		}
+	}
+}
+
+// discordTransport は Discord REST API のエラーとレート制限を数える http.RoundTripper
+type discordTransport struct {
+	next http.RoundTripper
+}
+
+// DiscordTransport は Discord セッションの HTTP クライアントに設定する RoundTripper を返す（next が nil の場合は http.DefaultTransport）
+func DiscordTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &discordTransport{next: next}
+}
+
+// RoundTrip はリクエストを送り、結果を数える
+func (t *discordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		discordAPIErrors.WithLabelValues("error").Inc()
+	case resp.StatusCode == http.StatusTooManyRequests:
+		discordRateLimits.Inc()
+	case resp.StatusCode >= 400:
+		discordAPIErrors.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+	}
+	return resp, err
+}
@@ -0,0 +1,92 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+// shutdownTimeout は停止時に処理中のリクエストを待つ時間
+const shutdownTimeout = 5 * time.Second
+
+// Server は監視用エンドポイント（/metrics、/healthz・/readyz）を提供する HTTP サーバー（認証なし）
+type Server struct {
+	config     utilities.MonitoringConfig
+	health     *healthHandler
+	httpServer *http.Server
+}
+
+// NewServer は新しい監視用サーバーを作成
+// 待ち受けアドレスと公開するエンドポイントは起動時に決まるため、設定の再読み込みでは変わらない
+func NewServer(config utilities.MonitoringConfig, appState *state.AppState, checks HealthChecks) *Server {
+	return &Server{
+		config: config,
+		health: &healthHandler{appState: appState, checks: checks},
+	}
+}
+
+// Start は待ち受けを開始する
+func (s *Server) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.config.Listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.config.Listen, err)
+	}
+
+	mux := http.NewServeMux()
+	if s.config.Enabled {
+		mux.Handle("GET /metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	}
+	if s.config.Health {
+		mux.HandleFunc("GET /healthz", s.health.handleHealthz)
+		mux.HandleFunc("GET /readyz", s.health.handleReadyz)
+	}
+
+	s.httpServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("Monitoring server stopped")
+		}
+	}()
+
+	log.Info().
+		Str("listen", listener.Addr().String()).
+		Bool("metrics", s.config.Enabled).
+		Bool("health", s.config.Health).
+		Msg("Monitoring server started")
+	return nil
+}
+
+// Stop はサーバーを停止する
+func (s *Server) Stop() error {
+	if s.httpServer == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+// writeJSON は JSON のレスポンスを書き込む
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debug().Err(err).Msg("Failed to write monitoring response")
+	}
+}
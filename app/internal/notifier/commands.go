@@ -0,0 +1,174 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/policy"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/rs/zerolog/log"
+)
+
+// commandTimeout はコマンドの完了を待つ時間（停止のタイムアウト 30 秒 + 余裕）
+const commandTimeout = 60 * time.Second
+
+// Handler はチャットのテキストコマンド（status / start / stop / restart / help）を処理する
+// Slack・Matrix・Telegram のアダプターで共有し、Discord のボタンと同じチェック（policy）を通してから実行する
+type Handler struct {
+	appState    *state.AppState
+	auditLog    *audit.Logger
+	commandChan chan<- routine.Command
+	source      audit.Source
+	prefix      string // ヘルプに表示するコマンドの接頭辞（"/mc" など）
+}
+
+// NewHandler は新しい Handler を作成
+func NewHandler(appState *state.AppState, auditLog *audit.Logger, commandChan chan<- routine.Command, source audit.Source, prefix string) *Handler {
+	return &Handler{
+		appState:    appState,
+		auditLog:    auditLog,
+		commandChan: commandChan,
+		source:      source,
+		prefix:      prefix,
+	}
+}
+
+// ParseCommand はメッセージが接頭辞で始まるコマンドなら、接頭辞を除いた単語を返す
+// Telegram のグループの "/mc@bot_name" のように接頭辞の直後に @ が続く場合も受け付ける
+func ParseCommand(text, prefix string) ([]string, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return nil, false
+	}
+	head := fields[0]
+	if at := strings.IndexByte(head, '@'); at > 0 {
+		head = head[:at]
+	}
+	if !strings.EqualFold(head, prefix) {
+		return nil, false
+	}
+	return fields[1:], true
+}
+
+// Handle はコマンドを実行して返信するテキストを返す（start / stop / restart は完了を待つ）
+// actor には実行者（UserID・UserName）を入れる。allowed が false の場合は起動・停止を拒否する
+func (h *Handler) Handle(ctx context.Context, actor audit.Entry, args []string, allowed bool) string {
+	actor.Source = h.source
+	if len(args) == 0 {
+		return h.help()
+	}
+
+	action := strings.ToLower(args[0])
+	switch action {
+	case "status":
+		server := ""
+		if len(args) > 1 {
+			server = h.resolveServer(strings.Join(args[1:], " "))
+			if _, ok := h.appState.GetSettings().RegisteredContainers[server]; !ok {
+				return fmt.Sprintf("Server '%s' not found", server)
+			}
+		}
+		return RenderStatus(h.appState, server)
+
+	case audit.ActionStart, audit.ActionStop, audit.ActionRestart:
+		if len(args) < 2 {
+			return fmt.Sprintf("Usage: %s %s <server>", h.prefix, action)
+		}
+		return h.runCommand(ctx, actor, action, h.resolveServer(strings.Join(args[1:], " ")), allowed)
+
+	default:
+		return h.help()
+	}
+}
+
+// help はコマンドの一覧を返す
+func (h *Handler) help() string {
+	return strings.Join([]string{
+		"Commands:",
+		fmt.Sprintf("%s status [server] - show server status", h.prefix),
+		fmt.Sprintf("%s start <server> - start a server", h.prefix),
+		fmt.Sprintf("%s stop <server> - stop a server", h.prefix),
+		fmt.Sprintf("%s restart <server> - restart a server", h.prefix),
+	}, "\n")
+}
+
+// resolveServer は入力値をコンテナキーに解決する（キーまたは表示名、大文字・小文字を区別しない）
+func (h *Handler) resolveServer(value string) string {
+	settings := h.appState.GetSettings()
+	if _, ok := settings.RegisteredContainers[value]; ok {
+		return value
+	}
+	for key, config := range settings.RegisteredContainers {
+		if strings.EqualFold(config.DisplayName, value) || strings.EqualFold(key, value) {
+			return key
+		}
+	}
+	return value
+}
+
+// runCommand は start / stop / restart を policy の判定を通してから commandChan に送り、完了を待つ
+func (h *Handler) runCommand(ctx context.Context, actor audit.Entry, action, key string, allowed bool) string {
+	rejection := policy.CheckCommand(ctx, h.appState, action, key)
+	if rejection == nil && !allowed {
+		rejection = &policy.Rejection{Reason: policy.ReasonForbidden, Message: "You are not allowed to control servers."}
+	}
+	if rejection != nil {
+		entry := actor
+		entry.Action = action
+		entry.Server = key
+		entry.Outcome = audit.OutcomeRejected
+		entry.Detail = rejection.Message
+		h.auditLog.Record(entry)
+		h.appState.Events().Publish(state.Event{
+			Type:    state.EventCommandRejected,
+			Server:  key,
+			Command: &state.CommandEvent{Action: action, Source: string(actor.Source), UserID: actor.UserID, UserName: actor.UserName, Error: rejection.Message},
+		})
+		return "❌ " + rejection.Message
+	}
+
+	name := h.appState.GetSettings().RegisteredContainers[key].DisplayName
+	reply := make(chan routine.CommandResult, 1)
+	cmd := routine.Command{
+		Type:        action,
+		ContainerID: key,
+		Timeout:     30,
+		Source:      actor.Source,
+		UserID:      actor.UserID,
+		UserName:    actor.UserName,
+		Reply:       reply,
+	}
+
+	select {
+	case h.commandChan <- cmd:
+		log.Info().
+			Str("action", action).
+			Str("container", key).
+			Str("user", actor.UserName).
+			Str("source", string(actor.Source)).
+			Msg("Command sent to channel")
+	default:
+		log.Error().Msg("Command channel is full")
+		return "❌ Command queue is full. Please try again later."
+	}
+
+	// 結果は main の監査ログに記録される
+	timer := time.NewTimer(commandTimeout)
+	defer timer.Stop()
+	select {
+	case result := <-reply:
+		if result.Err != nil {
+			return fmt.Sprintf("❌ Failed to %s %s: %v", action, name, result.Err)
+		}
+		return fmt.Sprintf("✅ %s completed on %s", action, name)
+	case <-timer.C:
+		// コマンド自体は実行が続く
+		return fmt.Sprintf("⏳ %s sent to %s (still in progress)", action, name)
+	case <-ctx.Done():
+		return ""
+	}
+}
@@ -0,0 +1,326 @@
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/notifier"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// commandPrefix はルームのコマンド
+	commandPrefix = "!mc"
+
+	// syncTimeout は /sync のロングポーリングで待つ時間（ミリ秒）
+	syncTimeout = 30000
+
+	// retryDelay は /sync が失敗した場合に待つ時間
+	retryDelay = 5 * time.Second
+)
+
+// Bot は Matrix の Client-Server API（/sync のロングポーリング）で動く Notifier
+// メッセージは m.notice で送り、状態表示は m.replace で編集する
+type Bot struct {
+	appState    *state.AppState
+	handler     *notifier.Handler
+	messenger   *notifier.Messenger
+	client      *http.Client
+	baseURL     string // <homeserver_url>/_matrix/client/v3
+	accessToken string
+	roomID      string // 参加が必要なため起動時の設定を使う
+	userID      string // 自分のユーザー ID（自分のメッセージを無視するため）
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// matrixError は Matrix の API のエラー
+type matrixError struct {
+	ErrCode string `json:"errcode"`
+	Error   string `json:"error"`
+}
+
+// syncResponse は /sync のレスポンス（参加中のルームのタイムラインのみ使う）
+type syncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []roomEvent `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+// roomEvent はルームのイベント
+type roomEvent struct {
+	Type    string `json:"type"`
+	EventID string `json:"event_id"`
+	Sender  string `json:"sender"`
+	Content struct {
+		MsgType   string          `json:"msgtype"`
+		Body      string          `json:"body"`
+		RelatesTo json.RawMessage `json:"m.relates_to"`
+	} `json:"content"`
+}
+
+// NewBot は新しい Matrix の Bot を作成（ホームサーバー・トークン・ルームは起動時の設定を使う）
+func NewBot(appState *state.AppState, auditLog *audit.Logger, commandChan chan<- routine.Command) *Bot {
+	config := appState.GetSettings().Matrix
+	bot := &Bot{
+		appState: appState,
+		handler:  notifier.NewHandler(appState, auditLog, commandChan, audit.SourceMatrix, commandPrefix),
+		// ロングポーリングの待ち時間より長くする
+		client:      &http.Client{Timeout: syncTimeout*time.Millisecond + 15*time.Second},
+		baseURL:     strings.TrimRight(config.HomeserverURL, "/") + "/_matrix/client/v3",
+		accessToken: config.AccessToken,
+		roomID:      config.RoomID,
+	}
+	bot.messenger = notifier.NewMessenger(bot.Name(), appState, bot)
+	return bot
+}
+
+// Name は Notifier の名前を返す
+func (b *Bot) Name() string {
+	return "matrix"
+}
+
+// config は現在の設定を返す（許可するユーザーは再読み込みで即時に反映される）
+func (b *Bot) config() utilities.MatrixConfig {
+	return b.appState.GetSettings().Matrix
+}
+
+// Start は自分のユーザー ID を確認してルームに参加し、コマンドの受け付けと投稿を始める
+func (b *Bot) Start(ctx context.Context) error {
+	var whoami struct {
+		UserID string `json:"user_id"`
+	}
+	if err := b.do(ctx, http.MethodGet, "/account/whoami", nil, &whoami); err != nil {
+		return fmt.Errorf("failed to connect to Matrix: %w", err)
+	}
+	b.userID = whoami.UserID
+	log.Info().Str("user_id", b.userID).Msg("Connected to Matrix")
+
+	// 招待済み・参加済みのどちらでも成功する
+	if err := b.do(ctx, http.MethodPost, "/join/"+url.PathEscape(b.Channel()), struct{}{}, nil); err != nil {
+		return fmt.Errorf("failed to join Matrix room %s: %w", b.Channel(), err)
+	}
+
+	ctx, b.cancel = context.WithCancel(ctx)
+	b.wg.Add(2)
+	go func() {
+		defer b.wg.Done()
+		b.messenger.Run(ctx)
+	}()
+	go func() {
+		defer b.wg.Done()
+		b.sync(ctx)
+	}()
+	b.messenger.RequestUpdate()
+	return nil
+}
+
+// Stop はコマンドの受け付けと投稿を止める
+func (b *Bot) Stop() error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.wg.Wait()
+	return nil
+}
+
+// RequestUpdate は状態表示のメッセージの更新を依頼する
+func (b *Bot) RequestUpdate() {
+	b.messenger.RequestUpdate()
+}
+
+// PostAuditEntry は監査ログのエントリをルームに投稿する
+func (b *Bot) PostAuditEntry(e audit.Entry) {
+	b.messenger.Post(notifier.FormatAuditEntry(b.appState.GetSettings(), e))
+}
+
+// PostAlert はアラートをルームに投稿する
+func (b *Bot) PostAlert(alert state.Alert) {
+	b.messenger.Post(notifier.FormatAlert(alert))
+}
+
+// Channel は投稿先のルーム ID を返す
+func (b *Bot) Channel() string {
+	return b.roomID
+}
+
+// Send はルームにメッセージを投稿してイベント ID を返す
+func (b *Bot) Send(ctx context.Context, text string) (string, error) {
+	return b.sendMessage(ctx, b.Channel(), map[string]any{
+		"msgtype": "m.notice",
+		"body":    text,
+	})
+}
+
+// Edit は投稿済みのメッセージを書き換える（m.replace）
+func (b *Bot) Edit(ctx context.Context, id, text string) error {
+	_, err := b.sendMessage(ctx, b.Channel(), map[string]any{
+		"msgtype": "m.notice",
+		"body":    "* " + text,
+		"m.new_content": map[string]any{
+			"msgtype": "m.notice",
+			"body":    text,
+		},
+		"m.relates_to": map[string]any{
+			"rel_type": "m.replace",
+			"event_id": id,
+		},
+	})
+	return err
+}
+
+// sendMessage は m.room.message を送ってイベント ID を返す
+func (b *Bot) sendMessage(ctx context.Context, roomID string, content map[string]any) (string, error) {
+	path := fmt.Sprintf("/rooms/%s/send/m.room.message/%s", url.PathEscape(roomID), newTxnID())
+	var sent struct {
+		EventID string `json:"event_id"`
+	}
+	if err := b.do(ctx, http.MethodPut, path, content, &sent); err != nil {
+		return "", err
+	}
+	return sent.EventID, nil
+}
+
+// sync は /sync でメッセージを受け取り続ける
+// 最初の /sync の内容は処理しない（再起動で古いコマンドを実行しないため）
+func (b *Bot) sync(ctx context.Context) {
+	since := ""
+	for ctx.Err() == nil {
+		query := url.Values{}
+		if since != "" {
+			query.Set("since", since)
+			query.Set("timeout", fmt.Sprint(syncTimeout))
+		} else {
+			// 最初は位置だけ取得する
+			query.Set("filter", `{"room":{"timeline":{"limit":1}}}`)
+		}
+
+		var resp syncResponse
+		if err := b.do(ctx, http.MethodGet, "/sync?"+query.Encode(), nil, &resp); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Error().Err(err).Msg("Failed to sync with Matrix")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+
+		if since != "" {
+			roomID := b.Channel()
+			for _, event := range resp.Rooms.Join[roomID].Timeline.Events {
+				b.handleEvent(ctx, roomID, event)
+			}
+		}
+		since = resp.NextBatch
+	}
+}
+
+// handleEvent はルームのコマンドを処理し、結果を返信する
+func (b *Bot) handleEvent(ctx context.Context, roomID string, event roomEvent) {
+	if event.Type != "m.room.message" || event.Content.MsgType != "m.text" || event.Sender == b.userID {
+		return
+	}
+	// 編集（m.replace）は新しいコマンドとして扱わない
+	if len(event.Content.RelatesTo) > 0 && strings.Contains(string(event.Content.RelatesTo), `"m.replace"`) {
+		return
+	}
+	args, ok := notifier.ParseCommand(event.Content.Body, commandPrefix)
+	if !ok {
+		return
+	}
+
+	config := b.config()
+	allowed := len(config.AllowedUsers) == 0 || slices.Contains(config.AllowedUsers, event.Sender)
+
+	// 完了を待つ間も次のメッセージを受け取れるようにする
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		reply := b.handler.Handle(ctx, audit.Entry{UserID: event.Sender, UserName: event.Sender}, args, allowed)
+		if reply == "" {
+			return
+		}
+		sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 15*time.Second)
+		defer cancel()
+		_, err := b.sendMessage(sendCtx, roomID, map[string]any{
+			"msgtype": "m.notice",
+			"body":    reply,
+			"m.relates_to": map[string]any{
+				"m.in_reply_to": map[string]any{"event_id": event.EventID},
+			},
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to reply to Matrix command")
+		}
+	}()
+}
+
+// do は Client-Server API を呼び出し、レスポンスを out に読み込む（out が nil なら読み込まない）
+func (b *Bot) do(ctx context.Context, method, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.accessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr matrixError
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&apiErr)
+		return fmt.Errorf("%s %s: unexpected status %d: %s %s", method, strings.SplitN(path, "?", 2)[0], resp.StatusCode, apiErr.ErrCode, apiErr.Error)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("%s %s: failed to decode response: %w", method, strings.SplitN(path, "?", 2)[0], err)
+		}
+	}
+	return nil
+}
+
+// newTxnID はメッセージの送信に使うトランザクション ID を返す
+func newTxnID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
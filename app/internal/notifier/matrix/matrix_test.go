@@ -0,0 +1,225 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+)
+
+const testRoomID = "!room:example.com"
+
+// apiCall はホームサーバーのスタブが受け取ったリクエスト
+type apiCall struct {
+	Method        string
+	Path          string
+	Authorization string
+	Body          map[string]any
+}
+
+// stubHomeserver は Client-Server API を真似る httptest のサーバー
+// 受け取ったリクエストを記録し、handle が返したステータスと本文を返す
+type stubHomeserver struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	calls []apiCall
+}
+
+func newStubHomeserver(t *testing.T, handle func(method, path string) (int, string)) *stubHomeserver {
+	t.Helper()
+	stub := &stubHomeserver{}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+
+		path := strings.TrimPrefix(r.URL.Path, "/_matrix/client/v3")
+		stub.mu.Lock()
+		stub.calls = append(stub.calls, apiCall{Method: r.Method, Path: path, Authorization: r.Header.Get("Authorization"), Body: body})
+		stub.mu.Unlock()
+
+		status, response := handle(r.Method, path)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+// recorded は受け取ったリクエストを返す
+func (s *stubHomeserver) recorded() []apiCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]apiCall(nil), s.calls...)
+}
+
+// newTestBot はホームサーバーをスタブに向けた Bot を作成する
+func newTestBot(t *testing.T, homeserverURL string) *Bot {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("DATA_DIR", dir)
+
+	settings := &utilities.Settings{
+		RegisteredContainers: map[string]utilities.ContainerConfig{
+			"survival": {DisplayName: "Survival", ContainerName: "mc-survival"},
+		},
+		Matrix: utilities.MatrixConfig{
+			Enabled:       true,
+			HomeserverURL: homeserverURL + "/",
+			AccessToken:   "syt_test",
+			RoomID:        testRoomID,
+		},
+	}
+	auditLog := audit.NewLogger(filepath.Join(dir, "audit.jsonl"), nil)
+	bot := NewBot(state.NewAppState(settings), auditLog, make(chan routine.Command, 1))
+	bot.userID = "@agent:example.com"
+	return bot
+}
+
+// textEvent はルームのテキストメッセージのイベントを作る
+func textEvent(id, sender, body string) roomEvent {
+	var event roomEvent
+	event.Type = "m.room.message"
+	event.EventID = id
+	event.Sender = sender
+	event.Content.MsgType = "m.text"
+	event.Content.Body = body
+	return event
+}
+
+func TestSendAndEdit(t *testing.T) {
+	stub := newStubHomeserver(t, func(method, path string) (int, string) {
+		return http.StatusOK, `{"event_id":"$sent"}`
+	})
+	bot := newTestBot(t, stub.URL)
+
+	id, err := bot.Send(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if id != "$sent" {
+		t.Errorf("Send returned %q, want $sent", id)
+	}
+	if err := bot.Edit(context.Background(), id, "updated"); err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+
+	calls := stub.recorded()
+	if len(calls) != 2 {
+		t.Fatalf("got %d calls, want 2", len(calls))
+	}
+	for _, call := range calls {
+		if call.Method != http.MethodPut || !strings.HasPrefix(call.Path, "/rooms/"+testRoomID+"/send/m.room.message/") {
+			t.Errorf("unexpected request %s %s", call.Method, call.Path)
+		}
+		if call.Authorization != "Bearer syt_test" {
+			t.Errorf("unexpected authorization %q", call.Authorization)
+		}
+	}
+	if calls[0].Path == calls[1].Path {
+		t.Errorf("transaction ID was reused: %s", calls[0].Path)
+	}
+	if calls[0].Body["msgtype"] != "m.notice" || calls[0].Body["body"] != "hello" {
+		t.Errorf("unexpected message body %v", calls[0].Body)
+	}
+	relates, _ := calls[1].Body["m.relates_to"].(map[string]any)
+	newContent, _ := calls[1].Body["m.new_content"].(map[string]any)
+	if relates["rel_type"] != "m.replace" || relates["event_id"] != "$sent" || newContent["body"] != "updated" {
+		t.Errorf("unexpected edit body %v", calls[1].Body)
+	}
+}
+
+func TestStartJoinsRoom(t *testing.T) {
+	stub := newStubHomeserver(t, func(method, path string) (int, string) {
+		switch {
+		case path == "/account/whoami":
+			return http.StatusOK, `{"user_id":"@agent:example.com"}`
+		case strings.HasPrefix(path, "/join/"):
+			return http.StatusForbidden, `{"errcode":"M_FORBIDDEN","error":"You are not invited to this room."}`
+		}
+		return http.StatusNotFound, `{"errcode":"M_UNRECOGNIZED"}`
+	})
+	bot := newTestBot(t, stub.URL)
+
+	err := bot.Start(context.Background())
+	if err == nil {
+		bot.Stop()
+		t.Fatal("Start succeeded without joining the room")
+	}
+	want := "failed to join Matrix room " + testRoomID + ": POST /join/" + url.PathEscape(testRoomID) + ": unexpected status 403: M_FORBIDDEN You are not invited to this room."
+	if err.Error() != want {
+		t.Errorf("Start error = %q, want %q", err, want)
+	}
+	if bot.userID != "@agent:example.com" {
+		t.Errorf("userID = %q after whoami", bot.userID)
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{name: "api error", status: http.StatusTooManyRequests, body: `{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests"}`, wantErr: "unexpected status 429: M_LIMIT_EXCEEDED Too many requests"},
+		{name: "invalid response", status: http.StatusOK, body: `not json`, wantErr: "failed to decode response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newStubHomeserver(t, func(method, path string) (int, string) {
+				return tt.status, tt.body
+			})
+			bot := newTestBot(t, stub.URL)
+
+			_, err := bot.Send(context.Background(), "hello")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Send error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandleEventRepliesToCommands(t *testing.T) {
+	stub := newStubHomeserver(t, func(method, path string) (int, string) {
+		return http.StatusOK, `{"event_id":"$reply"}`
+	})
+	bot := newTestBot(t, stub.URL)
+
+	edit := textEvent("$edit", "@alice:example.com", "!mc status")
+	edit.Content.RelatesTo = json.RawMessage(`{"rel_type":"m.replace","event_id":"$old"}`)
+
+	ctx := context.Background()
+	bot.handleEvent(ctx, testRoomID, textEvent("$cmd", "@alice:example.com", "!mc status"))
+	bot.handleEvent(ctx, testRoomID, textEvent("$own", "@agent:example.com", "!mc status"))
+	bot.handleEvent(ctx, testRoomID, textEvent("$chat", "@alice:example.com", "hello"))
+	bot.handleEvent(ctx, testRoomID, edit)
+	bot.wg.Wait()
+
+	calls := stub.recorded()
+	if len(calls) != 1 {
+		t.Fatalf("got %d replies, want 1 (own messages, chat and edits are ignored)", len(calls))
+	}
+	body := calls[0].Body
+	if text, _ := body["body"].(string); !strings.Contains(text, "Minecraft Server Status") || !strings.Contains(text, "Survival") {
+		t.Errorf("unexpected status reply %q", text)
+	}
+	relates, _ := body["m.relates_to"].(map[string]any)
+	inReplyTo, _ := relates["m.in_reply_to"].(map[string]any)
+	if inReplyTo["event_id"] != "$cmd" {
+		t.Errorf("reply is not in reply to the command: %v", body)
+	}
+}
@@ -0,0 +1,215 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// updateInterval は状態表示のメッセージを編集する最短の間隔（レート制限に当たらないようにまとめる）
+	updateInterval = 3 * time.Second
+
+	// sendTimeout は1回の投稿・編集の時間の上限
+	sendTimeout = 15 * time.Second
+
+	// postQueueSize は投稿待ちにできる監査ログ・アラートの数（あふれた分は破棄する）
+	postQueueSize = 50
+)
+
+// MessageClient は1つのチャンネル（ルーム・チャット）へのメッセージの投稿と編集
+// Slack・Matrix・Telegram のアダプターが実装する
+type MessageClient interface {
+	// Channel は現在の投稿先（設定の再読み込みで変わった場合は状態表示のメッセージを投稿し直す）
+	Channel() string
+
+	// Send はメッセージを投稿して ID を返す
+	Send(ctx context.Context, text string) (string, error)
+
+	// Edit は投稿済みのメッセージを書き換える
+	Edit(ctx context.Context, id, text string) error
+}
+
+// Messenger は状態表示のメッセージ（1つを編集し続ける）と、監査ログ・アラートの投稿を
+// 1つの goroutine で順番に行う（呼び出し側をブロックしない）
+type Messenger struct {
+	name     string
+	appState *state.AppState
+	client   MessageClient
+	store    *messageStore
+
+	updates chan struct{}
+	posts   chan string
+
+	// 最後に表示した内容（変わっていなければ編集しない）
+	lastText string
+}
+
+// NewMessenger は新しい Messenger を作成（name は Notifier の名前、投稿済みのメッセージの記録に使う）
+func NewMessenger(name string, appState *state.AppState, client MessageClient) *Messenger {
+	return &Messenger{
+		name:     name,
+		appState: appState,
+		client:   client,
+		store:    defaultMessageStore,
+		updates:  make(chan struct{}, 1),
+		posts:    make(chan string, postQueueSize),
+	}
+}
+
+// RequestUpdate は状態表示の更新を依頼する（既に依頼済みならまとめる）
+func (m *Messenger) RequestUpdate() {
+	select {
+	case m.updates <- struct{}{}:
+	default:
+	}
+}
+
+// Post はメッセージの投稿を依頼する
+func (m *Messenger) Post(text string) {
+	select {
+	case m.posts <- text:
+	default:
+		log.Warn().Str("notifier", m.name).Msg("Notifier post queue is full, dropping message")
+	}
+}
+
+// Run は ctx が終了するまで投稿・編集を行う
+func (m *Messenger) Run(ctx context.Context) {
+	var lastUpdate time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case text := <-m.posts:
+			sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+			if _, err := m.client.Send(sendCtx, text); err != nil {
+				log.Error().Err(err).Str("notifier", m.name).Msg("Failed to post message")
+			}
+			cancel()
+
+		case <-m.updates:
+			// 直前に編集した場合は少し待ってからまとめて反映する
+			if wait := updateInterval - time.Since(lastUpdate); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+			}
+			lastUpdate = time.Now()
+			m.updateStatus(ctx)
+		}
+	}
+}
+
+// updateStatus は状態表示のメッセージを最新の内容に編集する（無ければ投稿する）
+func (m *Messenger) updateStatus(ctx context.Context) {
+	channel := m.client.Channel()
+	if channel == "" {
+		return
+	}
+	text := RenderStatus(m.appState, "")
+	if text == m.lastText {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	if saved, ok := m.store.get(m.name); ok && saved.Channel == channel {
+		err := m.client.Edit(ctx, saved.MessageID, text)
+		if err == nil {
+			m.lastText = text
+			return
+		}
+		// 削除された場合などは投稿し直す
+		log.Warn().Err(err).Str("notifier", m.name).Msg("Failed to edit status message, posting a new one")
+	}
+
+	id, err := m.client.Send(ctx, text)
+	if err != nil {
+		log.Error().Err(err).Str("notifier", m.name).Msg("Failed to post status message")
+		return
+	}
+	m.lastText = text
+	if err := m.store.set(m.name, savedMessage{Channel: channel, MessageID: id}); err != nil {
+		log.Error().Err(err).Str("notifier", m.name).Msg("Failed to save status message")
+	}
+}
+
+// savedMessage は投稿済みの状態表示のメッセージ
+type savedMessage struct {
+	Channel   string `json:"channel"`
+	MessageID string `json:"message_id"`
+}
+
+// messageStore は Notifier ごとの状態表示のメッセージを保存する（再起動後も同じメッセージを編集する）
+type messageStore struct {
+	path     string
+	mu       sync.Mutex
+	messages map[string]savedMessage
+	loaded   bool
+}
+
+// defaultMessageStore はすべての Messenger で共有する（1つのファイルに書くため、パスは最初の読み込み時に決める）
+var defaultMessageStore = &messageStore{}
+
+// load はファイルから読み込む（ロック済みで呼ぶ、ファイルが無い場合は空）
+func (s *messageStore) load() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	s.messages = make(map[string]savedMessage)
+	if s.path == "" {
+		s.path = utilities.DataPath("notifier_messages.json")
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Error().Err(err).Str("path", s.path).Msg("Failed to read notifier messages")
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &s.messages); err != nil {
+		log.Error().Err(err).Str("path", s.path).Msg("Failed to parse notifier messages")
+	}
+}
+
+// get は保存済みのメッセージを返す
+func (s *messageStore) get(name string) (savedMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	message, ok := s.messages[name]
+	return message, ok
+}
+
+// set はメッセージを保存する
+func (s *messageStore) set(name string, message savedMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	s.messages[name] = message
+
+	data, err := json.MarshalIndent(s.messages, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode notifier messages: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write notifier messages: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,83 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/rs/zerolog/log"
+)
+
+// Notifier はユーザー向けのフロントエンド（チャットサービスの Bot）
+// Discord の Bot と Slack・Matrix・Telegram のアダプターが実装し、main からはこのインターフェースで扱う
+// コマンドの受け付けは各実装が行い、commandChan に routine.Command を送る
+type Notifier interface {
+	// Name はログに使う名前（"discord" など）
+	Name() string
+
+	// Start は接続してコマンドの受け付けを始める
+	Start(ctx context.Context) error
+
+	// Stop は接続を閉じる
+	Stop() error
+
+	// RequestUpdate は状態表示の更新を依頼する（即座に戻り、実際の更新はまとめて行う）
+	RequestUpdate()
+
+	// PostAuditEntry は監査ログのエントリを投稿する
+	PostAuditEntry(e audit.Entry)
+
+	// PostAlert はエージェント自体の異常（Docker に接続できない等）とその回復を投稿する
+	PostAlert(alert state.Alert)
+}
+
+// Group は起動中のすべての Notifier（main から一括で通知する）
+type Group []Notifier
+
+// RequestUpdate はすべての Notifier に状態表示の更新を依頼する
+func (g Group) RequestUpdate() {
+	for _, n := range g {
+		n.RequestUpdate()
+	}
+}
+
+// PostAuditEntry はすべての Notifier に監査ログのエントリを投稿させる
+func (g Group) PostAuditEntry(e audit.Entry) {
+	for _, n := range g {
+		n.PostAuditEntry(e)
+	}
+}
+
+// PostAlert はすべての Notifier にアラートを投稿させる
+func (g Group) PostAlert(alert state.Alert) {
+	for _, n := range g {
+		n.PostAlert(alert)
+	}
+}
+
+// Stop はすべての Notifier を停止する
+func (g Group) Stop() {
+	for _, n := range g {
+		if err := n.Stop(); err != nil {
+			log.Error().Err(err).Str("notifier", n.Name()).Msg("Failed to stop notifier")
+		}
+	}
+}
+
+// ForwardAlerts はイベントバスの alert を ctx が終了するまですべての Notifier に投稿する
+// main のループはコマンドの実行中に止まるため、別の goroutine で購読する
+func ForwardAlerts(ctx context.Context, appState *state.AppState, group Group) {
+	sub, _ := appState.Events().Subscribe(0, 16)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-sub.C:
+			if e.Type == state.EventAlert && e.Alert != nil {
+				group.PostAlert(*e.Alert)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package notifier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+)
+
+// icon は settings.icons の絵文字を返す（Discord のカスタム絵文字は他のサービスで表示できないため fallback を使う）
+func icon(settings *utilities.Settings, key, fallback string) string {
+	if value, ok := settings.Icons[key]; ok && value != "" && !utilities.IsCustomEmoji(value) {
+		return value
+	}
+	return fallback
+}
+
+// statusIcon はステータスに対応する絵文字を返す（Discord の表示と同じ）
+func statusIcon(settings *utilities.Settings, status container.WorkingStatus) string {
+	switch status {
+	case container.StatusRunning:
+		return icon(settings, "poweron", "🟢")
+	case container.StatusStarting:
+		return icon(settings, "reload", "🟡")
+	case container.StatusStopped:
+		return icon(settings, "poweroff", "🔴")
+	case container.StatusNotFound:
+		return icon(settings, "deny", "⚫")
+	default:
+		return "⚪"
+	}
+}
+
+// RenderStatus はサーバーの状態の一覧をプレーンテキストで返す（Discord の /mc-status と同じ内容）
+// server を指定した場合はそのサーバーのみ
+func RenderStatus(appState *state.AppState, server string) string {
+	settings := appState.GetSettings()
+	containers := appState.GetAllContainers()
+
+	keys := make([]string, 0, len(settings.RegisteredContainers))
+	for key := range settings.RegisteredContainers {
+		if server == "" || key == server {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	lines := []string{"🖥️ Minecraft Server Status"}
+	for _, key := range keys {
+		config := settings.RegisteredContainers[key]
+		name := config.DisplayName
+		if config.Icon != "" && !utilities.IsCustomEmoji(config.Icon) {
+			name = config.Icon + " " + name
+		}
+
+		cont, _ := containers[key].(*container.Container)
+		if cont == nil {
+			lines = append(lines, fmt.Sprintf("⚪ %s — %s", name, container.StatusUnknown.JapaneseString()))
+			continue
+		}
+
+		line := fmt.Sprintf("%s %s — %s", statusIcon(settings, cont.Status), name, cont.Status.JapaneseString())
+		if cont.Players > 0 {
+			line += fmt.Sprintf(" · 👥 Players: %d", cont.Players)
+		}
+		if config.AutoShutdown {
+			line += " · ⏱️ Auto-stop ON"
+		}
+		lines = append(lines, line)
+	}
+	if len(keys) == 0 {
+		lines = append(lines, "No registered servers found.")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatAuditEntry は監査ログのエントリを1行（エラーがあれば2行）に整形する
+func FormatAuditEntry(settings *utilities.Settings, e audit.Entry) string {
+	mark := icon(settings, "allow", "✅")
+	switch e.Outcome {
+	case audit.OutcomeFailure:
+		mark = icon(settings, "deny", "❌")
+	case audit.OutcomeRejected:
+		mark = "⚠️"
+	}
+
+	user := "system"
+	if e.UserName != "" {
+		user = e.UserName
+	} else if e.UserID != "" {
+		user = e.UserID
+	}
+
+	line := fmt.Sprintf("%s %s", mark, e.Action)
+	if e.Server != "" {
+		name := e.Server
+		if config, ok := settings.RegisteredContainers[e.Server]; ok {
+			name = config.DisplayName
+		}
+		line += " " + name
+	}
+	if e.Detail != "" {
+		line += fmt.Sprintf(" (%s)", e.Detail)
+	}
+	line += fmt.Sprintf(" — %s via %s", user, e.Source)
+	if e.Error != "" {
+		line += fmt.Sprintf("\n　↳ %s", e.Error)
+	}
+	return line
+}
+
+// FormatAlert はアラートを1行に整形する
+func FormatAlert(alert state.Alert) string {
+	if alert.Level == "info" {
+		return "✅ " + alert.Message
+	}
+	return "🚨 " + alert.Message
+}
@@ -0,0 +1,353 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/notifier"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// commandPrefix はヘルプに表示するスラッシュコマンド（Slack アプリに /mc を登録する）
+	commandPrefix = "/mc"
+
+	// requestTimeout は Web API の呼び出し1回の時間の上限
+	requestTimeout = 15 * time.Second
+
+	// readTimeout は Socket Mode の接続で何も受信しない場合に切断とみなす時間
+	readTimeout = 2 * time.Minute
+
+	// retryDelay は Socket Mode の接続に失敗した場合に待つ時間
+	retryDelay = 5 * time.Second
+)
+
+// Bot は Slack の Socket Mode（スラッシュコマンド）と Web API（chat.postMessage 等）で動く Notifier
+// Socket Mode は Bot から接続するため、エージェントを公開する必要はない
+type Bot struct {
+	appState  *state.AppState
+	handler   *notifier.Handler
+	messenger *notifier.Messenger
+	client    *http.Client
+	dialer    *websocket.Dialer
+	baseURL   string
+	botToken  string
+	appToken  string
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// envelope は Socket Mode で受け取るメッセージ
+type envelope struct {
+	Type       string          `json:"type"`
+	EnvelopeID string          `json:"envelope_id"`
+	Payload    json.RawMessage `json:"payload"`
+	Reason     string          `json:"reason"` // disconnect の理由
+}
+
+// slashCommand はスラッシュコマンドの内容
+type slashCommand struct {
+	Command     string `json:"command"`
+	Text        string `json:"text"`
+	UserID      string `json:"user_id"`
+	UserName    string `json:"user_name"`
+	ChannelID   string `json:"channel_id"`
+	ResponseURL string `json:"response_url"`
+}
+
+// NewBot は新しい Slack の Bot を作成（トークンと API の接続先は起動時の設定を使う）
+func NewBot(appState *state.AppState, auditLog *audit.Logger, commandChan chan<- routine.Command) *Bot {
+	config := appState.GetSettings().Slack
+	bot := &Bot{
+		appState: appState,
+		handler:  notifier.NewHandler(appState, auditLog, commandChan, audit.SourceSlack, commandPrefix),
+		client:   &http.Client{Timeout: requestTimeout},
+		dialer:   websocket.DefaultDialer,
+		baseURL:  strings.TrimRight(config.APIBaseURL, "/"),
+		botToken: config.BotToken,
+		appToken: config.AppToken,
+	}
+	bot.messenger = notifier.NewMessenger(bot.Name(), appState, bot)
+	return bot
+}
+
+// Name は Notifier の名前を返す
+func (b *Bot) Name() string {
+	return "slack"
+}
+
+// config は現在の設定を返す（チャンネルと許可するユーザーは再読み込みで即時に反映される）
+func (b *Bot) config() utilities.SlackConfig {
+	return b.appState.GetSettings().Slack
+}
+
+// Start は Bot のトークンを確認し、コマンドの受け付けと投稿を始める
+func (b *Bot) Start(ctx context.Context) error {
+	var auth struct {
+		User string `json:"user"`
+		Team string `json:"team"`
+	}
+	if err := b.call(ctx, b.botToken, "auth.test", nil, &auth); err != nil {
+		return fmt.Errorf("failed to connect to Slack: %w", err)
+	}
+	log.Info().Str("user", auth.User).Str("team", auth.Team).Msg("Connected to Slack")
+
+	ctx, b.cancel = context.WithCancel(ctx)
+	b.wg.Add(2)
+	go func() {
+		defer b.wg.Done()
+		b.messenger.Run(ctx)
+	}()
+	go func() {
+		defer b.wg.Done()
+		b.listen(ctx)
+	}()
+	b.messenger.RequestUpdate()
+	return nil
+}
+
+// Stop はコマンドの受け付けと投稿を止める
+func (b *Bot) Stop() error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.wg.Wait()
+	return nil
+}
+
+// RequestUpdate は状態表示のメッセージの更新を依頼する
+func (b *Bot) RequestUpdate() {
+	b.messenger.RequestUpdate()
+}
+
+// PostAuditEntry は監査ログのエントリをチャンネルに投稿する
+func (b *Bot) PostAuditEntry(e audit.Entry) {
+	b.messenger.Post(notifier.FormatAuditEntry(b.appState.GetSettings(), e))
+}
+
+// PostAlert はアラートをチャンネルに投稿する
+func (b *Bot) PostAlert(alert state.Alert) {
+	b.messenger.Post(notifier.FormatAlert(alert))
+}
+
+// Channel は投稿先のチャンネル ID を返す
+func (b *Bot) Channel() string {
+	return b.config().ChannelID
+}
+
+// Send はチャンネルにメッセージを投稿してタイムスタンプ（メッセージの ID）を返す
+func (b *Bot) Send(ctx context.Context, text string) (string, error) {
+	var sent struct {
+		TS string `json:"ts"`
+	}
+	err := b.call(ctx, b.botToken, "chat.postMessage", map[string]any{
+		"channel": b.Channel(),
+		"text":    escape(text),
+	}, &sent)
+	return sent.TS, err
+}
+
+// Edit は投稿済みのメッセージを書き換える
+func (b *Bot) Edit(ctx context.Context, id, text string) error {
+	return b.call(ctx, b.botToken, "chat.update", map[string]any{
+		"channel": b.Channel(),
+		"ts":      id,
+		"text":    escape(text),
+	}, nil)
+}
+
+// listen は Socket Mode で接続し、切断されたら接続し直す
+func (b *Bot) listen(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := b.connect(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Slack Socket Mode connection failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+// connect は Socket Mode の接続を1回開き、切断されるまでメッセージを処理する
+func (b *Bot) connect(ctx context.Context) error {
+	var opened struct {
+		URL string `json:"url"`
+	}
+	if err := b.call(ctx, b.appToken, "apps.connections.open", nil, &opened); err != nil {
+		return err
+	}
+
+	conn, _, err := b.dialer.DialContext(ctx, opened.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to dial Socket Mode: %w", err)
+	}
+	defer conn.Close()
+
+	// ctx の終了で読み込みを止める
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	conn.SetReadDeadline(time.Now().Add(readTimeout))
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(10*time.Second))
+	})
+
+	for {
+		var env envelope
+		if err := conn.ReadJSON(&env); err != nil {
+			return fmt.Errorf("failed to read Socket Mode message: %w", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+
+		// 3 秒以内に受信を確認する（確認しないと Slack が再送する）
+		if env.EnvelopeID != "" {
+			if err := conn.WriteJSON(map[string]string{"envelope_id": env.EnvelopeID}); err != nil {
+				return fmt.Errorf("failed to acknowledge Socket Mode message: %w", err)
+			}
+		}
+
+		switch env.Type {
+		case "hello":
+			log.Debug().Msg("Slack Socket Mode connected")
+		case "disconnect":
+			log.Debug().Str("reason", env.Reason).Msg("Slack requested reconnect")
+			return nil
+		case "slash_commands":
+			var command slashCommand
+			if err := json.Unmarshal(env.Payload, &command); err != nil {
+				log.Warn().Err(err).Msg("Failed to decode Slack slash command")
+				continue
+			}
+			b.handleCommand(ctx, command)
+		}
+	}
+}
+
+// handleCommand は設定したチャンネルのスラッシュコマンドを処理し、結果を本人にだけ返信する
+func (b *Bot) handleCommand(ctx context.Context, command slashCommand) {
+	config := b.config()
+	args := strings.Fields(command.Text)
+	allowed := len(config.AllowedUsers) == 0 || slices.Contains(config.AllowedUsers, command.UserID)
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		var reply string
+		if command.ChannelID != config.ChannelID {
+			reply = fmt.Sprintf("This command can only be used in <#%s>.", config.ChannelID)
+		} else {
+			reply = escape(b.handler.Handle(ctx, audit.Entry{UserID: command.UserID, UserName: command.UserName}, args, allowed))
+		}
+		if reply == "" {
+			return
+		}
+
+		respondCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), requestTimeout)
+		defer cancel()
+		if err := b.respond(respondCtx, command.ResponseURL, reply); err != nil {
+			log.Error().Err(err).Msg("Failed to reply to Slack command")
+		}
+	}()
+}
+
+// respond はスラッシュコマンドの response_url に本人にだけ見える返信を送る
+func (b *Bot) respond(ctx context.Context, responseURL, text string) error {
+	body, err := json.Marshal(map[string]string{"response_type": "ephemeral", "text": text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from response_url", resp.StatusCode)
+	}
+	return nil
+}
+
+// call は Web API のメソッドを呼び出し、レスポンスを out に読み込む（out が nil なら読み込まない）
+func (b *Bot) call(ctx context.Context, token, method string, params any, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	var reader io.Reader = http.NoBody
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+"/"+method, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("%s: rate limited (retry after %s seconds)", method, resp.Header.Get("Retry-After"))
+	}
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("%s: failed to decode response (status %d): %w", method, resp.StatusCode, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("%s: %s", method, result.Error)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("%s: failed to decode response: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// escape は Slack のメッセージで特別な意味を持つ文字をエスケープする
+func escape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
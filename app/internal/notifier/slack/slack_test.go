@@ -0,0 +1,264 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/gorilla/websocket"
+)
+
+// apiCall は Web API のスタブが受け取ったリクエスト
+type apiCall struct {
+	Path          string
+	Authorization string
+	Body          map[string]any
+}
+
+// stubAPI は Slack の Web API を真似る httptest のサーバー
+// responses にメソッド名ごとのレスポンスを登録し、受け取ったリクエストを calls に記録する
+type stubAPI struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	calls     []apiCall
+	responses map[string]string
+}
+
+func newStubAPI(t *testing.T, responses map[string]string) *stubAPI {
+	t.Helper()
+	stub := &stubAPI{responses: responses}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+
+		stub.mu.Lock()
+		stub.calls = append(stub.calls, apiCall{Path: r.URL.Path, Authorization: r.Header.Get("Authorization"), Body: body})
+		stub.mu.Unlock()
+
+		response, ok := responses[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+// recorded は受け取ったリクエストを返す
+func (s *stubAPI) recorded() []apiCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]apiCall(nil), s.calls...)
+}
+
+// newTestBot は API の接続先をスタブに向けた Bot を作成する
+func newTestBot(t *testing.T, baseURL string) *Bot {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("DATA_DIR", dir)
+
+	settings := &utilities.Settings{
+		RegisteredContainers: map[string]utilities.ContainerConfig{
+			"survival": {DisplayName: "Survival", ContainerName: "mc-survival"},
+		},
+		Slack: utilities.SlackConfig{
+			Enabled:    true,
+			BotToken:   "xoxb-test",
+			AppToken:   "xapp-test",
+			ChannelID:  "C123",
+			APIBaseURL: baseURL + "/",
+		},
+	}
+	auditLog := audit.NewLogger(filepath.Join(dir, "audit.jsonl"), nil)
+	return NewBot(state.NewAppState(settings), auditLog, make(chan routine.Command, 1))
+}
+
+func TestSendAndEdit(t *testing.T) {
+	stub := newStubAPI(t, map[string]string{
+		"chat.postMessage": `{"ok":true,"ts":"1700000000.000100"}`,
+		"chat.update":      `{"ok":true}`,
+	})
+	bot := newTestBot(t, stub.URL)
+
+	ts, err := bot.Send(context.Background(), "a < b & c")
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if ts != "1700000000.000100" {
+		t.Errorf("Send returned ts %q", ts)
+	}
+	if err := bot.Edit(context.Background(), ts, "updated"); err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+
+	calls := stub.recorded()
+	if len(calls) != 2 {
+		t.Fatalf("got %d calls, want 2", len(calls))
+	}
+	sent := calls[0]
+	if sent.Path != "/chat.postMessage" || sent.Authorization != "Bearer xoxb-test" {
+		t.Errorf("unexpected request %s with %q", sent.Path, sent.Authorization)
+	}
+	if sent.Body["channel"] != "C123" || sent.Body["text"] != "a &lt; b &amp; c" {
+		t.Errorf("unexpected postMessage body %v", sent.Body)
+	}
+	edited := calls[1]
+	if edited.Path != "/chat.update" || edited.Body["ts"] != ts || edited.Body["text"] != "updated" {
+		t.Errorf("unexpected update request %s %v", edited.Path, edited.Body)
+	}
+}
+
+func TestCallErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{name: "api error", status: http.StatusOK, body: `{"ok":false,"error":"channel_not_found"}`, wantErr: "chat.postMessage: channel_not_found"},
+		{name: "rate limited", status: http.StatusTooManyRequests, body: `{"ok":false,"error":"ratelimited"}`, wantErr: "rate limited (retry after 30 seconds)"},
+		{name: "invalid response", status: http.StatusBadGateway, body: `<html>bad gateway</html>`, wantErr: "failed to decode response (status 502)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "30")
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			bot := newTestBot(t, server.URL)
+			_, err := bot.Send(context.Background(), "hello")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Send error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandleCommandReplies(t *testing.T) {
+	stub := newStubAPI(t, map[string]string{"respond": `ok`})
+	bot := newTestBot(t, stub.URL)
+
+	bot.handleCommand(context.Background(), slashCommand{
+		Command:     "/mc",
+		Text:        "status",
+		UserID:      "U1",
+		ChannelID:   "C123",
+		ResponseURL: stub.URL + "/respond",
+	})
+	bot.handleCommand(context.Background(), slashCommand{
+		Command:     "/mc",
+		Text:        "start survival",
+		UserID:      "U2",
+		ChannelID:   "C999",
+		ResponseURL: stub.URL + "/respond",
+	})
+	bot.wg.Wait()
+
+	replies := make(map[string]bool)
+	for _, call := range stub.recorded() {
+		if call.Body["response_type"] != "ephemeral" {
+			t.Errorf("reply is not ephemeral: %v", call.Body)
+		}
+		text, _ := call.Body["text"].(string)
+		replies[text] = true
+		switch {
+		case strings.Contains(text, "Minecraft Server Status"):
+			if !strings.Contains(text, "Survival") {
+				t.Errorf("status reply does not list the server: %q", text)
+			}
+		case text == "This command can only be used in <#C123>.":
+		default:
+			t.Errorf("unexpected reply %q", text)
+		}
+	}
+	if len(replies) != 2 {
+		t.Errorf("got replies %v, want status and channel rejection", replies)
+	}
+}
+
+func TestRespondError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "expired_url", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	bot := newTestBot(t, server.URL)
+	err := bot.respond(context.Background(), server.URL+"/respond", "hello")
+	if err == nil || !strings.Contains(err.Error(), "unexpected status 404") {
+		t.Fatalf("respond error = %v, want unexpected status 404", err)
+	}
+}
+
+func TestSocketModeAcknowledgesAndHandlesCommands(t *testing.T) {
+	acks := make(chan string, 2)
+	upgrader := websocket.Upgrader{}
+	var stub *stubAPI
+	socket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		payload, _ := json.Marshal(slashCommand{
+			Command:     "/mc",
+			Text:        "help",
+			UserID:      "U1",
+			ChannelID:   "C123",
+			ResponseURL: stub.URL + "/respond",
+		})
+		conn.WriteJSON(envelope{Type: "hello"})
+		conn.WriteJSON(envelope{Type: "slash_commands", EnvelopeID: "env-1", Payload: payload})
+
+		var ack map[string]string
+		if err := conn.ReadJSON(&ack); err == nil {
+			acks <- ack["envelope_id"]
+		}
+		conn.WriteJSON(envelope{Type: "disconnect", Reason: "refresh_requested"})
+	}))
+	defer socket.Close()
+
+	stub = newStubAPI(t, map[string]string{
+		"apps.connections.open": `{"ok":true,"url":"ws` + strings.TrimPrefix(socket.URL, "http") + `"}`,
+		"respond":               `ok`,
+	})
+	bot := newTestBot(t, stub.URL)
+
+	if err := bot.connect(context.Background()); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	bot.wg.Wait()
+
+	if got := <-acks; got != "env-1" {
+		t.Errorf("acknowledged envelope %q, want env-1", got)
+	}
+
+	calls := stub.recorded()
+	if len(calls) != 2 {
+		t.Fatalf("got %d calls, want connections.open and the reply", len(calls))
+	}
+	if calls[0].Authorization != "Bearer xapp-test" {
+		t.Errorf("apps.connections.open used %q, want the app token", calls[0].Authorization)
+	}
+	if text, _ := calls[1].Body["text"].(string); !strings.Contains(text, "/mc status") {
+		t.Errorf("help reply = %q", text)
+	}
+}
@@ -0,0 +1,309 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/notifier"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// commandPrefix はチャットのコマンド（グループでは /mc@bot_name も受け付ける）
+	commandPrefix = "/mc"
+
+	// pollTimeout は getUpdates のロングポーリングで待つ秒数
+	pollTimeout = 30
+
+	// retryDelay は getUpdates が失敗した場合に待つ時間
+	retryDelay = 5 * time.Second
+)
+
+// Bot は Telegram の Bot API（getUpdates のロングポーリング）で動く Notifier
+type Bot struct {
+	appState  *state.AppState
+	handler   *notifier.Handler
+	messenger *notifier.Messenger
+	client    *http.Client
+	baseURL   string // <api_base_url>/bot<token>
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// apiResponse は Bot API のレスポンス
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+}
+
+// update は getUpdates の1件（message のみ使う）
+type update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *message `json:"message"`
+}
+
+// message は Telegram のメッセージ
+type message struct {
+	MessageID int64  `json:"message_id"`
+	From      *user  `json:"from"`
+	Chat      chat   `json:"chat"`
+	Text      string `json:"text"`
+}
+
+// user はメッセージの送信者
+type user struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+}
+
+// chat はメッセージのチャット
+type chat struct {
+	ID int64 `json:"id"`
+}
+
+// NewBot は新しい Telegram の Bot を作成（トークンと API の接続先は起動時の設定を使う）
+func NewBot(appState *state.AppState, auditLog *audit.Logger, commandChan chan<- routine.Command) *Bot {
+	config := appState.GetSettings().Telegram
+	bot := &Bot{
+		appState: appState,
+		handler:  notifier.NewHandler(appState, auditLog, commandChan, audit.SourceTelegram, commandPrefix),
+		// ロングポーリングの待ち時間より長くする
+		client:  &http.Client{Timeout: (pollTimeout + 15) * time.Second},
+		baseURL: strings.TrimRight(config.APIBaseURL, "/") + "/bot" + config.Token,
+	}
+	bot.messenger = notifier.NewMessenger(bot.Name(), appState, bot)
+	return bot
+}
+
+// Name は Notifier の名前を返す
+func (b *Bot) Name() string {
+	return "telegram"
+}
+
+// config は現在の設定を返す（チャットと許可するユーザーは再読み込みで即時に反映される）
+func (b *Bot) config() utilities.TelegramConfig {
+	return b.appState.GetSettings().Telegram
+}
+
+// Start は Bot の情報を確認し、コマンドの受け付けと投稿を始める
+func (b *Bot) Start(ctx context.Context) error {
+	var me user
+	if err := b.call(ctx, "getMe", struct{}{}, &me); err != nil {
+		return fmt.Errorf("failed to connect to Telegram: %w", err)
+	}
+	log.Info().Str("username", me.Username).Msg("Connected to Telegram")
+
+	ctx, b.cancel = context.WithCancel(ctx)
+	b.wg.Add(2)
+	go func() {
+		defer b.wg.Done()
+		b.messenger.Run(ctx)
+	}()
+	go func() {
+		defer b.wg.Done()
+		b.poll(ctx)
+	}()
+	b.messenger.RequestUpdate()
+	return nil
+}
+
+// Stop はコマンドの受け付けと投稿を止める
+func (b *Bot) Stop() error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.wg.Wait()
+	return nil
+}
+
+// RequestUpdate は状態表示のメッセージの更新を依頼する
+func (b *Bot) RequestUpdate() {
+	b.messenger.RequestUpdate()
+}
+
+// PostAuditEntry は監査ログのエントリをチャットに投稿する
+func (b *Bot) PostAuditEntry(e audit.Entry) {
+	b.messenger.Post(notifier.FormatAuditEntry(b.appState.GetSettings(), e))
+}
+
+// PostAlert はアラートをチャットに投稿する
+func (b *Bot) PostAlert(alert state.Alert) {
+	b.messenger.Post(notifier.FormatAlert(alert))
+}
+
+// Channel は投稿先のチャット ID を返す
+func (b *Bot) Channel() string {
+	return b.config().ChatID
+}
+
+// Send はチャットにメッセージを投稿する
+func (b *Bot) Send(ctx context.Context, text string) (string, error) {
+	return b.sendMessage(ctx, b.Channel(), text, 0)
+}
+
+// Edit は投稿済みのメッセージを書き換える
+func (b *Bot) Edit(ctx context.Context, id, text string) error {
+	messageID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid message id %q: %w", id, err)
+	}
+	err = b.call(ctx, "editMessageText", map[string]any{
+		"chat_id":    b.Channel(),
+		"message_id": messageID,
+		"text":       text,
+	}, nil)
+	// 内容が同じ場合もエラーになる（編集は不要だったので成功とみなす）
+	if err != nil && strings.Contains(err.Error(), "message is not modified") {
+		return nil
+	}
+	return err
+}
+
+// sendMessage はメッセージを投稿して ID を返す（replyTo が 0 でなければそのメッセージへの返信にする）
+func (b *Bot) sendMessage(ctx context.Context, chatID, text string, replyTo int64) (string, error) {
+	params := map[string]any{
+		"chat_id": chatID,
+		"text":    text,
+	}
+	if replyTo != 0 {
+		params["reply_parameters"] = map[string]any{"message_id": replyTo, "allow_sending_without_reply": true}
+	}
+	var sent message
+	if err := b.call(ctx, "sendMessage", params, &sent); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(sent.MessageID, 10), nil
+}
+
+// poll は getUpdates でメッセージを受け取り続ける
+// 起動前に送られたメッセージは処理しない（再起動で古いコマンドを実行しないため）
+func (b *Bot) poll(ctx context.Context) {
+	var offset int64
+	var pending []update
+	if err := b.call(ctx, "getUpdates", map[string]any{"offset": -1, "timeout": 0}, &pending); err != nil {
+		log.Warn().Err(err).Msg("Failed to skip pending Telegram updates")
+	}
+	for _, u := range pending {
+		offset = max(offset, u.UpdateID+1)
+	}
+
+	for ctx.Err() == nil {
+		var updates []update
+		err := b.call(ctx, "getUpdates", map[string]any{
+			"offset":          offset,
+			"timeout":         pollTimeout,
+			"allowed_updates": []string{"message"},
+		}, &updates)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Error().Err(err).Msg("Failed to get Telegram updates")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			offset = max(offset, u.UpdateID+1)
+			if u.Message != nil {
+				b.handleMessage(ctx, *u.Message)
+			}
+		}
+	}
+}
+
+// handleMessage は設定したチャットのコマンドを処理し、結果を返信する
+func (b *Bot) handleMessage(ctx context.Context, msg message) {
+	config := b.config()
+	chatID := strconv.FormatInt(msg.Chat.ID, 10)
+	if chatID != config.ChatID || msg.From == nil || msg.From.IsBot {
+		return
+	}
+	args, ok := notifier.ParseCommand(msg.Text, commandPrefix)
+	if !ok {
+		return
+	}
+
+	userID := strconv.FormatInt(msg.From.ID, 10)
+	userName := msg.From.Username
+	if userName == "" {
+		userName = msg.From.FirstName
+	}
+	allowed := len(config.AllowedUsers) == 0 || slices.Contains(config.AllowedUsers, userID)
+
+	// 完了を待つ間も次のメッセージを受け取れるようにする
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		reply := b.handler.Handle(ctx, audit.Entry{UserID: userID, UserName: userName}, args, allowed)
+		if reply == "" {
+			return
+		}
+		sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 15*time.Second)
+		defer cancel()
+		if _, err := b.sendMessage(sendCtx, chatID, reply, msg.MessageID); err != nil {
+			log.Error().Err(err).Msg("Failed to reply to Telegram command")
+		}
+	}()
+}
+
+// call は Bot API のメソッドを呼び出し、result を out に読み込む（out が nil なら読み込まない）
+func (b *Bot) call(ctx context.Context, method string, params any, out any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.baseURL+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// URL にトークンが含まれるためエラーメッセージには出さない
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var result apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%s: failed to decode response (status %d): %w", method, resp.StatusCode, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("%s: %d %s", method, result.ErrorCode, result.Description)
+	}
+	if out != nil {
+		if err := json.Unmarshal(result.Result, out); err != nil {
+			return fmt.Errorf("%s: failed to decode result: %w", method, err)
+		}
+	}
+	return nil
+}
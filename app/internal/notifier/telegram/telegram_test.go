@@ -0,0 +1,197 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+)
+
+const testToken = "123456:secret-token"
+
+// apiCall は Bot API のスタブが受け取ったリクエスト
+type apiCall struct {
+	Method string
+	Body   map[string]any
+}
+
+// stubAPI は Bot API を真似る httptest のサーバー
+// /bot<token>/<method> 以外のパスは 404 にし、受け取ったリクエストを記録して handle が返した本文を返す
+type stubAPI struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	calls []apiCall
+}
+
+func newStubAPI(t *testing.T, handle func(method string) string) *stubAPI {
+	t.Helper()
+	stub := &stubAPI{}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, ok := strings.CutPrefix(r.URL.Path, "/bot"+testToken+"/")
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"ok":false,"error_code":404,"description":"Not Found"}`)
+			return
+		}
+
+		var body map[string]any
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+
+		stub.mu.Lock()
+		stub.calls = append(stub.calls, apiCall{Method: method, Body: body})
+		stub.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, handle(method))
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+// recorded は受け取ったリクエストを返す
+func (s *stubAPI) recorded() []apiCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]apiCall(nil), s.calls...)
+}
+
+// newTestBot は API の接続先をスタブに向けた Bot を作成する
+func newTestBot(t *testing.T, baseURL string) *Bot {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("DATA_DIR", dir)
+
+	settings := &utilities.Settings{
+		RegisteredContainers: map[string]utilities.ContainerConfig{
+			"survival": {DisplayName: "Survival", ContainerName: "mc-survival"},
+		},
+		Telegram: utilities.TelegramConfig{
+			Enabled:    true,
+			Token:      testToken,
+			ChatID:     "-100200",
+			APIBaseURL: baseURL + "/",
+		},
+	}
+	auditLog := audit.NewLogger(filepath.Join(dir, "audit.jsonl"), nil)
+	return NewBot(state.NewAppState(settings), auditLog, make(chan routine.Command, 1))
+}
+
+// commandMessage はチャットのメッセージを作る
+func commandMessage(id, chatID, userID int64, isBot bool, text string) message {
+	return message{
+		MessageID: id,
+		From:      &user{ID: userID, IsBot: isBot, Username: "alice"},
+		Chat:      chat{ID: chatID},
+		Text:      text,
+	}
+}
+
+func TestSendAndEdit(t *testing.T) {
+	stub := newStubAPI(t, func(method string) string {
+		if method == "sendMessage" {
+			return `{"ok":true,"result":{"message_id":42,"chat":{"id":-100200},"text":"hello"}}`
+		}
+		return `{"ok":true,"result":true}`
+	})
+	bot := newTestBot(t, stub.URL)
+
+	id, err := bot.Send(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if id != "42" {
+		t.Errorf("Send returned %q, want 42", id)
+	}
+	if err := bot.Edit(context.Background(), id, "updated"); err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+
+	calls := stub.recorded()
+	if len(calls) != 2 {
+		t.Fatalf("got %d calls, want 2", len(calls))
+	}
+	if calls[0].Method != "sendMessage" || calls[0].Body["chat_id"] != "-100200" || calls[0].Body["text"] != "hello" {
+		t.Errorf("unexpected sendMessage %v", calls[0])
+	}
+	if calls[1].Method != "editMessageText" || calls[1].Body["message_id"] != float64(42) || calls[1].Body["text"] != "updated" {
+		t.Errorf("unexpected editMessageText %v", calls[1])
+	}
+}
+
+func TestEditErrors(t *testing.T) {
+	stub := newStubAPI(t, func(method string) string {
+		return `{"ok":false,"error_code":400,"description":"Bad Request: message is not modified"}`
+	})
+	bot := newTestBot(t, stub.URL)
+
+	if err := bot.Edit(context.Background(), "42", "same"); err != nil {
+		t.Errorf("Edit with unchanged text = %v, want nil", err)
+	}
+	if err := bot.Edit(context.Background(), "not-a-number", "text"); err == nil || !strings.Contains(err.Error(), "invalid message id") {
+		t.Errorf("Edit with invalid id = %v", err)
+	}
+}
+
+func TestCallErrors(t *testing.T) {
+	stub := newStubAPI(t, func(method string) string {
+		return `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`
+	})
+	bot := newTestBot(t, stub.URL)
+
+	_, err := bot.Send(context.Background(), "hello")
+	if err == nil || err.Error() != "sendMessage: 400 Bad Request: chat not found" {
+		t.Errorf("Send error = %v", err)
+	}
+
+	// 接続できない場合もトークンを含む URL をエラーに出さない
+	stub.Close()
+	_, err = bot.Send(context.Background(), "hello")
+	if err == nil {
+		t.Fatal("Send succeeded against a closed server")
+	}
+	if strings.Contains(err.Error(), testToken) {
+		t.Errorf("error leaks the token: %v", err)
+	}
+}
+
+func TestHandleMessageRepliesToCommands(t *testing.T) {
+	stub := newStubAPI(t, func(method string) string {
+		return `{"ok":true,"result":{"message_id":100,"chat":{"id":-100200}}}`
+	})
+	bot := newTestBot(t, stub.URL)
+
+	ctx := context.Background()
+	bot.handleMessage(ctx, commandMessage(7, -100200, 1001, false, "/mc@agent_bot status"))
+	bot.handleMessage(ctx, commandMessage(8, -999, 1001, false, "/mc status"))
+	bot.handleMessage(ctx, commandMessage(9, -100200, 2002, true, "/mc status"))
+	bot.handleMessage(ctx, commandMessage(10, -100200, 1001, false, "hello"))
+	bot.wg.Wait()
+
+	calls := stub.recorded()
+	if len(calls) != 1 {
+		t.Fatalf("got %d replies, want 1 (other chats, bots and chat are ignored)", len(calls))
+	}
+	body := calls[0].Body
+	if calls[0].Method != "sendMessage" || body["chat_id"] != "-100200" {
+		t.Errorf("unexpected reply %v", calls[0])
+	}
+	if text, _ := body["text"].(string); !strings.Contains(text, "Minecraft Server Status") || !strings.Contains(text, "Survival") {
+		t.Errorf("unexpected status reply %q", text)
+	}
+	reply, _ := body["reply_parameters"].(map[string]any)
+	if reply["message_id"] != float64(7) {
+		t.Errorf("reply is not in reply to the command: %v", body)
+	}
+}
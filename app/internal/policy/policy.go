@@ -0,0 +1,133 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/rs/zerolog/log"
+)
+
+// Reason は拒否の種類（HTTP のステータスコードなどへの対応付けに使う）
+type Reason int
+
+const (
+	ReasonNotFound    Reason = iota // 登録されていないサーバー
+	ReasonConflict                  // 現在の状態では実行できない（起動済み・プレイヤー在籍など）
+	ReasonUnavailable               // 状態を取得できない
+	ReasonForbidden                 // allowed_actions で許可されていない
+)
+
+// Rejection はコマンドを実行しない理由（Message は利用者にそのまま表示できる）
+type Rejection struct {
+	Reason  Reason
+	Message string
+}
+
+// Error は拒否メッセージを返す
+func (r *Rejection) Error() string {
+	return r.Message
+}
+
+// reject は Rejection を作成
+func reject(reason Reason, format string, args ...any) *Rejection {
+	return &Rejection{Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+// Options はコマンドの事前チェックの追加条件（制御用ソケットのみが指定する）
+type Options struct {
+	LockID string // 保守ロックの ID（一致すればロック中のサーバーも操作できる）
+	Force  bool   // プレイヤーがいても停止・再起動する
+}
+
+// CheckCommand は start / stop / restart をサーバーに実行してよいか確認する（実行してよい場合は nil）
+// Discord のボタン・スラッシュコマンドと HTTP API で同じ判定を使う
+// 稼働中のサーバーの停止・再起動はプレイヤーがいる場合に拒否する
+func CheckCommand(ctx context.Context, appState *state.AppState, action, key string) *Rejection {
+	return CheckCommandWithOptions(ctx, appState, action, key, Options{})
+}
+
+// CheckCommandWithOptions は CheckCommand と同じ確認を opts の条件で行う
+// 保守ロック中のサーバーは、ロックの ID を指定した場合のみ操作できる
+func CheckCommandWithOptions(ctx context.Context, appState *state.AppState, action, key string, opts Options) *Rejection {
+	settings := appState.GetSettings()
+
+	// 設定確認
+	config, ok := settings.RegisteredContainers[key]
+	if !ok {
+		return reject(ReasonNotFound, "Container '%s' not found", key)
+	}
+
+	// 保守ロックの確認（バックアップ等のスクリプトが作業中）
+	if lock, ok := appState.Lock(key); ok && lock.ID != opts.LockID {
+		return reject(ReasonConflict, "%s is under maintenance by %s until %s.", config.DisplayName, lock.Owner, lock.ExpiresAt.Format("15:04"))
+	}
+
+	// コンテナの現在状態をチェック（起動済み・停止済み・プレイヤー在籍など）
+	stateObj, ok := appState.GetContainer(key)
+	if !ok {
+		return reject(ReasonUnavailable, "Unable to retrieve status for %s. Please try again later.", config.DisplayName)
+	}
+	if cont, ok := stateObj.(*container.Container); ok {
+		switch action {
+		case "start":
+			if cont.Status == container.StatusRunning {
+				return reject(ReasonConflict, "%s is already running.", config.DisplayName)
+			}
+			if cont.Status == container.StatusStarting {
+				return reject(ReasonConflict, "%s is currently starting. Please wait and try again.", config.DisplayName)
+			}
+			if cont.Status == container.StatusNotFound || cont.ID == "" {
+				return reject(ReasonUnavailable, "%s is currently unavailable (container not found).", config.DisplayName)
+			}
+		case "stop", "restart":
+			if cont.Status == container.StatusStopped || cont.Status == container.StatusNotFound {
+				return reject(ReasonConflict, "%s is already stopped.", config.DisplayName)
+			}
+			if players := onlinePlayers(ctx, key, cont); players > 0 && !opts.Force {
+				return reject(ReasonConflict, "%s cannot be %s because there are players online (%d players).", config.DisplayName, pastParticiple(action), players)
+			}
+		}
+	}
+
+	// アクション確認
+	if !IsActionAllowed(settings.AllowedActions, action) {
+		return reject(ReasonForbidden, "Sorry, the action `%s` is not allowed.", action)
+	}
+	return nil
+}
+
+// IsActionAllowed はアクションが許可されているか確認（再起動は起動・停止の両方が必要）
+func IsActionAllowed(allowed utilities.AllowedActions, action string) bool {
+	switch action {
+	case "start":
+		return allowed.PowerOn
+	case "stop":
+		return allowed.PowerOff
+	case "restart":
+		return allowed.PowerOn && allowed.PowerOff
+	default:
+		return false
+	}
+}
+
+// onlinePlayers はオンラインのプレイヤー数を返す
+// リアルタイムで取得（rcon-cli経由）し、失敗した場合はキャッシュ値にフォールバック
+func onlinePlayers(ctx context.Context, key string, cont *container.Container) int {
+	players, err := cont.FetchAllPlayers(ctx)
+	if err != nil {
+		log.Warn().Err(err).Str("container", key).Msg("Failed to fetch realtime players, using cached value")
+		return cont.Players
+	}
+	return len(players)
+}
+
+// pastParticiple は拒否メッセージ用にアクションを過去分詞にする
+func pastParticiple(action string) string {
+	if action == "restart" {
+		return "restarted"
+	}
+	return "stopped"
+}
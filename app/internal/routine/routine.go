@@ -2,10 +2,13 @@ package routine
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
 	"github.com/Koranoa3/mc-server-agent/internal/docker"
 	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/Koranoa3/mc-server-agent/internal/monitoring"
 	"github.com/Koranoa3/mc-server-agent/internal/state"
 	"github.com/rs/zerolog/log"
 )
@@ -21,6 +24,20 @@ type Command struct {
 	Type        string
 	ContainerID string
 	Timeout     int
+
+	// 監査ログ用の発行元情報
+	Source   audit.Source
+	UserID   string
+	UserName string
+
+	// 実行結果の返却先（nil の場合は返さない）
+	Reply chan<- CommandResult
+}
+
+// CommandResult はコマンドの実行結果
+type CommandResult struct {
+	Message string
+	Err     error
 }
 
 // Run は定期監視ループを実行
@@ -36,6 +53,11 @@ func Run(ctx context.Context, appState *state.AppState, dockerMgr *docker.Manage
 	// 前回のハッシュを保存
 	previousHashes := make(map[string]string)
 
+	// イベント用に前回の状態とオンラインのプレイヤー名を保存
+	previousStatus := make(map[string]container.WorkingStatus)
+	onlinePlayers := make(map[string][]string)
+	updateFailing := false
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -44,12 +66,47 @@ func Run(ctx context.Context, appState *state.AppState, dockerMgr *docker.Manage
 
 		case <-ticker.C:
 			log.Debug().Msg("Routine: checking containers")
+			tickStart := time.Now()
+
+			// 設定の再読み込みで間隔が変わっていれば反映
+			if current := time.Duration(appState.GetSettings().RegularTask.Interval) * time.Second; current != interval {
+				interval = current
+				ticker.Reset(interval)
+				log.Info().Dur("interval", interval).Msg("Routine interval changed")
+			}
 
 			// コンテナ情報を更新
-			if err := dockerMgr.UpdateAllContainers(ctx); err != nil {
+			err := dockerMgr.UpdateAllContainers(ctx)
+			monitoring.RecordRoutineTick(err)
+			if err != nil {
 				log.Error().Err(err).Msg("Routine: failed to update containers")
+				// 失敗が続いている間は最初の1回だけ通知
+				if !updateFailing {
+					updateFailing = true
+					appState.Events().Publish(state.Event{
+						Type:  state.EventAlert,
+						Alert: &state.Alert{Level: "error", Message: fmt.Sprintf("Failed to update containers: %v", err)},
+					})
+				}
+				monitoring.ObserveRoutineTick(time.Since(tickStart))
 				continue
 			}
+			if updateFailing {
+				updateFailing = false
+				appState.Events().Publish(state.Event{
+					Type:  state.EventAlert,
+					Alert: &state.Alert{Level: "info", Message: "Container updates recovered"},
+				})
+			}
+
+			// 期限切れの保守ロックを解除（スクリプトが解除しないまま終了した場合など）
+			for _, lock := range appState.PruneExpiredLocks() {
+				log.Warn().Str("container", lock.Server).Str("owner", lock.Owner).Msg("Maintenance lock expired")
+				appState.Events().Publish(state.Event{
+					Type:  state.EventAlert,
+					Alert: &state.Alert{Level: "info", Message: fmt.Sprintf("Maintenance lock on %s held by %s expired", lock.Server, lock.Owner)},
+				})
+			}
 
 			// 各コンテナの状態をチェック
 			containers := appState.GetAllContainers()
@@ -71,6 +128,11 @@ func Run(ctx context.Context, appState *state.AppState, dockerMgr *docker.Manage
 						ContainerID: key,
 						Changed:     true,
 					}
+
+					// 初回は起動直後の状態なので変化として配信しない
+					prevStatus, known := previousStatus[key]
+					onlinePlayers[key] = publishChanges(ctx, appState.Events(), key, cont, known, prevStatus, onlinePlayers[key])
+					previousStatus[key] = cont.Status
 					previousHashes[key] = cont.StateHash
 				}
 
@@ -81,6 +143,11 @@ func Run(ctx context.Context, appState *state.AppState, dockerMgr *docker.Manage
 					continue
 				}
 
+				// 保守ロック中は自動停止しない
+				if _, locked := appState.Lock(key); locked {
+					continue
+				}
+
 				// 稼働中でプレイヤーゼロの場合
 				if cont.Status == container.StatusRunning && cont.Players == 0 && !cont.StopTimer.IsZero() {
 					elapsed := time.Since(cont.StopTimer)
@@ -96,10 +163,70 @@ func Run(ctx context.Context, appState *state.AppState, dockerMgr *docker.Manage
 							Type:        "stop",
 							ContainerID: key,
 							Timeout:     10,
+							Source:      audit.SourceAutoShutdown,
 						}
 					}
 				}
 			}
+
+			monitoring.ObserveRoutineTick(time.Since(tickStart))
+		}
+	}
+}
+
+// publishChanges は前回からの状態・プレイヤーの変化をイベントとして配信し、現在のオンラインのプレイヤー名を返す
+// プレイヤー名は人数が変わったときだけ RCON で取得する（人数が同じままの入れ替わりは検知しない）
+func publishChanges(ctx context.Context, events *state.EventBus, key string, cont *container.Container, known bool, prevStatus container.WorkingStatus, prevPlayers []string) []string {
+	if known && cont.Status != prevStatus {
+		change := &state.StatusChange{
+			From:    prevStatus.String(),
+			To:      cont.Status.String(),
+			Health:  cont.Health,
+			Players: cont.Players,
+		}
+		if cont.Status == container.StatusStopped {
+			exitCode := cont.ExitCode
+			change.ExitCode = &exitCode
+			change.OOMKilled = cont.OOMKilled
+		}
+		events.Publish(state.Event{Type: state.EventStatusChanged, Server: key, Status: change})
+	}
+
+	var players []string
+	switch {
+	case cont.Status != container.StatusRunning || cont.Players == 0:
+		// 停止・プレイヤーなしの場合は全員退出
+	case cont.Players == len(prevPlayers):
+		players = prevPlayers
+	default:
+		fetched, err := cont.FetchAllPlayers(ctx)
+		if err != nil {
+			log.Warn().Err(err).Str("container", key).Msg("Routine: failed to fetch players")
+			return prevPlayers
+		}
+		for _, player := range fetched {
+			players = append(players, player.Name)
+		}
+	}
+	if !known {
+		return players
+	}
+
+	online := make(map[string]bool, len(players))
+	for _, name := range players {
+		online[name] = true
+	}
+	previous := make(map[string]bool, len(prevPlayers))
+	for _, name := range prevPlayers {
+		previous[name] = true
+		if !online[name] {
+			events.Publish(state.Event{Type: state.EventPlayerLeft, Server: key, Player: &state.PlayerChange{Name: name, Online: len(players)}})
+		}
+	}
+	for _, name := range players {
+		if !previous[name] {
+			events.Publish(state.Event{Type: state.EventPlayerJoined, Server: key, Player: &state.PlayerChange{Name: name, Online: len(players)}})
 		}
 	}
+	return players
 }
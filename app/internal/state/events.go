@@ -0,0 +1,185 @@
+package state
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EventType はイベントの種類
+type EventType string
+
+const (
+	EventStatusChanged    EventType = "status_changed"    // サーバーの状態（稼働中・停止中など）が変わった
+	EventPlayerJoined     EventType = "player_joined"     // プレイヤーが参加した
+	EventPlayerLeft       EventType = "player_left"       // プレイヤーが退出した（サーバー停止を含む）
+	EventCommandStarted   EventType = "command_started"   // start / stop / restart の実行を開始した
+	EventCommandCompleted EventType = "command_completed" // コマンドが成功した
+	EventCommandFailed    EventType = "command_failed"    // コマンドが失敗した
+	EventCommandRejected  EventType = "command_rejected"  // 事前チェック（起動済み・プレイヤー在籍・許可設定など）で拒否した
+	EventAlert            EventType = "alert"             // エージェント自体の異常（Docker に接続できない等）
+
+	EventMaintenanceLocked   EventType = "maintenance_locked"   // サーバーの保守ロックを取得した
+	EventMaintenanceUnlocked EventType = "maintenance_unlocked" // 保守ロックを解除した（期限切れを含む）
+)
+
+// EventTypes はすべてのイベントの種類
+var EventTypes = []EventType{
+	EventStatusChanged,
+	EventPlayerJoined,
+	EventPlayerLeft,
+	EventCommandStarted,
+	EventCommandCompleted,
+	EventCommandFailed,
+	EventCommandRejected,
+	EventAlert,
+	EventMaintenanceLocked,
+	EventMaintenanceUnlocked,
+}
+
+// Event は購読者に配るイベント（種類に応じたフィールドだけが入る）
+type Event struct {
+	ID      uint64        `json:"id"` // プロセス内で単調増加（再起動でリセット）
+	Type    EventType     `json:"type"`
+	Time    time.Time     `json:"time"`
+	Server  string        `json:"server,omitempty"` // registered_containers のキー
+	Status  *StatusChange `json:"status,omitempty"`
+	Player  *PlayerChange `json:"player,omitempty"`
+	Command *CommandEvent `json:"command,omitempty"`
+	Alert   *Alert        `json:"alert,omitempty"`
+	Lock    *LockEvent    `json:"lock,omitempty"`
+}
+
+// StatusChange は status_changed の内容
+type StatusChange struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Health  string `json:"health,omitempty"`
+	Players int    `json:"players"`
+
+	// 停止した場合のコンテナの終了状態
+	ExitCode  *int `json:"exit_code,omitempty"`
+	OOMKilled bool `json:"oom_killed,omitempty"`
+}
+
+// PlayerChange は player_joined / player_left の内容
+type PlayerChange struct {
+	Name   string `json:"name"`
+	Online int    `json:"online"` // 変化後のオンライン人数
+}
+
+// CommandEvent は command_* の内容
+type CommandEvent struct {
+	Action   string `json:"action"`
+	Source   string `json:"source"`
+	UserID   string `json:"user_id,omitempty"`
+	UserName string `json:"user_name,omitempty"`
+	Error    string `json:"error,omitempty"` // 失敗・拒否の理由
+}
+
+// LockEvent は maintenance_locked / maintenance_unlocked の内容（ロックの ID は含めない）
+type LockEvent struct {
+	Owner     string    `json:"owner"`
+	Reason    string    `json:"reason,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Expired   bool      `json:"expired,omitempty"` // 解除されずに期限が切れた
+}
+
+// Alert は alert の内容
+type Alert struct {
+	Level   string `json:"level"` // "error" または "info"（回復）
+	Message string `json:"message"`
+}
+
+// eventHistorySize は再接続時に再送できるよう保持する直近のイベント数
+const eventHistorySize = 256
+
+// EventBus は型付きのイベントを複数の購読者に配る
+// 配信はブロックしない（購読者のバッファがあふれた場合はそのイベントを破棄する）
+type EventBus struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription は1つの購読（C からイベントを受け取り、終了時に Close を呼ぶ）
+type Subscription struct {
+	C <-chan Event
+
+	ch  chan Event
+	bus *EventBus
+}
+
+// NewEventBus は新しい EventBus を作成
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish は ID と時刻を付けてイベントを配る
+func (b *EventBus) Publish(e Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e.ID = b.nextID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.history = append(b.history, e)
+	if len(b.history) > eventHistorySize {
+		b.history = b.history[len(b.history)-eventHistorySize:]
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.ch <- e:
+		default:
+			log.Warn().Str("type", string(e.Type)).Uint64("id", e.ID).Msg("Event subscriber is too slow, dropping event")
+		}
+	}
+	return e
+}
+
+// Recent は保持中のイベントのうち新しいものから最大 n 件を古い順に返す
+func (b *EventBus) Recent(n int) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := max(len(b.history)-n, 0)
+	return append([]Event(nil), b.history[start:]...)
+}
+
+// Subscribe は購読を開始する
+// lastID が 0 でなければ、それより後の保持中のイベントを backlog として返す（取りこぼしなく C に続く）
+func (b *EventBus) Subscribe(lastID uint64, buffer int) (*Subscription, []Event) {
+	ch := make(chan Event, buffer)
+	sub := &Subscription{C: ch, ch: ch, bus: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var backlog []Event
+	// 再起動前の ID は引き継がないため、未来の ID は無視する
+	if lastID != 0 && lastID <= b.nextID {
+		for _, e := range b.history {
+			if e.ID > lastID {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+
+	b.subscribers[sub] = struct{}{}
+	return sub, backlog
+}
+
+// Close は購読を終了する（C は閉じない）
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	delete(s.bus.subscribers, s)
+}
@@ -0,0 +1,158 @@
+package state
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrLockNotFound は指定IDの保守ロックが無い（解除済み・期限切れを含む）場合のエラー
+var ErrLockNotFound = errors.New("maintenance lock not found")
+
+// MaintenanceLock はサーバーの保守ロック
+// ロック中のサーバーは自動停止されず、ロックの ID を持たない start / stop / restart は拒否される
+// ロックはメモリ上にのみ保持する（エージェントを再起動すると解除される）
+type MaintenanceLock struct {
+	ID         string    `json:"id"`     // 解除・延長とロック中のコマンドに使う
+	Server     string    `json:"server"` // registered_containers のキー
+	Owner      string    `json:"owner"`  // 取得したユーザー・スクリプトの名前
+	Reason     string    `json:"reason,omitempty"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// LockConflictError は既にロックされているサーバーのロックを取得しようとした場合のエラー
+type LockConflictError struct {
+	Lock MaintenanceLock // 既存のロック
+}
+
+// Error はエラーメッセージを返す
+func (e *LockConflictError) Error() string {
+	return fmt.Sprintf("%s is already locked by %s until %s", e.Lock.Server, e.Lock.Owner, e.Lock.ExpiresAt.Format(time.RFC3339))
+}
+
+// AcquireLock はサーバーの保守ロックを取得する（期限切れのロックは取得時に置き換える）
+func (s *AppState) AcquireLock(server, owner, reason string, ttl time.Duration) (MaintenanceLock, error) {
+	s.mu.Lock()
+	now := time.Now()
+	if existing, ok := s.locks[server]; ok && now.Before(existing.ExpiresAt) {
+		s.mu.Unlock()
+		return MaintenanceLock{}, &LockConflictError{Lock: existing}
+	}
+
+	lock := MaintenanceLock{
+		ID:         newLockID(),
+		Server:     server,
+		Owner:      owner,
+		Reason:     reason,
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+	s.locks[server] = lock
+	s.mu.Unlock()
+
+	s.events.Publish(Event{Type: EventMaintenanceLocked, Server: server, Lock: lock.event(false)})
+	return lock, nil
+}
+
+// RenewLock は保守ロックの期限を現在から ttl 後に延長する
+func (s *AppState) RenewLock(id string, ttl time.Duration) (MaintenanceLock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for server, lock := range s.locks {
+		if lock.ID == id && time.Now().Before(lock.ExpiresAt) {
+			lock.ExpiresAt = time.Now().Add(ttl)
+			s.locks[server] = lock
+			return lock, nil
+		}
+	}
+	return MaintenanceLock{}, ErrLockNotFound
+}
+
+// ReleaseLock は保守ロックを解除する
+func (s *AppState) ReleaseLock(id string) (MaintenanceLock, error) {
+	s.mu.Lock()
+	var (
+		released MaintenanceLock
+		found    bool
+	)
+	for server, lock := range s.locks {
+		if lock.ID == id {
+			released, found = lock, true
+			delete(s.locks, server)
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if !found {
+		return MaintenanceLock{}, ErrLockNotFound
+	}
+	s.events.Publish(Event{Type: EventMaintenanceUnlocked, Server: released.Server, Lock: released.event(false)})
+	return released, nil
+}
+
+// Lock はサーバーの有効な保守ロックを返す
+func (s *AppState) Lock(server string) (MaintenanceLock, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	lock, ok := s.locks[server]
+	if !ok || !time.Now().Before(lock.ExpiresAt) {
+		return MaintenanceLock{}, false
+	}
+	return lock, true
+}
+
+// Locks は有効なすべての保守ロックをサーバーの順に返す
+func (s *AppState) Locks() []MaintenanceLock {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	locks := make([]MaintenanceLock, 0, len(s.locks))
+	for _, lock := range s.locks {
+		if now.Before(lock.ExpiresAt) {
+			locks = append(locks, lock)
+		}
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Server < locks[j].Server })
+	return locks
+}
+
+// PruneExpiredLocks は期限切れの保守ロックを削除し、削除したロックを返す（解除のイベントも配る）
+func (s *AppState) PruneExpiredLocks() []MaintenanceLock {
+	s.mu.Lock()
+	now := time.Now()
+	var expired []MaintenanceLock
+	for server, lock := range s.locks {
+		if !now.Before(lock.ExpiresAt) {
+			expired = append(expired, lock)
+			delete(s.locks, server)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, lock := range expired {
+		s.events.Publish(Event{Type: EventMaintenanceUnlocked, Server: lock.Server, Lock: lock.event(true)})
+	}
+	return expired
+}
+
+// event はイベントバスに配る内容を返す（ID はロック中のコマンドの許可に使うため配らない）
+func (l MaintenanceLock) event(expired bool) *LockEvent {
+	return &LockEvent{Owner: l.Owner, Reason: l.Reason, ExpiresAt: l.ExpiresAt, Expired: expired}
+}
+
+// newLockID は保守ロックのIDを生成（ロック中のコマンドの許可に使うため推測されにくい長さにする）
+func newLockID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
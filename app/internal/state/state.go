@@ -16,6 +16,8 @@ type AppState struct {
 	mu         sync.RWMutex
 	settings   *utilities.Settings
 	containers map[string]Container
+	locks      map[string]MaintenanceLock // サーバーごとの保守ロック
+	events     *EventBus
 }
 
 // NewAppState は新しい AppState を作成
@@ -23,9 +25,16 @@ func NewAppState(settings *utilities.Settings) *AppState {
 	return &AppState{
 		settings:   settings,
 		containers: make(map[string]Container),
+		locks:      make(map[string]MaintenanceLock),
+		events:     NewEventBus(),
 	}
 }
 
+// Events は状態変化・コマンド・アラートのイベントバスを返す
+func (s *AppState) Events() *EventBus {
+	return s.events
+}
+
 // GetSettings は設定を取得（読み取り専用）
 func (s *AppState) GetSettings() *utilities.Settings {
 	s.mu.RLock()
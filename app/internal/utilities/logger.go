@@ -13,23 +13,7 @@ import (
 // InitLogger はログ設定を初期化する
 func InitLogger(level string) {
 	// ログレベルの設定
-	var logLevel zerolog.Level
-	switch strings.ToUpper(level) {
-	case "DEBUG":
-		logLevel = zerolog.DebugLevel
-	case "INFO":
-		logLevel = zerolog.InfoLevel
-	case "WARN", "WARNING":
-		logLevel = zerolog.WarnLevel
-	case "ERROR":
-		logLevel = zerolog.ErrorLevel
-	case "FATAL":
-		logLevel = zerolog.FatalLevel
-	default:
-		logLevel = zerolog.InfoLevel
-	}
-
-	zerolog.SetGlobalLevel(logLevel)
+	SetLogLevel(level)
 
 	// 読みやすい形式（開発用）
 	// 本番では JSON 形式が推奨
@@ -43,6 +27,29 @@ func InitLogger(level string) {
 
 // InitLoggerJSON はJSON形式でログを初期化（本番推奨）
 func InitLoggerJSON(level string, output io.Writer) {
+	SetLogLevel(level)
+
+	if output == nil {
+		output = os.Stdout
+	}
+
+	log.Logger = zerolog.New(output).With().Timestamp().Caller().Logger()
+}
+
+// InitCLILogger はサブコマンド用にログを初期化する（警告以上のみ、コマンドの出力と混ざらないよう stderr に出す）
+func InitCLILogger() {
+	SetLogLevel("warn")
+
+	output := zerolog.ConsoleWriter{
+		Out:        os.Stderr,
+		TimeFormat: time.Kitchen,
+	}
+
+	log.Logger = zerolog.New(output).With().Timestamp().Logger()
+}
+
+// SetLogLevel はグローバルなログレベルを変更する（設定の再読み込み時にも使用）
+func SetLogLevel(level string) {
 	var logLevel zerolog.Level
 	switch strings.ToUpper(level) {
 	case "DEBUG":
@@ -60,12 +67,6 @@ func InitLoggerJSON(level string, output io.Writer) {
 	}
 
 	zerolog.SetGlobalLevel(logLevel)
-
-	if output == nil {
-		output = os.Stdout
-	}
-
-	log.Logger = zerolog.New(output).With().Timestamp().Caller().Logger()
 }
 
 // GetLogger はグローバルロガーを返す
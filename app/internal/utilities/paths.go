@@ -0,0 +1,32 @@
+package utilities
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ResolveSettingsPath は設定ファイルのパスを解決する
+// 引数が空の場合は SETTINGS_PATH 環境変数、それも無ければ "settings.json" を使う
+func ResolveSettingsPath(path string) string {
+	if path != "" {
+		return path
+	}
+	if env := os.Getenv("SETTINGS_PATH"); env != "" {
+		return env
+	}
+	return "settings.json"
+}
+
+// DataDir は永続データ（監査ログ等）の保存先ディレクトリを返す
+// DATA_DIR 環境変数が未設定の場合は設定ファイルと同じディレクトリ（Docker では /data）
+func DataDir() string {
+	if dir := os.Getenv("DATA_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Dir(ResolveSettingsPath(""))
+}
+
+// DataPath はデータディレクトリ配下のファイルパスを返す
+func DataPath(name string) string {
+	return filepath.Join(DataDir(), name)
+}
@@ -0,0 +1,320 @@
+package utilities
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// サーバーディレクトリ内のプレイヤーリストのファイル名
+const (
+	WhitelistFileName     = "whitelist.json"
+	OpsFileName           = "ops.json"
+	BannedPlayersFileName = "banned-players.json"
+	BannedIPsFileName     = "banned-ips.json"
+)
+
+// MinecraftTimeFormat は banned-*.json の created / expires の形式
+const MinecraftTimeFormat = "2006-01-02 15:04:05 -0700"
+
+// BanForever は期限なしの BAN を表す expires の値
+const BanForever = "forever"
+
+// PlayerListEntry はプレイヤーリストの1エントリ（Key で同一性を判定）
+type PlayerListEntry interface {
+	Key() string
+}
+
+// OpEntry は ops.json の1エントリ
+type OpEntry struct {
+	UUID                string `json:"uuid"`
+	Name                string `json:"name"`
+	Level               int    `json:"level"`
+	BypassesPlayerLimit bool   `json:"bypassesPlayerLimit"`
+}
+
+// Key は UUID を返す
+func (e OpEntry) Key() string { return e.UUID }
+
+// BanEntry は banned-players.json の1エントリ
+type BanEntry struct {
+	UUID    string `json:"uuid"`
+	Name    string `json:"name"`
+	Created string `json:"created"`
+	Source  string `json:"source"`
+	Expires string `json:"expires"`
+	Reason  string `json:"reason"`
+}
+
+// Key は UUID を返す
+func (e BanEntry) Key() string { return e.UUID }
+
+// IPBanEntry は banned-ips.json の1エントリ
+type IPBanEntry struct {
+	IP      string `json:"ip"`
+	Created string `json:"created"`
+	Source  string `json:"source"`
+	Expires string `json:"expires"`
+	Reason  string `json:"reason"`
+}
+
+// Key は IP アドレスを返す
+func (e IPBanEntry) Key() string { return e.IP }
+
+// NewBanTimes は BAN の created / expires を返す（duration が 0 なら期限なし）
+func NewBanTimes(now time.Time, duration time.Duration) (created, expires string) {
+	created = now.Format(MinecraftTimeFormat)
+	expires = BanForever
+	if duration > 0 {
+		expires = now.Add(duration).Format(MinecraftTimeFormat)
+	}
+	return created, expires
+}
+
+// LoadPlayerList はプレイヤーリストのファイルを読み込む（存在しなければ空）
+// 書き込みはその場で書き換えるため、ロックファイルを共有ロックして書きかけの内容を読まないようにする
+// ロックファイルを作れない場合（読み取り専用のマウント等）はロックなしで読む
+func LoadPlayerList[T any](path string) ([]T, error) {
+	if unlock, err := lockPlayerListFile(path, syscall.LOCK_SH); err == nil {
+		defer unlock()
+	}
+	return readPlayerList[T](path)
+}
+
+// readPlayerList はプレイヤーリストのファイルを読み込む（呼び出し側でロック済み）
+func readPlayerList[T any](path string) ([]T, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// ファイルが存在しない場合は空のリストを返す
+			return []T{}, nil
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	var entries []T
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+	if entries == nil {
+		entries = []T{}
+	}
+
+	return entries, nil
+}
+
+// SavePlayerList はプレイヤーリストのファイルを丸ごと保存
+// 既存の内容を元に変更する場合は UpdatePlayerList を使う
+func SavePlayerList[T any](path string, entries []T) error {
+	unlock, err := lockPlayerList(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return writePlayerList(path, entries)
+}
+
+// UpdatePlayerList はロックを保持したまま読み込み → update → 書き込みを1つの操作として行う
+// update が false を返した場合（変更なし）はファイルを書き換えない
+func UpdatePlayerList[T any](path string, update func(entries []T) ([]T, bool, error)) error {
+	unlock, err := lockPlayerList(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := readPlayerList[T](path)
+	if err != nil {
+		return err
+	}
+
+	updated, changed, err := update(entries)
+	if err != nil || !changed {
+		return err
+	}
+	return writePlayerList(path, updated)
+}
+
+// lockPlayerList はプレイヤーリストの隣のロックファイル（<path>.lock）を排他ロックする
+func lockPlayerList(path string) (func(), error) {
+	return lockPlayerListFile(path, syscall.LOCK_EX)
+}
+
+// lockPlayerListFile はロックファイルを how（LOCK_EX / LOCK_SH）でロックする
+// 本体は Minecraft サーバーも書き換えるため、本体ではなくロックファイルをロックする
+func lockPlayerListFile(path string, how int) (func(), error) {
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file for %s: %w", path, err)
+	}
+
+	if err := syscall.Flock(int(lock.Fd()), how); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	return func() {
+		syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+		lock.Close()
+	}, nil
+}
+
+// writePlayerList はプレイヤーリストをその場で書き換える（truncate → write → fsync、呼び出し側でロック済み）
+// whitelist.json はファイル単位でバインドマウントされることがあり、マウントポイントは rename で置き換えられないため
+// 既存のファイルを開き直して書くので、所有者とパーミッションはそのまま残る
+func writePlayerList[T any](path string, entries []T) error {
+	// JSON にマーシャル
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", path, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	// 書き込み
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	// fsync で確実にディスクに書き込み
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// UpsertPlayerList はエントリを追加する
+// 同じキーのエントリがあれば merge(既存) で更新する（merge が nil なら置き換え）
+// 戻り値は新規追加かどうか
+func UpsertPlayerList[T PlayerListEntry](path string, entry T, merge func(existing T) T) (bool, error) {
+	added := false
+	err := UpdatePlayerList(path, func(entries []T) ([]T, bool, error) {
+		for idx, existing := range entries {
+			if strings.EqualFold(existing.Key(), entry.Key()) {
+				if merge != nil {
+					entries[idx] = merge(existing)
+				} else {
+					entries[idx] = entry
+				}
+				return entries, true, nil
+			}
+		}
+
+		added = true
+		return append(entries, entry), true, nil
+	})
+	return added, err
+}
+
+// RemoveFromPlayerList はキーに一致するエントリを削除する
+// 戻り値は削除したかどうか（該当なしの場合はファイルを書き換えない）
+func RemoveFromPlayerList[T PlayerListEntry](path, key string) (bool, error) {
+	removed := false
+	err := UpdatePlayerList(path, func(entries []T) ([]T, bool, error) {
+		newEntries := make([]T, 0, len(entries))
+		for _, entry := range entries {
+			if strings.EqualFold(entry.Key(), key) {
+				removed = true
+				continue
+			}
+			newEntries = append(newEntries, entry)
+		}
+		return newEntries, removed, nil
+	})
+	return removed, err
+}
+
+// AddOp はプレイヤーを ops.json に追加（既に OP なら権限レベルを更新）
+func AddOp(path, uuid, name string, level int, bypassesPlayerLimit bool) (bool, error) {
+	entry := OpEntry{
+		UUID:                FormatUUID(uuid),
+		Name:                name,
+		Level:               level,
+		BypassesPlayerLimit: bypassesPlayerLimit,
+	}
+	return UpsertPlayerList(path, entry, nil)
+}
+
+// RemoveOp はプレイヤーを ops.json から削除
+func RemoveOp(path, uuid string) (bool, error) {
+	return RemoveFromPlayerList[OpEntry](path, FormatUUID(uuid))
+}
+
+// AddBan はプレイヤーを banned-players.json に追加（既に BAN 済みなら内容を更新）
+func AddBan(path, uuid, name, source, reason string, duration time.Duration) (bool, error) {
+	created, expires := NewBanTimes(time.Now(), duration)
+	entry := BanEntry{
+		UUID:    FormatUUID(uuid),
+		Name:    name,
+		Created: created,
+		Source:  source,
+		Expires: expires,
+		Reason:  reason,
+	}
+	return UpsertPlayerList(path, entry, nil)
+}
+
+// RemoveBan はプレイヤーを banned-players.json から削除
+func RemoveBan(path, uuid string) (bool, error) {
+	return RemoveFromPlayerList[BanEntry](path, FormatUUID(uuid))
+}
+
+// AddIPBan は IP アドレスを banned-ips.json に追加（既に BAN 済みなら内容を更新）
+func AddIPBan(path, ip, source, reason string, duration time.Duration) (bool, error) {
+	created, expires := NewBanTimes(time.Now(), duration)
+	entry := IPBanEntry{
+		IP:      ip,
+		Created: created,
+		Source:  source,
+		Expires: expires,
+		Reason:  reason,
+	}
+	return UpsertPlayerList(path, entry, nil)
+}
+
+// RemoveIPBan は IP アドレスを banned-ips.json から削除
+func RemoveIPBan(path, ip string) (bool, error) {
+	return RemoveFromPlayerList[IPBanEntry](path, ip)
+}
+
+// ParseListDuration は BAN や一時的なホワイトリストの期間を解釈する（time.ParseDuration の形式に加えて d / w を受け付ける）
+func ParseListDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if n, ok := strings.CutSuffix(value, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count <= 0 {
+				return 0, fmt.Errorf("Invalid duration: %s", value)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("Invalid duration: %s", value)
+	}
+	return duration, nil
+}
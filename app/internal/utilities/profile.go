@@ -0,0 +1,285 @@
+package utilities
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrPlayerNotFound はプレイヤーが存在しない場合のエラー
+var ErrPlayerNotFound = errors.New("player not found")
+
+// Mojang API のデフォルトの接続先
+const (
+	DefaultMojangAPIBaseURL     = "https://api.mojang.com"
+	DefaultMojangSessionBaseURL = "https://sessionserver.mojang.com"
+)
+
+// profileMaxAttempts はレート制限（429）時の最大試行回数
+const profileMaxAttempts = 4
+
+// MojangProfile は Mojang API のレスポンス
+type MojangProfile struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// MojangConfig は Mojang API の設定
+type MojangConfig struct {
+	APIBaseURL     string `json:"api_base_url"`     // 名前 → UUID（/users/profiles/minecraft/<name>）
+	SessionBaseURL string `json:"session_base_url"` // UUID → 現在の名前（/session/minecraft/profile/<uuid>）
+	CacheTTL       int    `json:"cache_ttl"`        // 秒（0 の場合はキャッシュしない）
+}
+
+// cachedProfile はキャッシュされたプロフィール
+type cachedProfile struct {
+	UUID      string    `json:"uuid"` // ハイフン付き
+	Name      string    `json:"name"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// ProfileResolver はプレイヤー名と UUID を相互に解決する
+// 結果はディスクにキャッシュし、429 の場合は Retry-After に従って再試行する
+type ProfileResolver struct {
+	mu       sync.Mutex
+	path     string
+	config   func() MojangConfig // 再読み込みに追従するため毎回取得
+	client   *http.Client
+	profiles []cachedProfile
+	loaded   bool
+}
+
+// NewProfileResolver は新しい ProfileResolver を作成（path が空の場合はディスクに保存しない）
+func NewProfileResolver(path string, config func() MojangConfig) *ProfileResolver {
+	return &ProfileResolver{
+		path:   path,
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Lookup はプレイヤー名から UUID を取得
+// キャッシュが有効期限内ならそれを返し、API が失敗した場合は期限切れのキャッシュで代用する
+func (r *ProfileResolver) Lookup(name string) (*MojangProfile, error) {
+	config := r.resolvedConfig()
+
+	cached, fresh := r.cachedByName(name, config.CacheTTL)
+	if fresh {
+		return cached, nil
+	}
+
+	var profile MojangProfile
+	url := fmt.Sprintf("%s/users/profiles/minecraft/%s", config.APIBaseURL, name)
+	if err := r.get(url, &profile); err != nil {
+		if cached != nil && !errors.Is(err, ErrPlayerNotFound) {
+			log.Warn().Err(err).Str("player", name).Msg("Mojang API failed, using cached profile")
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	r.store(profile)
+	return &profile, nil
+}
+
+// LookupUUID は UUID から現在のプレイヤー名を取得（名前の変更を反映するため）
+func (r *ProfileResolver) LookupUUID(uuid string) (*MojangProfile, error) {
+	config := r.resolvedConfig()
+
+	cached, fresh := r.cachedByUUID(uuid, config.CacheTTL)
+	if fresh {
+		return cached, nil
+	}
+
+	var profile MojangProfile
+	url := fmt.Sprintf("%s/session/minecraft/profile/%s", config.SessionBaseURL, strings.ReplaceAll(uuid, "-", ""))
+	if err := r.get(url, &profile); err != nil {
+		if cached != nil && !errors.Is(err, ErrPlayerNotFound) {
+			log.Warn().Err(err).Str("uuid", uuid).Msg("Mojang API failed, using cached profile")
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	r.store(profile)
+	return &profile, nil
+}
+
+// resolvedConfig は空の項目をデフォルト値で埋めた設定を返す
+func (r *ProfileResolver) resolvedConfig() MojangConfig {
+	var config MojangConfig
+	if r.config != nil {
+		config = r.config()
+	}
+	if config.APIBaseURL == "" {
+		config.APIBaseURL = DefaultMojangAPIBaseURL
+	}
+	if config.SessionBaseURL == "" {
+		config.SessionBaseURL = DefaultMojangSessionBaseURL
+	}
+	config.APIBaseURL = strings.TrimSuffix(config.APIBaseURL, "/")
+	config.SessionBaseURL = strings.TrimSuffix(config.SessionBaseURL, "/")
+	return config
+}
+
+// get は API を呼び出して v にデコードする（429 の場合は待ってから再試行）
+func (r *ProfileResolver) get(url string, v any) error {
+	backoff := time.Second
+
+	for attempt := 1; ; attempt++ {
+		resp, err := r.client.Get(url)
+		if err != nil {
+			return fmt.Errorf("failed to fetch profile: %w", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			if attempt >= profileMaxAttempts {
+				return fmt.Errorf("mojang API rate limited")
+			}
+			wait := backoff
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+				wait = time.Duration(seconds) * time.Second
+			}
+			log.Warn().Dur("retry_after", wait).Int("attempt", attempt).Msg("Mojang API rate limited")
+			time.Sleep(wait)
+			backoff *= 2
+			continue
+		case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusNoContent:
+			return ErrPlayerNotFound
+		case resp.StatusCode != http.StatusOK:
+			return fmt.Errorf("mojang API returned status %d", resp.StatusCode)
+		}
+
+		if err := json.Unmarshal(body, v); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+		return nil
+	}
+}
+
+// loadLocked は必要ならキャッシュファイルを読み込む（呼び出し側でロック済み）
+func (r *ProfileResolver) loadLocked() {
+	if r.loaded {
+		return
+	}
+	r.loaded = true
+
+	if r.path == "" {
+		return
+	}
+	if _, err := LoadJSONFile(r.path, &r.profiles); err != nil {
+		log.Warn().Err(err).Msg("Failed to load profile cache")
+	}
+}
+
+// cachedByName はキャッシュを名前（大文字小文字を無視）で探す
+// 戻り値の bool は有効期限内かどうか
+func (r *ProfileResolver) cachedByName(name string, ttl int) (*MojangProfile, bool) {
+	return r.cached(func(c cachedProfile) bool { return strings.EqualFold(c.Name, name) }, ttl)
+}
+
+// cachedByUUID はキャッシュを UUID で探す
+func (r *ProfileResolver) cachedByUUID(uuid string, ttl int) (*MojangProfile, bool) {
+	uuid = FormatUUID(strings.ToLower(uuid))
+	return r.cached(func(c cachedProfile) bool { return c.UUID == uuid }, ttl)
+}
+
+// cached は条件に一致するキャッシュを返す
+func (r *ProfileResolver) cached(match func(cachedProfile) bool, ttl int) (*MojangProfile, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loadLocked()
+
+	if ttl <= 0 {
+		return nil, false
+	}
+
+	for _, c := range r.profiles {
+		if match(c) {
+			profile := &MojangProfile{ID: strings.ReplaceAll(c.UUID, "-", ""), Name: c.Name}
+			return profile, time.Since(c.FetchedAt) < time.Duration(ttl)*time.Second
+		}
+	}
+	return nil, false
+}
+
+// store は取得したプロフィールをキャッシュに保存
+// 同じ UUID（名前の変更）や同じ名前（別のプレイヤーが取得した名前）の古いエントリは置き換える
+func (r *ProfileResolver) store(profile MojangProfile) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loadLocked()
+
+	entry := cachedProfile{
+		UUID:      FormatUUID(strings.ToLower(profile.ID)),
+		Name:      profile.Name,
+		FetchedAt: time.Now(),
+	}
+
+	kept := make([]cachedProfile, 0, len(r.profiles)+1)
+	for _, c := range r.profiles {
+		if c.UUID == entry.UUID || strings.EqualFold(c.Name, entry.Name) {
+			continue
+		}
+		kept = append(kept, c)
+	}
+	r.profiles = append(kept, entry)
+
+	if r.path == "" {
+		return
+	}
+	if err := SaveJSONFile(r.path, r.profiles); err != nil {
+		log.Warn().Err(err).Msg("Failed to save profile cache")
+	}
+}
+
+// OfflineProfile はオフラインモード（online-mode=false）のサーバーで使われるプロフィールを返す
+// UUID は "OfflinePlayer:<name>" の MD5 から作る version 3 の UUID（名前の大文字小文字を区別）
+func OfflineProfile(name string) *MojangProfile {
+	sum := md5.Sum([]byte("OfflinePlayer:" + name))
+	sum[6] = sum[6]&0x0f | 0x30 // version 3
+	sum[8] = sum[8]&0x3f | 0x80 // RFC 4122 variant
+	return &MojangProfile{ID: hex.EncodeToString(sum[:]), Name: name}
+}
+
+// ServerOnlineMode は server.properties の online-mode を返す（ファイルやキーが無い場合は true）
+func ServerOnlineMode(serverPath string) bool {
+	file, err := os.Open(filepath.Join(serverPath, "server.properties"))
+	if err != nil {
+		return true
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "online-mode" {
+			return !strings.EqualFold(strings.TrimSpace(value), "false")
+		}
+	}
+	return true
+}
@@ -1,21 +1,42 @@
 package utilities
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"syscall"
 )
 
 // Settings はアプリケーションの設定を保持する構造体
 type Settings struct {
+	Schema               string                     `json:"$schema,omitempty"` // エディタ補完用の JSON Schema の参照
+	Version              int                        `json:"version"`
 	LogLevel             string                     `json:"log_level"`
 	RegularTask          RegularTaskConfig          `json:"regular_task"`
 	RegisteredContainers map[string]ContainerConfig `json:"registered_containers"`
 	MessageDeleteAfter   int                        `json:"message_deleteafter"`
 	AllowedActions       AllowedActions             `json:"allowed_actions"`
 	Icons                map[string]string          `json:"icons"`
+	Audit                AuditConfig                `json:"audit"`
+	WhitelistApproval    WhitelistApprovalConfig    `json:"whitelist_approval"`
+	AccountLinks         AccountLinkConfig          `json:"account_links"`
+	Mojang               MojangConfig               `json:"mojang"`
+	API                  APIConfig                  `json:"api"`
+	Monitoring           MonitoringConfig           `json:"monitoring"`
+	Dashboard            DashboardConfig            `json:"dashboard"`
+	Control              ControlConfig              `json:"control"`
+	Webhooks             map[string]WebhookConfig   `json:"webhooks"` // キーは Webhook の名前（ログ・失敗の記録に使う）
+	Discord              DiscordConfig              `json:"discord"`
+	Slack                SlackConfig                `json:"slack"`
+	Matrix               MatrixConfig               `json:"matrix"`
+	Telegram             TelegramConfig             `json:"telegram"`
+	WhitelistPath        string                     `json:"whitelist_path"` // 非推奨: 全サーバー共通のホワイトリスト（WHITELIST_PATH）
 }
 
 // RegularTaskConfig は定期タスクの設定
@@ -31,6 +52,7 @@ type ContainerConfig struct {
 	Path          string `json:"path"`
 	Icon          string `json:"icon"`
 	AutoShutdown  bool   `json:"auto_shutdown"`
+	WhitelistPath string `json:"whitelist_path"` // 空の場合は <path>/whitelist.json
 }
 
 // AllowedActions は許可するアクション
@@ -42,15 +64,256 @@ type AllowedActions struct {
 	PlaceButtons bool `json:"place_buttons"`
 }
 
-// LoadSettings は設定ファイルを読み込む
-func LoadSettings(path string) (*Settings, error) {
-	if path == "" {
-		path = os.Getenv("SETTINGS_PATH")
-		if path == "" {
-			path = "settings.json"
+// AuditConfig は監査ログの設定
+type AuditConfig struct {
+	Path      string `json:"path"`       // 空の場合はデータディレクトリの audit.jsonl
+	ChannelID string `json:"channel_id"` // 空の場合はチャンネル投稿しない
+}
+
+// WhitelistApprovalConfig はホワイトリスト申請（承認制）の設定
+type WhitelistApprovalConfig struct {
+	Enabled     bool   `json:"enabled"`      // true の場合、管理者以外の /whitelist add は申請になる
+	ChannelID   string `json:"channel_id"`   // 申請を投稿するモデレーター用チャンネル
+	ExpireAfter int    `json:"expire_after"` // 秒（0 の場合は期限なし）
+}
+
+// AccountLinkConfig は Discord アカウントと Minecraft アカウントの紐付けの設定
+type AccountLinkConfig struct {
+	MaxPerUser     int    `json:"max_per_user"`     // 1ユーザーが紐付けられるプレイヤー数（0 の場合は無制限、管理者は対象外）
+	RequiredRoleID string `json:"required_role_id"` // このロールを失ったユーザーのプレイヤーをホワイトリストから外す（空の場合は無効）
+	RemoveOnLeave  bool   `json:"remove_on_leave"`  // サーバーを抜けたユーザーのプレイヤーをホワイトリストから外す
+}
+
+// WatchesMembers はメンバーの脱退・ロール変更を監視する必要があるか判定
+func (c AccountLinkConfig) WatchesMembers() bool {
+	return c.RemoveOnLeave || c.RequiredRoleID != ""
+}
+
+// WhitelistFile はサーバーのホワイトリストファイルのパスを返す（解決できなければ空）
+// サーバー個別の whitelist_path → 全体の whitelist_path（従来の共通ファイル） → <path>/whitelist.json の順
+func (s *Settings) WhitelistFile(key string) string {
+	config, ok := s.RegisteredContainers[key]
+	if !ok {
+		return ""
+	}
+	if config.WhitelistPath != "" {
+		return config.WhitelistPath
+	}
+	if s.WhitelistPath != "" {
+		return s.WhitelistPath
+	}
+	return s.ServerFile(key, WhitelistFileName)
+}
+
+// OfflineMode はサーバーが online-mode=false（オフラインモード）か判定（server.properties を参照）
+func (s *Settings) OfflineMode(key string) bool {
+	config, ok := s.RegisteredContainers[key]
+	if !ok || config.Path == "" {
+		return false
+	}
+	return !ServerOnlineMode(config.Path)
+}
+
+// ServerFile はサーバーディレクトリ内のファイルのパスを返す（path が未設定なら空）
+func (s *Settings) ServerFile(key, name string) string {
+	config, ok := s.RegisteredContainers[key]
+	if !ok || config.Path == "" {
+		return ""
+	}
+	return filepath.Join(config.Path, name)
+}
+
+// APIConfig は HTTP API の設定
+type APIConfig struct {
+	Enabled bool                `json:"enabled"`
+	Listen  string              `json:"listen"` // 待ち受けアドレス（例: ":8080"）
+	Tokens  map[string]APIToken `json:"tokens"` // キーはトークンの名前（監査ログに記録される）
+}
+
+// API トークンに付与できる権限
+const (
+	APIScopeRead      = "read"      // サーバーの状態・ホワイトリストの参照、イベントの購読
+	APIScopeControl   = "control"   // 起動・停止・再起動（allowed_actions とプレイヤー在籍のチェックは Discord と同じ）
+	APIScopeWhitelist = "whitelist" // ホワイトリストの追加・削除（Discord の管理者相当）
+)
+
+// APIScopes は API トークンに付与できる権限の一覧
+var APIScopes = []string{APIScopeRead, APIScopeControl, APIScopeWhitelist}
+
+// APIToken は HTTP API のトークン
+// 値は MC_AGENT_API__TOKENS__<名前>__TOKEN_FILE などで secret ファイルから指定できる
+type APIToken struct {
+	Token  string   `json:"token" secret:"true"`
+	Scopes []string `json:"scopes"`
+}
+
+// HasScope はトークンに権限が付与されているか判定
+func (t APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
 		}
 	}
+	return false
+}
+
+// MonitoringConfig は監視用エンドポイント（Prometheus の /metrics、/healthz・/readyz）の設定
+// 認証なしで公開されるため、必要に応じて listen をホスト内やプライベートネットワークに限定する
+type MonitoringConfig struct {
+	Enabled bool   `json:"enabled"` // /metrics を公開する
+	Health  bool   `json:"health"`  // /healthz・/readyz を公開する（mc-agent healthcheck が使う）
+	Listen  string `json:"listen"`  // 待ち受けアドレス（例: ":9464"）
+}
+
+// Serving は監視用の HTTP サーバーを起動するか返す
+func (c MonitoringConfig) Serving() bool {
+	return c.Enabled || c.Health
+}
+
+// Webhook で送るイベント
+const (
+	WebhookEventServerStarted = "server.started" // サーバーが稼働中になった
+	WebhookEventServerStopped = "server.stopped" // サーバーが停止した（停止コマンド・自動停止・ゲーム内の /stop など）
+	WebhookEventServerCrashed = "server.crashed" // 停止コマンドなしに 0 以外の終了コード（またはメモリ不足）で停止した
+	WebhookEventPlayerJoined  = "player.joined"  // プレイヤーが参加した
+	WebhookEventPlayerLeft    = "player.left"    // プレイヤーが退出した
+)
+
+// WebhookEvents は Webhook で送れるイベントの一覧
+var WebhookEvents = []string{
+	WebhookEventServerStarted,
+	WebhookEventServerStopped,
+	WebhookEventServerCrashed,
+	WebhookEventPlayerJoined,
+	WebhookEventPlayerLeft,
+}
+
+// WebhookConfig はイベントを JSON で POST する送信先
+// secret・token は MC_AGENT_WEBHOOKS__<名前>__SECRET_FILE などで secret ファイルから指定できる
+type WebhookConfig struct {
+	URL     string   `json:"url"`
+	Secret  string   `json:"secret" secret:"true"` // HMAC-SHA256 の署名の鍵（空の場合は署名しない）
+	Token   string   `json:"token" secret:"true"`  // Authorization: Bearer で送るトークン（ntfy のアクセストークン等、空の場合は送らない）
+	Events  []string `json:"events"`               // 送るイベント（空の場合はすべて）
+	Servers []string `json:"servers"`              // 対象のサーバー（registered_containers のキー、空の場合はすべて）
+}
+
+// Wants はイベントを送る対象か判定する
+func (c WebhookConfig) Wants(event, server string) bool {
+	return (len(c.Events) == 0 || slices.Contains(c.Events, event)) &&
+		(len(c.Servers) == 0 || slices.Contains(c.Servers, server))
+}
+
+// DashboardConfig は Web ダッシュボードの設定
+// ログインには Discord の OAuth2 を使う（Client ID は discord.app_id と同じ）
+type DashboardConfig struct {
+	Enabled      bool   `json:"enabled"`
+	Listen       string `json:"listen"`                      // 待ち受けアドレス（例: ":8090"）
+	PublicURL    string `json:"public_url"`                  // ブラウザから開く URL（OAuth2 のリダイレクト先は public_url + /auth/callback）
+	ClientSecret string `json:"client_secret" secret:"true"` // Discord アプリケーションの OAuth2 Client Secret
+	Public       bool   `json:"public"`                      // ログインしていなくてもサーバーの状態とイベントを表示する
+}
+
+// ControlConfig はローカルの制御用ソケット（mc-agent status 等のサブコマンド・ホスト側のスクリプトが使う）の設定
+// ソケットのファイルの権限で接続できるユーザーを制限する（トークンによる認証はしない）
+type ControlConfig struct {
+	Enabled     bool   `json:"enabled"`
+	SocketPath  string `json:"socket_path"`  // 空の場合はデータディレクトリの control.sock
+	SocketMode  string `json:"socket_mode"`  // ソケットのファイルの権限（8 進数、例: "0660"）。その他のユーザーには許可できない
+	SocketGroup string `json:"socket_group"` // ソケットのファイルのグループ（空の場合はエージェントのグループのまま）
+}
+
+// DiscordConfig は Discord Bot の接続情報
+// 通常は環境変数（DISCORD_BOT_TOKEN 等）や secret ファイルで指定する
+type DiscordConfig struct {
+	Token   string `json:"token" secret:"true"`
+	GuildID string `json:"guild_id"`
+	AppID   string `json:"app_id"`
+}
+
+// Slack・Telegram の API のデフォルトの接続先
+const (
+	DefaultSlackAPIBaseURL    = "https://slack.com/api"
+	DefaultTelegramAPIBaseURL = "https://api.telegram.org"
+)
+
+// SlackConfig は Slack の Bot の設定（Socket Mode で接続するため公開 URL は不要）
+type SlackConfig struct {
+	Enabled      bool     `json:"enabled"`
+	BotToken     string   `json:"bot_token" secret:"true"` // xoxb- で始まる Bot User OAuth Token
+	AppToken     string   `json:"app_token" secret:"true"` // xapp- で始まる App-Level Token（connections:write）
+	ChannelID    string   `json:"channel_id"`              // 状態の表示・監査ログ・アラートを投稿し、コマンドを受け付けるチャンネル
+	AllowedUsers []string `json:"allowed_users"`           // 起動・停止できるユーザー ID（空の場合はチャンネルの全員）
+	APIBaseURL   string   `json:"api_base_url"`
+}
+
+// MatrixConfig は Matrix の Bot の設定
+type MatrixConfig struct {
+	Enabled       bool     `json:"enabled"`
+	HomeserverURL string   `json:"homeserver_url"`
+	AccessToken   string   `json:"access_token" secret:"true"`
+	RoomID        string   `json:"room_id"`       // 状態の表示・監査ログ・アラートを投稿し、コマンドを受け付けるルーム（!xxx:example.com）
+	AllowedUsers  []string `json:"allowed_users"` // 起動・停止できるユーザー（@user:example.com、空の場合はルームの全員）
+}
 
+// TelegramConfig は Telegram の Bot の設定
+type TelegramConfig struct {
+	Enabled      bool     `json:"enabled"`
+	Token        string   `json:"token" secret:"true"` // BotFather が発行するトークン
+	ChatID       string   `json:"chat_id"`             // 状態の表示・監査ログ・アラートを投稿し、コマンドを受け付けるチャット（グループは負の数）
+	AllowedUsers []string `json:"allowed_users"`       // 起動・停止できるユーザー ID（空の場合はチャットの全員）
+	APIBaseURL   string   `json:"api_base_url"`
+}
+
+// AuditLogPath は監査ログファイルのパスを返す
+func (s *Settings) AuditLogPath() string {
+	if s.Audit.Path != "" {
+		return s.Audit.Path
+	}
+	return DataPath("audit.jsonl")
+}
+
+// ControlSocketPath は制御用ソケットのパスを返す
+func (s *Settings) ControlSocketPath() string {
+	if s.Control.SocketPath != "" {
+		return s.Control.SocketPath
+	}
+	return DataPath("control.sock")
+}
+
+// ControlSocketMode は制御用ソケットのファイルの権限を返す（Validate 済みの前提、解釈できない場合は 0600）
+func (s *Settings) ControlSocketMode() os.FileMode {
+	mode, err := strconv.ParseUint(s.Control.SocketMode, 8, 32)
+	if err != nil {
+		return 0600
+	}
+	return os.FileMode(mode)
+}
+
+// LoadSettings は設定ファイルを読み込む（デフォルト値 + 設定ファイル）
+// 環境変数による上書きは含まない（設定ファイルを書き換える処理はこちらを使う）
+// 必須の値を環境変数で指定している場合があるため、値の検証は ValidateEffectiveSettings で行う
+func LoadSettings(path string) (*Settings, error) {
+	data, err := readSettingsFile(ResolveSettingsPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	// 移行・未知キーと型の誤りの検出
+	file, err := parseSettingsTree(data)
+	if err != nil {
+		return nil, wrapSettingsError(err)
+	}
+	settings, err := decodeSettings(mergeSettingsTree(defaultSettingsTree(), file), nil, false)
+	if err != nil {
+		return nil, wrapSettingsError(err)
+	}
+
+	return settings, nil
+}
+
+// readSettingsFile は共有ロックを取って設定ファイルを読み込む
+func readSettingsFile(path string) ([]byte, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open settings file: %w", err)
@@ -63,100 +326,83 @@ func LoadSettings(path string) (*Settings, error) {
 	}
 	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
 
-	var settings Settings
-	if err := json.NewDecoder(file).Decode(&settings); err != nil {
-		return nil, fmt.Errorf("failed to parse settings JSON: %w", err)
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settings file: %w", err)
 	}
+	return data, nil
+}
 
-	// バリデーション
-	if err := settings.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid settings: %w", err)
+// wrapSettingsError は検証エラーに接頭辞を付ける（errors.As で ValidationErrors を取り出せる）
+func wrapSettingsError(err error) error {
+	var validationErrs ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return fmt.Errorf("invalid settings: %w", err)
 	}
-
-	return &settings, nil
+	return err
 }
 
-// SaveSettings は設定を atomic に書き込む
+// SaveSettings は設定を設定ファイルに書き込む
+// settings は設定ファイルの層（環境変数の値は書き込まない）で、検証は環境変数と合成して行う
+// 設定ファイルに無くデフォルト値のままの項目は書き出さない（保存のたびにデフォルト値が増えないようにする）
+// ファイル単位のバインドマウントは rename で置き換えられないため、排他ロックを取ってその場で書き換える（パーミッションと所有者はそのまま）
 func SaveSettings(path string, settings *Settings) error {
-	if path == "" {
-		path = os.Getenv("SETTINGS_PATH")
-		if path == "" {
-			path = "settings.json"
-		}
-	}
+	path = ResolveSettingsPath(path)
 
 	// バリデーション
-	if err := settings.Validate(); err != nil {
+	if err := ValidateEffectiveSettings(settings); err != nil {
 		return fmt.Errorf("invalid settings: %w", err)
 	}
 
-	// JSON にマーシャル
-	data, err := json.MarshalIndent(settings, "", "  ")
+	tree, err := settingsTree(settings)
 	if err != nil {
-		return fmt.Errorf("failed to marshal settings: %w", err)
+		return err
 	}
-
-	// atomic write: 一時ファイル → rename
-	dir := filepath.Dir(path)
-	tmpFile, err := os.CreateTemp(dir, "settings-*.tmp")
+	defaults, err := defaultSettingsValues()
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return err
 	}
-	tmpPath := tmpFile.Name()
-	defer os.Remove(tmpPath) // 失敗時のクリーンアップ
 
-	// flock で排他ロック
-	if err := syscall.Flock(int(tmpFile.Fd()), syscall.LOCK_EX); err != nil {
-		tmpFile.Close()
-		return fmt.Errorf("failed to lock temp file: %w", err)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open settings file: %w", err)
 	}
+	defer file.Close()
 
-	// 書き込み
-	if _, err := tmpFile.Write(data); err != nil {
-		syscall.Flock(int(tmpFile.Fd()), syscall.LOCK_UN)
-		tmpFile.Close()
-		return fmt.Errorf("failed to write temp file: %w", err)
+	// flock で排他ロック（読み込みは readSettingsFile の共有ロックで待つ）
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock settings file: %w", err)
 	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
 
-	// fsync で確実にディスクに書き込み
-	if err := tmpFile.Sync(); err != nil {
-		syscall.Flock(int(tmpFile.Fd()), syscall.LOCK_UN)
-		tmpFile.Close()
-		return fmt.Errorf("failed to sync temp file: %w", err)
+	// 現在のファイルにある項目は値がデフォルトと同じでも残す
+	current, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("failed to read settings file: %w", err)
 	}
-
-	// ロック解除とファイルクローズ
-	syscall.Flock(int(tmpFile.Fd()), syscall.LOCK_UN)
-	if err := tmpFile.Close(); err != nil {
-		return fmt.Errorf("failed to close temp file: %w", err)
+	var fileTree map[string]any
+	if len(bytes.TrimSpace(current)) > 0 {
+		// 読めない場合はデフォルト値以外を書き出す
+		fileTree, _ = parseSettingsTree(current)
 	}
 
-	// atomic rename
-	if err := os.Rename(tmpPath, path); err != nil {
-		return fmt.Errorf("failed to rename temp file: %w", err)
+	// JSON にマーシャル
+	data, err := json.MarshalIndent(fileLayerTree(tree, defaults, fileTree), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal settings: %w", err)
 	}
 
-	return nil
-}
-
-// Validate は設定の妥当性をチェック
-func (s *Settings) Validate() error {
-	if s.RegularTask.Interval <= 0 {
-		return fmt.Errorf("regular_task.interval must be > 0, got %d", s.RegularTask.Interval)
+	if err := file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate settings file: %w", err)
 	}
-	if s.RegularTask.AutoShutdownDelay < 0 {
-		return fmt.Errorf("regular_task.auto_shutdown_delay must be >= 0, got %d", s.RegularTask.AutoShutdownDelay)
+	if _, err := file.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("failed to write settings file: %w", err)
 	}
-	if len(s.RegisteredContainers) == 0 {
-		return fmt.Errorf("registered_containers must not be empty")
-	}
-	for key, c := range s.RegisteredContainers {
-		if c.ContainerName == "" {
-			return fmt.Errorf("container %s: container_name is required", key)
-		}
-		if c.DisplayName == "" {
-			return fmt.Errorf("container %s: display_name is required", key)
-		}
+
+	// fsync で確実にディスクに書き込み
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync settings file: %w", err)
 	}
+
 	return nil
 }
@@ -0,0 +1,92 @@
+package utilities
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DiffSettings は2つの設定の差分を "key.path: old → new" 形式で返す
+func DiffSettings(oldSettings, newSettings *Settings) []string {
+	oldValues := flattenSettings(oldSettings)
+	newValues := flattenSettings(newSettings)
+
+	keys := make([]string, 0, len(oldValues)+len(newValues))
+	for k := range oldValues {
+		keys = append(keys, k)
+	}
+	for k := range newValues {
+		if _, ok := oldValues[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	diff := make([]string, 0)
+	for _, k := range keys {
+		oldValue, inOld := oldValues[k]
+		newValue, inNew := newValues[k]
+		if IsSecretSettingKey(k) {
+			// 秘密情報は変更の有無だけを示す
+			if inOld && inNew && oldValue != newValue {
+				diff = append(diff, fmt.Sprintf("~ %s: (changed)", k))
+				continue
+			}
+			oldValue, newValue = maskedValue, maskedValue
+		}
+		switch {
+		case !inOld:
+			diff = append(diff, fmt.Sprintf("+ %s: %s", k, newValue))
+		case !inNew:
+			diff = append(diff, fmt.Sprintf("- %s: %s", k, oldValue))
+		case oldValue != newValue:
+			diff = append(diff, fmt.Sprintf("~ %s: %s → %s", k, oldValue, newValue))
+		}
+	}
+	return diff
+}
+
+// maskedValue は秘密情報の代わりに表示する値
+const maskedValue = `"***"`
+
+// flattenSettings は設定を JSON のキーパス → 値（JSON 表記）のマップに変換する
+func flattenSettings(settings *Settings) map[string]string {
+	result := make(map[string]string)
+	if settings == nil {
+		return result
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return result
+	}
+
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return result
+	}
+
+	flattenValue("", tree, result)
+	return result
+}
+
+// flattenValue はオブジェクトを再帰的に展開する（配列は1つの値として扱う）
+func flattenValue(prefix string, value any, result map[string]string) {
+	if obj, ok := value.(map[string]any); ok && len(obj) > 0 {
+		for k, v := range obj {
+			flattenValue(strings.TrimPrefix(prefix+"."+k, "."), v, result)
+		}
+		return
+	}
+
+	// カスタム絵文字（<:name:id>）がそのまま読めるよう HTML エスケープしない
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return
+	}
+	result[prefix] = strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,490 @@
+package utilities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// errUnknownSettingKey は設定に無いキーパスを指定した場合のエラー
+var errUnknownSettingKey = errors.New("unknown key")
+
+// EnvPrefix は任意の設定キーを上書きする環境変数の接頭辞
+// キーパスの "." は "__" で表す（例: MC_AGENT_REGULAR_TASK__INTERVAL=10）
+const EnvPrefix = "MC_AGENT_"
+
+// secretFileSuffix はファイルから値を読み込む環境変数の接尾辞（Docker / Kubernetes の secret 用）
+const secretFileSuffix = "_FILE"
+
+// legacyEnvAliases は以前から使われている環境変数とキーパスの対応
+var legacyEnvAliases = []struct {
+	Env  string
+	Path string
+}{
+	{"DISCORD_BOT_TOKEN", "discord.token"},
+	{"DISCORD_GUILD_ID", "discord.guild_id"},
+	{"DISCORD_APP_ID", "discord.app_id"},
+	{"WHITELIST_PATH", "whitelist_path"},
+}
+
+// 値の出どころ
+const (
+	SourceDefault = "default" // デフォルト値
+	SourceFile    = "file"    // 設定ファイル
+	SourceEnv     = "env"     // 環境変数
+	SourceSecret  = "secret"  // *_FILE で指定されたファイル
+)
+
+// SettingSource は値の出どころ（Name は環境変数名）
+type SettingSource struct {
+	Kind string
+	Name string
+}
+
+// String は表示用の文字列を返す
+func (s SettingSource) String() string {
+	if s.Name == "" {
+		return s.Kind
+	}
+	return s.Kind + ":" + s.Name
+}
+
+// IsOverride は設定ファイルより優先される層から来た値か判定
+func (s SettingSource) IsOverride() bool {
+	return s.Kind == SourceEnv || s.Kind == SourceSecret
+}
+
+// SettingSources はキーパスごとの値の出どころ
+type SettingSources map[string]SettingSource
+
+// Keys はキーパスをソートして返す
+func (s SettingSources) Keys() []string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// defaultSettingsTree はデフォルト値（設定ファイルで省略されたキーに使う）
+func defaultSettingsTree() map[string]any {
+	return map[string]any{
+		"log_level": "INFO",
+		"regular_task": map[string]any{
+			"interval":            5,
+			"auto_shutdown_delay": 600,
+		},
+		"message_deleteafter": 7,
+		"icons": map[string]any{
+			"allow": "✅",
+			"deny":  "❌",
+		},
+		"whitelist_approval": map[string]any{
+			"expire_after": 259200,
+		},
+		"account_links": map[string]any{
+			"remove_on_leave": true,
+		},
+		"mojang": map[string]any{
+			"api_base_url":     DefaultMojangAPIBaseURL,
+			"session_base_url": DefaultMojangSessionBaseURL,
+			"cache_ttl":        86400,
+		},
+		"api": map[string]any{
+			"listen": ":8080",
+		},
+		"dashboard": map[string]any{
+			"listen": ":8090",
+		},
+		"monitoring": map[string]any{
+			"health": true,
+			"listen": ":9464",
+		},
+		"control": map[string]any{
+			"enabled":     true,
+			"socket_mode": "0600",
+		},
+		"slack": map[string]any{
+			"api_base_url": DefaultSlackAPIBaseURL,
+		},
+		"telegram": map[string]any{
+			"api_base_url": DefaultTelegramAPIBaseURL,
+		},
+	}
+}
+
+// LoadEffectiveSettings は実際に使う設定を読み込む
+// デフォルト値 → 設定ファイル → 環境変数（MC_AGENT_* と従来の変数） → *_FILE の順に上書きする
+// 戻り値の SettingSources で各値の出どころを確認できる
+func LoadEffectiveSettings(path string) (*Settings, SettingSources, error) {
+	data, err := readSettingsFile(ResolveSettingsPath(path))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := parseSettingsTree(data)
+	if err != nil {
+		return nil, nil, wrapSettingsError(err)
+	}
+
+	sources := make(SettingSources)
+	tree := defaultSettingsTree()
+	markSources(tree, "", SettingSource{Kind: SourceDefault}, sources)
+	markSources(file, "", SettingSource{Kind: SourceFile}, sources)
+	tree = mergeSettingsTree(tree, file)
+
+	if err := applyEnvOverrides(tree, sources, os.Environ()); err != nil {
+		return nil, nil, err
+	}
+
+	settings, err := decodeSettings(tree, sources, true)
+	if err != nil {
+		return nil, nil, wrapSettingsError(err)
+	}
+	return settings, sources, nil
+}
+
+// ValidateEffectiveSettings は設定ファイルの層に環境変数（*_FILE を含む）を重ねた設定を検証する
+// トークン等を環境変数や secret ファイルで指定している場合に、ファイルだけを検証すると必須エラーになるため
+func ValidateEffectiveSettings(file *Settings) error {
+	tree, err := settingsTree(file)
+	if err != nil {
+		return err
+	}
+
+	sources := make(SettingSources)
+	markSources(tree, "", SettingSource{Kind: SourceFile}, sources)
+	if err := applyEnvOverrides(tree, sources, os.Environ()); err != nil {
+		return err
+	}
+
+	_, err = decodeSettings(tree, sources, true)
+	return err
+}
+
+// SettingEnvOverride はキーパスを上書きしている環境変数があれば返す
+func SettingEnvOverride(path string) (string, bool) {
+	names := []string{EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "__"))}
+	for _, alias := range legacyEnvAliases {
+		if alias.Path == path {
+			names = append(names, alias.Env)
+		}
+	}
+
+	for _, name := range names {
+		for _, candidate := range []string{name, name + secretFileSuffix} {
+			if _, ok := os.LookupEnv(candidate); ok {
+				return candidate, true
+			}
+		}
+	}
+	return "", false
+}
+
+// DescribeSettings は "key = value (source)" 形式で実際の設定値と出どころを返す（秘密情報は伏せる）
+func DescribeSettings(settings *Settings, sources SettingSources) []string {
+	values := flattenSettings(settings)
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, k := range keys {
+		value := values[k]
+		if IsSecretSettingKey(k) && value != `""` {
+			value = maskedValue
+		}
+		source, ok := sources[k]
+		if !ok {
+			// どの層にも無いキーは構造体のゼロ値
+			source = SettingSource{Kind: SourceDefault}
+		}
+		lines = append(lines, fmt.Sprintf("%s = %s (%s)", k, value, source))
+	}
+	return lines
+}
+
+// IsSecretSettingKey は値を表示してはいけないキーか判定（secret:"true" タグ）
+func IsSecretSettingKey(path string) bool {
+	_, tag, err := lookupSettingField(strings.Split(path, "."), nil)
+	return err == nil && tag.Get("secret") == "true"
+}
+
+// envOverride は1つの上書き
+type envOverride struct {
+	path   string
+	value  string
+	source SettingSource
+}
+
+// applyEnvOverrides は環境変数の値をツリーに反映する
+func applyEnvOverrides(tree map[string]any, sources SettingSources, environ []string) error {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			env[name] = value
+		}
+	}
+
+	// 従来の変数を先に、MC_AGENT_* を後に適用（後の方が優先）
+	type candidate struct{ env, path string }
+	candidates := make([]candidate, 0)
+	for _, alias := range legacyEnvAliases {
+		candidates = append(candidates, candidate{alias.Env, alias.Path})
+	}
+
+	prefixed := make(map[string]bool)
+	for name := range env {
+		if !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		prefixed[strings.TrimSuffix(name, secretFileSuffix)] = true
+	}
+	names := make([]string, 0, len(prefixed))
+	for name := range prefixed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, EnvPrefix), "__", "."))
+		candidates = append(candidates, candidate{name, path})
+	}
+
+	var errs ValidationErrors
+	for _, c := range candidates {
+		override, ok, err := resolveEnvOverride(env, c.env, c.path)
+		if err != nil {
+			errs = append(errs, ValidationError{Path: c.path, Message: err.Error()})
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := setTreeValue(tree, override, sources); err != nil {
+			// .env の書き間違いなどで起動・再読み込みが止まらないよう、設定に無いキーは無視する
+			if errors.Is(err, errUnknownSettingKey) {
+				log.Warn().Str("env", c.env).Str("key", c.path).Msg("Ignoring environment variable that does not match any setting")
+				continue
+			}
+			errs = append(errs, ValidationError{Path: c.path, Message: fmt.Sprintf("%v (from %s)", err, override.source)})
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid environment overrides: %w", errs)
+	}
+	return nil
+}
+
+// resolveEnvOverride は環境変数（または *_FILE の指すファイル）から値を取得する
+func resolveEnvOverride(env map[string]string, name, path string) (envOverride, bool, error) {
+	value, hasValue := env[name]
+	file, hasFile := env[name+secretFileSuffix]
+
+	switch {
+	case hasValue && hasFile:
+		return envOverride{}, false, fmt.Errorf("both %s and %s are set", name, name+secretFileSuffix)
+	case hasFile:
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return envOverride{}, false, fmt.Errorf("failed to read %s: %w", name+secretFileSuffix, err)
+		}
+		return envOverride{
+			path:   path,
+			value:  strings.TrimRight(string(data), "\r\n"),
+			source: SettingSource{Kind: SourceSecret, Name: name + secretFileSuffix},
+		}, true, nil
+	case hasValue:
+		return envOverride{
+			path:   path,
+			value:  value,
+			source: SettingSource{Kind: SourceEnv, Name: name},
+		}, true, nil
+	}
+	return envOverride{}, false, nil
+}
+
+// setTreeValue はキーパスの型に合わせて値を変換し、ツリーに設定する
+func setTreeValue(tree map[string]any, override envOverride, sources SettingSources) error {
+	keys := strings.Split(override.path, ".")
+	t, _, err := lookupSettingField(keys, tree)
+	if err != nil {
+		return err
+	}
+
+	// 文字列のキーはそのまま、それ以外は JSON として解釈
+	var value any = override.value
+	if t.Kind() != reflect.String {
+		if err := json.Unmarshal([]byte(override.value), &value); err != nil {
+			return fmt.Errorf("invalid %s value %q", t.Kind(), override.value)
+		}
+	}
+
+	// lookupSettingField でマップのキーが既存のものに揃えられている
+	obj := tree
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := obj[key].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			obj[key] = child
+		}
+		obj = child
+	}
+	last := keys[len(keys)-1]
+	obj[last] = value
+
+	path := strings.Join(keys, ".")
+	for k := range sources {
+		if k == path || strings.HasPrefix(k, path+".") {
+			delete(sources, k)
+		}
+	}
+	markSources(value, path, override.source, sources)
+	return nil
+}
+
+// lookupSettingField はキーパスに対応する型と構造体タグを返す
+// tree が指定されていればマップのキーを既存のものに大文字小文字を無視して揃える（keys を書き換える）
+func lookupSettingField(keys []string, tree map[string]any) (reflect.Type, reflect.StructTag, error) {
+	t := reflect.TypeOf(Settings{})
+	var tag reflect.StructTag
+	var node any = tree
+
+	for idx, key := range keys {
+		path := strings.Join(keys[:idx+1], ".")
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := findJSONField(t, key)
+			if !ok {
+				return nil, "", fmt.Errorf("%w %s", errUnknownSettingKey, path)
+			}
+			keys[idx] = jsonName(field)
+			t, tag = field.Type, field.Tag
+		case reflect.Map:
+			if obj, ok := node.(map[string]any); ok {
+				for existing := range obj {
+					if strings.EqualFold(existing, key) {
+						keys[idx] = existing
+						break
+					}
+				}
+			}
+			t, tag = t.Elem(), ""
+		default:
+			return nil, "", fmt.Errorf("%s is not an object", strings.Join(keys[:idx], "."))
+		}
+
+		if obj, ok := node.(map[string]any); ok {
+			node = obj[keys[idx]]
+		} else {
+			node = nil
+		}
+	}
+	return t, tag, nil
+}
+
+// findJSONField は JSON キー名（大文字小文字を無視）に一致するフィールドを探す
+func findJSONField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		if field.IsExported() && strings.EqualFold(jsonName(field), key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// jsonName はフィールドの JSON キー名を返す
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// mergeSettingsTree は override の値で base を上書きする（オブジェクトは再帰的にマージ）
+func mergeSettingsTree(base, override map[string]any) map[string]any {
+	for key, value := range override {
+		baseObj, baseIsObj := base[key].(map[string]any)
+		overrideObj, overrideIsObj := value.(map[string]any)
+		if baseIsObj && overrideIsObj {
+			base[key] = mergeSettingsTree(baseObj, overrideObj)
+			continue
+		}
+		base[key] = value
+	}
+	return base
+}
+
+// defaultSettingsValues はデフォルト値の設定のツリーを返す（defaultSettingsTree に無い項目はゼロ値）
+func defaultSettingsValues() (map[string]any, error) {
+	settings, err := decodeSettings(defaultSettingsTree(), nil, false)
+	if err != nil {
+		return nil, err
+	}
+	return settingsTree(settings)
+}
+
+// fileLayerTree は保存する設定のツリーから、設定ファイル（file）に無くデフォルト値と同じ項目を除く
+// registered_containers のようにキーが自由なオブジェクトの中はゼロ値をデフォルトとみなす
+func fileLayerTree(tree, defaults, file map[string]any) map[string]any {
+	out := make(map[string]any)
+	for key, value := range tree {
+		fileValue, inFile := file[key]
+		if obj, ok := value.(map[string]any); ok {
+			defaultObj, _ := defaults[key].(map[string]any)
+			fileObj, _ := fileValue.(map[string]any)
+			if pruned := fileLayerTree(obj, defaultObj, fileObj); len(pruned) > 0 || inFile {
+				out[key] = pruned
+			}
+			continue
+		}
+
+		defaultValue, hasDefault := defaults[key]
+		if !inFile && (reflect.DeepEqual(value, defaultValue) || (!hasDefault && isZeroJSONValue(value))) {
+			continue
+		}
+		out[key] = value
+	}
+	return out
+}
+
+// isZeroJSONValue は JSON の値がゼロ値（null・空文字列・false・0・空の配列）か判定
+func isZeroJSONValue(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case []any:
+		return len(v) == 0
+	}
+	return false
+}
+
+// markSources はツリーの末端のキーパスに出どころを記録する
+func markSources(value any, path string, source SettingSource, sources SettingSources) {
+	if obj, ok := value.(map[string]any); ok && len(obj) > 0 {
+		for key, child := range obj {
+			markSources(child, joinPath(path, key), source, sources)
+		}
+		return
+	}
+	if path != "" {
+		sources[path] = source
+	}
+}
@@ -0,0 +1,227 @@
+package utilities
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// settingsFileMu はプロセス内での設定ファイルの read-modify-write を直列化する
+var settingsFileMu sync.Mutex
+
+// GetSettingValue はキーパス（例: "regular_task.interval"）の値を JSON 表記で返す
+// 空のパスは設定全体を返す
+func GetSettingValue(settings *Settings, path string) (string, error) {
+	tree, err := settingsTree(settings)
+	if err != nil {
+		return "", err
+	}
+
+	maskSecrets(tree, "")
+
+	var value any = tree
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			obj, ok := value.(map[string]any)
+			if !ok {
+				return "", fmt.Errorf("%s is not an object", path)
+			}
+			value, ok = obj[key]
+			if !ok {
+				return "", fmt.Errorf("unknown key: %s", path)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal value: %w", err)
+	}
+	return string(data), nil
+}
+
+// SetSettingValue はキーパスに値を設定した新しい Settings を返す（元の設定は変更しない）
+// raw は JSON として解釈し、解釈できなければ文字列として扱う
+// 存在しないキーや型の合わない値はエラーになる
+func SetSettingValue(settings *Settings, path, raw string) (*Settings, error) {
+	if path == "" {
+		return nil, fmt.Errorf("key path is required")
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		value = raw
+	}
+
+	tree, err := settingsTree(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := strings.Split(path, ".")
+	obj := tree
+	for idx, key := range keys[:len(keys)-1] {
+		child, ok := obj[key].(map[string]any)
+		if !ok {
+			if obj[key] != nil {
+				return nil, fmt.Errorf("%s is not an object", strings.Join(keys[:idx+1], "."))
+			}
+			// map 型のフィールド（icons 等）は途中のキーを作成
+			child = map[string]any{}
+			obj[key] = child
+		}
+		obj = child
+	}
+	last := keys[len(keys)-1]
+	obj[last] = value
+
+	newSettings, err := settingsFromTree(tree)
+	if err != nil {
+		if _, isString := value.(string); !isString {
+			// 数値などとして解釈できても文字列フィールドの場合があるため、文字列として再試行
+			obj[last] = raw
+			if retried, retryErr := settingsFromTree(tree); retryErr == nil {
+				return retried, nil
+			}
+		}
+		return nil, err
+	}
+	return newSettings, nil
+}
+
+// SettingKeyPaths は設定可能なキーパスの一覧を返す（補完用）
+func SettingKeyPaths(settings *Settings) []string {
+	values := flattenSettings(settings)
+	paths := make([]string, 0, len(values))
+	for k := range values {
+		paths = append(paths, k)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// CloneSettings は設定のディープコピーを返す
+func CloneSettings(settings *Settings) (*Settings, error) {
+	tree, err := settingsTree(settings)
+	if err != nil {
+		return nil, err
+	}
+	return settingsFromTree(tree)
+}
+
+// UpdateSettingsFile は設定ファイルを読み込み、modify で変更してから保存する
+// 保存前に環境変数と合成した設定を検証し、直前の内容は "<path>.bak" にバックアップする
+// modify に渡す設定と保存する内容は設定ファイルの層のみ（環境変数の値は書き込まない）
+// 戻り値は変更前と変更後の設定
+func UpdateSettingsFile(path string, modify func(*Settings) (*Settings, error)) (*Settings, *Settings, error) {
+	settingsFileMu.Lock()
+	defer settingsFileMu.Unlock()
+
+	path = ResolveSettingsPath(path)
+
+	oldSettings, err := LoadSettings(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	working, err := CloneSettings(oldSettings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newSettings, err := modify(working)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := ValidateEffectiveSettings(newSettings); err != nil {
+		return nil, nil, fmt.Errorf("invalid settings: %w", err)
+	}
+
+	if err := BackupSettings(path); err != nil {
+		return nil, nil, err
+	}
+
+	if err := SaveSettings(path, newSettings); err != nil {
+		return nil, nil, err
+	}
+
+	return oldSettings, newSettings, nil
+}
+
+// BackupSettings は現在の設定ファイルを "<path>.bak" にコピーする
+// トークン等を含むため、バックアップのパーミッションは元のファイルに合わせる
+func BackupSettings(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read settings for backup: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read settings for backup: %w", err)
+	}
+
+	backup, err := os.OpenFile(path+".bak", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write settings backup: %w", err)
+	}
+	defer backup.Close()
+
+	// 既存のバックアップは開いてもパーミッションが変わらないため、書き込む前に合わせる
+	if err := backup.Chmod(info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to chmod settings backup: %w", err)
+	}
+	if _, err := backup.Write(data); err != nil {
+		return fmt.Errorf("failed to write settings backup: %w", err)
+	}
+	return nil
+}
+
+// maskSecrets は秘密情報のキーの値を伏せる
+func maskSecrets(obj map[string]any, prefix string) {
+	for key, value := range obj {
+		path := joinPath(prefix, key)
+		if child, ok := value.(map[string]any); ok {
+			maskSecrets(child, path)
+			continue
+		}
+		if str, ok := value.(string); ok && str != "" && IsSecretSettingKey(path) {
+			obj[key] = "***"
+		}
+	}
+}
+
+// settingsTree は設定を汎用の JSON ツリーに変換する
+func settingsTree(settings *Settings) (map[string]any, error) {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal settings: %w", err)
+	}
+
+	var tree map[string]any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to convert settings: %w", err)
+	}
+	return tree, nil
+}
+
+// settingsFromTree は JSON ツリーを Settings に戻す（未知のキーはエラー）
+func settingsFromTree(tree map[string]any) (*Settings, error) {
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal settings: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var settings Settings
+	if err := decoder.Decode(&settings); err != nil {
+		return nil, fmt.Errorf("invalid value: %w", err)
+	}
+	return &settings, nil
+}
@@ -0,0 +1,256 @@
+package utilities
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CurrentSettingsVersion は現在の設定ファイルのバージョン
+const CurrentSettingsVersion = 2
+
+// settingsMigrations はバージョンごとの移行処理（キーは移行元のバージョン）
+// 古い形式の設定は読み込み時に順番に適用して現在の形式にする
+var settingsMigrations = map[int]func(tree map[string]any) error{
+	0: migrateSettingsV0,
+	1: migrateSettingsV1,
+}
+
+// migrateSettingsV0 は version 導入前の設定を version 1 にする
+// 構造の変更はないため version を付与するのみ
+func migrateSettingsV0(tree map[string]any) error {
+	return nil
+}
+
+// migrateSettingsV1 は全サーバー共通のホワイトリストをサーバーごとの設定に移す
+// 共通の whitelist_path が指定されていれば、個別の指定が無いサーバーにそのパスを設定する
+func migrateSettingsV1(tree map[string]any) error {
+	global, _ := tree["whitelist_path"].(string)
+	delete(tree, "whitelist_path")
+	if global == "" {
+		return nil
+	}
+
+	containers, _ := tree["registered_containers"].(map[string]any)
+	for _, value := range containers {
+		config, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+		if current, _ := config["whitelist_path"].(string); current == "" {
+			config["whitelist_path"] = global
+		}
+	}
+	return nil
+}
+
+// ParseSettings は設定ファイルの内容を解釈する（デフォルト値 + 設定ファイル）
+// 古いバージョンは移行してから読み込み、未知のキー・型の誤り・検証エラーをまとめて返す
+func ParseSettings(data []byte) (*Settings, error) {
+	file, err := parseSettingsTree(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSettings(mergeSettingsTree(defaultSettingsTree(), file), nil, true)
+}
+
+// parseSettingsTree は設定ファイルを JSON ツリーとして読み込み、現在のバージョンまで移行する
+func parseSettingsTree(data []byte) (map[string]any, error) {
+	var tree map[string]any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to parse settings JSON: %w", describeJSONError(data, err))
+	}
+	if tree == nil {
+		return nil, fmt.Errorf("failed to parse settings JSON: top level must be an object")
+	}
+
+	if err := migrateSettings(tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// decodeSettings は JSON ツリーを Settings に変換し、validate の場合は値も検証する
+// sources があれば、環境変数などファイル以外から来た値のエラーに出どころを付記する
+func decodeSettings(tree map[string]any, sources SettingSources, validate bool) (*Settings, error) {
+	// 未知のキー（typo など）を検出
+	var errs ValidationErrors
+	checkUnknownKeys(tree, reflect.TypeOf(Settings{}), "", &errs)
+
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal settings: %w", err)
+	}
+
+	var settings Settings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("failed to parse settings JSON: %w", err)
+		}
+		errs = append(errs, ValidationError{
+			Path:    typeErr.Field,
+			Message: fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value),
+		})
+	} else if validate {
+		// 型が正しく読めた場合は値の検証結果もまとめて返す
+		if err := settings.Validate(); err != nil {
+			var validationErrs ValidationErrors
+			if !errors.As(err, &validationErrs) {
+				return nil, err
+			}
+			errs = append(errs, validationErrs...)
+		}
+	}
+
+	if len(errs) > 0 {
+		for idx, e := range errs {
+			if source := sources[e.Path]; source.IsOverride() {
+				errs[idx].Message = fmt.Sprintf("%s (from %s)", e.Message, source)
+			}
+		}
+		errs.sort()
+		return nil, errs
+	}
+
+	return &settings, nil
+}
+
+// migrateSettings は設定ツリーを現在のバージョンまで移行する
+func migrateSettings(tree map[string]any) error {
+	version := 0
+	if raw, ok := tree["version"]; ok {
+		number, ok := raw.(float64)
+		if !ok || number != float64(int(number)) {
+			return ValidationErrors{{Path: "version", Message: "must be an integer"}}
+		}
+		version = int(number)
+	}
+
+	if version > CurrentSettingsVersion {
+		return ValidationErrors{{
+			Path:    "version",
+			Message: fmt.Sprintf("version %d is newer than supported version %d", version, CurrentSettingsVersion),
+		}}
+	}
+
+	for version < CurrentSettingsVersion {
+		migrate, ok := settingsMigrations[version]
+		if !ok {
+			return fmt.Errorf("no migration from settings version %d", version)
+		}
+		if err := migrate(tree); err != nil {
+			return fmt.Errorf("failed to migrate settings from version %d: %w", version, err)
+		}
+		version++
+	}
+
+	tree["version"] = version
+	return nil
+}
+
+// checkUnknownKeys は構造体に存在しないキーを探す
+func checkUnknownKeys(value any, t reflect.Type, path string, errs *ValidationErrors) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	obj, ok := value.(map[string]any)
+	if !ok {
+		// 型の誤りはデコード時に検出する
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := jsonFields(t)
+		for key, child := range obj {
+			field, ok := fields[key]
+			if !ok {
+				*errs = append(*errs, ValidationError{Path: joinPath(path, key), Message: "unknown key"})
+				continue
+			}
+			checkUnknownKeys(child, field, joinPath(path, key), errs)
+		}
+	case reflect.Map:
+		for key, child := range obj {
+			checkUnknownKeys(child, t.Elem(), joinPath(path, key), errs)
+		}
+	}
+}
+
+// jsonFields は構造体の JSON キーとフィールドの型の対応を返す
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		if !field.IsExported() {
+			continue
+		}
+		if name := jsonName(field); name != "-" {
+			fields[name] = field.Type
+		}
+	}
+	return fields
+}
+
+// joinPath はキーパスを連結する
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// describeJSONError は構文エラーに行・列番号を付ける
+func describeJSONError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return err
+	}
+
+	offset := int(syntaxErr.Offset)
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(data[:offset], '\n')
+	return fmt.Errorf("line %d, column %d: %w", line, column, err)
+}
+
+// ValidationError は設定の検証エラー（Path は JSON のキーパス）
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+// Error はエラーメッセージを返す
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors は検証エラーの一覧
+type ValidationErrors []ValidationError
+
+// Error はすべてのエラーを連結したメッセージを返す
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// sort はキーパス順に並べる
+func (e ValidationErrors) sort() {
+	sort.SliceStable(e, func(a, b int) bool {
+		return e[a].Path < e[b].Path
+	})
+}
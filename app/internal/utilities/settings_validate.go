@@ -0,0 +1,373 @@
+package utilities
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	// snowflakePattern は Discord の ID（snowflake）の形式
+	snowflakePattern = regexp.MustCompile(`^\d{17,20}$`)
+
+	// customEmojiPattern はカスタム絵文字の形式（例: <:name:123...>、アニメーションは <a:name:123...>）
+	customEmojiPattern = regexp.MustCompile(`^<a?:[A-Za-z0-9_]{2,32}:\d{17,20}>$`)
+)
+
+// minAPITokenLength は API トークンの最小の長さ（推測されにくい値を要求する）
+const minAPITokenLength = 16
+
+// validLogLevels は log_level に指定できる値
+var validLogLevels = []string{"DEBUG", "INFO", "WARN", "WARNING", "ERROR", "FATAL"}
+
+// Validate は設定の妥当性をチェック
+// 問題があればすべてを ValidationErrors として返す
+func (s *Settings) Validate() error {
+	var errs ValidationErrors
+	add := func(path, format string, args ...any) {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Version != CurrentSettingsVersion {
+		add("version", "must be %d, got %d", CurrentSettingsVersion, s.Version)
+	}
+
+	if s.LogLevel != "" && !containsFold(validLogLevels, s.LogLevel) {
+		add("log_level", "must be one of %s, got %q", strings.Join(validLogLevels, ", "), s.LogLevel)
+	}
+
+	if s.RegularTask.Interval <= 0 {
+		add("regular_task.interval", "must be > 0, got %d", s.RegularTask.Interval)
+	}
+	if s.RegularTask.AutoShutdownDelay < 0 {
+		add("regular_task.auto_shutdown_delay", "must be >= 0, got %d", s.RegularTask.AutoShutdownDelay)
+	}
+	if s.MessageDeleteAfter < 0 {
+		add("message_deleteafter", "must be >= 0, got %d", s.MessageDeleteAfter)
+	}
+
+	if len(s.RegisteredContainers) == 0 {
+		add("registered_containers", "must not be empty")
+	}
+
+	// 重複チェックの結果を安定させるためキー順に検証
+	keys := make([]string, 0, len(s.RegisteredContainers))
+	for key := range s.RegisteredContainers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	containerNames := make(map[string]string)
+	for _, key := range keys {
+		c := s.RegisteredContainers[key]
+		path := "registered_containers." + key
+
+		if strings.TrimSpace(key) == "" {
+			add(path, "key must not be empty")
+		}
+
+		if c.ContainerName == "" {
+			add(path+".container_name", "is required")
+		} else if other, ok := containerNames[c.ContainerName]; ok {
+			add(path+".container_name", "%q is already used by %s", c.ContainerName, other)
+		} else {
+			containerNames[c.ContainerName] = key
+		}
+
+		if c.DisplayName == "" {
+			add(path+".display_name", "is required")
+		}
+
+		// icons のキーを指定した場合はその絵文字を使う
+		if _, ok := s.Icons[c.Icon]; c.Icon != "" && !ok && !isValidEmoji(c.Icon) {
+			add(path+".icon", "must be a key in icons, a unicode emoji or custom emoji like <:name:id>, got %q", c.Icon)
+		}
+	}
+
+	iconKeys := make([]string, 0, len(s.Icons))
+	for key := range s.Icons {
+		iconKeys = append(iconKeys, key)
+	}
+	sort.Strings(iconKeys)
+	for _, key := range iconKeys {
+		if icon := s.Icons[key]; icon != "" && !isValidEmoji(icon) {
+			add("icons."+key, "must be a unicode emoji or custom emoji like <:name:id>, got %q", icon)
+		}
+	}
+
+	if s.WhitelistApproval.Enabled && s.WhitelistApproval.ChannelID == "" {
+		add("whitelist_approval.channel_id", "is required when whitelist_approval.enabled is true")
+	}
+	if s.WhitelistApproval.ExpireAfter < 0 {
+		add("whitelist_approval.expire_after", "must be 0 or greater, got %d", s.WhitelistApproval.ExpireAfter)
+	}
+
+	if s.AccountLinks.MaxPerUser < 0 {
+		add("account_links.max_per_user", "must be 0 or greater, got %d", s.AccountLinks.MaxPerUser)
+	}
+
+	baseURLs := []struct{ path, value string }{
+		{"mojang.api_base_url", s.Mojang.APIBaseURL},
+		{"mojang.session_base_url", s.Mojang.SessionBaseURL},
+	}
+	for _, u := range baseURLs {
+		if parsed, err := url.Parse(u.value); u.value != "" && (err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "") {
+			add(u.path, "must be an http(s) URL, got %q", u.value)
+		}
+	}
+	if s.Mojang.CacheTTL < 0 {
+		add("mojang.cache_ttl", "must be 0 or greater, got %d", s.Mojang.CacheTTL)
+	}
+
+	// 無効の場合はトークンを検証しない（設定例のように値を空のままにしておける）
+	if s.API.Enabled {
+		if s.API.Listen == "" {
+			add("api.listen", "is required when api.enabled is true")
+		} else if _, _, err := net.SplitHostPort(s.API.Listen); err != nil {
+			add("api.listen", "must be host:port, got %q", s.API.Listen)
+		}
+		if len(s.API.Tokens) == 0 {
+			add("api.tokens", "must not be empty when api.enabled is true")
+		}
+
+		tokenNames := make([]string, 0, len(s.API.Tokens))
+		for name := range s.API.Tokens {
+			tokenNames = append(tokenNames, name)
+		}
+		sort.Strings(tokenNames)
+		tokenValues := make(map[string]string)
+		for _, name := range tokenNames {
+			token := s.API.Tokens[name]
+			path := "api.tokens." + name
+			switch {
+			case len(token.Token) < minAPITokenLength:
+				add(path+".token", "must be at least %d characters", minAPITokenLength)
+			case tokenValues[token.Token] != "":
+				add(path+".token", "is the same as %s", tokenValues[token.Token])
+			default:
+				tokenValues[token.Token] = name
+			}
+			if len(token.Scopes) == 0 {
+				add(path+".scopes", "must not be empty")
+			}
+			for _, scope := range token.Scopes {
+				if !slices.Contains(APIScopes, scope) {
+					add(path+".scopes", "must be one of %s, got %q", strings.Join(APIScopes, ", "), scope)
+				}
+			}
+		}
+	}
+
+	if s.Monitoring.Serving() {
+		if s.Monitoring.Listen == "" {
+			add("monitoring.listen", "is required when monitoring.enabled or monitoring.health is true")
+		} else if _, _, err := net.SplitHostPort(s.Monitoring.Listen); err != nil {
+			add("monitoring.listen", "must be host:port, got %q", s.Monitoring.Listen)
+		} else if s.API.Enabled && s.Monitoring.Listen == s.API.Listen {
+			add("monitoring.listen", "must differ from api.listen (%s)", s.API.Listen)
+		}
+	}
+
+	if s.Dashboard.Enabled {
+		if s.Dashboard.Listen == "" {
+			add("dashboard.listen", "is required when dashboard.enabled is true")
+		} else if _, _, err := net.SplitHostPort(s.Dashboard.Listen); err != nil {
+			add("dashboard.listen", "must be host:port, got %q", s.Dashboard.Listen)
+		} else if s.API.Enabled && s.Dashboard.Listen == s.API.Listen {
+			add("dashboard.listen", "must differ from api.listen (%s)", s.API.Listen)
+		} else if s.Monitoring.Serving() && s.Dashboard.Listen == s.Monitoring.Listen {
+			add("dashboard.listen", "must differ from monitoring.listen (%s)", s.Monitoring.Listen)
+		}
+		if u, err := url.Parse(s.Dashboard.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("dashboard.public_url", "must be an absolute http(s) URL, got %q", s.Dashboard.PublicURL)
+		}
+		if s.Dashboard.ClientSecret == "" {
+			add("dashboard.client_secret", "is required when dashboard.enabled is true")
+		}
+		if s.Discord.AppID == "" {
+			add("discord.app_id", "is required when dashboard.enabled is true (used as the OAuth2 client ID)")
+		}
+	}
+
+	// UNIX ドメインソケットのパスは 108 バイト（macOS は 104 バイト）まで
+	if s.Control.Enabled && len(s.ControlSocketPath()) > 100 {
+		add("control.socket_path", "must be at most 100 bytes for a unix socket, got %q", s.ControlSocketPath())
+	}
+	// 接続できれば認証なしで操作できるため、その他のユーザーには許可しない
+	if mode, err := strconv.ParseUint(s.Control.SocketMode, 8, 32); err != nil || mode > 0777 {
+		add("control.socket_mode", "must be an octal file mode such as \"0660\", got %q", s.Control.SocketMode)
+	} else if mode&0007 != 0 {
+		add("control.socket_mode", "must not grant access to other users, got %q", s.Control.SocketMode)
+	} else if s.Control.SocketGroup != "" && mode&0060 == 0 {
+		add("control.socket_group", "has no effect unless control.socket_mode grants group access (e.g. \"0660\")")
+	}
+
+	webhookNames := make([]string, 0, len(s.Webhooks))
+	for name := range s.Webhooks {
+		webhookNames = append(webhookNames, name)
+	}
+	sort.Strings(webhookNames)
+	for _, name := range webhookNames {
+		hook := s.Webhooks[name]
+		path := "webhooks." + name
+		if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add(path+".url", "must be an absolute http(s) URL, got %q", hook.URL)
+		}
+		for _, event := range hook.Events {
+			if !slices.Contains(WebhookEvents, event) {
+				add(path+".events", "must be one of %s, got %q", strings.Join(WebhookEvents, ", "), event)
+			}
+		}
+		for _, server := range hook.Servers {
+			if _, ok := s.RegisteredContainers[server]; !ok {
+				add(path+".servers", "unknown server %q (not in registered_containers)", server)
+			}
+		}
+	}
+
+	if s.Slack.Enabled {
+		if !strings.HasPrefix(s.Slack.BotToken, "xoxb-") {
+			add("slack.bot_token", "must be a bot token starting with xoxb- when slack.enabled is true")
+		}
+		if !strings.HasPrefix(s.Slack.AppToken, "xapp-") {
+			add("slack.app_token", "must be an app-level token starting with xapp- when slack.enabled is true")
+		}
+		if s.Slack.ChannelID == "" {
+			add("slack.channel_id", "is required when slack.enabled is true")
+		}
+		if u, err := url.Parse(s.Slack.APIBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("slack.api_base_url", "must be an absolute http(s) URL, got %q", s.Slack.APIBaseURL)
+		}
+	}
+
+	if s.Matrix.Enabled {
+		if u, err := url.Parse(s.Matrix.HomeserverURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("matrix.homeserver_url", "must be an absolute http(s) URL, got %q", s.Matrix.HomeserverURL)
+		}
+		if s.Matrix.AccessToken == "" {
+			add("matrix.access_token", "is required when matrix.enabled is true")
+		}
+		if !strings.HasPrefix(s.Matrix.RoomID, "!") || !strings.Contains(s.Matrix.RoomID, ":") {
+			add("matrix.room_id", "must be a room ID like !abc:example.com, got %q", s.Matrix.RoomID)
+		}
+		for _, user := range s.Matrix.AllowedUsers {
+			if !strings.HasPrefix(user, "@") || !strings.Contains(user, ":") {
+				add("matrix.allowed_users", "must be user IDs like @user:example.com, got %q", user)
+			}
+		}
+	}
+
+	if s.Telegram.Enabled {
+		if s.Telegram.Token == "" {
+			add("telegram.token", "is required when telegram.enabled is true")
+		}
+		if _, err := strconv.ParseInt(s.Telegram.ChatID, 10, 64); err != nil {
+			add("telegram.chat_id", "must be a numeric chat ID, got %q", s.Telegram.ChatID)
+		}
+		for _, user := range s.Telegram.AllowedUsers {
+			if _, err := strconv.ParseInt(user, 10, 64); err != nil {
+				add("telegram.allowed_users", "must be numeric user IDs, got %q", user)
+			}
+		}
+		if u, err := url.Parse(s.Telegram.APIBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("telegram.api_base_url", "must be an absolute http(s) URL, got %q", s.Telegram.APIBaseURL)
+		}
+	}
+
+	snowflakes := []struct{ path, value string }{
+		{"audit.channel_id", s.Audit.ChannelID},
+		{"whitelist_approval.channel_id", s.WhitelistApproval.ChannelID},
+		{"account_links.required_role_id", s.AccountLinks.RequiredRoleID},
+		{"discord.guild_id", s.Discord.GuildID},
+		{"discord.app_id", s.Discord.AppID},
+	}
+	for _, id := range snowflakes {
+		if id.value != "" && !IsSnowflake(id.value) {
+			add(id.path, "must be a Discord ID, got %q", id.value)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// CheckServerPaths は registered_containers.*.path のディレクトリが存在するか確認する
+// エージェントのコンテナにマウントしていない場合や一時的に見えない場合もあるため、Validate とは別にする
+// （起動・再読み込みでは警告のみ、config validate ではエラーとして扱う）
+func (s *Settings) CheckServerPaths() ValidationErrors {
+	keys := make([]string, 0, len(s.RegisteredContainers))
+	for key := range s.RegisteredContainers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs ValidationErrors
+	for _, key := range keys {
+		dir := s.RegisteredContainers[key].Path
+		if dir == "" {
+			continue
+		}
+
+		path := "registered_containers." + key + ".path"
+		info, err := os.Stat(dir)
+		switch {
+		case os.IsNotExist(err):
+			errs = append(errs, ValidationError{Path: path, Message: "directory does not exist: " + dir})
+		case err != nil:
+			errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf("cannot access %s: %v", dir, err)})
+		case !info.IsDir():
+			errs = append(errs, ValidationError{Path: path, Message: "not a directory: " + dir})
+		}
+	}
+	return errs
+}
+
+// IsSnowflake は Discord の ID の形式か判定
+func IsSnowflake(id string) bool {
+	return snowflakePattern.MatchString(id)
+}
+
+// IsCustomEmoji は Discord のカスタム絵文字の表記か判定（Discord 以外では表示できない）
+func IsCustomEmoji(s string) bool {
+	return customEmojiPattern.MatchString(s)
+}
+
+// isValidEmoji はメッセージに使える絵文字の表記か判定
+// カスタム絵文字（<:name:id>）またはユニコード絵文字（文字・空白を含まないもの）
+func isValidEmoji(s string) bool {
+	if customEmojiPattern.MatchString(s) {
+		return true
+	}
+
+	// キーキャップ（例: 1️⃣）は ASCII の数字・記号を含む
+	keycap := strings.ContainsRune(s, '\u20e3')
+
+	for _, r := range s {
+		if keycap && (unicode.IsDigit(r) || r == '#' || r == '*') {
+			continue
+		}
+		if r < 0x80 || unicode.IsLetter(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// containsFold は大文字小文字を区別せずに含まれているか判定
+func containsFold(values []string, target string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package utilities
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// settingsWatchDelay は変更検知から通知までの待ち時間（エディタの連続書き込みをまとめる）
+const settingsWatchDelay = 500 * time.Millisecond
+
+// WatchSettings は設定ファイルの変更を監視し、変更があれば changed に通知する
+// atomic rename による置き換えも検知できるよう、ファイルではなく親ディレクトリを監視する
+func WatchSettings(ctx context.Context, path string, changed chan<- struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		target := filepath.Clean(path)
+		var debounce <-chan time.Time
+
+		log.Info().Str("path", path).Msg("Watching settings file")
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != target {
+					continue
+				}
+				if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+					continue
+				}
+				log.Debug().Str("event", event.Op.String()).Msg("Settings file event")
+				debounce = time.After(settingsWatchDelay)
+
+			case <-debounce:
+				debounce = nil
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Error().Err(err).Msg("Settings watcher error")
+			}
+		}
+	}()
+
+	return nil
+}
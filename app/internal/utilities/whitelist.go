@@ -1,195 +1,227 @@
 package utilities
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"syscall"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
+// whitelistMetaFileName はホワイトリストのエントリの付加情報を保存するファイル（データディレクトリ配下）
+// サーバーは whitelist.json を書き換える際に未知のフィールドを落とすため、追加したユーザーと期限はエージェント側で持つ
+const whitelistMetaFileName = "whitelist_meta.json"
+
+// whitelistMetaMu は whitelist_meta.json の読み込み → 書き込みを直列化する
+var whitelistMetaMu sync.Mutex
+
 // WhitelistEntry はホワイトリストの1エントリ
+// AddedUserID と ExpiresAt は whitelist.json ではなく whitelist_meta.json に保存する（読み書きの際に合わせる）
 type WhitelistEntry struct {
-	UUID        string `json:"uuid"`
-	Name        string `json:"name"`
-	AddedUserID string `json:"added_user_id,omitempty"`
+	UUID        string     `json:"uuid"`
+	Name        string     `json:"name"`
+	AddedUserID string     `json:"added_user_id,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // nil の場合は無期限
 }
 
-// MojangProfile は Mojang API のレスポンス
-type MojangProfile struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-}
+// Key は UUID を返す
+func (e WhitelistEntry) Key() string { return e.UUID }
 
-// FetchMojangProfile はプレイヤー名から UUID を取得
-func FetchMojangProfile(playerName string) (*MojangProfile, error) {
-	url := fmt.Sprintf("https://api.mojang.com/users/profiles/minecraft/%s", playerName)
+// whitelistFileEntry は whitelist.json の1エントリ（サーバーが扱うフィールドのみ書き込む）
+// 以前のバージョンが whitelist.json に書いた added_user_id / expires_at は読み込み時に付加情報へ移す
+type whitelistFileEntry struct {
+	UUID              string     `json:"uuid"`
+	Name              string     `json:"name"`
+	LegacyAddedUserID string     `json:"added_user_id,omitempty"`
+	LegacyExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+// whitelistMeta はエントリの付加情報
+type whitelistMeta struct {
+	AddedUserID string     `json:"added_user_id,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
 
-	resp, err := client.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch profile: %w", err)
-	}
-	defer resp.Body.Close()
+// whitelistMetaStore は whitelist_meta.json の内容（ホワイトリストファイルの絶対パス → 小文字の UUID → 付加情報）
+// 複数のサーバーが同じホワイトリストファイルを使う場合は付加情報も共有する
+type whitelistMetaStore map[string]map[string]whitelistMeta
 
-	if resp.StatusCode == 404 {
-		return nil, fmt.Errorf("player not found")
+// whitelistMetaKey はホワイトリストファイルを whitelist_meta.json で識別するキーを返す
+func whitelistMetaKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
 	}
+	return filepath.Clean(path)
+}
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("mojang API returned status %d", resp.StatusCode)
+// loadWhitelistMeta は whitelist_meta.json を読み込む（存在しなければ空）
+func loadWhitelistMeta() (whitelistMetaStore, error) {
+	store := make(whitelistMetaStore)
+	if _, err := LoadJSONFile(DataPath(whitelistMetaFileName), &store); err != nil {
+		return nil, err
 	}
+	return store, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+// mergeWhitelistMeta は whitelist.json のエントリに付加情報を合わせる
+// ファイルに無いプレイヤーの付加情報は使わない（サーバー側で削除された場合など）
+func mergeWhitelistMeta(files []whitelistFileEntry, meta map[string]whitelistMeta) []WhitelistEntry {
+	entries := make([]WhitelistEntry, 0, len(files))
+	for _, file := range files {
+		entry := WhitelistEntry{UUID: file.UUID, Name: file.Name}
+		if m, ok := meta[strings.ToLower(file.UUID)]; ok {
+			entry.AddedUserID, entry.ExpiresAt = m.AddedUserID, m.ExpiresAt
+		} else {
+			entry.AddedUserID, entry.ExpiresAt = file.LegacyAddedUserID, file.LegacyExpiresAt
+		}
+		entries = append(entries, entry)
 	}
+	return entries
+}
 
-	var profile MojangProfile
-	if err := json.Unmarshal(body, &profile); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+// splitWhitelistMeta はエントリを whitelist.json に書く内容と付加情報に分ける
+func splitWhitelistMeta(entries []WhitelistEntry) ([]whitelistFileEntry, map[string]whitelistMeta) {
+	files := make([]whitelistFileEntry, 0, len(entries))
+	meta := make(map[string]whitelistMeta)
+	for _, entry := range entries {
+		files = append(files, whitelistFileEntry{UUID: entry.UUID, Name: entry.Name})
+		if entry.AddedUserID != "" || entry.ExpiresAt != nil {
+			meta[strings.ToLower(entry.UUID)] = whitelistMeta{AddedUserID: entry.AddedUserID, ExpiresAt: entry.ExpiresAt}
+		}
 	}
-
-	return &profile, nil
+	return files, meta
 }
 
-// LoadWhitelist はホワイトリストファイルを読み込む
+// LoadWhitelist はホワイトリストファイルを読み込む（付加情報も合わせる）
 func LoadWhitelist(path string) ([]WhitelistEntry, error) {
-	file, err := os.Open(path)
+	files, err := LoadPlayerList[whitelistFileEntry](path)
 	if err != nil {
-		if os.IsNotExist(err) {
-			// ファイルが存在しない場合は空のリストを返す
-			return []WhitelistEntry{}, nil
-		}
-		return nil, fmt.Errorf("failed to open whitelist file: %w", err)
-	}
-	defer file.Close()
-
-	// 読み取り共有ロック
-	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_SH); err != nil {
-		return nil, fmt.Errorf("failed to lock whitelist file: %w", err)
+		return nil, err
 	}
-	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
-
-	var entries []WhitelistEntry
-	if err := json.NewDecoder(file).Decode(&entries); err != nil {
-		return nil, fmt.Errorf("failed to parse whitelist JSON: %w", err)
+	store, err := loadWhitelistMeta()
+	if err != nil {
+		return nil, err
 	}
-
-	return entries, nil
+	return mergeWhitelistMeta(files, store[whitelistMetaKey(path)]), nil
 }
 
 // SaveWhitelist はホワイトリストファイルを保存
 func SaveWhitelist(path string, entries []WhitelistEntry) error {
-	// JSON にマーシャル
-	data, err := json.MarshalIndent(entries, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal whitelist: %w", err)
-	}
-
-	// ファイルを開く（存在しなければ作成）
-	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open whitelist file: %w", err)
-	}
-	defer file.Close()
+	return UpdateWhitelist(path, func([]WhitelistEntry) ([]WhitelistEntry, bool, error) {
+		return entries, true, nil
+	})
+}
 
-	// 排他ロック
-	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
-		return fmt.Errorf("failed to lock whitelist file: %w", err)
-	}
-	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+// UpdateWhitelist は UpdatePlayerList のホワイトリスト版（付加情報も合わせて読み込み → update → 書き込みを行う）
+// 付加情報を先に保存し、失敗した場合は whitelist.json を書き換えない
+func UpdateWhitelist(path string, update func(entries []WhitelistEntry) ([]WhitelistEntry, bool, error)) error {
+	whitelistMetaMu.Lock()
+	defer whitelistMetaMu.Unlock()
 
-	// 書き込み
-	if _, err := file.Write(data); err != nil {
-		return fmt.Errorf("failed to write whitelist file: %w", err)
+	store, err := loadWhitelistMeta()
+	if err != nil {
+		return err
 	}
+	key := whitelistMetaKey(path)
 
-	// fsync で確実にディスクに書き込み
-	if err := file.Sync(); err != nil {
-		return fmt.Errorf("failed to sync whitelist file: %w", err)
-	}
+	return UpdatePlayerList(path, func(files []whitelistFileEntry) ([]whitelistFileEntry, bool, error) {
+		updated, changed, err := update(mergeWhitelistMeta(files, store[key]))
+		if err != nil || !changed {
+			return nil, false, err
+		}
 
-	return nil
+		files, meta := splitWhitelistMeta(updated)
+		if len(meta) > 0 {
+			store[key] = meta
+		} else {
+			delete(store, key)
+		}
+		if err := SaveJSONFile(DataPath(whitelistMetaFileName), store); err != nil {
+			return nil, false, err
+		}
+		return files, true, nil
+	})
 }
 
-// AddToWhitelist はプレイヤーをホワイトリストに追加
-func AddToWhitelist(path, uuid, name, addedUserID string) (bool, error) {
-	entries, err := LoadWhitelist(path)
-	if err != nil {
-		return false, err
-	}
-
+// AddToWhitelist はプレイヤーをホワイトリストに追加（expiresAt が nil の場合は無期限）
+// 既に存在する場合は名前と期限だけ更新する
+func AddToWhitelist(path, uuid, name, addedUserID string, expiresAt *time.Time) (bool, error) {
 	// UUIDをハイフン付き形式に変換 (Minecraftの標準形式)
-	formattedUUID := formatUUID(uuid)
-
-	// 既存エントリを探す
-	found := false
-	for i, entry := range entries {
-		if entry.UUID == formattedUUID {
-			// 既に存在する場合は名前を更新
-			entries[i].Name = name
-			found = true
-			break
+	uuid = FormatUUID(uuid)
+	added := false
+	err := UpdateWhitelist(path, func(entries []WhitelistEntry) ([]WhitelistEntry, bool, error) {
+		for idx := range entries {
+			if strings.EqualFold(entries[idx].UUID, uuid) {
+				entries[idx].Name = name
+				entries[idx].ExpiresAt = expiresAt
+				return entries, true, nil
+			}
 		}
-	}
-
-	if !found {
-		// 新規追加
-		entries = append(entries, WhitelistEntry{
-			UUID:        formattedUUID,
-			Name:        name,
-			AddedUserID: addedUserID,
-		})
-	}
 
-	if err := SaveWhitelist(path, entries); err != nil {
-		return false, err
-	}
+		added = true
+		return append(entries, WhitelistEntry{UUID: uuid, Name: name, AddedUserID: addedUserID, ExpiresAt: expiresAt}), true, nil
+	})
+	return added, err // true = 新規追加, false = 既に存在
+}
 
-	return !found, nil // true = 新規追加, false = 既に存在
+// RenameInWhitelist はプレイヤーの名前を更新（期限などは変えない）
+func RenameInWhitelist(path, uuid, name string) (bool, error) {
+	renamed := false
+	uuid = FormatUUID(uuid)
+	err := UpdateWhitelist(path, func(entries []WhitelistEntry) ([]WhitelistEntry, bool, error) {
+		for idx := range entries {
+			if strings.EqualFold(entries[idx].UUID, uuid) && entries[idx].Name != name {
+				entries[idx].Name = name
+				renamed = true
+				break
+			}
+		}
+		return entries, renamed, nil
+	})
+	return renamed, err
 }
 
-// RemoveFromWhitelist はプレイヤーをホワイトリストから削除
-func RemoveFromWhitelist(path, uuid string) (bool, error) {
-	entries, err := LoadWhitelist(path)
+// RemoveExpiredFromWhitelist は期限切れのエントリを削除して返す（該当なしの場合はファイルを書き換えない）
+func RemoveExpiredFromWhitelist(path string, now time.Time) ([]WhitelistEntry, error) {
+	var expired []WhitelistEntry
+	err := UpdateWhitelist(path, func(entries []WhitelistEntry) ([]WhitelistEntry, bool, error) {
+		kept := make([]WhitelistEntry, 0, len(entries))
+		for _, entry := range entries {
+			if entry.ExpiresAt != nil && !now.Before(*entry.ExpiresAt) {
+				expired = append(expired, entry)
+				continue
+			}
+			kept = append(kept, entry)
+		}
+		return kept, len(expired) > 0, nil
+	})
 	if err != nil {
-		return false, err
+		return nil, err
 	}
+	return expired, nil
+}
 
-	// UUIDをハイフン付き形式に変換
-	formattedUUID := formatUUID(uuid)
-
-	// 該当エントリを削除
-	found := false
-	newEntries := make([]WhitelistEntry, 0, len(entries))
-	for _, entry := range entries {
-		if entry.UUID == formattedUUID {
-			found = true
-			continue
+// RemoveFromWhitelist はプレイヤーをホワイトリストから削除
+func RemoveFromWhitelist(path, uuid string) (bool, error) {
+	removed := false
+	uuid = FormatUUID(uuid)
+	err := UpdateWhitelist(path, func(entries []WhitelistEntry) ([]WhitelistEntry, bool, error) {
+		kept := make([]WhitelistEntry, 0, len(entries))
+		for _, entry := range entries {
+			if strings.EqualFold(entry.UUID, uuid) {
+				removed = true
+				continue
+			}
+			kept = append(kept, entry)
 		}
-		newEntries = append(newEntries, entry)
-	}
-
-	if !found {
-		return false, nil // 削除するものがなかった
-	}
-
-	if err := SaveWhitelist(path, newEntries); err != nil {
-		return false, err
-	}
-
-	return true, nil // 削除成功
+		return kept, removed, nil
+	})
+	return removed, err
 }
 
-// formatUUID はハイフンなしのUUIDをハイフン付き形式に変換
+// FormatUUID はハイフンなしのUUIDをハイフン付き形式に変換
 // 例: "069a79f444e94726a5befca90e38aaf5" -> "069a79f4-44e9-4726-a5be-fca90e38aaf5"
-func formatUUID(uuid string) string {
+func FormatUUID(uuid string) string {
 	if len(uuid) != 32 {
 		return uuid // 既にフォーマット済みまたは無効
 	}
@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/docker/container"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+)
+
+// PayloadVersion はペイロードのスキーマのバージョン
+// フィールドの追加では変えず、既存のフィールドの削除・意味の変更をする場合に上げる
+const PayloadVersion = 1
+
+// payload は Webhook で POST する JSON
+type payload struct {
+	Version int            `json:"version"`
+	ID      string         `json:"id"`    // 配信 ID（再送しても同じ、受信側での重複の排除に使う）
+	Event   string         `json:"event"` // server.started など
+	Time    time.Time      `json:"time"`  // イベントが起きた時刻
+	Server  payloadServer  `json:"server"`
+	Status  *payloadStatus `json:"status,omitempty"` // server.* のみ
+	Player  *payloadPlayer `json:"player,omitempty"` // player.* のみ
+}
+
+// payloadServer は対象のサーバー
+type payloadServer struct {
+	ID          string `json:"id"` // registered_containers のキー
+	DisplayName string `json:"display_name"`
+}
+
+// payloadStatus はサーバーの状態の変化
+type payloadStatus struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Players   int    `json:"players"`
+	ExitCode  *int   `json:"exit_code,omitempty"` // 停止した場合のコンテナの終了コード
+	OOMKilled bool   `json:"oom_killed,omitempty"`
+}
+
+// payloadPlayer は参加・退出したプレイヤー
+type payloadPlayer struct {
+	Name   string `json:"name"`
+	Online int    `json:"online"` // 変化後のオンライン人数
+}
+
+// classifier はイベントバスのイベントを Webhook のイベントに変換する
+// 停止が停止コマンド（自動停止を含む）によるものかを判定するため、直前のコマンドを覚えておく
+type classifier struct {
+	expectedStops map[string]time.Time
+}
+
+// expectedStopWindow は停止コマンドの開始から、停止を想定どおりとみなす時間
+const expectedStopWindow = 10 * time.Minute
+
+// newClassifier は新しい classifier を作成
+func newClassifier() *classifier {
+	return &classifier{expectedStops: make(map[string]time.Time)}
+}
+
+// classify は Webhook のイベント名を返す（送らないイベントは空）
+func (c *classifier) classify(e state.Event) string {
+	switch e.Type {
+	case state.EventCommandStarted:
+		if e.Command != nil && (e.Command.Action == "stop" || e.Command.Action == "restart") {
+			c.expectedStops[e.Server] = e.Time
+		}
+	case state.EventStatusChanged:
+		if e.Status == nil {
+			return ""
+		}
+		switch e.Status.To {
+		case container.StatusRunning.String():
+			return utilities.WebhookEventServerStarted
+		case container.StatusStopped.String():
+			commandAt, expected := c.expectedStops[e.Server]
+			delete(c.expectedStops, e.Server)
+			expected = expected && e.Time.Sub(commandAt) <= expectedStopWindow
+
+			abnormal := e.Status.OOMKilled || (e.Status.ExitCode != nil && *e.Status.ExitCode != 0)
+			if abnormal && !expected {
+				return utilities.WebhookEventServerCrashed
+			}
+			return utilities.WebhookEventServerStopped
+		}
+	case state.EventPlayerJoined:
+		return utilities.WebhookEventPlayerJoined
+	case state.EventPlayerLeft:
+		return utilities.WebhookEventPlayerLeft
+	}
+	return ""
+}
+
+// newPayload はイベントからペイロードを作る
+func newPayload(id, event string, e state.Event, settings *utilities.Settings) payload {
+	p := payload{
+		Version: PayloadVersion,
+		ID:      id,
+		Event:   event,
+		Time:    e.Time.UTC(),
+		Server:  payloadServer{ID: e.Server, DisplayName: settings.RegisteredContainers[e.Server].DisplayName},
+	}
+	if e.Status != nil {
+		p.Status = &payloadStatus{
+			From:      e.Status.From,
+			To:        e.Status.To,
+			Players:   e.Status.Players,
+			ExitCode:  e.Status.ExitCode,
+			OOMKilled: e.Status.OOMKilled,
+		}
+	}
+	if e.Player != nil {
+		p.Player = &payloadPlayer{Name: e.Player.Name, Online: e.Player.Online}
+	}
+	return p
+}
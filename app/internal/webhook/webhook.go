@@ -0,0 +1,334 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxAttempts は1つの配信を送る回数の上限（初回を含む）
+	maxAttempts = 6
+
+	// initialBackoff / maxBackoff は再送までの待ち時間（失敗するたびに倍にする）
+	initialBackoff = 2 * time.Second
+	maxBackoff     = 5 * time.Minute
+
+	// requestTimeout は1回の送信の時間の上限
+	requestTimeout = 10 * time.Second
+
+	// queueSize は送信先ごとに送信待ちにできる配信の数（あふれた分は失敗として記録する）
+	queueSize = 100
+
+	// userAgent は送信時の User-Agent
+	userAgent = "mc-agent-webhook/1"
+)
+
+// 送信するヘッダー
+const (
+	HeaderEvent     = "X-MC-Agent-Event"
+	HeaderDelivery  = "X-MC-Agent-Delivery"
+	HeaderTimestamp = "X-MC-Agent-Timestamp"
+	HeaderSignature = "X-MC-Agent-Signature" // sha256=<hex>（secret を設定した場合のみ）
+)
+
+// errPermanent は再送しても成功しない失敗（4xx）
+var errPermanent = errors.New("permanent failure")
+
+// delivery は1つの送信先への1つのイベントの配信
+type delivery struct {
+	webhook string
+	event   string
+	payload payload
+}
+
+// deadLetter は送信できなかった配信の記録（webhook_dead_letter.jsonl の1行）
+type deadLetter struct {
+	Time     time.Time `json:"time"`
+	Webhook  string    `json:"webhook"`
+	URL      string    `json:"url"`
+	Delivery string    `json:"delivery"`
+	Event    string    `json:"event"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	Payload  payload   `json:"payload"`
+}
+
+// Dispatcher はイベントバスのイベントを設定された Webhook に送る
+// 送信先ごとに1つの goroutine で順番に送り、失敗した場合は待ち時間を倍にしながら再送する
+// 送信先と secret は送信のたびに設定から参照するため、再読み込みで即時に反映される
+type Dispatcher struct {
+	appState       *state.AppState
+	deadLetterPath string
+	client         *http.Client
+
+	queues   map[string]chan delivery // Run の goroutine からのみ参照する
+	workers  sync.WaitGroup
+	deadMu   sync.Mutex
+	classify *classifier
+}
+
+// NewDispatcher は新しい Dispatcher を作成
+// deadLetterPath には再送しても送れなかった配信を JSON Lines で追記する
+func NewDispatcher(appState *state.AppState, deadLetterPath string) *Dispatcher {
+	return &Dispatcher{
+		appState:       appState,
+		deadLetterPath: deadLetterPath,
+		client:         &http.Client{Timeout: requestTimeout},
+		queues:         make(map[string]chan delivery),
+		classify:       newClassifier(),
+	}
+}
+
+// Run はイベントバスを購読し、ctx が終了するまで Webhook に送り続ける
+// イベントは routine が StatusUpdate を送るのと同じ箇所で配信される（状態の変化・プレイヤーの参加と退出）
+func (d *Dispatcher) Run(ctx context.Context) {
+	sub, _ := d.appState.Events().Subscribe(0, 64)
+	defer sub.Close()
+
+	log.Info().Msg("Webhook dispatcher started")
+	defer func() {
+		d.workers.Wait()
+		log.Info().Msg("Webhook dispatcher stopped")
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-sub.C:
+			event := d.classify.classify(e)
+			if event == "" {
+				continue
+			}
+			d.dispatch(ctx, event, e)
+		}
+	}
+}
+
+// dispatch はイベントを対象の送信先のキューに入れる
+func (d *Dispatcher) dispatch(ctx context.Context, event string, e state.Event) {
+	settings := d.appState.GetSettings()
+	for name, hook := range settings.Webhooks {
+		if !hook.Wants(event, e.Server) {
+			continue
+		}
+
+		item := delivery{
+			webhook: name,
+			event:   event,
+			payload: newPayload(newDeliveryID(), event, e, settings),
+		}
+		select {
+		case d.queue(ctx, name) <- item:
+		default:
+			log.Error().Str("webhook", name).Str("event", event).Msg("Webhook queue is full, dropping delivery")
+			d.writeDeadLetter(item, hook.URL, 0, errors.New("queue is full"))
+		}
+	}
+}
+
+// queue は送信先のキューを返す（無ければ送信用の goroutine を起動する）
+func (d *Dispatcher) queue(ctx context.Context, name string) chan delivery {
+	if queue, ok := d.queues[name]; ok {
+		return queue
+	}
+	queue := make(chan delivery, queueSize)
+	d.queues[name] = queue
+	d.workers.Add(1)
+	go func() {
+		defer d.workers.Done()
+		d.worker(ctx, queue)
+	}()
+	return queue
+}
+
+// worker はキューの配信を順番に送る
+func (d *Dispatcher) worker(ctx context.Context, queue <-chan delivery) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item := <-queue:
+			d.deliver(ctx, item)
+		}
+	}
+}
+
+// deliver は配信を送り、失敗した場合は再送する（送れなかった場合は dead letter に記録）
+func (d *Dispatcher) deliver(ctx context.Context, item delivery) {
+	body, err := json.Marshal(item.payload)
+	if err != nil {
+		log.Error().Err(err).Str("webhook", item.webhook).Msg("Failed to encode webhook payload")
+		return
+	}
+
+	backoff := initialBackoff
+	var hook utilities.WebhookConfig
+	for attempt := 1; ; attempt++ {
+		// 再読み込みで削除・変更された場合に備えて毎回参照する
+		current, ok := d.appState.GetSettings().Webhooks[item.webhook]
+		if !ok {
+			log.Info().Str("webhook", item.webhook).Str("delivery", item.payload.ID).Msg("Webhook was removed, dropping delivery")
+			return
+		}
+		hook = current
+
+		retryAfter, err := d.send(ctx, hook, item, body)
+		if err == nil {
+			log.Debug().
+				Str("webhook", item.webhook).
+				Str("event", item.event).
+				Str("delivery", item.payload.ID).
+				Int("attempt", attempt).
+				Msg("Webhook delivered")
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		if errors.Is(err, errPermanent) || attempt >= maxAttempts {
+			log.Error().
+				Err(err).
+				Str("webhook", item.webhook).
+				Str("event", item.event).
+				Str("delivery", item.payload.ID).
+				Int("attempts", attempt).
+				Msg("Webhook delivery failed")
+			d.writeDeadLetter(item, hook.URL, attempt, err)
+			return
+		}
+
+		wait := max(backoff, retryAfter)
+		// 同時に失敗した配信が一斉に再送しないよう最大 20% ずらす
+		wait += time.Duration(mathrand.Int64N(int64(wait)/5 + 1))
+		log.Warn().
+			Err(err).
+			Str("webhook", item.webhook).
+			Str("delivery", item.payload.ID).
+			Int("attempt", attempt).
+			Dur("retry_in", wait).
+			Msg("Webhook delivery failed, retrying")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// send は1回送信する（429 / 5xx・通信エラーは再送、それ以外の 4xx は errPermanent）
+// Retry-After が指定された場合はその秒数を返す
+func (d *Dispatcher) send(ctx context.Context, hook utilities.WebhookConfig, item delivery, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errPermanent, err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderEvent, item.event)
+	req.Header.Set(HeaderDelivery, item.payload.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if hook.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+	}
+	if hook.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+hook.Token)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500:
+		var retryAfter time.Duration
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter = min(time.Duration(seconds)*time.Second, maxBackoff)
+		}
+		return retryAfter, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	default:
+		return 0, fmt.Errorf("%w: unexpected status %d", errPermanent, resp.StatusCode)
+	}
+}
+
+// Sign は HMAC-SHA256 の署名（"sha256=" + 16進数）を返す
+// 署名の対象は "<X-MC-Agent-Timestamp>.<ボディ>"（受信側はタイムスタンプが古すぎないかも確認する）
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// writeDeadLetter は送れなかった配信をファイルに追記する
+func (d *Dispatcher) writeDeadLetter(item delivery, url string, attempts int, cause error) {
+	record := deadLetter{
+		Time:     time.Now(),
+		Webhook:  item.webhook,
+		URL:      url,
+		Delivery: item.payload.ID,
+		Event:    item.event,
+		Attempts: attempts,
+		Error:    cause.Error(),
+		Payload:  item.payload,
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode webhook dead letter")
+		return
+	}
+
+	d.deadMu.Lock()
+	defer d.deadMu.Unlock()
+
+	file, err := os.OpenFile(d.deadLetterPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		log.Error().Err(err).Str("path", d.deadLetterPath).Msg("Failed to open webhook dead letter file")
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		log.Error().Err(err).Str("path", d.deadLetterPath).Msg("Failed to write webhook dead letter")
+	}
+}
+
+// newDeliveryID はランダムな配信 ID を返す
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
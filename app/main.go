@@ -5,31 +5,43 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
+	"github.com/Koranoa3/mc-server-agent/internal/api"
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/control"
+	"github.com/Koranoa3/mc-server-agent/internal/dashboard"
 	"github.com/Koranoa3/mc-server-agent/internal/discord"
 	"github.com/Koranoa3/mc-server-agent/internal/docker"
+	"github.com/Koranoa3/mc-server-agent/internal/monitoring"
+	"github.com/Koranoa3/mc-server-agent/internal/notifier"
+	"github.com/Koranoa3/mc-server-agent/internal/notifier/matrix"
+	"github.com/Koranoa3/mc-server-agent/internal/notifier/slack"
+	"github.com/Koranoa3/mc-server-agent/internal/notifier/telegram"
 	"github.com/Koranoa3/mc-server-agent/internal/routine"
 	"github.com/Koranoa3/mc-server-agent/internal/state"
 	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/Koranoa3/mc-server-agent/internal/webhook"
 	"github.com/joho/godotenv"
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
+	// サブコマンド（config validate 等）
+	if code, handled := runCLI(os.Args[1:]); handled {
+		os.Exit(code)
+	}
+
 	// .env ファイルを読み込み
 	if err := godotenv.Load(); err != nil {
 		log.Warn().Err(err).Msg("No .env file found, using environment variables")
 	}
 
 	// 設定ファイルの読み込み
-	settingsPath := os.Getenv("SETTINGS_PATH")
-	if settingsPath == "" {
-		settingsPath = "settings.json"
-	}
+	settingsPath := utilities.ResolveSettingsPath("")
 
-	settings, err := utilities.LoadSettings(settingsPath)
+	settings, sources, err := utilities.LoadEffectiveSettings(settingsPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load settings: %v\n", err)
 		os.Exit(1)
@@ -38,6 +50,15 @@ func main() {
 	// ロガー初期化
 	utilities.InitLogger(settings.LogLevel)
 	log.Info().Msg("Application starting")
+	monitoring.RecordSettingsLoad(nil)
+	warnServerPaths(settings)
+
+	// 設定ファイル以外から来た値を記録（値そのものは出さない）
+	for _, key := range sources.Keys() {
+		if source := sources[key]; source.IsOverride() {
+			log.Info().Str("key", key).Str("source", source.String()).Msg("Setting overridden")
+		}
+	}
 
 	// アプリケーション状態の初期化
 	appState := state.NewAppState(settings)
@@ -61,24 +82,35 @@ func main() {
 	commandChan := make(chan routine.Command, 10)
 	statusUpdateChan := make(chan routine.StatusUpdate, 10)
 	errorChan := make(chan error, 10)
+	auditChan := make(chan audit.Entry, 10)
+
+	// 監査ログの初期化
+	auditLog := audit.NewLogger(settings.AuditLogPath(), auditChan)
 
 	// 初期コンテナ情報取得
 	log.Info().Msg("Fetching initial container information")
-	if err := dockerManager.UpdateAllContainers(ctx); err != nil {
+	err = dockerManager.UpdateAllContainers(ctx)
+	monitoring.RecordRoutineTick(err)
+	if err != nil {
 		log.Error().Err(err).Msg("Failed to update containers")
 	} else {
 		containers := appState.GetAllContainers()
 		log.Info().Int("count", len(containers)).Msg("Containers loaded")
 	}
 
+	// Mojang のプロフィール解決（Discord Bot と HTTP API で共有）
+	profiles := utilities.NewProfileResolver(utilities.DataPath("profiles.json"), func() utilities.MojangConfig {
+		return appState.GetSettings().Mojang
+	})
+
 	// Discord Bot の初期化と起動
-	discordToken := os.Getenv("DISCORD_BOT_TOKEN")
-	discordGuildID := os.Getenv("DISCORD_GUILD_ID")
-	discordAppID := os.Getenv("DISCORD_APP_ID")
+	discordToken := settings.Discord.Token
+	discordGuildID := settings.Discord.GuildID
+	discordAppID := settings.Discord.AppID
 
 	var discordBot *discord.Bot
 	if discordToken != "" && discordGuildID != "" && discordAppID != "" {
-		discordBot, err = discord.NewBot(discordToken, discordGuildID, discordAppID, settings, appState, commandChan)
+		discordBot, err = discord.NewBot(discordToken, discordGuildID, discordAppID, appState, auditLog, profiles, commandChan)
 		if err != nil {
 			log.Fatal().Err(err).Msg("Failed to create Discord bot")
 		}
@@ -98,13 +130,128 @@ func main() {
 		log.Warn().Msg("Discord bot credentials not found, running without Discord integration")
 	}
 
+	// Slack・Matrix・Telegram の Bot の起動（それぞれ enabled の場合のみ）
+	var chatBots notifier.Group
+	if settings.Slack.Enabled {
+		chatBots = append(chatBots, slack.NewBot(appState, auditLog, commandChan))
+	}
+	if settings.Matrix.Enabled {
+		chatBots = append(chatBots, matrix.NewBot(appState, auditLog, commandChan))
+	}
+	if settings.Telegram.Enabled {
+		chatBots = append(chatBots, telegram.NewBot(appState, auditLog, commandChan))
+	}
+	for _, bot := range chatBots {
+		if err := bot.Start(ctx); err != nil {
+			log.Fatal().Err(err).Str("notifier", bot.Name()).Msg("Failed to start chat bot")
+		}
+		log.Info().Str("notifier", bot.Name()).Msg("Chat bot started")
+	}
+	defer chatBots.Stop()
+
+	// 状態表示の更新・監査ログ・アラートはすべての Notifier に送る
+	var notifiers notifier.Group
+	if discordBot != nil {
+		notifiers = append(notifiers, discordBot)
+	}
+	notifiers = append(notifiers, chatBots...)
+	go notifier.ForwardAlerts(ctx, appState, notifiers)
+
+	// HTTP API のハンドラー（制御用ソケットは api.enabled に関係なく同じハンドラーを使う）
+	apiServer := api.NewServer(appState, auditLog, profiles, commandChan)
+
+	// 制御用ソケットの起動（control.enabled の場合のみ、mc-agent status 等のサブコマンドやホスト側のスクリプトが接続する）
+	if settings.Control.Enabled {
+		controlServer := control.NewServer(settings.ControlSocketPath(), settings.ControlSocketMode(), settings.Control.SocketGroup, apiServer.LocalHandler(audit.SourceCLI))
+		if err := controlServer.Start(ctx); err != nil {
+			log.Fatal().Err(err).Msg("Failed to start control socket")
+		}
+
+		defer func() {
+			if err := controlServer.Stop(); err != nil {
+				log.Error().Err(err).Msg("Failed to stop control socket")
+			}
+		}()
+	}
+
+	// HTTP API の起動（api.enabled の場合のみ）
+	// 停止時にイベントのストリームを終了させるため、制御用ソケットより先に停止する
+	if settings.API.Enabled {
+		if err := apiServer.Start(ctx); err != nil {
+			log.Fatal().Err(err).Msg("Failed to start HTTP API")
+		}
+	}
+	defer func() {
+		if err := apiServer.Stop(); err != nil {
+			log.Error().Err(err).Msg("Failed to stop HTTP API")
+		}
+	}()
+
+	// Web ダッシュボードの起動（dashboard.enabled の場合のみ、ログインに Discord Bot を使う）
+	if settings.Dashboard.Enabled {
+		if discordBot == nil {
+			log.Error().Msg("Dashboard requires the Discord integration, not starting dashboard")
+		} else {
+			dashboardServer := dashboard.NewServer(appState, auditLog, discordBot.Membership, commandChan)
+			if err := dashboardServer.Start(ctx); err != nil {
+				log.Fatal().Err(err).Msg("Failed to start dashboard")
+			}
+
+			defer func() {
+				if err := dashboardServer.Stop(); err != nil {
+					log.Error().Err(err).Msg("Failed to stop dashboard")
+				}
+			}()
+		}
+	}
+
+	// Prometheus メトリクス（monitoring.enabled）と /healthz・/readyz（monitoring.health）の公開
+	if settings.Monitoring.Serving() {
+		if settings.Monitoring.Enabled {
+			monitoring.RegisterServerCollector(appState)
+			go monitoring.CountCommands(ctx, appState)
+		}
+
+		checks := monitoring.HealthChecks{DockerPing: dockerManager.Ping}
+		if discordBot != nil {
+			checks.DiscordConnected = discordBot.Connected
+		}
+		monitoringServer := monitoring.NewServer(settings.Monitoring, appState, checks)
+		if err := monitoringServer.Start(ctx); err != nil {
+			log.Fatal().Err(err).Msg("Failed to start monitoring server")
+		}
+
+		defer func() {
+			if err := monitoringServer.Stop(); err != nil {
+				log.Error().Err(err).Msg("Failed to stop monitoring server")
+			}
+		}()
+	}
+
+	// Webhook の送信（webhooks が空の間は何もしない、再読み込みで追加した送信先にも送る）
+	go webhook.NewDispatcher(appState, utilities.DataPath("webhook_dead_letter.jsonl")).Run(ctx)
+
 	// Routine goroutine の起動
 	go routine.Run(ctx, appState, dockerManager, statusUpdateChan, commandChan)
 
+	// 設定の再読み込み（ファイル変更検知 / SIGHUP / /mc-reload）
+	reloader := &settingsReloader{
+		path:       settingsPath,
+		appState:   appState,
+		auditLog:   auditLog,
+		discordBot: discordBot,
+	}
+
+	settingsChangedChan := make(chan struct{}, 1)
+	if err := utilities.WatchSettings(ctx, settingsPath, settingsChangedChan); err != nil {
+		log.Warn().Err(err).Msg("Failed to watch settings file, use SIGHUP or /mc-reload to reload")
+	}
+
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+
 	// メインループ
 	log.Info().Msg("Entering main event loop")
-	ticker := time.NewTicker(time.Duration(settings.RegularTask.Interval) * time.Second)
-	defer ticker.Stop()
 
 	for {
 		select {
@@ -117,45 +264,37 @@ func main() {
 			cancel()
 			return
 
+		case <-settingsChangedChan:
+			reloader.reload(routine.Command{Type: "reload", Source: audit.SourceSettingsFile})
+
+		case <-hupCh:
+			log.Info().Msg("Received SIGHUP")
+			reloader.reload(routine.Command{Type: "reload", Source: audit.SourceSignal})
+
 		case cmd := <-commandChan:
 			log.Info().
 				Str("type", cmd.Type).
 				Str("container", cmd.ContainerID).
 				Msg("Processing command")
 
-			switch cmd.Type {
-			case "start":
-				if err := dockerManager.StartContainer(ctx, cmd.ContainerID); err != nil {
-					log.Error().Err(err).Str("container", cmd.ContainerID).Msg("Failed to start container")
-					errorChan <- err
-				} else {
-					log.Info().Str("container", cmd.ContainerID).Msg("Container started")
-				}
+			// 設定の再読み込みは結果（差分）を返して終了
+			if cmd.Type == "reload" {
+				diff, err := reloader.reload(cmd)
+				replyCommand(cmd, routine.CommandResult{Message: strings.Join(diff, "\n"), Err: err})
+				continue
+			}
 
-			case "stop":
-				timeout := cmd.Timeout
-				if timeout == 0 {
-					timeout = 10
-				}
-				if err := dockerManager.StopContainer(ctx, cmd.ContainerID, timeout); err != nil {
-					log.Error().Err(err).Str("container", cmd.ContainerID).Msg("Failed to stop container")
-					errorChan <- err
-				} else {
-					log.Info().Str("container", cmd.ContainerID).Msg("Container stopped")
-				}
+			// 自動停止の判定後に保守ロックが取得された場合は停止しない
+			if _, locked := appState.Lock(cmd.ContainerID); locked && cmd.Source == audit.SourceAutoShutdown {
+				log.Info().Str("container", cmd.ContainerID).Msg("Skipping auto-shutdown, server is under maintenance")
+				continue
+			}
 
-			case "restart":
-				timeout := cmd.Timeout
-				if timeout == 0 {
-					timeout = 10
-				}
-				if err := dockerManager.RestartContainer(ctx, cmd.ContainerID, timeout); err != nil {
-					log.Error().Err(err).Str("container", cmd.ContainerID).Msg("Failed to restart container")
-					errorChan <- err
-				} else {
-					log.Info().Str("container", cmd.ContainerID).Msg("Container restarted")
-				}
+			cmdErr := executeCommand(ctx, appState, dockerManager, auditLog, cmd)
+			if cmdErr != nil {
+				errorChan <- cmdErr
 			}
+			replyCommand(cmd, routine.CommandResult{Err: cmdErr})
 
 		case update := <-statusUpdateChan:
 			log.Debug().
@@ -163,11 +302,12 @@ func main() {
 				Bool("changed", update.Changed).
 				Msg("Status update received")
 
-			// Discord Bot のプレゼンスと最後の常駐メッセージを更新
-			if discordBot != nil {
-				discordBot.UpdatePresence()
-				discordBot.UpdatePinnedMessages()
-			}
+			// Discord Bot のプレゼンス・常駐パネルとチャットの状態表示の更新を依頼（非同期）
+			notifiers.RequestUpdate()
+
+		case entry := <-auditChan:
+			// 監査チャンネル・チャットへ投稿
+			notifiers.PostAuditEntry(entry)
 
 		case err := <-errorChan:
 			log.Error().Err(err).Msg("Error received")
@@ -176,3 +316,97 @@ func main() {
 		}
 	}
 }
+
+// executeCommand は start / stop / restart を Docker で実行し、監査ログとイベントバスに記録する
+// エージェントのメインループと、エージェントを介さずに操作するサブコマンド（--direct）で共有する
+func executeCommand(ctx context.Context, appState *state.AppState, dockerManager *docker.Manager, auditLog *audit.Logger, cmd routine.Command) error {
+	appState.Events().Publish(commandEvent(cmd, state.EventCommandStarted, nil))
+
+	var cmdErr error
+	switch cmd.Type {
+	case "start":
+		if cmdErr = dockerManager.StartContainer(ctx, cmd.ContainerID); cmdErr != nil {
+			log.Error().Err(cmdErr).Str("container", cmd.ContainerID).Msg("Failed to start container")
+		} else {
+			log.Info().Str("container", cmd.ContainerID).Msg("Container started")
+		}
+
+	case "stop":
+		timeout := cmd.Timeout
+		if timeout == 0 {
+			timeout = 10
+		}
+		if cmdErr = dockerManager.StopContainer(ctx, cmd.ContainerID, timeout); cmdErr != nil {
+			log.Error().Err(cmdErr).Str("container", cmd.ContainerID).Msg("Failed to stop container")
+		} else {
+			log.Info().Str("container", cmd.ContainerID).Msg("Container stopped")
+		}
+
+	case "restart":
+		timeout := cmd.Timeout
+		if timeout == 0 {
+			timeout = 10
+		}
+		if cmdErr = dockerManager.RestartContainer(ctx, cmd.ContainerID, timeout); cmdErr != nil {
+			log.Error().Err(cmdErr).Str("container", cmd.ContainerID).Msg("Failed to restart container")
+		} else {
+			log.Info().Str("container", cmd.ContainerID).Msg("Container restarted")
+		}
+	}
+
+	// 監査ログに記録
+	auditLog.Record(commandAuditEntry(cmd, cmdErr))
+	if cmdErr != nil {
+		appState.Events().Publish(commandEvent(cmd, state.EventCommandFailed, cmdErr))
+	} else {
+		appState.Events().Publish(commandEvent(cmd, state.EventCommandCompleted, nil))
+	}
+	return cmdErr
+}
+
+// commandAuditEntry はコマンドの実行結果から監査ログエントリを作成
+func commandAuditEntry(cmd routine.Command, err error) audit.Entry {
+	entry := audit.Entry{
+		Action:   cmd.Type,
+		Server:   cmd.ContainerID,
+		UserID:   cmd.UserID,
+		UserName: cmd.UserName,
+		Source:   cmd.Source,
+		Outcome:  audit.OutcomeSuccess,
+	}
+	if err != nil {
+		entry.Outcome = audit.OutcomeFailure
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// commandEvent はコマンドの実行状況をイベントバスに配るイベントを作成
+func commandEvent(cmd routine.Command, eventType state.EventType, err error) state.Event {
+	event := state.Event{
+		Type:   eventType,
+		Server: cmd.ContainerID,
+		Command: &state.CommandEvent{
+			Action:   cmd.Type,
+			Source:   string(cmd.Source),
+			UserID:   cmd.UserID,
+			UserName: cmd.UserName,
+		},
+	}
+	if err != nil {
+		event.Command.Error = err.Error()
+	}
+	return event
+}
+
+// replyCommand はコマンドの発行元に結果を返す（返却先が無い・受信されない場合は破棄）
+func replyCommand(cmd routine.Command, result routine.CommandResult) {
+	if cmd.Reply == nil {
+		return
+	}
+	select {
+	case cmd.Reply <- result:
+	default:
+		log.Warn().Str("type", cmd.Type).Msg("Command reply dropped")
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/Koranoa3/mc-server-agent/internal/audit"
+	"github.com/Koranoa3/mc-server-agent/internal/discord"
+	"github.com/Koranoa3/mc-server-agent/internal/monitoring"
+	"github.com/Koranoa3/mc-server-agent/internal/routine"
+	"github.com/Koranoa3/mc-server-agent/internal/state"
+	"github.com/Koranoa3/mc-server-agent/internal/utilities"
+	"github.com/rs/zerolog/log"
+)
+
+// settingsReloader は settings.json を再読み込みして各モジュールに反映する
+type settingsReloader struct {
+	path       string
+	appState   *state.AppState
+	auditLog   *audit.Logger
+	discordBot *discord.Bot
+}
+
+// reload は設定ファイルを読み直し、検証に通れば AppState の設定を差し替える
+// 失敗した場合は現在の設定を維持する。戻り値は変更内容の一覧
+func (r *settingsReloader) reload(cmd routine.Command) ([]string, error) {
+	log.Info().Str("path", r.path).Str("source", string(cmd.Source)).Msg("Reloading settings")
+
+	entry := audit.Entry{
+		Action:   audit.ActionSettingsChange,
+		UserID:   cmd.UserID,
+		UserName: cmd.UserName,
+		Source:   cmd.Source,
+	}
+
+	// 環境変数の上書きも含めて読み直す（Validate 済み）
+	newSettings, _, err := utilities.LoadEffectiveSettings(r.path)
+	monitoring.RecordSettingsLoad(err)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to reload settings, keeping current settings")
+		entry.Outcome = audit.OutcomeFailure
+		entry.Error = err.Error()
+		r.auditLog.Record(entry)
+		return nil, err
+	}
+
+	warnServerPaths(newSettings)
+
+	oldSettings := r.appState.GetSettings()
+	diff := utilities.DiffSettings(oldSettings, newSettings)
+	if len(diff) == 0 {
+		log.Info().Msg("Settings unchanged")
+		return diff, nil
+	}
+
+	// 差し替え（以降の参照はすべて新しい設定になる）
+	r.appState.UpdateSettings(newSettings)
+
+	// 登録解除されたコンテナを state から削除
+	for key := range r.appState.GetAllContainers() {
+		if _, ok := newSettings.RegisteredContainers[key]; !ok {
+			r.appState.DeleteContainer(key)
+		}
+	}
+
+	// HTTP API の待ち受けは起動時に決まる（トークンと無効化は即時に反映される）
+	if (!oldSettings.API.Enabled && newSettings.API.Enabled) || oldSettings.API.Listen != newSettings.API.Listen {
+		log.Warn().Msg("Enabling the API or changing api.listen takes effect after restarting the agent")
+	}
+	// ダッシュボードの待ち受けも起動時に決まる（public_url・client_secret・public と無効化は即時に反映される）
+	if (!oldSettings.Dashboard.Enabled && newSettings.Dashboard.Enabled) || oldSettings.Dashboard.Listen != newSettings.Dashboard.Listen {
+		log.Warn().Msg("Enabling the dashboard or changing dashboard.listen takes effect after restarting the agent")
+	}
+	// Slack・Matrix・Telegram の接続と Matrix のルームは起動時に決まる（チャンネル・チャットと allowed_users は即時に反映される）
+	if oldSettings.Slack.Enabled != newSettings.Slack.Enabled || oldSettings.Slack.BotToken != newSettings.Slack.BotToken ||
+		oldSettings.Slack.AppToken != newSettings.Slack.AppToken || oldSettings.Slack.APIBaseURL != newSettings.Slack.APIBaseURL ||
+		oldSettings.Matrix.Enabled != newSettings.Matrix.Enabled || oldSettings.Matrix.HomeserverURL != newSettings.Matrix.HomeserverURL ||
+		oldSettings.Matrix.AccessToken != newSettings.Matrix.AccessToken || oldSettings.Matrix.RoomID != newSettings.Matrix.RoomID ||
+		oldSettings.Telegram.Enabled != newSettings.Telegram.Enabled || oldSettings.Telegram.Token != newSettings.Telegram.Token ||
+		oldSettings.Telegram.APIBaseURL != newSettings.Telegram.APIBaseURL {
+		log.Warn().Msg("Enabling chat bots or changing their tokens takes effect after restarting the agent")
+	}
+	// 制御用ソケットも起動時に作成する
+	if oldSettings.Control != newSettings.Control {
+		log.Warn().Msg("Changing control settings takes effect after restarting the agent")
+	}
+	// メトリクスの公開も起動時に決まる
+	if oldSettings.Monitoring != newSettings.Monitoring {
+		log.Warn().Msg("Changing monitoring settings takes effect after restarting the agent")
+	}
+
+	utilities.SetLogLevel(newSettings.LogLevel)
+	r.auditLog.SetPath(newSettings.AuditLogPath())
+
+	if r.discordBot != nil {
+		// Discord API の呼び出し（レート制限で待たされることがある）でメインループを止めないよう別 goroutine で行う
+		go func(bot *discord.Bot) {
+			if err := bot.SyncCommandsIfChanged(); err != nil {
+				log.Error().Err(err).Msg("Failed to sync Discord commands after reload")
+			}
+		}(r.discordBot)
+		r.discordBot.RequestUpdate()
+	}
+
+	for _, line := range diff {
+		log.Info().Str("change", line).Msg("Settings changed")
+	}
+
+	entry.Outcome = audit.OutcomeSuccess
+	entry.Detail = strings.Join(diff, "; ")
+	r.auditLog.Record(entry)
+
+	return diff, nil
+}
+
+// warnServerPaths は見つからないサーバーのディレクトリを警告する（起動・再読み込みは止めない）
+func warnServerPaths(settings *utilities.Settings) {
+	for _, e := range settings.CheckServerPaths() {
+		log.Warn().Str("key", e.Path).Str("detail", e.Message).Msg("Server directory is not accessible")
+	}
+}